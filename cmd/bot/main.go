@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"botGastosPareja/internal/bot"
 	"botGastosPareja/internal/config"
@@ -45,6 +46,10 @@ func main() {
 		log.Println("Bot commands registered with Telegram")
 	}
 
+	// Start background scheduler (recurring expenses); missed runs are caught up on startup
+	stopScheduler := make(chan struct{})
+	go handler.RunScheduler(stopScheduler, time.Hour)
+
 	// Set up update configuration
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
@@ -64,6 +69,7 @@ func main() {
 			handler.HandleUpdate(update)
 		case <-sigChan:
 			log.Println("Shutting down...")
+			close(stopScheduler)
 			return
 		}
 	}
@@ -3,6 +3,7 @@ package bot
 import (
 	"botGastosPareja/internal/service"
 	"botGastosPareja/pkg/utils"
+	"errors"
 	"fmt"
 	"sort"
 
@@ -25,6 +26,10 @@ func (h *Handler) handleAnalyze(handler *Handler, message *tgbotapi.Message, arg
 	}
 
 	result, err := handler.analysisService.AnalyzeMonthly(lobby.ID)
+	if errors.Is(err, service.ErrExchangeRateNotFound) && message.From != nil {
+		handler.sendTranslatedMessage(message.From.ID, message.Chat.ID, "rate_missing", err)
+		return
+	}
 	if err != nil {
 		handler.sendMessage(message.Chat.ID,
 			fmt.Sprintf("❌ Error analyzing spending: %v", err))
@@ -47,8 +52,8 @@ func (h *Handler) formatAnalysisResult(result *service.AnalysisResult) string {
 	msg += fmt.Sprintf("*Overall Spending:*\n"+
 		"Current: %s\n"+
 		"Previous: %s\n",
-		utils.FormatCurrency(result.CurrentTotal),
-		utils.FormatCurrency(result.PreviousTotal))
+		result.CurrentTotal.String(),
+		result.PreviousTotal.String())
 
 	if result.ChangePercent > 0 {
 		msg += fmt.Sprintf("📈 Increase: %.1f%%\n\n", result.ChangePercent)
@@ -64,7 +69,7 @@ func (h *Handler) formatAnalysisResult(result *service.AnalysisResult) string {
 		for _, spike := range result.SpendingSpikes {
 			msg += fmt.Sprintf("• %s: %s (+%.1f%%)\n",
 				spike.Category,
-				utils.FormatCurrency(spike.Amount),
+				spike.Amount.String(),
 				spike.ChangePercent)
 		}
 		msg += "\n"
@@ -108,11 +113,11 @@ func (h *Handler) formatAnalysisResult(result *service.AnalysisResult) string {
 			if count >= 5 {
 				break
 			}
-			if cc.change.PreviousTotal > 0 {
+			if cc.change.PreviousTotal.IsPositive() {
 				msg += fmt.Sprintf("• %s: %s → %s (%.1f%%)\n",
 					cc.name,
-					utils.FormatCurrency(cc.change.PreviousTotal),
-					utils.FormatCurrency(cc.change.CurrentTotal),
+					cc.change.PreviousTotal.String(),
+					cc.change.CurrentTotal.String(),
 					cc.change.ChangePercent)
 				count++
 			}
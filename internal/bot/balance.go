@@ -0,0 +1,233 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// balanceRecentPeriods is how many months /balance shows; /balance history shows every month
+const balanceRecentPeriods = 3
+
+// paymentHistoryLimit is how many payments /balance history lists
+const paymentHistoryLimit = 20
+
+// handlePaid handles the /paid command: records a transfer between members, or deletes one
+func (h *Handler) handlePaid(handler *Handler, message *tgbotapi.Message, args string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) < 1 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "paid_usage")
+		return
+	}
+
+	if strings.EqualFold(argsParts[0], "delete") {
+		if len(argsParts) < 2 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "paid_usage")
+			return
+		}
+		id, err := strconv.ParseInt(argsParts[1], 10, 64)
+		if err != nil || id <= 0 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "paid_not_found")
+			return
+		}
+		err = handler.settlementService.DeletePayment(lobby.ID, id)
+		if errors.Is(err, service.ErrSettlementPaymentNotFound) {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "paid_not_found")
+			return
+		}
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "paid_deleted", id)
+		return
+	}
+
+	amount, err := utils.ParseMoney(argsParts[0])
+	if err != nil || !amount.IsPositive() {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "paid_usage")
+		return
+	}
+
+	// Optional date, payer and payee (members: partner, user2, @username) and a free-text note, in any order
+	paymentDate := time.Now()
+	var memberArgs []string
+	dateSet := false
+	var note []string
+	for _, arg := range argsParts[1:] {
+		if !dateSet {
+			if date, ok := utils.ParseDateWord(arg, time.Now()); ok {
+				paymentDate = date
+				dateSet = true
+				continue
+			}
+		}
+		if len(memberArgs) < 2 && isSpenderArg(arg) {
+			memberArgs = append(memberArgs, arg)
+			continue
+		}
+		note = append(note, arg)
+	}
+
+	// One member is who paid the user (or whom the user paid, when it is the user); two are payer and payee
+	payerID, payeeID := userID, int64(0)
+	for i, arg := range memberArgs {
+		memberID, errKey := h.resolveSpender(lobby, userID, arg)
+		if errKey != "" {
+			if memberID = h.resolveFormerMember(lobby, arg); memberID == 0 {
+				handler.sendTranslatedMessage(userID, message.Chat.ID, errKey)
+				return
+			}
+		}
+		switch {
+		case i == 1:
+			payeeID = memberID
+		case len(memberArgs) == 2:
+			payerID = memberID
+		case memberID != userID:
+			payerID, payeeID = memberID, userID
+		}
+	}
+	if payeeID == 0 {
+		// In a couple the payee is the other member; with more members they have to be named
+		if len(lobby.Members) > 2 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "paid_payee_required")
+			return
+		}
+		if payeeID = lobby.Partner(payerID); payeeID == 0 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "waiting_partner")
+			return
+		}
+	}
+
+	payment, err := handler.settlementService.RecordPayment(lobby.ID, payerID, payeeID, amount, paymentDate, strings.Join(note, " "))
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "paid_error", err)
+		return
+	}
+
+	msg := translator.T("paid_recorded",
+		payment.ID,
+		h.getMemberName(lobby, payment.PayerTelegramID),
+		h.getMemberName(lobby, payment.PayeeTelegramID),
+		payment.Amount.String(),
+		utils.FormatDate(payment.PaymentDate))
+
+	balance, err := handler.settlementService.CalculateBalance(lobby.ID, time.Now())
+	if err == nil {
+		msg += h.formatBalanceLine(lobby, balance, translator)
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleBalance handles the /balance command: the running balance, or its full history with "history"
+func (h *Handler) handleBalance(handler *Handler, message *tgbotapi.Message, args string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	showHistory := len(argsParts) > 0 && (strings.EqualFold(argsParts[0], "history") || strings.EqualFold(argsParts[0], "historial"))
+
+	now := time.Now()
+	_, monthEnd := utils.GetMonthStartEnd(now.Year(), now.Month())
+	balance, err := handler.settlementService.CalculateBalance(lobby.ID, monthEnd)
+	if err != nil {
+		handler.sendConversionError(userID, message.Chat.ID, "balance_error", err)
+		return
+	}
+
+	msg := translator.T("balance_header")
+	msg += h.formatBalanceLine(lobby, balance, translator)
+
+	periods := balance.Periods
+	if !showHistory && len(periods) > balanceRecentPeriods {
+		periods = periods[len(periods)-balanceRecentPeriods:]
+	}
+	if len(periods) > 0 {
+		msg += translator.T("balance_periods_header")
+		for _, period := range periods {
+			msg += translator.T("balance_period_item", utils.FormatMonth(period.Start))
+			for _, member := range period.Members {
+				msg += translator.T("balance_period_member",
+					h.getMemberName(lobby, member.UserID),
+					member.Opening.String(),
+					member.Debt.String(),
+					member.Paid.String(),
+					member.Closing.String())
+			}
+		}
+	}
+
+	if showHistory {
+		payments, err := handler.settlementService.GetPayments(lobby.ID, nil, nil)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "balance_error", err)
+			return
+		}
+		if len(payments) == 0 {
+			msg += translator.T("balance_history_none")
+		} else {
+			msg += translator.T("balance_history_header")
+			if len(payments) > paymentHistoryLimit {
+				payments = payments[:paymentHistoryLimit]
+			}
+			for _, payment := range payments {
+				msg += translator.T("balance_history_item",
+					payment.ID,
+					utils.FormatDate(payment.PaymentDate),
+					h.getMemberName(lobby, payment.PayerTelegramID),
+					h.getMemberName(lobby, payment.PayeeTelegramID),
+					payment.Amount.String())
+				if payment.Note.Valid {
+					msg += translator.T("balance_history_note", payment.Note.String)
+				}
+			}
+		}
+	} else {
+		msg += translator.T("balance_history_hint")
+	}
+
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// formatBalanceLine describes the transfers that settle a running balance
+func (h *Handler) formatBalanceLine(lobby *database.Lobby, balance *service.BalanceResult, translator *i18n.Translator) string {
+	return h.formatTransfers(lobby, balance.Transfers, translator)
+}
+
+// formatTransfers describes who pays whom, or that everyone is settled
+func (h *Handler) formatTransfers(lobby *database.Lobby, transfers []service.Transfer, translator *i18n.Translator) string {
+	if len(transfers) == 0 {
+		return translator.T("balance_settled")
+	}
+	msg := ""
+	for _, transfer := range transfers {
+		msg += translator.T("balance_owes",
+			h.getMemberName(lobby, transfer.FromID),
+			h.getMemberName(lobby, transfer.ToID),
+			transfer.Amount.String())
+	}
+	return msg
+}
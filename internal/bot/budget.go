@@ -0,0 +1,290 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerBudgetCommands registers budget commands
+func (h *Handler) registerBudgetCommands() {
+	h.router.RegisterCommand("budget", h.handleBudget)
+}
+
+// handleBudget handles the /budget command
+func (h *Handler) handleBudget(handler *Handler, message *tgbotapi.Message, args string) {
+	if message.From == nil {
+		handler.sendMessage(message.Chat.ID, "❌ Error: This command must be used by a user.")
+		return
+	}
+	userID := message.From.ID
+
+	// Get user's lobby for this specific chat (group/private)
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) == 0 {
+		h.handleBudgetStatus(handler, message, lobby.ID, nil)
+		return
+	}
+
+	action := strings.ToLower(argsParts[0])
+	switch action {
+	case "status", "estado":
+		h.handleBudgetStatus(handler, message, lobby.ID, argsParts[1:])
+	case "delete", "remove", "borrar":
+		h.handleDeleteBudget(handler, message, lobby.ID, argsParts[1:])
+	case "alerts", "alertas", "thresholds":
+		h.handleBudgetThresholds(handler, message, lobby.ID, argsParts[1:])
+	default:
+		h.handleSetBudget(handler, message, lobby.ID, argsParts)
+	}
+}
+
+// handleSetBudget handles /budget <amount> [category]; without a category it sets the overall budget
+func (h *Handler) handleSetBudget(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	amount, err := utils.ParseMoney(args[0])
+	if err != nil || !amount.IsPositive() {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "budget_usage")
+		return
+	}
+
+	category := ""
+	if len(args) > 1 && !isOverallBudgetArg(strings.Join(args[1:], " ")) {
+		name, isNew, ok := handler.resolveCategoryArg(lobbyID, userID, message.Chat.ID, strings.Join(args[1:], " "))
+		if !ok {
+			return
+		}
+		if isNew {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "category_not_found", fmt.Errorf("%w: %s", service.ErrCategoryNotFound, name))
+			return
+		}
+		category = name
+	}
+
+	budget, err := handler.budgetService.SetBudget(lobbyID, category, amount)
+	if err != nil {
+		handler.sendBudgetError(userID, message.Chat.ID, err)
+		return
+	}
+
+	msg := translator.T("budget_set", budgetLabel(budget, translator), budget.Amount.String())
+	report, err := handler.budgetService.GetReport(lobbyID, time.Now())
+	if err == nil {
+		for _, status := range report.Budgets {
+			if status.Budget.ID == budget.ID {
+				msg += translator.T("budget_set_spent", status.Spent.String(), status.Percent)
+			}
+		}
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleDeleteBudget handles /budget delete [category]; without a category it deletes the overall budget
+func (h *Handler) handleDeleteBudget(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	category := strings.Join(args, " ")
+	if isOverallBudgetArg(category) {
+		category = ""
+	}
+
+	budget, err := handler.budgetService.DeleteBudget(lobbyID, category)
+	if err != nil {
+		handler.sendBudgetError(userID, message.Chat.ID, err)
+		return
+	}
+	handler.sendMessage(message.Chat.ID, translator.T("budget_deleted", budgetLabel(budget, translator)))
+}
+
+// handleBudgetThresholds handles /budget alerts [percent...]: shows or sets the warning thresholds
+func (h *Handler) handleBudgetThresholds(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+
+	if len(args) == 0 {
+		thresholds, err := handler.lobbyService.GetBudgetThresholds(lobbyID)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "budget_thresholds", formatThresholds(thresholds))
+		return
+	}
+
+	var thresholds []int
+	for _, arg := range args {
+		for _, part := range strings.Split(arg, ",") {
+			part = strings.TrimSuffix(strings.TrimSpace(part), "%")
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				handler.sendTranslatedMessage(userID, message.Chat.ID, "budget_thresholds_usage")
+				return
+			}
+			thresholds = append(thresholds, n)
+		}
+	}
+
+	if err := handler.lobbyService.SetBudgetThresholds(lobbyID, thresholds); err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "budget_error", err)
+		return
+	}
+	thresholds, _ = handler.lobbyService.GetBudgetThresholds(lobbyID)
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "budget_thresholds_set", formatThresholds(thresholds))
+}
+
+// handleBudgetStatus handles /budget status [YYYY-MM]: spent vs. limit of every budget
+func (h *Handler) handleBudgetStatus(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	month := time.Now()
+	if len(args) > 0 {
+		monthTime, err := utils.ParseMonth(args[0])
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_invalid_period")
+			return
+		}
+		month = monthTime
+	}
+
+	report, err := handler.budgetService.GetReport(lobbyID, month)
+	if err != nil {
+		handler.sendConversionError(userID, message.Chat.ID, "budget_error", err)
+		return
+	}
+	if len(report.Budgets) == 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "budget_none")
+		return
+	}
+
+	thresholds, err := handler.lobbyService.GetBudgetThresholds(lobbyID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	msg := translator.T("budget_status_header", utils.FormatMonth(report.Month))
+	if report.DaysLeft > 0 {
+		msg += translator.T("budget_status_days_left", report.DaysLeft)
+	}
+	msg += "\n"
+
+	for _, status := range report.Budgets {
+		msg += translator.T("budget_status_item",
+			budgetIcon(status.Percent, thresholds),
+			budgetLabel(status.Budget, translator),
+			status.Spent.String(),
+			status.Limit.String(),
+			status.Percent)
+		remaining := status.Remaining
+		if remaining.IsNegative() {
+			msg += translator.T("budget_status_over", remaining.Abs().String())
+		} else if report.DaysLeft > 0 {
+			msg += translator.T("budget_status_left_per_day", remaining.String(), remaining.Split(report.DaysLeft)[0].String())
+		} else {
+			msg += translator.T("budget_status_left", remaining.String())
+		}
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// checkBudgets checks a new expense against the lobby's budgets and posts a warning to the lobby
+// for every threshold it has just reached
+func (h *Handler) checkBudgets(expense *database.Expense) {
+	alerts, err := h.budgetService.CheckExpense(expense)
+	if err != nil {
+		log.Printf("Error checking budgets of lobby %d: %v", expense.LobbyID, err)
+		return
+	}
+
+	for _, alert := range alerts {
+		status := alert.Status
+		month := utils.FormatMonth(alert.Month)
+		overall := !status.Budget.CategoryID.Valid
+
+		switch {
+		case alert.Threshold >= 100 && overall:
+			h.notifyLobby(expense.LobbyID, "budget_alert_exceeded_total",
+				status.Spent.String(), status.Limit.String(), status.Remaining.Abs().String(), month, alert.DaysLeft)
+		case alert.Threshold >= 100:
+			h.notifyLobby(expense.LobbyID, "budget_alert_exceeded",
+				status.Budget.CategoryName, status.Spent.String(), status.Limit.String(), status.Remaining.Abs().String(), month, alert.DaysLeft)
+		case overall:
+			h.notifyLobby(expense.LobbyID, "budget_alert_total",
+				alert.Threshold, status.Spent.String(), status.Limit.String(), month, alert.DaysLeft)
+		default:
+			h.notifyLobby(expense.LobbyID, "budget_alert",
+				status.Budget.CategoryName, alert.Threshold, status.Spent.String(), status.Limit.String(), month, alert.DaysLeft)
+		}
+	}
+}
+
+// sendBudgetError reports a budget service error with a friendly message when it is a known one
+func (h *Handler) sendBudgetError(userID, chatID int64, err error) {
+	switch {
+	case errors.Is(err, service.ErrBudgetNotFound):
+		h.sendTranslatedMessage(userID, chatID, "budget_not_found")
+	case errors.Is(err, service.ErrCategoryNotFound):
+		h.sendTranslatedMessage(userID, chatID, "category_not_found", err)
+	default:
+		h.sendTranslatedMessage(userID, chatID, "budget_error", err)
+	}
+}
+
+// isOverallBudgetArg reports whether a category argument means the overall budget
+func isOverallBudgetArg(arg string) bool {
+	switch utils.FoldText(arg) {
+	case "", "total", "overall", "general", "todo":
+		return true
+	}
+	return false
+}
+
+// budgetLabel returns the category name of a budget, or the "overall" label for the lobby's budget
+func budgetLabel(budget *database.Budget, translator *i18n.Translator) string {
+	if !budget.CategoryID.Valid {
+		return translator.T("budget_overall")
+	}
+	return budget.CategoryName
+}
+
+// budgetIcon picks the status icon of a budget: fine, past the first warning threshold, or exceeded
+func budgetIcon(percent float64, thresholds []int) string {
+	switch {
+	case percent >= 100:
+		return "🔴"
+	case len(thresholds) > 0 && percent >= float64(thresholds[0]):
+		return "🟡"
+	default:
+		return "🟢"
+	}
+}
+
+// formatThresholds formats warning thresholds as "80%, 100%"
+func formatThresholds(thresholds []int) string {
+	parts := make([]string, len(thresholds))
+	for i, threshold := range thresholds {
+		parts[i] = fmt.Sprintf("%d%%", threshold)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,227 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"errors"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerCategoryCommands registers category commands
+func (h *Handler) registerCategoryCommands() {
+	h.router.RegisterCommand("categories", h.handleCategories)
+}
+
+// handleCategories handles the /categories command
+func (h *Handler) handleCategories(handler *Handler, message *tgbotapi.Message, args string) {
+	if message.From == nil {
+		handler.sendMessage(message.Chat.ID, "❌ Error: This command must be used by a user.")
+		return
+	}
+	userID := message.From.ID
+
+	// Get user's lobby for this specific chat (group/private)
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) == 0 {
+		h.handleListCategories(handler, message, lobby.ID)
+		return
+	}
+
+	action := strings.ToLower(argsParts[0])
+	rest := argsParts[1:]
+	switch action {
+	case "add", "agregar":
+		h.handleAddCategory(handler, message, lobby.ID, rest)
+	case "rename", "renombrar":
+		h.handleRenameCategory(handler, message, lobby.ID, rest)
+	case "merge", "unir":
+		h.handleMergeCategories(handler, message, lobby.ID, rest)
+	case "archive", "archivar":
+		h.handleArchiveCategory(handler, message, lobby.ID, rest, true)
+	case "unarchive", "desarchivar", "restore":
+		h.handleArchiveCategory(handler, message, lobby.ID, rest, false)
+	default:
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "category_usage")
+	}
+}
+
+// handleListCategories lists the lobby's categories with how many expenses each has
+func (h *Handler) handleListCategories(handler *Handler, message *tgbotapi.Message, lobbyID int64) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	categories, err := handler.categoryService.GetCategories(lobbyID, true)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	usage, err := handler.categoryService.GetCategoryUsage(lobbyID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	var active, archived []string
+	for _, category := range categories {
+		if category.IsArchived {
+			archived = append(archived, category.Name)
+			continue
+		}
+		active = append(active, translator.T("category_item", category.Name, usage[category.ID]))
+	}
+
+	if len(active) == 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "category_none")
+		return
+	}
+
+	msg := translator.T("category_list", strings.Join(active, "\n"))
+	if len(archived) > 0 {
+		msg += translator.T("category_archived_list", strings.Join(archived, ", "))
+	}
+	msg += translator.T("category_list_hint")
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleAddCategory handles /categories add <name>
+func (h *Handler) handleAddCategory(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	if len(args) == 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "category_usage")
+		return
+	}
+
+	category, err := handler.categoryService.CreateCategory(lobbyID, strings.Join(args, " "))
+	if err != nil {
+		handler.sendCategoryError(userID, message.Chat.ID, err)
+		return
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "category_added", category.Name)
+}
+
+// handleRenameCategory handles /categories rename <name> <new name>
+func (h *Handler) handleRenameCategory(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+
+	category, newName := handler.splitCategoryArgs(lobbyID, args)
+	if category == nil || newName == "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "category_rename_usage")
+		return
+	}
+
+	oldName := category.Name
+	category, err := handler.categoryService.RenameCategory(lobbyID, oldName, newName)
+	if err != nil {
+		handler.sendCategoryError(userID, message.Chat.ID, err)
+		return
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "category_renamed", oldName, category.Name)
+}
+
+// handleMergeCategories handles /categories merge <from> <into>
+func (h *Handler) handleMergeCategories(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+
+	source, into := handler.splitCategoryArgs(lobbyID, args)
+	if source == nil || into == "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "category_merge_usage")
+		return
+	}
+
+	moved, target, err := handler.categoryService.MergeCategories(lobbyID, source.Name, into)
+	if err != nil {
+		handler.sendCategoryError(userID, message.Chat.ID, err)
+		return
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "category_merged", source.Name, target.Name, moved)
+}
+
+// handleArchiveCategory handles /categories archive <name> and /categories unarchive <name>
+func (h *Handler) handleArchiveCategory(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string, archived bool) {
+	userID := message.From.ID
+	if len(args) == 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "category_usage")
+		return
+	}
+
+	category, err := handler.categoryService.SetArchived(lobbyID, strings.Join(args, " "), archived)
+	if err != nil {
+		handler.sendCategoryError(userID, message.Chat.ID, err)
+		return
+	}
+	if archived {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "category_archived", category.Name, category.Name)
+	} else {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "category_unarchived", category.Name)
+	}
+}
+
+// splitCategoryArgs splits "<existing category> <other name>" when names may contain spaces:
+// the longest leading run of words naming an existing category is the first name, the rest is the second
+func (h *Handler) splitCategoryArgs(lobbyID int64, args []string) (*database.Category, string) {
+	for i := len(args) - 1; i >= 1; i-- {
+		category, err := h.categoryService.GetCategoryByName(lobbyID, strings.Join(args[:i], " "))
+		if err == nil && category != nil {
+			return category, strings.Join(args[i:], " ")
+		}
+	}
+	return nil, ""
+}
+
+// sendCategoryError reports a category service error with a friendly message when it is a known one
+func (h *Handler) sendCategoryError(userID, chatID int64, err error) {
+	switch {
+	case errors.Is(err, service.ErrCategoryNotFound):
+		h.sendTranslatedMessage(userID, chatID, "category_not_found", err)
+	case errors.Is(err, service.ErrCategoryExists):
+		h.sendTranslatedMessage(userID, chatID, "category_exists", err)
+	default:
+		h.sendTranslatedMessage(userID, chatID, "category_error", err)
+	}
+}
+
+// resolveCategoryArg maps a category typed in a command to a lobby category.
+// It returns the category name to use and whether it is a new category.
+// When the name is unknown but close to existing ones, or names an archived category,
+// it tells the user and returns ok = false.
+func (h *Handler) resolveCategoryArg(lobbyID int64, userID int64, chatID int64, name string) (string, bool, bool) {
+	if name == "" {
+		return "", false, true
+	}
+
+	category, suggestions, err := h.categoryService.MatchCategory(lobbyID, name)
+	if err != nil {
+		h.sendTranslatedMessage(userID, chatID, "category_error", err)
+		return "", false, false
+	}
+	if category != nil {
+		if category.IsArchived {
+			h.sendTranslatedMessage(userID, chatID, "category_is_archived", category.Name, category.Name)
+			return "", false, false
+		}
+		return category.Name, false, true
+	}
+	if len(suggestions) > 0 {
+		h.sendTranslatedMessage(userID, chatID, "category_suggest", name, formatCategorySuggestions(suggestions), name)
+		return "", false, false
+	}
+	return name, true, true
+}
+
+// formatCategorySuggestions formats suggested category names as a bullet list
+func formatCategorySuggestions(names []string) string {
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("• %s", name)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,7 +1,9 @@
 package bot
 
 import (
-	"botGastosPareja/pkg/utils"
+	"botGastosPareja/internal/service"
+	"errors"
+	"log"
 	"strings"
 	"time"
 
@@ -21,12 +23,45 @@ func (h *Handler) registerCommands() {
 	// Payment method commands
 	h.registerPaymentMethodCommands()
 
+	// Category commands
+	h.registerCategoryCommands()
+
+	// Categorization rule commands
+	h.registerRuleCommands()
+
+	// Budget commands
+	h.registerBudgetCommands()
+
 	// Expense commands
 	h.registerExpenseCommands()
 
+	// Statement reconciliation commands
+	h.registerReconcileCommands()
+
+	// Free-text expense capture buttons
+	h.registerQuickCaptureCallbacks()
+
+	// Recurring expense commands
+	h.registerRecurringCommands()
+
+	// Exchange rate commands
+	h.registerRateCommands()
+
 	// Settlement commands
 	h.registerSettlementCommands()
 
+	// Shared account commands
+	h.registerDepositCommands()
+
+	// Lobby member commands
+	h.registerMemberCommands()
+
+	// Lobby switching commands
+	h.registerLobbyCommands()
+
+	// Income commands
+	h.registerIncomeCommands()
+
 	// Reporting commands
 	h.registerReportingCommands()
 
@@ -77,6 +112,36 @@ func (h *Handler) handleStart(handler *Handler, message *tgbotapi.Message, args
 	// Get translator with user's language preference
 	translator := handler.getTranslator(userID)
 
+	// Check if user wants to join an existing lobby; being in other lobbies does not prevent it
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) > 0 {
+		// Try to join lobby by invitation token (pass groupChatID for validation)
+		inviteToken := argsParts[0]
+		joined, err := handler.lobbyService.JoinLobbyByToken(inviteToken, userID, groupChatID)
+		if errors.Is(err, service.ErrJoinLocked) {
+			handler.sendJoinLocked(userID, message.Chat.ID)
+			return
+		}
+		if err != nil {
+			if key := joinErrorKey(err); key != "" {
+				handler.sendTranslatedMessage(userID, message.Chat.ID, key)
+				return
+			}
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_join", err)
+			return
+		}
+
+		// In private chat, commands act on the lobby just joined until the user switches with /use
+		if !isGroup && joined != nil {
+			if err := handler.lobbyService.SetActiveLobby(userID, joined.ID); err != nil {
+				log.Printf("Error setting active lobby for user %d: %v", userID, err)
+			}
+		}
+
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "lobby_joined_token")
+		return
+	}
+
 	// Check if user is already in a lobby FOR THIS GROUP (or private)
 	lobby, err := handler.lobbyService.GetLobbyByUserIDAndGroup(userID, groupChatID)
 	if err != nil {
@@ -86,17 +151,7 @@ func (h *Handler) handleStart(handler *Handler, message *tgbotapi.Message, args
 
 	if lobby != nil {
 		// User is already in a lobby for this group/private chat
-		var partnerInfo string
-		if lobby.User1TelegramID == userID {
-			if lobby.User2TelegramID == 0 {
-				partnerInfo = translator.T("waiting_partner")
-			} else {
-				partnerInfo = translator.T("partner_id", lobby.User2TelegramID)
-			}
-		} else {
-			partnerInfo = translator.T("partner_id", lobby.User1TelegramID)
-		}
-
+		partnerInfo := handler.formatPartnerInfo(lobby, userID, translator)
 		welcomeMsg := translator.T("welcome_back", displayName, lobby.ID, lobby.AccountType, partnerInfo)
 		handler.sendMessage(message.Chat.ID, welcomeMsg)
 		return
@@ -109,29 +164,29 @@ func (h *Handler) handleStart(handler *Handler, message *tgbotapi.Message, args
 		if err == nil && existingLobby != nil {
 			// There's already a lobby for this group
 			// If it has space and user is not already in it, join automatically
-			if existingLobby.User2TelegramID == 0 && existingLobby.User1TelegramID != userID {
+			if !existingLobby.IsMember(userID) && !existingLobby.IsFull() {
 				// Join the existing lobby
 				err = handler.lobbyService.JoinLobbyDirectly(existingLobby.ID, userID)
 				if err == nil {
-					// Successfully joined - lobby is now complete with both users
-					partnerInfo := translator.T("partner_id", existingLobby.User1TelegramID)
+					// Successfully joined - show who else is already in the lobby
+					joined, err := handler.lobbyService.GetLobbyByID(existingLobby.ID)
+					if err == nil && joined != nil {
+						existingLobby = joined
+					}
+					partnerInfo := handler.formatPartnerInfo(existingLobby, userID, translator)
 					welcomeMsg := translator.T("lobby_ready_group", displayName, existingLobby.ID, existingLobby.AccountType, partnerInfo)
 					handler.sendMessage(message.Chat.ID, welcomeMsg)
 					return
 				}
+				// Removed members wait for an invitation instead of getting a lobby of their own
+				if errors.Is(err, service.ErrMemberRemoved) {
+					handler.sendTranslatedMessage(userID, message.Chat.ID, "error_member_removed")
+					return
+				}
 				// If join failed, log and continue to create new lobby
-			} else if existingLobby.User1TelegramID == userID || existingLobby.User2TelegramID == userID {
+			} else if existingLobby.IsMember(userID) {
 				// User is already in this lobby
-				var partnerInfo string
-				if existingLobby.User1TelegramID == userID {
-					if existingLobby.User2TelegramID == 0 {
-						partnerInfo = translator.T("waiting_partner")
-					} else {
-						partnerInfo = translator.T("partner_id", existingLobby.User2TelegramID)
-					}
-				} else {
-					partnerInfo = translator.T("partner_id", existingLobby.User1TelegramID)
-				}
+				partnerInfo := handler.formatPartnerInfo(existingLobby, userID, translator)
 				welcomeMsg := translator.T("welcome_back", displayName, existingLobby.ID, existingLobby.AccountType, partnerInfo)
 				handler.sendMessage(message.Chat.ID, welcomeMsg)
 				return
@@ -140,21 +195,6 @@ func (h *Handler) handleStart(handler *Handler, message *tgbotapi.Message, args
 		}
 	}
 
-	// Check if user wants to join an existing lobby
-	argsParts := parseCommandArgs(args)
-	if len(argsParts) > 0 {
-		// Try to join lobby by invitation token (pass groupChatID for validation)
-		inviteToken := argsParts[0]
-		err := handler.lobbyService.JoinLobbyByToken(inviteToken, userID, groupChatID)
-		if err != nil {
-			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_join", err)
-			return
-		}
-
-		handler.sendTranslatedMessage(userID, message.Chat.ID, "lobby_joined_token")
-		return
-	}
-
 	// For new users, prompt language selection first (only in private chats)
 	if isNewUser && !isGroup {
 		handler.promptLanguageSelection(userID, message.Chat.ID)
@@ -176,13 +216,7 @@ func (h *Handler) handleStart(handler *Handler, message *tgbotapi.Message, args
 		handler.sendMessage(message.Chat.ID, welcomeMsg)
 	} else {
 		// In private chats, use token-based invitation
-		formattedToken := utils.FormatInviteToken(newLobby.InviteToken.String)
-		welcomeMsg := translator.T("lobby_created", displayName, newLobby.ID, newLobby.AccountType, formattedToken, formattedToken)
-		handler.sendMessage(message.Chat.ID, welcomeMsg)
-
-		// Send security instructions (token appears twice in the message)
-		securityMsg := translator.T("lobby_security_info", formattedToken, formattedToken)
-		handler.sendMessage(message.Chat.ID, securityMsg)
+		handler.sendLobbyCreated(userID, message.Chat.ID, displayName, newLobby)
 	}
 }
 
@@ -0,0 +1,207 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// depositHistoryLimit is how many deposits /deposit history lists
+const depositHistoryLimit = 20
+
+// registerDepositCommands registers shared account commands
+func (h *Handler) registerDepositCommands() {
+	h.router.RegisterCommand("deposit", h.handleDeposit)
+}
+
+// handleDeposit handles the /deposit command: records money put into the shared account, lists or
+// deletes deposits, or compares the deposits of a month with each member's share
+func (h *Handler) handleDeposit(handler *Handler, message *tgbotapi.Message, args string) {
+	if message.From == nil {
+		handler.sendMessage(message.Chat.ID, "❌ Error: This command must be used by a user.")
+		return
+	}
+	userID := message.From.ID
+
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+	if lobby.AccountType != "shared" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "deposit_not_shared")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) == 0 {
+		h.sendSharedAccount(userID, message.Chat.ID, lobby, time.Now(), "")
+		return
+	}
+
+	switch strings.ToLower(argsParts[0]) {
+	case "history", "historial":
+		h.handleDepositHistory(handler, message, lobby)
+	case "delete", "borrar":
+		h.handleDeleteDeposit(handler, message, lobby, argsParts[1:])
+	default:
+		if month, err := utils.ParseMonth(argsParts[0]); err == nil && len(argsParts) == 1 {
+			h.sendSharedAccount(userID, message.Chat.ID, lobby, month, "")
+			return
+		}
+		h.handleRecordDeposit(handler, message, lobby, argsParts)
+	}
+}
+
+// handleRecordDeposit handles /deposit <amount> [date] [member] [note]
+func (h *Handler) handleRecordDeposit(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	amount, err := utils.ParseMoney(args[0])
+	if err != nil || !amount.IsPositive() {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "deposit_usage")
+		return
+	}
+
+	// Optional date, depositor (a member: partner, user2, @username) and a free-text note, in any order
+	depositDate := time.Now()
+	depositorArg := ""
+	dateSet := false
+	var note []string
+	for _, arg := range args[1:] {
+		if !dateSet {
+			if date, ok := utils.ParseDateWord(arg, time.Now()); ok {
+				depositDate = date
+				dateSet = true
+				continue
+			}
+		}
+		if depositorArg == "" && isSpenderArg(arg) {
+			depositorArg = arg
+			continue
+		}
+		note = append(note, arg)
+	}
+
+	depositorID, errKey := h.resolveSpender(lobby, userID, depositorArg)
+	if errKey != "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, errKey)
+		return
+	}
+
+	contribution, err := handler.settlementService.RecordContribution(lobby.ID, depositorID, amount, depositDate, strings.Join(note, " "))
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "deposit_error", err)
+		return
+	}
+
+	msg := translator.T("deposit_recorded",
+		contribution.ID,
+		h.getMemberName(lobby, contribution.UserTelegramID),
+		contribution.Amount.String(),
+		utils.FormatDate(contribution.ContributionDate))
+	h.sendSharedAccount(userID, message.Chat.ID, lobby, contribution.ContributionDate, msg)
+}
+
+// handleDeleteDeposit handles /deposit delete <id>
+func (h *Handler) handleDeleteDeposit(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+
+	if len(args) < 1 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "deposit_usage")
+		return
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "deposit_not_found")
+		return
+	}
+
+	err = handler.settlementService.DeleteContribution(lobby.ID, id)
+	if errors.Is(err, service.ErrContributionNotFound) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "deposit_not_found")
+		return
+	}
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "deposit_deleted", id)
+}
+
+// handleDepositHistory handles /deposit history
+func (h *Handler) handleDepositHistory(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	contributions, err := handler.settlementService.GetContributions(lobby.ID, nil, nil)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	if len(contributions) == 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "deposit_history_none")
+		return
+	}
+
+	if len(contributions) > depositHistoryLimit {
+		contributions = contributions[:depositHistoryLimit]
+	}
+	msg := translator.T("deposit_history_header")
+	for _, contribution := range contributions {
+		msg += translator.T("deposit_history_item",
+			contribution.ID,
+			utils.FormatDate(contribution.ContributionDate),
+			h.getMemberName(lobby, contribution.UserTelegramID),
+			contribution.Amount.String())
+		if contribution.Note.Valid {
+			msg += translator.T("deposit_history_note", contribution.Note.String)
+		}
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// sendSharedAccount sends the shared account status for the month of a date, after an optional prefix
+func (h *Handler) sendSharedAccount(userID, chatID int64, lobby *database.Lobby, month time.Time, prefix string) {
+	translator := h.getTranslator(userID)
+
+	start, end := utils.GetMonthStartEnd(month.Year(), month.Month())
+	account, err := h.settlementService.CalculateSharedAccount(lobby.ID, start, end)
+	if err != nil {
+		h.sendConversionError(userID, chatID, "shared_account_error", err)
+		return
+	}
+	h.sendMessage(chatID, prefix+h.formatSharedAccount(lobby, account, translator))
+}
+
+// formatSharedAccount describes the deposits of each member against their share of what the shared
+// account paid, and the account balance
+func (h *Handler) formatSharedAccount(lobby *database.Lobby, account *service.SharedAccountResult, translator *i18n.Translator) string {
+	msg := translator.T("shared_account_header", utils.FormatDate(account.PeriodStart), utils.FormatDate(account.PeriodEnd))
+	msg += translator.T("shared_account_spent", account.Spent.String())
+	for _, member := range account.Members {
+		msg += translator.T("shared_account_member", h.getMemberName(lobby, member.UserID), member.Deposited.String(), member.Share.String())
+	}
+
+	short := false
+	for _, member := range account.Members {
+		if difference := member.Difference; difference.IsNegative() {
+			msg += translator.T("shared_account_short", h.getMemberName(lobby, member.UserID), difference.Neg().String())
+			short = true
+		}
+	}
+	if !short {
+		msg += translator.T("shared_account_even")
+	}
+
+	msg += translator.T("shared_account_balance", account.Opening.String(), account.Closing.String())
+	return msg
+}
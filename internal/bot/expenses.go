@@ -2,8 +2,13 @@ package bot
 
 import (
 	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
 	"botGastosPareja/pkg/utils"
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -32,19 +37,31 @@ func (h *Handler) handleAddExpense(handler *Handler, message *tgbotapi.Message,
 		return
 	}
 
-	argsParts := parseCommandArgs(args)
+	// A trailing "confirm" adds the expense even to a reconciled statement
+	argsParts, force := stripConfirm(parseCommandArgs(args))
 	if len(argsParts) < 2 {
 		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_add_usage")
 		return
 	}
 
-	// Parse amount
-	amount, err := strconv.ParseFloat(argsParts[0], 64)
-	if err != nil || amount <= 0 {
+	// Parse amount, optionally with a currency ("50usd", "USD50")
+	amount, err := utils.ParseMoney(argsParts[0])
+	if err != nil || !amount.IsPositive() {
 		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_invalid_amount")
 		return
 	}
 
+	// Pull option tokens (installments, interest, currency) out before positional parsing
+	rest, opts, errKey := extractAddOptions(argsParts[2:])
+	if errKey != "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, errKey)
+		return
+	}
+	argsParts = append(argsParts[:2], rest...)
+	if opts.Currency != "" {
+		amount = amount.WithCurrency(opts.Currency)
+	}
+
 	description := argsParts[1]
 	category := ""
 	paymentMethodName := ""
@@ -53,21 +70,18 @@ func (h *Handler) handleAddExpense(handler *Handler, message *tgbotapi.Message,
 	// Parse optional arguments in order: [category] [payment_method] [spender]
 	// Spender is always the last optional argument
 	argIndex := 2
-	if len(argsParts) > argIndex {
-		// Check if last argument is a spender identifier
-		lastArg := strings.ToLower(argsParts[len(argsParts)-1])
-		if lastArg == "user1" || lastArg == "user2" || lastArg == "partner" || lastArg == "pareja" {
-			spenderArg = argsParts[len(argsParts)-1]
-			// Remove spender from argsParts for category/payment method parsing
-			argsParts = argsParts[:len(argsParts)-1]
-		} else if _, err := strconv.ParseInt(argsParts[len(argsParts)-1], 10, 64); err == nil && len(argsParts[len(argsParts)-1]) > 3 {
-			// If last arg is a long number (likely Telegram ID), treat as spender
-			spenderArg = argsParts[len(argsParts)-1]
-			argsParts = argsParts[:len(argsParts)-1]
-		}
+	if len(argsParts) > argIndex && isSpenderArg(argsParts[len(argsParts)-1]) {
+		spenderArg = argsParts[len(argsParts)-1]
+		// Remove spender from argsParts for category/payment method parsing
+		argsParts = argsParts[:len(argsParts)-1]
 	}
 
-	// Parse category and payment method from remaining args
+	// Parse category and payment method from remaining args. A lone argument naming a payment method
+	// (and no category) is the payment method: "/add 500 Nafta Visa"
+	if len(argsParts) == argIndex+1 && handler.isPaymentMethodArg(lobby.ID, argsParts[argIndex]) {
+		paymentMethodName = argsParts[argIndex]
+		argIndex++
+	}
 	if len(argsParts) > argIndex {
 		category = argsParts[argIndex]
 		argIndex++
@@ -77,29 +91,16 @@ func (h *Handler) handleAddExpense(handler *Handler, message *tgbotapi.Message,
 	}
 
 	// Determine spender ID
-	spenderID := userID // Default to the user adding the expense
-	if spenderArg != "" {
-		spenderArgLower := strings.ToLower(spenderArg)
-		if spenderArgLower == "user2" || spenderArgLower == "partner" || spenderArgLower == "pareja" {
-			// Add expense for the other user (user2)
-			if lobby.User2TelegramID != 0 {
-				spenderID = lobby.User2TelegramID
-			} else {
-				handler.sendTranslatedMessage(userID, message.Chat.ID, "waiting_partner")
-				return
-			}
-		} else if spenderArgLower == "user1" {
-			// Explicitly add for user1
-			spenderID = lobby.User1TelegramID
-		} else if parsedID, err := strconv.ParseInt(spenderArg, 10, 64); err == nil {
-			// Check if it's a valid user ID in the lobby
-			if parsedID == lobby.User1TelegramID || parsedID == lobby.User2TelegramID {
-				spenderID = parsedID
-			} else {
-				handler.sendTranslatedMessage(userID, message.Chat.ID, "error_invalid_user_id")
-				return
-			}
-		}
+	spenderID, errKey := handler.resolveSpender(lobby, userID, spenderArg)
+	if errKey != "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, errKey)
+		return
+	}
+
+	// Match the category against the lobby's ones ("super" -> "Supermercado")
+	category, newCategory, ok := handler.resolveCategoryArg(lobby.ID, userID, message.Chat.ID, category)
+	if !ok {
+		return
 	}
 
 	var paymentMethodID *int64
@@ -133,10 +134,59 @@ func (h *Handler) handleAddExpense(handler *Handler, message *tgbotapi.Message,
 			} else {
 				handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_not_found", paymentMethodName)
 			}
+			// Don't add it with the spender's default method instead of the one they typed
+			return
 		}
 	}
 
 	expenseDate := time.Now()
+
+	if opts.Installments > 1 {
+		// Installments need a card; the spender's default one applies when none was given
+		var appliedDefault *database.PaymentMethodDefault
+		if paymentMethodID == nil {
+			appliedDefault = handler.defaultPaymentMethodFor(lobby.ID, spenderID, category)
+			if appliedDefault != nil {
+				paymentMethodID = &appliedDefault.PaymentMethodID
+			}
+		}
+		if paymentMethodID == nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_installments_need_card")
+			return
+		}
+		installments, err := handler.expenseService.CreateInstallmentExpense(
+			lobby.ID,
+			spenderID,
+			amount,
+			opts.Installments,
+			opts.InterestPct,
+			description,
+			category,
+			expenseDate,
+			*paymentMethodID,
+			opts.Split,
+			force,
+		)
+		if errors.Is(err, service.ErrStatementReconciled) {
+			handler.askReconciledConfirm(userID, message.Chat.ID, nil, "/add "+args)
+			return
+		}
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_add_error", err)
+			return
+		}
+		msg := h.formatInstallmentPurchase(installments, opts, translator)
+		if newCategory {
+			msg += translator.T("category_created_note", installments[0].Category.String)
+		}
+		if appliedDefault != nil {
+			msg += formatDefaultApplied(appliedDefault, translator)
+		}
+		msg += h.creditWarning(*paymentMethodID, translator)
+		handler.sendMessage(message.Chat.ID, msg)
+		return
+	}
+
 	expense, err := handler.expenseService.CreateExpense(
 		lobby.ID,
 		spenderID, // Use the determined spender ID
@@ -145,14 +195,38 @@ func (h *Handler) handleAddExpense(handler *Handler, message *tgbotapi.Message,
 		category,
 		expenseDate,
 		paymentMethodID,
+		opts.Split,
+		force,
 	)
+	if errors.Is(err, service.ErrStatementReconciled) {
+		handler.askReconciledConfirm(userID, message.Chat.ID, nil, "/add "+args)
+		return
+	}
 	if err != nil {
 		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_add_error", err)
 		return
 	}
 
+	msg := h.formatExpenseAdded(expense, translator)
+	if newCategory {
+		msg += translator.T("category_created_note", expense.Category.String)
+	}
+	if paymentMethodID == nil && expense.PaymentMethodID.Valid {
+		def, err := handler.paymentMethodService.DefaultPaymentMethod(lobby.ID, spenderID, expense.CategoryID)
+		if err == nil && def != nil && def.PaymentMethodID == expense.PaymentMethodID.Int64 {
+			msg += formatDefaultApplied(def, translator)
+		}
+	}
+	if expense.PaymentMethodID.Valid {
+		msg += h.creditWarning(expense.PaymentMethodID.Int64, translator)
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// formatExpenseAdded formats the confirmation for a newly added expense
+func (h *Handler) formatExpenseAdded(expense *database.Expense, translator *i18n.Translator) string {
 	msg := translator.T("expense_added",
-		utils.FormatCurrency(expense.Amount),
+		expense.Amount.String(),
 		expense.Description.String)
 	msg += fmt.Sprintf("ID: %d\n", expense.ID)
 
@@ -160,18 +234,21 @@ func (h *Handler) handleAddExpense(handler *Handler, message *tgbotapi.Message,
 		msg += translator.T("expense_category", expense.Category.String)
 	}
 	if expense.PaymentMethodID.Valid {
-		pm, _ := handler.paymentMethodService.GetPaymentMethodByID(expense.PaymentMethodID.Int64)
+		pm, _ := h.paymentMethodService.GetPaymentMethodByID(expense.PaymentMethodID.Int64)
 		if pm != nil {
 			msg += translator.T("expense_payment_method", pm.Name)
 		}
 	}
+	if !expense.IsShared() {
+		msg += translator.T("expense_split", formatSplitLabel(expense, translator))
+	}
 	if expense.BillingPeriodStart.Valid {
 		msg += translator.T("expense_billing_period",
 			utils.FormatDate(expense.BillingPeriodStart.Time),
 			utils.FormatDate(expense.BillingPeriodEnd.Time))
 	}
 
-	handler.sendMessage(message.Chat.ID, msg)
+	return msg
 }
 
 // handleListExpenses handles the /list command
@@ -220,51 +297,19 @@ func (h *Handler) handleListExpenses(handler *Handler, message *tgbotapi.Message
 		return
 	}
 
-	// Get user names for display
-	user1, _ := handler.userService.GetUserByTelegramID(lobby.User1TelegramID)
-	user2, _ := handler.userService.GetUserByTelegramID(lobby.User2TelegramID)
-
-	var total float64
+	totals := currencyTotals(expenses)
 	msg := translator.T("expense_list_header", len(expenses))
 	for _, exp := range expenses {
-		total += exp.Amount
 		desc := exp.Description.String
 		if !exp.Description.Valid {
 			desc = translator.T("expense_no_description")
 		}
 
 		// Get the spender's name
-		var userLabel string
-		if exp.SpenderTelegramID == lobby.User1TelegramID {
-			if user1 != nil && user1.DisplayName.Valid && user1.DisplayName.String != "" {
-				userLabel = user1.DisplayName.String
-			} else if user1 != nil && user1.Username.Valid && user1.Username.String != "" {
-				userLabel = "@" + user1.Username.String
-			} else {
-				userLabel = "User 1"
-			}
-		} else if exp.SpenderTelegramID == lobby.User2TelegramID {
-			if user2 != nil && user2.DisplayName.Valid && user2.DisplayName.String != "" {
-				userLabel = user2.DisplayName.String
-			} else if user2 != nil && user2.Username.Valid && user2.Username.String != "" {
-				userLabel = "@" + user2.Username.String
-			} else {
-				userLabel = "User 2"
-			}
-		} else {
-			// Try to get the user
-			spender, _ := handler.userService.GetUserByTelegramID(exp.SpenderTelegramID)
-			if spender != nil && spender.DisplayName.Valid && spender.DisplayName.String != "" {
-				userLabel = spender.DisplayName.String
-			} else if spender != nil && spender.Username.Valid && spender.Username.String != "" {
-				userLabel = "@" + spender.Username.String
-			} else {
-				userLabel = fmt.Sprintf("User %d", exp.SpenderTelegramID)
-			}
-		}
+		userLabel := handler.getMemberName(lobby, exp.SpenderTelegramID)
 
 		msg += fmt.Sprintf("[ID: %d] ", exp.ID)
-		msg += translator.T("expense_list_item", utils.FormatCurrency(exp.Amount), desc)
+		msg += translator.T("expense_list_item", exp.Amount.String(), desc+installmentLabel(exp, translator))
 		msg += fmt.Sprintf("  Added by: %s\n", userLabel)
 		if exp.Category.Valid {
 			msg += translator.T("expense_list_category", exp.Category.String)
@@ -278,7 +323,7 @@ func (h *Handler) handleListExpenses(handler *Handler, message *tgbotapi.Message
 		msg += translator.T("expense_list_date", utils.FormatDate(exp.ExpenseDate))
 	}
 
-	msg += translator.T("expense_list_total", utils.FormatCurrency(total))
+	msg += translator.T("expense_list_total", formatCurrencyTotals(totals))
 	handler.sendMessage(message.Chat.ID, msg)
 }
 
@@ -326,19 +371,19 @@ func (h *Handler) handleListBillingExpenses(handler *Handler, message *tgbotapi.
 	}
 
 	// Parse period or use current
-	var periodStart, periodEnd time.Time
+	month := time.Now()
 	if len(argsParts) >= 2 {
 		monthTime, err := utils.ParseMonth(argsParts[1])
 		if err != nil {
 			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_invalid_period")
 			return
 		}
-		periodStart, periodEnd = utils.GetBillingPeriodForMonth(
-			monthTime.Year(), monthTime.Month(), int(paymentMethod.ClosingDay.Int64))
-	} else {
-		now := time.Now()
-		periodStart, periodEnd = utils.GetBillingPeriodForMonth(
-			now.Year(), now.Month(), int(paymentMethod.ClosingDay.Int64))
+		month = monthTime
+	}
+	periodStart, periodEnd, err := handler.paymentMethodService.BillingPeriodForMonth(paymentMethod, month)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
 	}
 
 	expenses, err := handler.expenseService.GetExpensesByBillingPeriod(
@@ -354,26 +399,29 @@ func (h *Handler) handleListBillingExpenses(handler *Handler, message *tgbotapi.
 		return
 	}
 
-	var total float64
+	totals := currencyTotals(expenses)
 	msg := translator.T("expense_billing_header",
 		paymentMethod.Name,
 		utils.FormatDate(periodStart),
 		utils.FormatDate(periodEnd))
 
 	for _, exp := range expenses {
-		total += exp.Amount
 		desc := exp.Description.String
 		if !exp.Description.Valid {
 			desc = translator.T("expense_no_description")
 		}
-		msg += fmt.Sprintf("[ID: %d] • %s - %s (%s)\n",
+		msg += fmt.Sprintf("[ID: %d] • %s - %s%s (%s)\n",
 			exp.ID,
-			utils.FormatCurrency(exp.Amount),
+			exp.Amount.String(),
 			desc,
+			installmentLabel(exp, translator),
 			utils.FormatDate(exp.ExpenseDate))
 	}
 
-	msg += fmt.Sprintf("\n*Total: %s*", utils.FormatCurrency(total))
+	msg += fmt.Sprintf("\n*Total: %s*", formatCurrencyTotals(totals))
+	if dueDate, ok, err := handler.paymentMethodService.StatementDueDate(paymentMethod, periodEnd); err == nil && ok {
+		msg += translator.T("billing_due_date", utils.FormatDate(dueDate))
+	}
 	handler.sendMessage(message.Chat.ID, msg)
 }
 
@@ -429,10 +477,11 @@ func (h *Handler) handleDeleteExpense(handler *Handler, message *tgbotapi.Messag
 					pm = " | " + pmObj.Name
 				}
 			}
-			msg += fmt.Sprintf("%d. %s - %s%s%s (%s)\n",
+			msg += fmt.Sprintf("%d. %s - %s%s%s%s (%s)\n",
 				exp.ID,
-				utils.FormatCurrency(exp.Amount),
+				exp.Amount.String(),
 				desc,
+				installmentLabel(exp, translator),
 				cat,
 				pm,
 				utils.FormatDate(exp.ExpenseDate))
@@ -461,14 +510,35 @@ func (h *Handler) handleDeleteExpense(handler *Handler, message *tgbotapi.Messag
 		return
 	}
 
+	// Deleting from a reconciled statement must be confirmed
+	_, force := stripConfirm(argsParts)
+
+	// Deleting the parent purchase also removes its remaining installments; the ones already billed stay
+	installments := 0
+	if expense.IsInstallment() && !expense.ParentExpenseID.Valid {
+		all, _ := handler.expenseService.GetInstallments(expenseID)
+		installments = len(all) - 1
+	}
+
 	// Delete the expense
-	err = handler.expenseService.DeleteExpense(expenseID)
+	cascaded, err := handler.expenseService.DeleteExpense(expenseID, force)
+	if errors.Is(err, service.ErrStatementReconciled) {
+		handler.askReconciledConfirm(userID, message.Chat.ID, expense, "/delete "+args)
+		return
+	}
 	if err != nil {
 		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_delete_error", err)
 		return
 	}
 
-	handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_deleted")
+	msg := translator.T("expense_deleted")
+	if cascaded > 0 {
+		msg = translator.T("expense_deleted_installments", cascaded)
+	}
+	if kept := installments - cascaded; kept > 0 {
+		msg += translator.T("expense_installments_kept", kept)
+	}
+	handler.sendMessage(message.Chat.ID, msg)
 }
 
 // handleEditExpense handles the /edit command
@@ -508,18 +578,28 @@ func (h *Handler) handleEditExpense(handler *Handler, message *tgbotapi.Message,
 		return
 	}
 
+	// Editing an expense of a reconciled statement must be confirmed
+	argsParts, force := stripConfirm(argsParts)
+	if len(argsParts) < 2 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_edit_usage")
+		return
+	}
+
 	// Parse what to edit: field value
 	field := strings.ToLower(argsParts[1])
 	var category *string
 	var paymentMethodID *int64
+	var split *service.ExpenseSplit
 
 	if field == "category" {
 		if len(argsParts) < 3 {
 			handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_edit_category_usage")
 			return
 		}
-		cat := strings.Join(argsParts[2:], " ")
-		// Allow empty string to clear category
+		cat, _, ok := handler.resolveCategoryArg(lobby.ID, userID, message.Chat.ID, strings.Join(argsParts[2:], " "))
+		if !ok {
+			return
+		}
 		category = &cat
 	} else if field == "payment_method" || field == "payment" {
 		if len(argsParts) < 3 {
@@ -541,13 +621,28 @@ func (h *Handler) handleEditExpense(handler *Handler, message *tgbotapi.Message,
 			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_not_found", paymentMethodName)
 			return
 		}
+	} else if field == "split" || field == "reparto" {
+		if len(argsParts) < 3 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_split_invalid")
+			return
+		}
+		var ok bool
+		split, ok = parseSplit(argsParts[2])
+		if !ok {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_split_invalid")
+			return
+		}
 	} else {
 		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_edit_invalid_field")
 		return
 	}
 
 	// Update the expense
-	err = handler.expenseService.UpdateExpense(expenseID, nil, nil, category, nil, paymentMethodID)
+	err = handler.expenseService.UpdateExpense(expenseID, nil, nil, category, nil, paymentMethodID, split, force)
+	if errors.Is(err, service.ErrStatementReconciled) {
+		handler.askReconciledConfirm(userID, message.Chat.ID, expense, "/edit "+args)
+		return
+	}
 	if err != nil {
 		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_edit_error", err)
 		return
@@ -559,7 +654,7 @@ func (h *Handler) handleEditExpense(handler *Handler, message *tgbotapi.Message,
 	if updatedExpense != nil {
 		msg += fmt.Sprintf("\n\nID: %d\nAmount: %s\nDescription: %s\n",
 			updatedExpense.ID,
-			utils.FormatCurrency(updatedExpense.Amount),
+			updatedExpense.Amount.String(),
 			updatedExpense.Description.String)
 		if updatedExpense.Category.Valid {
 			msg += translator.T("expense_category", updatedExpense.Category.String)
@@ -570,7 +665,290 @@ func (h *Handler) handleEditExpense(handler *Handler, message *tgbotapi.Message,
 				msg += translator.T("expense_payment_method", pm.Name)
 			}
 		}
+		msg += translator.T("expense_split", formatSplitLabel(updatedExpense, translator))
 	}
 
 	handler.sendMessage(message.Chat.ID, msg)
 }
+
+// isSpenderArg reports whether an argument identifies a lobby member
+// ("me"/"yo", "partner"/"pareja", "user1".."userN", "@username" or a Telegram ID)
+func isSpenderArg(arg string) bool {
+	lower := strings.ToLower(arg)
+	switch lower {
+	case "me", "yo", "partner", "pareja":
+		return true
+	}
+	if _, ok := memberPosition(lower); ok {
+		return true
+	}
+	if strings.HasPrefix(lower, "@") && len(lower) > 1 {
+		return true
+	}
+	// A long number is most likely a Telegram ID rather than a category
+	_, err := strconv.ParseInt(arg, 10, 64)
+	return err == nil && len(arg) > 3
+}
+
+// memberPosition parses a "userN" argument into the index of the member in joining order
+func memberPosition(arg string) (int, bool) {
+	if !strings.HasPrefix(arg, "user") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(arg[len("user"):])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n - 1, true
+}
+
+// resolveSpender maps a member argument to a lobby member's Telegram ID, defaulting to the user.
+// It returns the translation key of the error to show when the argument can't be resolved.
+func (h *Handler) resolveSpender(lobby *database.Lobby, userID int64, spenderArg string) (int64, string) {
+	if spenderArg == "" {
+		return userID, ""
+	}
+
+	lower := strings.ToLower(spenderArg)
+	switch lower {
+	case "me", "yo":
+		return userID, ""
+	case "partner", "pareja":
+		// The other member of a couple; with more members they have to be named
+		switch {
+		case len(lobby.Members) < 2:
+			return 0, "waiting_partner"
+		case len(lobby.Members) > 2:
+			return 0, "error_partner_ambiguous"
+		}
+		if partnerID := lobby.Partner(userID); partnerID != 0 {
+			return partnerID, ""
+		}
+		return 0, "error_invalid_user_id"
+	}
+
+	if position, ok := memberPosition(lower); ok {
+		if position >= len(lobby.Members) {
+			if len(lobby.Members) < 2 {
+				return 0, "waiting_partner"
+			}
+			return 0, "error_invalid_user_id"
+		}
+		return lobby.Members[position].UserTelegramID, ""
+	}
+
+	if strings.HasPrefix(lower, "@") {
+		for _, member := range lobby.Members {
+			user, err := h.userService.GetUserByTelegramID(member.UserTelegramID)
+			if err == nil && user != nil && user.Username.Valid && strings.EqualFold("@"+user.Username.String, spenderArg) {
+				return member.UserTelegramID, ""
+			}
+		}
+		return 0, "error_invalid_user_id"
+	}
+
+	// Check if it's a valid user ID in the lobby
+	parsedID, err := strconv.ParseInt(spenderArg, 10, 64)
+	if err != nil || !lobby.IsMember(parsedID) {
+		return 0, "error_invalid_user_id"
+	}
+	return parsedID, ""
+}
+
+// addOptions holds the optional flags accepted anywhere after the description in /add
+type addOptions struct {
+	Installments int                   // Number of installments (cuotas), 0 for a single payment
+	InterestPct  float64               // Interest added to the purchase total when paid in installments
+	Currency     string                // Currency given as a separate token (e.g. "USD"), "" if none
+	Split        *service.ExpenseSplit // Split given with "split:<mode>", nil for the lobby ratio
+}
+
+var (
+	installmentsOptionRegex = regexp.MustCompile(`(?i)^(\d+)(x|cuotas)$`)
+	interestOptionRegex     = regexp.MustCompile(`^\+(\d+(?:\.\d+)?)%$`)
+	splitOptionRegex        = regexp.MustCompile(`(?i)^(?:split|reparto):(.+)$`)
+)
+
+// extractAddOptions removes option tokens such as "6x", "+10%", "USD" or "split:personal" from the /add arguments.
+// It returns the remaining arguments and, if an option is malformed, the translation key of the error.
+func extractAddOptions(args []string) ([]string, addOptions, string) {
+	var opts addOptions
+	rest := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		if m := installmentsOptionRegex.FindStringSubmatch(arg); m != nil {
+			n, err := strconv.Atoi(m[1])
+			if err != nil || n < 2 || n > service.MaxInstallments {
+				return nil, opts, "expense_installments_invalid"
+			}
+			opts.Installments = n
+			continue
+		}
+		if m := interestOptionRegex.FindStringSubmatch(arg); m != nil {
+			pct, err := strconv.ParseFloat(m[1], 64)
+			if err != nil {
+				return nil, opts, "expense_installments_invalid"
+			}
+			opts.InterestPct = pct
+			continue
+		}
+		if m := splitOptionRegex.FindStringSubmatch(arg); m != nil {
+			split, ok := parseSplit(m[1])
+			if !ok {
+				return nil, opts, "expense_split_invalid"
+			}
+			opts.Split = split
+			continue
+		}
+		if currency := utils.NormalizeCurrency(arg); currency != "" && opts.Currency == "" {
+			opts.Currency = currency
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	// Interest only makes sense for installment purchases
+	if opts.InterestPct > 0 && opts.Installments == 0 {
+		return nil, opts, "expense_installments_invalid"
+	}
+
+	return rest, opts, ""
+}
+
+// parseSplit parses a split mode: "shared", "personal" (all mine), "partner" (all my partner's),
+// a percentage of my share ("70%") or the amount of my share ("3000")
+func parseSplit(value string) (*service.ExpenseSplit, bool) {
+	switch strings.ToLower(value) {
+	case "shared", "compartido":
+		return &service.ExpenseSplit{Mode: database.SplitShared}, true
+	case "personal", "mine", "mio", "mío":
+		return &service.ExpenseSplit{Mode: database.SplitPersonal}, true
+	case "partner", "pareja", "theirs", "suyo":
+		return &service.ExpenseSplit{Mode: database.SplitPartner}, true
+	}
+
+	if strings.HasSuffix(value, "%") {
+		pct, err := strconv.ParseFloat(strings.Replace(strings.TrimSuffix(value, "%"), ",", ".", 1), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return nil, false
+		}
+		return &service.ExpenseSplit{Mode: database.SplitCustom, Percent: pct}, true
+	}
+
+	amount, err := utils.ParseMoney(value)
+	if err != nil {
+		return nil, false
+	}
+	return &service.ExpenseSplit{Mode: database.SplitCustom, Amount: &amount}, true
+}
+
+// formatSplitLabel describes how an expense is split, e.g. "personal" or "70% / 30%"
+func formatSplitLabel(exp *database.Expense, translator *i18n.Translator) string {
+	switch exp.SplitMode {
+	case database.SplitPersonal:
+		return translator.T("split_personal")
+	case database.SplitPartner:
+		return translator.T("split_partner")
+	case database.SplitCustom:
+		if exp.SplitAmount.Valid {
+			return translator.T("split_custom_amount", utils.NewMoney(exp.SplitAmount.Int64, exp.Amount.Currency).String())
+		}
+		return translator.T("split_custom_percent", exp.SplitPercent.Float64, 100-exp.SplitPercent.Float64)
+	}
+	return translator.T("split_shared")
+}
+
+// installmentLabel returns the " (cuota 3/6)" suffix for installment expenses, or an empty string
+func installmentLabel(exp *database.Expense, translator *i18n.Translator) string {
+	if !exp.IsInstallment() {
+		return ""
+	}
+	return translator.T("expense_installment_label", exp.InstallmentNumber.Int64, exp.InstallmentCount.Int64)
+}
+
+// formatInstallmentLines lists the installment charges among the given expenses
+func formatInstallmentLines(expenses []*database.Expense, translator *i18n.Translator) string {
+	var lines []string
+	for _, exp := range expenses {
+		if !exp.IsInstallment() {
+			continue
+		}
+		desc := exp.Description.String
+		if !exp.Description.Valid {
+			desc = translator.T("expense_no_description")
+		}
+		lines = append(lines, translator.T("expense_installment_item",
+			desc, installmentLabel(exp, translator), exp.Amount.String()))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return translator.T("expense_installments_header") + strings.Join(lines, "")
+}
+
+// currencyTotals adds up expenses per currency, so amounts in different currencies are never mixed
+func currencyTotals(expenses []*database.Expense) map[string]utils.Money {
+	totals := make(map[string]utils.Money)
+	for _, exp := range expenses {
+		currency := exp.Amount.Currency
+		totals[currency] = utils.NewMoney(totals[currency].Amount+exp.Amount.Amount, currency)
+	}
+	return totals
+}
+
+// formatCurrencyTotals formats per-currency totals, e.g. "1500.00 ARS + 20.00 USD"
+func formatCurrencyTotals(totals map[string]utils.Money) string {
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	parts := make([]string, 0, len(currencies))
+	for _, currency := range currencies {
+		parts = append(parts, totals[currency].String())
+	}
+	return strings.Join(parts, " + ")
+}
+
+// formatInstallmentPurchase formats the confirmation for a purchase split in installments
+func (h *Handler) formatInstallmentPurchase(installments []*database.Expense, opts addOptions, translator *i18n.Translator) string {
+	parent := installments[0]
+
+	// Every installment of a purchase is in the purchase's currency
+	total := currencyTotals(installments)[parent.Amount.Currency]
+
+	msg := translator.T("expense_added_installments",
+		total.String(),
+		len(installments),
+		parent.Description.String)
+	msg += fmt.Sprintf("ID: %d\n", parent.ID)
+
+	if opts.InterestPct > 0 {
+		msg += translator.T("expense_installment_interest", opts.InterestPct)
+	}
+	if parent.Category.Valid {
+		msg += translator.T("expense_category", parent.Category.String)
+	}
+	if parent.PaymentMethodID.Valid {
+		pm, _ := h.paymentMethodService.GetPaymentMethodByID(parent.PaymentMethodID.Int64)
+		if pm != nil {
+			msg += translator.T("expense_payment_method", pm.Name)
+		}
+	}
+	if !parent.IsShared() {
+		msg += translator.T("expense_split", formatSplitLabel(parent, translator))
+	}
+
+	msg += "\n"
+	for _, exp := range installments {
+		msg += translator.T("expense_installment_line",
+			exp.InstallmentNumber.Int64,
+			exp.InstallmentCount.Int64,
+			exp.Amount.String(),
+			utils.FormatDate(exp.BillingPeriodStart.Time),
+			utils.FormatDate(exp.BillingPeriodEnd.Time))
+	}
+
+	return msg
+}
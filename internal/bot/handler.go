@@ -4,6 +4,7 @@ import (
 	"botGastosPareja/internal/database"
 	"botGastosPareja/internal/service"
 	"botGastosPareja/pkg/i18n"
+	"fmt"
 	"log"
 	"regexp"
 	"strings"
@@ -13,15 +14,23 @@ import (
 
 // Handler handles Telegram bot updates
 type Handler struct {
-	bot                  *tgbotapi.BotAPI
-	db                   *database.DB
-	router               *Router
-	userService          *service.UserService
-	lobbyService         *service.LobbyService
-	paymentMethodService *service.PaymentMethodService
-	expenseService       *service.ExpenseService
-	settlementService    *service.SettlementService
-	analysisService      *service.AnalysisService
+	bot                   *tgbotapi.BotAPI
+	db                    *database.DB
+	router                *Router
+	userService           *service.UserService
+	lobbyService          *service.LobbyService
+	paymentMethodService  *service.PaymentMethodService
+	expenseService        *service.ExpenseService
+	settlementService     *service.SettlementService
+	analysisService       *service.AnalysisService
+	recurringService      *service.RecurringService
+	exchangeRateService   *service.ExchangeRateService
+	categoryService       *service.CategoryService
+	ruleService           *service.RuleService
+	budgetService         *service.BudgetService
+	reconciliationService *service.ReconciliationService
+	incomeService         *service.IncomeService
+	securityService       *service.SecurityService
 }
 
 // getTranslator gets a translator for a user
@@ -52,6 +61,32 @@ func (h *Handler) getUserDisplayName(telegramID int64, defaultLabel string) stri
 	return defaultLabel
 }
 
+// getMemberName returns the display name of a lobby member, or their position ("user3") when they have none
+func (h *Handler) getMemberName(lobby *database.Lobby, telegramID int64) string {
+	defaultLabel := fmt.Sprint(telegramID)
+	for i, memberID := range lobby.MemberIDs() {
+		if memberID == telegramID {
+			defaultLabel = fmt.Sprintf("user%d", i+1)
+		}
+	}
+	return h.getUserDisplayName(telegramID, defaultLabel)
+}
+
+// formatPartnerInfo describes who else is in the lobby: the partner in a couple, or every member in a larger lobby
+func (h *Handler) formatPartnerInfo(lobby *database.Lobby, userID int64, translator *i18n.Translator) string {
+	switch {
+	case len(lobby.Members) < 2:
+		return translator.T("waiting_partner")
+	case len(lobby.Members) == 2 && lobby.IsMember(userID):
+		return translator.T("partner_id", lobby.Partner(userID))
+	}
+	names := make([]string, 0, len(lobby.Members))
+	for _, memberID := range lobby.MemberIDs() {
+		names = append(names, h.getMemberName(lobby, memberID))
+	}
+	return translator.T("lobby_members_info", len(lobby.Members), lobby.MaxMembers, strings.Join(names, ", "))
+}
+
 // Services interface for dependency injection (if needed)
 type Services struct {
 	UserService  *service.UserService
@@ -65,19 +100,37 @@ func NewHandler(bot *tgbotapi.BotAPI, db *database.DB) *Handler {
 	lobbyService := service.NewLobbyService(db)
 	paymentMethodService := service.NewPaymentMethodService(db)
 	expenseService := service.NewExpenseService(db)
-	settlementService := service.NewSettlementService(db, expenseService, lobbyService)
-	analysisService := service.NewAnalysisService(db, expenseService)
+	exchangeRateService := service.NewExchangeRateService(db, lobbyService)
+	settlementService := service.NewSettlementService(db, expenseService, lobbyService, exchangeRateService)
+	analysisService := service.NewAnalysisService(db, expenseService, exchangeRateService)
+	recurringService := service.NewRecurringService(db, expenseService)
+	categoryService := service.NewCategoryService(db)
+	ruleService := service.NewRuleService(db)
+	budgetService := service.NewBudgetService(db, exchangeRateService)
+	reconciliationService := service.NewReconciliationService(db, expenseService)
+	incomeService := service.NewIncomeService(db)
+	securityService := service.NewSecurityService(db)
 	handler := &Handler{
-		bot:                  bot,
-		db:                   db,
-		router:               router,
-		userService:          userService,
-		lobbyService:         lobbyService,
-		paymentMethodService: paymentMethodService,
-		expenseService:       expenseService,
-		settlementService:    settlementService,
-		analysisService:      analysisService,
+		bot:                   bot,
+		db:                    db,
+		router:                router,
+		userService:           userService,
+		lobbyService:          lobbyService,
+		paymentMethodService:  paymentMethodService,
+		expenseService:        expenseService,
+		settlementService:     settlementService,
+		analysisService:       analysisService,
+		recurringService:      recurringService,
+		exchangeRateService:   exchangeRateService,
+		categoryService:       categoryService,
+		ruleService:           ruleService,
+		budgetService:         budgetService,
+		reconciliationService: reconciliationService,
+		incomeService:         incomeService,
+		securityService:       securityService,
 	}
+	expenseService.OnExpenseCreated(handler.checkBudgets)
+	lobbyService.OnRepeatedJoinFailures(handler.alertJoinFailures)
 	handler.registerCommands()
 	return handler
 }
@@ -114,6 +167,38 @@ func (h *Handler) RegisterTelegramCommands() error {
 			Command:     "settle",
 			Description: "Calculate who owes whom",
 		},
+		{
+			Command:     "paid",
+			Description: "Record a payment to your partner",
+		},
+		{
+			Command:     "balance",
+			Description: "Show the running balance between you",
+		},
+		{
+			Command:     "deposit",
+			Description: "Deposits into the shared account",
+		},
+		{
+			Command:     "members",
+			Description: "Lobby members and their shares",
+		},
+		{
+			Command:     "leave",
+			Description: "Leave the lobby",
+		},
+		{
+			Command:     "lobbies",
+			Description: "List your lobbies",
+		},
+		{
+			Command:     "use",
+			Description: "Switch the lobby of this private chat",
+		},
+		{
+			Command:     "invite",
+			Description: "Invite someone to the lobby",
+		},
 		{
 			Command:     "settings",
 			Description: "Configure lobby settings",
@@ -126,6 +211,34 @@ func (h *Handler) RegisterTelegramCommands() error {
 			Command:     "payment_methods",
 			Description: "Manage payment methods",
 		},
+		{
+			Command:     "categories",
+			Description: "Manage categories",
+		},
+		{
+			Command:     "rules",
+			Description: "Categorize expenses automatically",
+		},
+		{
+			Command:     "income",
+			Description: "Monthly incomes that set the split ratio",
+		},
+		{
+			Command:     "budget",
+			Description: "Monthly budgets and their status",
+		},
+		{
+			Command:     "reconcile",
+			Description: "Check a card statement against the bank's total",
+		},
+		{
+			Command:     "recurring",
+			Description: "Manage recurring expenses",
+		},
+		{
+			Command:     "rate",
+			Description: "Manage exchange rates and base currency",
+		},
 	}
 
 	cmd := tgbotapi.NewSetMyCommands(commands...)
@@ -241,8 +354,8 @@ func (h *Handler) handleCommand(message *tgbotapi.Message) {
 
 // handleMessage processes regular text messages
 func (h *Handler) handleMessage(message *tgbotapi.Message) {
-	// Handle interactive flows (will be implemented later)
-	// For now, just acknowledge
+	// Messages that look like an expense ("1500 super visa") are added as one
+	h.handleQuickCapture(message)
 }
 
 // handleCallbackQuery processes inline keyboard button presses
@@ -300,7 +413,8 @@ func (h *Handler) sendTranslatedMessage(userID int64, chatID int64, key string,
 	h.sendMessage(chatID, text)
 }
 
-// getLobbyForMessage gets the lobby for a user in the context of the message's chat (group/private)
+// getLobbyForMessage gets the lobby for a user in the context of the message's chat: the group's lobby,
+// or in private chat the lobby the user switched to with /use
 func (h *Handler) getLobbyForMessage(message *tgbotapi.Message) (*database.Lobby, error) {
 	userID := message.From.ID
 
@@ -318,6 +432,59 @@ func (h *Handler) getLobbyForMessage(message *tgbotapi.Message) (*database.Lobby
 	return h.lobbyService.GetLobbyByUserIDAndGroup(userID, groupChatID)
 }
 
+// getLobbyForCallback gets the lobby of the user pressing a button, in the context of the button's chat
+func (h *Handler) getLobbyForCallback(query *tgbotapi.CallbackQuery) (*database.Lobby, error) {
+	if query.Message == nil {
+		return nil, nil
+	}
+
+	var groupChatID *int64
+	chat := query.Message.Chat
+	if chat.IsGroup() || chat.IsSuperGroup() || chat.IsChannel() {
+		groupID := chat.ID
+		groupChatID = &groupID
+	}
+
+	return h.lobbyService.GetLobbyByUserIDAndGroup(query.From.ID, groupChatID)
+}
+
+// answerCallback acknowledges a button press, optionally showing a short notification
+func (h *Handler) answerCallback(queryID string, text string) {
+	callback := tgbotapi.NewCallback(queryID, text)
+	if _, err := h.bot.Request(callback); err != nil {
+		log.Printf("Error acknowledging callback: %v", err)
+	}
+}
+
+// editMessage replaces the text of a sent message, removing its inline keyboard
+func (h *Handler) editMessage(chatID int64, messageID int, text string) {
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, convertMarkdownToHTML(text))
+	editMsg.ParseMode = tgbotapi.ModeHTML
+	if _, err := h.bot.Send(editMsg); err != nil {
+		log.Printf("Error editing message: %v", err)
+	}
+}
+
+// editMessageWithKeyboard edits a message, replacing its inline keyboard
+func (h *Handler) editMessageWithKeyboard(chatID int64, messageID int, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, convertMarkdownToHTML(text), keyboard)
+	editMsg.ParseMode = tgbotapi.ModeHTML
+	if _, err := h.bot.Send(editMsg); err != nil {
+		log.Printf("Error editing message: %v", err)
+	}
+}
+
+// replyWithKeyboard replies to a message with inline keyboard
+func (h *Handler) replyWithKeyboard(message *tgbotapi.Message, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
+	msg := tgbotapi.NewMessage(message.Chat.ID, convertMarkdownToHTML(text))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyToMessageID = message.MessageID
+	msg.ReplyMarkup = keyboard
+	if _, err := h.bot.Send(msg); err != nil {
+		log.Printf("Error sending message with keyboard: %v", err)
+	}
+}
+
 // sendMessageWithKeyboard sends a message with inline keyboard
 func (h *Handler) sendMessageWithKeyboard(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
 	msg := tgbotapi.NewMessage(chatID, text)
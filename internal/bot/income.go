@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerIncomeCommands registers income commands
+func (h *Handler) registerIncomeCommands() {
+	h.router.RegisterCommand("income", h.handleIncome)
+}
+
+// handleIncome handles the /income command
+func (h *Handler) handleIncome(handler *Handler, message *tgbotapi.Message, args string) {
+	if message.From == nil {
+		handler.sendMessage(message.Chat.ID, "❌ Error: This command must be used by a user.")
+		return
+	}
+	userID := message.From.ID
+
+	// Get user's lobby for this specific chat (group/private)
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) == 0 {
+		h.sendIncomes(userID, message.Chat.ID, lobby, time.Now(), "")
+		return
+	}
+
+	switch strings.ToLower(argsParts[0]) {
+	case "list", "lista":
+		h.handleListIncomes(handler, message, lobby, argsParts[1:])
+	case "delete", "remove", "borrar":
+		h.handleDeleteIncome(handler, message, lobby, argsParts[1:])
+	default:
+		if _, err := utils.ParseMonth(argsParts[0]); err == nil && len(argsParts) == 1 {
+			h.handleListIncomes(handler, message, lobby, argsParts)
+			return
+		}
+		h.handleSetIncome(handler, message, lobby, argsParts)
+	}
+}
+
+// handleSetIncome handles /income <amount> [YYYY-MM] [partner]: sets a member's income for a month
+func (h *Handler) handleSetIncome(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	amount, err := utils.ParseMoney(args[0])
+	if err != nil || amount.IsNegative() {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "income_usage")
+		return
+	}
+
+	month, memberID, ok := h.parseIncomeArgs(userID, message.Chat.ID, lobby, args[1:])
+	if !ok {
+		return
+	}
+
+	income, err := handler.incomeService.SetIncome(lobby.ID, memberID, month, amount)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "income_error", err)
+		return
+	}
+
+	msg := translator.T("income_set", handler.getUserDisplayName(memberID, fmt.Sprint(memberID)), income.Month, income.Amount.String())
+	h.sendIncomes(userID, message.Chat.ID, lobby, month, msg)
+}
+
+// handleDeleteIncome handles /income delete [YYYY-MM] [partner]
+func (h *Handler) handleDeleteIncome(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+
+	month, memberID, ok := h.parseIncomeArgs(userID, message.Chat.ID, lobby, args)
+	if !ok {
+		return
+	}
+
+	name := handler.getUserDisplayName(memberID, fmt.Sprint(memberID))
+	err := handler.incomeService.DeleteIncome(lobby.ID, memberID, month)
+	if errors.Is(err, service.ErrIncomeNotFound) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "income_not_found", name, utils.FormatMonth(month))
+		return
+	}
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "income_error", err)
+		return
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "income_deleted", name, utils.FormatMonth(month))
+}
+
+// handleListIncomes handles /income list [YYYY-MM]
+func (h *Handler) handleListIncomes(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+
+	month := time.Now()
+	if len(args) > 0 {
+		monthTime, err := utils.ParseMonth(args[0])
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_invalid_period")
+			return
+		}
+		month = monthTime
+	}
+	h.sendIncomes(userID, message.Chat.ID, lobby, month, "")
+}
+
+// parseIncomeArgs parses the optional month (YYYY-MM, current month by default) and member
+// (partner, user1, user2, the user by default) of an income command, in any order
+func (h *Handler) parseIncomeArgs(userID, chatID int64, lobby *database.Lobby, args []string) (time.Time, int64, bool) {
+	month := time.Now()
+	memberArg := ""
+	for _, arg := range args {
+		if monthTime, err := utils.ParseMonth(arg); err == nil {
+			month = monthTime
+			continue
+		}
+		if memberArg != "" {
+			h.sendTranslatedMessage(userID, chatID, "income_usage")
+			return time.Time{}, 0, false
+		}
+		memberArg = arg
+	}
+
+	memberID, errKey := h.resolveSpender(lobby, userID, memberArg)
+	if errKey != "" {
+		h.sendTranslatedMessage(userID, chatID, errKey)
+		return time.Time{}, 0, false
+	}
+	return month, memberID, true
+}
+
+// sendIncomes sends the members' incomes for a month and the ratio they set, after an optional prefix
+func (h *Handler) sendIncomes(userID, chatID int64, lobby *database.Lobby, month time.Time, prefix string) {
+	translator := h.getTranslator(userID)
+
+	incomes, err := h.incomeService.GetIncomes(lobby.ID, month, month)
+	if err != nil {
+		h.sendTranslatedMessage(userID, chatID, "error_generic", err)
+		return
+	}
+	if len(incomes) == 0 && prefix == "" {
+		h.sendTranslatedMessage(userID, chatID, "income_none", utils.FormatMonth(month))
+		return
+	}
+
+	converter, err := h.exchangeRateService.NewConverter(lobby.ID)
+	if err != nil {
+		h.sendTranslatedMessage(userID, chatID, "error_generic", err)
+		return
+	}
+	ratio, err := h.incomeService.IncomeRatio(lobby, month, month, converter)
+	if err != nil {
+		h.sendConversionError(userID, chatID, "income_error", err)
+		return
+	}
+
+	msg := prefix + translator.T("income_header", utils.FormatMonth(month))
+	for _, memberID := range lobby.MemberIDs() {
+		amount := translator.T("income_missing")
+		for _, income := range incomes {
+			if income.UserTelegramID == memberID {
+				amount = income.Amount.String()
+			}
+		}
+		msg += translator.T("income_line", h.getMemberName(lobby, memberID), amount)
+	}
+
+	if ratio != nil {
+		msg += translator.T("income_ratio",
+			h.getMemberName(lobby, lobby.Members[0].UserTelegramID), ratio.User1Percentage*100,
+			h.getMemberName(lobby, lobby.Members[1].UserTelegramID), ratio.User2Percentage*100)
+	} else if len(lobby.Members) > 2 {
+		// Larger lobbies split by each member's share weight rather than by income
+		msg += translator.T("income_ratio_weights")
+	} else {
+		// The settings in force at the end of the month, or today for the current one
+		_, monthEnd := utils.GetMonthStartEnd(month.Year(), month.Month())
+		if monthEnd.After(time.Now()) {
+			monthEnd = time.Now()
+		}
+		settings, err := h.lobbyService.GetSettingsAt(lobby.ID, monthEnd)
+		if err != nil {
+			h.sendTranslatedMessage(userID, chatID, "error_generic", err)
+			return
+		}
+		msg += translator.T("income_ratio_fallback", settings.User1SalaryPercentage*100, settings.User2SalaryPercentage*100)
+	}
+	h.sendMessage(chatID, msg)
+}
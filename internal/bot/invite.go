@@ -1,8 +1,15 @@
 package bot
 
 import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
 	"botGastosPareja/pkg/utils"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -13,7 +20,101 @@ func (h *Handler) registerInviteCommands() {
 	h.router.RegisterCommand("regenerate_invite", h.handleRegenerateInvite)
 }
 
-// handleInvite handles the /invite command to show invitation token
+// parseInviteTTL parses how long an invitation is valid: hours ("12h") or days ("3d")
+func parseInviteTTL(arg string) (time.Duration, bool) {
+	arg = strings.ToLower(arg)
+	if len(arg) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(arg[:len(arg)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	switch arg[len(arg)-1] {
+	case 'h':
+		return time.Duration(n) * time.Hour, true
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// formatInviteExpiry formats when an invitation stops working
+func formatInviteExpiry(invite *database.InviteToken) string {
+	return invite.ExpiresAt.Format("2006-01-02 15:04")
+}
+
+// joinErrorKey returns the message explaining why joining with an invite token failed, or "" for other errors
+func joinErrorKey(err error) string {
+	switch {
+	case errors.Is(err, service.ErrInviteNotFound):
+		return "error_invalid_token"
+	case errors.Is(err, service.ErrInviteExpired):
+		return "invite_expired"
+	case errors.Is(err, service.ErrInviteUsedUp):
+		return "invite_used_up"
+	case errors.Is(err, service.ErrInviteRevoked):
+		return "invite_revoked"
+	case errors.Is(err, service.ErrLobbyFull):
+		return "invite_lobby_full"
+	case errors.Is(err, service.ErrAlreadyMember):
+		return "invite_already_member"
+	}
+	return ""
+}
+
+// sendJoinLocked tells a user until when they cannot try to join lobbies after too many failed attempts
+func (h *Handler) sendJoinLocked(userID, chatID int64) {
+	until, err := h.securityService.JoinLockedUntil(userID)
+	if err != nil || until.IsZero() {
+		h.sendTranslatedMessage(userID, chatID, "join_locked_later")
+		return
+	}
+	h.sendTranslatedMessage(userID, chatID, "join_locked", until.Format("2006-01-02 15:04"))
+}
+
+// alertJoinFailures warns the owner of a lobby that someone keeps failing to join it: in the group of a group
+// lobby, privately otherwise
+func (h *Handler) alertJoinFailures(lobby *database.Lobby, userID int64, failures int) {
+	translator := h.getTranslator(lobby.OwnerTelegramID)
+	name := h.getUserDisplayName(userID, fmt.Sprint(userID))
+
+	// A private chat's ID is the user's Telegram ID
+	chatID := lobby.OwnerTelegramID
+	if lobby.GroupChatID.Valid {
+		chatID = lobby.GroupChatID.Int64
+	}
+	h.sendMessage(chatID, translator.T("join_failures_alert", name, userID, failures, lobbyLabel(lobby, translator)))
+}
+
+// formatInvite describes a new invitation: the token, how to use it, how many joins it allows and until when
+func formatInvite(invite *database.InviteToken, translator *i18n.Translator) string {
+	formattedToken := utils.FormatInviteToken(invite.Token)
+	return translator.T("invite_token_display", formattedToken, formattedToken, invite.MaxUses, formatInviteExpiry(invite))
+}
+
+// sendLobbyCreated welcomes the creator of a private lobby with an invitation for their partner
+func (h *Handler) sendLobbyCreated(userID, chatID int64, displayName string, lobby *database.Lobby) {
+	translator := h.getTranslator(userID)
+
+	invite, err := h.lobbyService.CreateInviteToken(lobby.ID, userID, service.DefaultInviteTTL, 1)
+	if err != nil {
+		h.sendTranslatedMessage(userID, chatID, "invite_error", err)
+		return
+	}
+
+	// Format invitation token for display
+	formattedToken := utils.FormatInviteToken(invite.Token)
+	welcomeMsg := translator.T("lobby_created", displayName, lobby.ID, lobby.AccountType, formattedToken, formattedToken)
+	h.sendMessage(chatID, welcomeMsg)
+
+	// Send security instructions (token appears twice in the message)
+	securityMsg := translator.T("lobby_security_info", formattedToken, formattedToken, formatInviteExpiry(invite))
+	h.sendMessage(chatID, securityMsg)
+}
+
+// handleInvite handles the /invite command: creates an invitation (optionally valid for a duration and
+// several joins), lists the active ones, or revokes them
 func (h *Handler) handleInvite(handler *Handler, message *tgbotapi.Message, args string) {
 	userID := message.From.ID
 	translator := handler.getTranslator(userID)
@@ -25,18 +126,116 @@ func (h *Handler) handleInvite(handler *Handler, message *tgbotapi.Message, args
 		return
 	}
 
-	if !lobby.InviteToken.Valid {
-		handler.sendMessage(message.Chat.ID,
-			translator.T("error_no_invite_token"))
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) > 0 && (strings.EqualFold(argsParts[0], "list") || strings.EqualFold(argsParts[0], "lista")) {
+		h.sendInvites(userID, message.Chat.ID, lobby)
 		return
 	}
 
-	formattedToken := utils.FormatInviteToken(lobby.InviteToken.String)
-	msg := translator.T("invite_token_display", formattedToken, formattedToken)
-	handler.sendMessage(message.Chat.ID, msg)
+	// Only the lobby owner invites people and revokes invitations
+	if lobby.OwnerTelegramID != userID {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_not_lobby_owner")
+		return
+	}
+
+	if len(argsParts) > 0 && (strings.EqualFold(argsParts[0], "revoke") || strings.EqualFold(argsParts[0], "revocar")) {
+		h.handleRevokeInvite(handler, message, lobby, argsParts[1:])
+		return
+	}
+
+	// Optional duration ("12h", "3d") and number of joins, in any order
+	ttl := service.DefaultInviteTTL
+	maxUses := 1
+	for _, arg := range argsParts {
+		if duration, ok := parseInviteTTL(arg); ok {
+			ttl = duration
+			continue
+		}
+		if uses, err := strconv.Atoi(arg); err == nil && uses > 0 {
+			maxUses = uses
+			continue
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "invite_usage")
+		return
+	}
+
+	invite, err := handler.lobbyService.CreateInviteToken(lobby.ID, userID, ttl, maxUses)
+	if errors.Is(err, service.ErrLobbyFull) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "invite_lobby_full")
+		return
+	}
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "invite_error", err)
+		return
+	}
+	handler.sendMessage(message.Chat.ID, formatInvite(invite, translator))
 }
 
-// handleRegenerateInvite handles the /regenerate_invite command
+// handleRevokeInvite handles /invite revoke [id]: one invitation, or all of them without an ID
+func (h *Handler) handleRevokeInvite(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+
+	if len(args) == 0 {
+		revoked, err := handler.lobbyService.RevokeInviteTokens(lobby.ID)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "invite_revoked_all", revoked)
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "invite_usage")
+		return
+	}
+	err = handler.lobbyService.RevokeInviteToken(lobby.ID, id)
+	if errors.Is(err, service.ErrInviteNotFound) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "invite_not_found")
+		return
+	}
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "invite_revoked_one", id)
+}
+
+// sendInvites lists the invitations of a lobby that can still be used
+func (h *Handler) sendInvites(userID, chatID int64, lobby *database.Lobby) {
+	translator := h.getTranslator(userID)
+
+	invites, err := h.lobbyService.GetActiveInviteTokens(lobby.ID)
+	if err != nil {
+		h.sendTranslatedMessage(userID, chatID, "error_generic", err)
+		return
+	}
+	if len(invites) == 0 {
+		h.sendTranslatedMessage(userID, chatID, "invite_none")
+		return
+	}
+
+	msg := translator.T("invite_list_header")
+	for _, invite := range invites {
+		creator := "-"
+		if invite.CreatedBy.Valid {
+			creator = h.getUserDisplayName(invite.CreatedBy.Int64, fmt.Sprint(invite.CreatedBy.Int64))
+		}
+		msg += translator.T("invite_list_item", invite.ID, creator, invite.Uses, invite.MaxUses, formatInviteExpiry(invite))
+		if len(invite.UsedBy) > 0 {
+			names := make([]string, len(invite.UsedBy))
+			for i, usedBy := range invite.UsedBy {
+				names[i] = h.getMemberName(lobby, usedBy)
+			}
+			msg += translator.T("invite_list_used_by", strings.Join(names, ", "))
+		}
+	}
+	msg += translator.T("invite_list_hint")
+	h.sendMessage(chatID, msg)
+}
+
+// handleRegenerateInvite handles the /regenerate_invite command: replaces every invitation with a new one
 func (h *Handler) handleRegenerateInvite(handler *Handler, message *tgbotapi.Message, args string) {
 	userID := message.From.ID
 	translator := handler.getTranslator(userID)
@@ -48,21 +247,24 @@ func (h *Handler) handleRegenerateInvite(handler *Handler, message *tgbotapi.Mes
 		return
 	}
 
-	// Check if user is the lobby creator
-	if lobby.User1TelegramID != userID {
+	// Check if user is the lobby owner
+	if lobby.OwnerTelegramID != userID {
 		handler.sendMessage(message.Chat.ID,
 			translator.T("error_not_lobby_owner"))
 		return
 	}
 
-	newToken, err := handler.lobbyService.RegenerateInviteToken(lobby.ID)
+	invite, err := handler.lobbyService.RegenerateInviteToken(lobby.ID, userID)
+	if errors.Is(err, service.ErrLobbyFull) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "invite_lobby_full")
+		return
+	}
 	if err != nil {
 		handler.sendMessage(message.Chat.ID,
 			fmt.Sprintf("❌ Error: %v", err))
 		return
 	}
 
-	formattedToken := utils.FormatInviteToken(newToken)
-	msg := translator.T("invite_token_regenerated", formattedToken, formattedToken)
+	msg := translator.T("invite_token_regenerated") + formatInvite(invite, translator)
 	handler.sendMessage(message.Chat.ID, msg)
 }
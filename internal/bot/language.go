@@ -2,7 +2,6 @@ package bot
 
 import (
 	"botGastosPareja/pkg/i18n"
-	"botGastosPareja/pkg/utils"
 	"fmt"
 	"strings"
 
@@ -135,17 +134,7 @@ func (h *Handler) continueStartAfterLanguage(userID int64, chatID int64, firstNa
 
 	if lobby != nil {
 		// User is already in a lobby
-		var partnerInfo string
-		if lobby.User1TelegramID == userID {
-			if lobby.User2TelegramID == 0 {
-				partnerInfo = translator.T("waiting_partner")
-			} else {
-				partnerInfo = translator.T("partner_id", lobby.User2TelegramID)
-			}
-		} else {
-			partnerInfo = translator.T("partner_id", lobby.User1TelegramID)
-		}
-
+		partnerInfo := h.formatPartnerInfo(lobby, userID, translator)
 		welcomeMsg := translator.T("welcome_back", displayName, lobby.ID, lobby.AccountType, partnerInfo)
 		h.sendMessage(chatID, welcomeMsg)
 		return
@@ -158,14 +147,7 @@ func (h *Handler) continueStartAfterLanguage(userID int64, chatID int64, firstNa
 		return
 	}
 
-	// Format invitation token for display
-	formattedToken := utils.FormatInviteToken(newLobby.InviteToken.String)
-	welcomeMsg := translator.T("lobby_created", displayName, newLobby.ID, newLobby.AccountType, formattedToken, formattedToken)
-	h.sendMessage(chatID, welcomeMsg)
-
-	// Send security instructions (token appears twice in the message)
-	securityMsg := translator.T("lobby_security_info", formattedToken, formattedToken)
-	h.sendMessage(chatID, securityMsg)
+	h.sendLobbyCreated(userID, chatID, displayName, newLobby)
 }
 
 // getLanguageName returns the display name for a language
@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerLobbyCommands registers the commands to list, name and switch between lobbies
+func (h *Handler) registerLobbyCommands() {
+	h.router.RegisterCommand("lobbies", h.handleLobbies)
+	h.router.RegisterCommand("use", h.handleUse)
+}
+
+// isPrivateChat reports whether a message was sent in a private chat with the bot
+func isPrivateChat(message *tgbotapi.Message) bool {
+	return !(message.Chat.IsGroup() || message.Chat.IsSuperGroup() || message.Chat.IsChannel())
+}
+
+// lobbyLabel returns the name of a lobby, or its ID when it has none
+func lobbyLabel(lobby *database.Lobby, translator *i18n.Translator) string {
+	if lobby.Name.Valid {
+		return lobby.Name.String
+	}
+	return translator.T("lobby_unnamed", lobby.ID)
+}
+
+// handleLobbies handles the /lobbies command: lists the user's lobbies, names the lobby of this chat,
+// or creates another private lobby
+func (h *Handler) handleLobbies(handler *Handler, message *tgbotapi.Message, args string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) == 0 {
+		h.sendLobbies(message, "")
+		return
+	}
+
+	switch strings.ToLower(argsParts[0]) {
+	case "name", "nombre":
+		lobby, err := handler.getLobbyForMessage(message)
+		if err != nil || lobby == nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+			return
+		}
+		if len(argsParts) < 2 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "lobbies_usage")
+			return
+		}
+		name := strings.Join(argsParts[1:], " ")
+		if err := handler.lobbyService.SetLobbyName(lobby.ID, name); err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "lobbies_error", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "lobbies_named", lobby.ID, strings.TrimSpace(name))
+
+	case "new", "nuevo":
+		// Group lobbies are created by /start in the group
+		if !isPrivateChat(message) {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "lobbies_private_only")
+			return
+		}
+		lobby, err := handler.lobbyService.CreateLobby(userID, "separate", nil)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_create")
+			return
+		}
+		if len(argsParts) > 1 {
+			name := strings.Join(argsParts[1:], " ")
+			if err := handler.lobbyService.SetLobbyName(lobby.ID, name); err != nil {
+				handler.sendTranslatedMessage(userID, message.Chat.ID, "lobbies_error", err)
+				return
+			}
+			lobby.Name.String, lobby.Name.Valid = strings.TrimSpace(name), true
+		}
+		if err := handler.lobbyService.SetActiveLobby(userID, lobby.ID); err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "lobbies_error", err)
+			return
+		}
+		msg := translator.T("lobbies_created", lobbyLabel(lobby, translator))
+		if invite, err := handler.lobbyService.CreateInviteToken(lobby.ID, userID, service.DefaultInviteTTL, 1); err == nil {
+			msg += formatInvite(invite, translator)
+		}
+		handler.sendMessage(message.Chat.ID, msg)
+
+	default:
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "lobbies_usage")
+	}
+}
+
+// handleUse handles the /use command: switches the lobby commands act on in private chat, by ID or name
+func (h *Handler) handleUse(handler *Handler, message *tgbotapi.Message, args string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	// In a group the lobby is always the group's
+	if !isPrivateChat(message) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "lobbies_private_only")
+		return
+	}
+
+	selector := strings.TrimSpace(args)
+	if selector == "" {
+		h.sendLobbies(message, "")
+		return
+	}
+
+	lobbies, err := handler.lobbyService.GetLobbiesByUserID(userID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_check")
+		return
+	}
+
+	var selected *database.Lobby
+	id, idErr := strconv.ParseInt(strings.TrimPrefix(selector, "#"), 10, 64)
+	for _, lobby := range lobbies {
+		if (idErr == nil && lobby.ID == id) || (lobby.Name.Valid && strings.EqualFold(lobby.Name.String, selector)) {
+			selected = lobby
+			break
+		}
+	}
+	if selected == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "use_not_found", selector)
+		return
+	}
+
+	if err := handler.lobbyService.SetActiveLobby(userID, selected.ID); err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "lobbies_error", err)
+		return
+	}
+	handler.sendMessage(message.Chat.ID, translator.T("use_switched", lobbyLabel(selected, translator), selected.ID))
+}
+
+// sendLobbies lists the user's lobbies, marking the one this chat acts on, after an optional prefix
+func (h *Handler) sendLobbies(message *tgbotapi.Message, prefix string) {
+	userID := message.From.ID
+	translator := h.getTranslator(userID)
+
+	lobbies, err := h.lobbyService.GetLobbiesByUserID(userID)
+	if err != nil {
+		h.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_check")
+		return
+	}
+	if len(lobbies) == 0 {
+		h.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	var currentID int64
+	if current, err := h.getLobbyForMessage(message); err == nil && current != nil {
+		currentID = current.ID
+	}
+
+	msg := prefix + translator.T("lobbies_header")
+	for _, lobby := range lobbies {
+		kind := translator.T("lobbies_kind_private")
+		if lobby.GroupChatID.Valid {
+			kind = translator.T("lobbies_kind_group")
+		}
+		marker := "•"
+		if lobby.ID == currentID {
+			marker = "✅"
+		}
+		msg += translator.T("lobbies_item", marker, lobbyLabel(lobby, translator), lobby.ID, kind, len(lobby.Members), lobby.MaxMembers)
+	}
+	msg += translator.T("lobbies_hint")
+	h.sendMessage(message.Chat.ID, msg)
+}
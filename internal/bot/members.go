@@ -0,0 +1,385 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerMemberCommands registers lobby member commands
+func (h *Handler) registerMemberCommands() {
+	h.router.RegisterCommand("members", h.handleMembers)
+	h.router.RegisterCommand("leave", h.handleLeave)
+	h.router.RegisterCommand("remove_member", h.handleRemoveMember)
+	h.router.RegisterCommand("transfer_owner", h.handleTransferOwner)
+	h.router.RegisterCallback("member_remove", h.handleRemoveMemberCallback)
+	h.router.RegisterCallback("member_remove_cancel", h.handleRemoveMemberCancel)
+}
+
+// handleMembers handles the /members command: lists the lobby members and their share weights,
+// or (for the owner) changes how many members the lobby allows or a member's weight
+func (h *Handler) handleMembers(handler *Handler, message *tgbotapi.Message, args string) {
+	userID := message.From.ID
+
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) == 0 {
+		h.sendMembers(userID, message.Chat.ID, lobby, "")
+		return
+	}
+
+	if lobby.OwnerTelegramID != userID {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_not_lobby_owner")
+		return
+	}
+
+	switch strings.ToLower(argsParts[0]) {
+	case "max":
+		if len(argsParts) < 2 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "members_usage")
+			return
+		}
+		maxMembers, err := strconv.Atoi(argsParts[1])
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "members_usage")
+			return
+		}
+		if err := handler.lobbyService.SetMaxMembers(lobby.ID, maxMembers); err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "members_error", err)
+			return
+		}
+		lobby.MaxMembers = maxMembers
+		h.sendMembers(userID, message.Chat.ID, lobby, handler.getTranslator(userID).T("members_max_updated", maxMembers))
+
+	case "share", "peso":
+		if len(argsParts) < 3 || !isSpenderArg(argsParts[1]) {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "members_usage")
+			return
+		}
+		memberID, errKey := h.resolveSpender(lobby, userID, argsParts[1])
+		if errKey != "" {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, errKey)
+			return
+		}
+		weight, err := strconv.ParseFloat(strings.Replace(argsParts[2], ",", ".", 1), 64)
+		if err != nil || weight < 0 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "members_usage")
+			return
+		}
+		if err := handler.lobbyService.SetShareWeight(lobby.ID, memberID, weight); err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "members_error", err)
+			return
+		}
+		updated, err := handler.lobbyService.GetLobbyByID(lobby.ID)
+		if err != nil || updated == nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		prefix := handler.getTranslator(userID).T("members_share_updated", h.getMemberName(updated, memberID), weight)
+		h.sendMembers(userID, message.Chat.ID, updated, prefix)
+
+	default:
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "members_usage")
+	}
+}
+
+// sendMembers lists the lobby members with their position and share weight, after an optional prefix
+func (h *Handler) sendMembers(userID, chatID int64, lobby *database.Lobby, prefix string) {
+	translator := h.getTranslator(userID)
+
+	totalWeight := 0.0
+	for _, member := range lobby.Members {
+		totalWeight += member.ShareWeight
+	}
+
+	msg := prefix + translator.T("members_header", len(lobby.Members), lobby.MaxMembers)
+	for i, member := range lobby.Members {
+		share := 1 / float64(len(lobby.Members))
+		if totalWeight > 0 {
+			share = member.ShareWeight / totalWeight
+		}
+		msg += translator.T("members_item", i+1, h.getMemberName(lobby, member.UserTelegramID), member.ShareWeight, share*100)
+		if member.UserTelegramID == lobby.OwnerTelegramID {
+			msg += translator.T("members_owner")
+		}
+		msg += "\n"
+	}
+
+	// Couples split by salary percentages or incomes; weights only apply to larger lobbies
+	if len(lobby.Members) <= 2 {
+		msg += translator.T("members_couple_hint")
+	}
+	h.sendMessage(chatID, msg)
+}
+
+// resolveFormerMember returns the Telegram ID of a former member of a lobby named by @username or ID, or 0
+func (h *Handler) resolveFormerMember(lobby *database.Lobby, arg string) int64 {
+	members, err := h.lobbyService.GetMembersBetween(lobby.ID, time.Time{}, time.Time{})
+	if err != nil {
+		return 0
+	}
+	for _, member := range members {
+		if !member.LeftAt.Valid {
+			continue
+		}
+		if arg == strconv.FormatInt(member.UserTelegramID, 10) {
+			return member.UserTelegramID
+		}
+		if strings.HasPrefix(arg, "@") {
+			user, err := h.userService.GetUserByTelegramID(member.UserTelegramID)
+			if err == nil && user != nil && user.Username.Valid && strings.EqualFold("@"+user.Username.String, arg) {
+				return member.UserTelegramID
+			}
+		}
+	}
+	return 0
+}
+
+// notifyOtherMembers tells the members of a private lobby, but one, about a change in it; in a group
+// lobby everyone already sees the reply in the group
+func (h *Handler) notifyOtherMembers(lobby *database.Lobby, exceptID int64, key string, args ...interface{}) {
+	if lobby.GroupChatID.Valid {
+		return
+	}
+	for _, memberID := range lobby.MemberIDs() {
+		if memberID != exceptID {
+			// A private chat's ID is the user's Telegram ID
+			h.sendTranslatedMessage(memberID, memberID, key, args...)
+		}
+	}
+}
+
+// handleLeave handles the /leave command: takes the user out of the lobby of this chat
+func (h *Handler) handleLeave(handler *Handler, message *tgbotapi.Message, args string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	name := h.getMemberName(lobby, userID)
+	err = handler.lobbyService.LeaveLobby(lobby.ID, userID)
+	if errors.Is(err, service.ErrOwnerMustTransfer) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "leave_owner_must_transfer")
+		return
+	}
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "members_error", err)
+		return
+	}
+
+	label := lobbyLabel(lobby, translator)
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "leave_done", label)
+	h.notifyOtherMembers(lobby, userID, "leave_notice", name, label)
+}
+
+// handleRemoveMember handles the /remove_member command: asks the other members to approve taking a member out
+// of the lobby. Any member but the owner can approve, the one being removed too: in a couple the partner confirms.
+func (h *Handler) handleRemoveMember(handler *Handler, message *tgbotapi.Message, args string) {
+	userID := message.From.ID
+
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+	if lobby.OwnerTelegramID != userID {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_not_lobby_owner")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) != 1 || !isSpenderArg(argsParts[0]) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "remove_member_usage")
+		return
+	}
+	memberID, errKey := h.resolveSpender(lobby, userID, argsParts[0])
+	if errKey != "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, errKey)
+		return
+	}
+	if memberID == userID {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "remove_member_self")
+		return
+	}
+
+	// In a group everyone sees the question there; in a private lobby each member gets it in their own chat
+	if lobby.GroupChatID.Valid {
+		h.sendRemovalApproval(lobby.GroupChatID.Int64, handler.getTranslator(userID), lobby, memberID)
+		if message.Chat.ID == lobby.GroupChatID.Int64 {
+			return
+		}
+	} else {
+		for _, approverID := range lobby.MemberIDs() {
+			if approverID != userID {
+				// A private chat's ID is the user's Telegram ID
+				h.sendRemovalApproval(approverID, handler.getTranslator(approverID), lobby, memberID)
+			}
+		}
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "remove_member_requested", h.getMemberName(lobby, memberID))
+}
+
+// sendRemovalApproval asks in a chat to approve or reject the owner's request to remove a member. The owner is
+// in the approval so that it no longer applies once someone else owns the lobby.
+func (h *Handler) sendRemovalApproval(chatID int64, translator *i18n.Translator, lobby *database.Lobby, memberID int64) {
+	name := h.getMemberName(lobby, memberID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translator.T("remove_member_button", name),
+				fmt.Sprintf("member_remove:%d:%d:%d", lobby.ID, memberID, lobby.OwnerTelegramID)),
+			tgbotapi.NewInlineKeyboardButtonData(translator.T("remove_member_cancel_button"),
+				fmt.Sprintf("member_remove_cancel:%d:%d", lobby.ID, memberID)),
+		),
+	)
+	msg := translator.T("remove_member_confirm", h.getMemberName(lobby, lobby.OwnerTelegramID), name, lobbyLabel(lobby, translator))
+	h.sendMessageWithKeyboard(chatID, msg, keyboard)
+}
+
+// parseRemovalCallback returns the IDs after the prefix of a member removal callback, or nil if one is not a number
+func parseRemovalCallback(data string) []int64 {
+	_, payload, _ := strings.Cut(data, ":")
+	var ids []int64
+	for _, part := range strings.Split(payload, ":") {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// handleRemoveMemberCallback removes a member once another member approves it with the button
+func (h *Handler) handleRemoveMemberCallback(handler *Handler, query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+	translator := handler.getTranslator(userID)
+
+	ids := parseRemovalCallback(query.Data)
+	if len(ids) != 3 {
+		handler.answerCallback(query.ID, "")
+		return
+	}
+	lobbyID, memberID, ownerID := ids[0], ids[1], ids[2]
+
+	lobby, err := handler.lobbyService.GetLobbyByID(lobbyID)
+	if err != nil || lobby == nil {
+		handler.answerCallback(query.ID, translator.T("error_lobby_not_found"))
+		return
+	}
+
+	name := h.getMemberName(lobby, memberID)
+	err = handler.lobbyService.RemoveMember(lobby.ID, ownerID, memberID, userID)
+	switch {
+	case errors.Is(err, service.ErrRemovalNotApproved):
+		handler.answerCallback(query.ID, translator.T("remove_member_not_approver"))
+		return
+	case errors.Is(err, service.ErrNotLobbyOwner):
+		handler.answerCallback(query.ID, translator.T("remove_member_outdated"))
+		return
+	case errors.Is(err, service.ErrNotMember):
+		handler.answerCallback(query.ID, translator.T("remove_member_gone", name))
+		return
+	case err != nil:
+		handler.answerCallback(query.ID, translator.T("members_error", err))
+		return
+	}
+
+	handler.answerCallback(query.ID, "")
+	if query.Message != nil {
+		handler.editMessage(query.Message.Chat.ID, query.Message.MessageID, translator.T("remove_member_done", name))
+	}
+	approver := h.getMemberName(lobby, userID)
+	if !lobby.GroupChatID.Valid {
+		ownerTranslator := handler.getTranslator(ownerID)
+		handler.sendMessage(ownerID, ownerTranslator.T("remove_member_approved_notice", approver, name, lobbyLabel(lobby, ownerTranslator)))
+	}
+	if memberID != userID {
+		memberTranslator := handler.getTranslator(memberID)
+		handler.sendMessage(memberID, memberTranslator.T("remove_member_notice", lobbyLabel(lobby, memberTranslator)))
+	}
+}
+
+// handleRemoveMemberCancel rejects a pending member removal; any member can, the owner withdrawing it too
+func (h *Handler) handleRemoveMemberCancel(handler *Handler, query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+	translator := handler.getTranslator(userID)
+
+	ids := parseRemovalCallback(query.Data)
+	if len(ids) != 2 {
+		handler.answerCallback(query.ID, "")
+		return
+	}
+	lobby, err := handler.lobbyService.GetLobbyByID(ids[0])
+	if err != nil || lobby == nil || !lobby.IsMember(userID) {
+		handler.answerCallback(query.ID, translator.T("error_lobby_not_found"))
+		return
+	}
+
+	name, rejecter := h.getMemberName(lobby, ids[1]), h.getMemberName(lobby, userID)
+	handler.answerCallback(query.ID, "")
+	if query.Message != nil {
+		handler.editMessage(query.Message.Chat.ID, query.Message.MessageID, translator.T("remove_member_cancelled", rejecter, name))
+	}
+	if !lobby.GroupChatID.Valid && userID != lobby.OwnerTelegramID {
+		ownerTranslator := handler.getTranslator(lobby.OwnerTelegramID)
+		handler.sendMessage(lobby.OwnerTelegramID,
+			ownerTranslator.T("remove_member_rejected_notice", rejecter, name, lobbyLabel(lobby, ownerTranslator)))
+	}
+}
+
+// handleTransferOwner handles the /transfer_owner command: gives the lobby to another member
+func (h *Handler) handleTransferOwner(handler *Handler, message *tgbotapi.Message, args string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+	if lobby.OwnerTelegramID != userID {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_not_lobby_owner")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) != 1 || !isSpenderArg(argsParts[0]) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "transfer_owner_usage")
+		return
+	}
+	newOwnerID, errKey := h.resolveSpender(lobby, userID, argsParts[0])
+	if errKey != "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, errKey)
+		return
+	}
+
+	if err := handler.lobbyService.TransferOwnership(lobby.ID, userID, newOwnerID); err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "members_error", err)
+		return
+	}
+
+	label := lobbyLabel(lobby, translator)
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "transfer_owner_done", h.getMemberName(lobby, newOwnerID), label)
+	if !lobby.GroupChatID.Valid {
+		// A private chat's ID is the user's Telegram ID
+		newOwnerTranslator := handler.getTranslator(newOwnerID)
+		handler.sendMessage(newOwnerID, newOwnerTranslator.T("transfer_owner_notice",
+			h.getMemberName(lobby, userID), lobbyLabel(lobby, newOwnerTranslator)))
+	}
+}
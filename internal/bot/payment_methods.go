@@ -1,8 +1,17 @@
 package bot
 
 import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -49,11 +58,28 @@ func (h *Handler) handlePaymentMethods(handler *Handler, message *tgbotapi.Messa
 				status = "❌"
 			}
 			item := translator.T("payment_method_item", status, method.Name, method.Type)
+			if credit, err := handler.creditStatus(method); err != nil {
+				log.Printf("Error computing credit of payment method %d: %v", method.ID, err)
+				item += translator.T("payment_method_credit_limit", method.CreditLimit.String())
+			} else if credit != nil {
+				item += translator.T("payment_method_credit", credit.Available.String(), credit.Limit.String())
+			}
 			if method.ClosingDay.Valid {
 				item += translator.T("payment_method_closing", method.ClosingDay.Int64)
+				if method.ClosingShift == string(utils.ShiftBefore) {
+					item += translator.T("payment_method_shift_before")
+				} else if method.ClosingShift == string(utils.ShiftAfter) {
+					item += translator.T("payment_method_shift_after")
+				}
+			}
+			if method.DueOffsetDays.Valid {
+				item += translator.T("payment_method_due_offset", method.DueOffsetDays.Int64)
+			} else if method.DueDay.Valid {
+				item += translator.T("payment_method_due_day", method.DueDay.Int64)
 			}
 			if method.OwnerTelegramID.Valid {
-				item += translator.T("payment_method_owner", method.OwnerTelegramID.Int64)
+				item += translator.T("payment_method_owner",
+					handler.getUserDisplayName(method.OwnerTelegramID.Int64, fmt.Sprint(method.OwnerTelegramID.Int64)))
 			}
 			items = append(items, item)
 		}
@@ -70,6 +96,14 @@ func (h *Handler) handlePaymentMethods(handler *Handler, message *tgbotapi.Messa
 		h.handleEditPaymentMethod(handler, message, argsParts[1:])
 	case "delete", "remove":
 		h.handleDeletePaymentMethod(handler, message, argsParts[1:])
+	case "cycle", "cycles", "ciclo", "cierre":
+		h.handlePaymentMethodCycle(handler, message, lobby.ID, argsParts[1:])
+	case "holidays", "holiday", "feriados", "feriado":
+		h.handleHolidays(handler, message, lobby.ID, argsParts[1:])
+	case "limit", "limite", "límite":
+		h.handleCreditLimit(handler, message, lobby.ID, argsParts[1:])
+	case "default", "defaults", "predeterminado":
+		h.handleDefaultPaymentMethod(handler, message, lobby.ID, argsParts[1:])
 	default:
 		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_unknown_action")
 	}
@@ -110,6 +144,17 @@ func (h *Handler) handleAddPaymentMethod(handler *Handler, message *tgbotapi.Mes
 		return
 	}
 
+	// Parse due date if provided: a day of the month ("5") or days after closing ("+10")
+	var dueDay, dueOffset *int64
+	if len(args) >= 4 {
+		var ok bool
+		dueDay, dueOffset, ok = parseDueDateArg(args[3])
+		if !ok {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_due_invalid")
+			return
+		}
+	}
+
 	method, err := handler.paymentMethodService.CreatePaymentMethod(
 		lobbyID, name, methodType, ownerID, closingDay)
 	if err != nil {
@@ -117,10 +162,22 @@ func (h *Handler) handleAddPaymentMethod(handler *Handler, message *tgbotapi.Mes
 		return
 	}
 
+	if dueDay != nil || dueOffset != nil {
+		if err := handler.paymentMethodService.SetDueDate(method.ID, dueDay, dueOffset); err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_add_error", err)
+			return
+		}
+	}
+
 	msg := translator.T("payment_method_added", method.Name)
 	if method.ClosingDay.Valid {
 		msg += translator.T("payment_method_closing_day", method.ClosingDay.Int64)
 	}
+	if dueOffset != nil {
+		msg += translator.T("payment_method_due_offset_line", *dueOffset)
+	} else if dueDay != nil {
+		msg += translator.T("payment_method_due_day_line", *dueDay)
+	}
 	handler.sendMessage(message.Chat.ID, msg)
 }
 
@@ -178,6 +235,93 @@ func (h *Handler) handleEditPaymentMethod(handler *Handler, message *tgbotapi.Me
 		}
 		closingDay = &cd
 
+	case "due_day", "due", "vencimiento":
+		if len(args) < 3 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_edit_usage")
+			return
+		}
+		var dueDay, dueOffset *int64
+		if value := strings.ToLower(args[2]); value != "none" && value != "off" && value != "ninguno" {
+			var ok bool
+			dueDay, dueOffset, ok = parseDueDateArg(args[2])
+			if !ok {
+				handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_due_invalid")
+				return
+			}
+		}
+		if err := handler.paymentMethodService.SetDueDate(id, dueDay, dueOffset); err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_update_error", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_updated")
+		return
+
+	case "closing_shift", "shift":
+		if len(args) < 3 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_edit_usage")
+			return
+		}
+		shift, ok := parseClosingShiftArg(args[2])
+		if !ok {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_shift_invalid")
+			return
+		}
+		moved, err := handler.paymentMethodService.SetClosingShift(id, shift)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_update_error", err)
+			return
+		}
+		translator := handler.getTranslator(userID)
+		handler.sendMessage(message.Chat.ID, translator.T("payment_method_updated")+translator.T("payment_method_cycle_moved", moved))
+		return
+
+	case "owner", "titular":
+		if len(args) < 3 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_edit_usage")
+			return
+		}
+		lobby, err := handler.getLobbyForMessage(message)
+		if err != nil || lobby == nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+			return
+		}
+		var owner *int64
+		switch strings.ToLower(args[2]) {
+		case "shared", "none", "compartida", "compartido":
+		case "me", "yo":
+			owner = &userID
+		default:
+			ownerID, errKey := h.resolveSpender(lobby, userID, args[2])
+			if errKey != "" {
+				handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_owner_invalid")
+				return
+			}
+			owner = &ownerID
+		}
+		if err := handler.paymentMethodService.SetOwner(id, owner); err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_update_error", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_updated")
+		return
+
+	case "due_offset":
+		if len(args) < 3 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_edit_usage")
+			return
+		}
+		_, dueOffset, ok := parseDueDateArg("+" + strings.TrimPrefix(args[2], "+"))
+		if !ok {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_due_invalid")
+			return
+		}
+		if err := handler.paymentMethodService.SetDueDate(id, nil, dueOffset); err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_update_error", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_updated")
+		return
+
 	case "active":
 		if len(args) < 3 {
 			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_edit_usage")
@@ -227,3 +371,446 @@ func (h *Handler) handleDeletePaymentMethod(handler *Handler, message *tgbotapi.
 
 	handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_deleted")
 }
+
+// handlePaymentMethodCycle handles /payment_methods cycle <name> [<YYYY-MM> <closing_date>|clear [due_date]]:
+// lists or sets the closing dates the bank published for specific statements
+func (h *Handler) handlePaymentMethodCycle(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	if len(args) != 1 && len(args) != 3 && len(args) != 4 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_cycle_usage")
+		return
+	}
+
+	methods, err := handler.paymentMethodService.GetPaymentMethodsByLobby(lobbyID, false)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	var paymentMethod *database.PaymentMethod
+	for _, method := range methods {
+		if strings.EqualFold(method.Name, args[0]) {
+			paymentMethod = method
+			break
+		}
+	}
+	if paymentMethod == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_not_found", args[0])
+		return
+	}
+	if !paymentMethod.ClosingDay.Valid {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_billing_no_cycle")
+		return
+	}
+
+	if len(args) == 1 {
+		cycles, err := handler.paymentMethodService.GetBillingCycles(paymentMethod.ID)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		if len(cycles) == 0 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_cycles_none",
+				paymentMethod.Name, paymentMethod.ClosingDay.Int64)
+			return
+		}
+		msg := translator.T("payment_method_cycles_header", paymentMethod.Name, paymentMethod.ClosingDay.Int64)
+		for _, cycle := range cycles {
+			msg += translator.T("payment_method_cycle_item", cycle.Month, utils.FormatDate(cycle.ClosingDate))
+			if cycle.DueDate.Valid {
+				msg += translator.T("payment_method_cycle_item_due", utils.FormatDate(cycle.DueDate.Time))
+			}
+			msg += "\n"
+		}
+		handler.sendMessage(message.Chat.ID, msg)
+		return
+	}
+
+	month, err := utils.ParseMonth(args[1])
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_invalid_period")
+		return
+	}
+
+	switch strings.ToLower(args[2]) {
+	case "clear", "reset", "delete", "borrar":
+		moved, err := handler.paymentMethodService.DeleteBillingCycle(paymentMethod.ID, month)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_update_error", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_cycle_cleared",
+			paymentMethod.Name, utils.FormatMonth(month), paymentMethod.ClosingDay.Int64, moved)
+		return
+	}
+
+	closingDate, err := utils.ParseDate(args[2])
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_cycle_usage")
+		return
+	}
+	var dueDate *time.Time
+	if len(args) == 4 {
+		due, err := utils.ParseDate(args[3])
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_cycle_usage")
+			return
+		}
+		dueDate = &due
+	}
+
+	moved, err := handler.paymentMethodService.SetBillingCycle(paymentMethod.ID, month, closingDate, dueDate)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_update_error", err)
+		return
+	}
+
+	msg := translator.T("payment_method_cycle_set", paymentMethod.Name, utils.FormatMonth(month), utils.FormatDate(closingDate))
+	if dueDate != nil {
+		msg += translator.T("payment_method_cycle_set_due", utils.FormatDate(*dueDate))
+	}
+	msg += translator.T("payment_method_cycle_moved", moved)
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleCreditLimit handles /payment_methods limit <name> [<amount>|none] [warn%]: shows or sets a card's
+// credit limit and the usage that warns when adding expenses
+func (h *Handler) handleCreditLimit(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	if len(args) < 1 || len(args) > 3 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_limit_usage")
+		return
+	}
+
+	methods, err := handler.paymentMethodService.GetPaymentMethodsByLobby(lobbyID, false)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	var paymentMethod *database.PaymentMethod
+	for _, method := range methods {
+		if strings.EqualFold(method.Name, args[0]) {
+			paymentMethod = method
+			break
+		}
+	}
+	if paymentMethod == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_not_found", args[0])
+		return
+	}
+	if !paymentMethod.ClosingDay.Valid {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_billing_no_cycle")
+		return
+	}
+
+	if len(args) == 1 {
+		credit, err := handler.creditStatus(paymentMethod)
+		if err != nil {
+			handler.sendConversionError(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		if credit == nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_limit_none", paymentMethod.Name)
+			return
+		}
+		handler.sendMessage(message.Chat.ID, formatCreditStatus(credit, translator))
+		return
+	}
+
+	var limit *utils.Money
+	switch strings.ToLower(args[1]) {
+	case "none", "off", "clear", "ninguno":
+	default:
+		amount, err := utils.ParseMoney(args[1])
+		if err != nil || !amount.IsPositive() {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_limit_usage")
+			return
+		}
+		limit = &amount
+	}
+
+	var warnPercent *int64
+	if len(args) == 3 {
+		percent, err := strconv.ParseInt(strings.TrimSuffix(args[2], "%"), 10, 64)
+		if err != nil || percent < 1 || percent > 100 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_limit_warn_invalid")
+			return
+		}
+		warnPercent = &percent
+	}
+
+	if err := handler.paymentMethodService.SetCreditLimit(paymentMethod.ID, limit, warnPercent); err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_update_error", err)
+		return
+	}
+	if limit == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_limit_cleared", paymentMethod.Name)
+		return
+	}
+
+	paymentMethod, err = handler.paymentMethodService.GetPaymentMethodByID(paymentMethod.ID)
+	if err != nil || paymentMethod == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_updated")
+		return
+	}
+	msg := translator.T("payment_method_limit_set", paymentMethod.Name, paymentMethod.CreditLimit.String(), paymentMethod.CreditWarnPercent)
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// creditStatus returns how much of a card's credit limit is in use, or nil when it has no limit
+func (h *Handler) creditStatus(method *database.PaymentMethod) (*service.CreditStatus, error) {
+	if !method.HasCreditLimit() || !method.ClosingDay.Valid {
+		return nil, nil
+	}
+	converter, err := h.exchangeRateService.NewConverter(method.LobbyID)
+	if err != nil {
+		return nil, err
+	}
+	return h.expenseService.CreditStatus(method, converter, time.Now())
+}
+
+// creditWarning returns the warning for a new expense that took its card's usage to the card's warning
+// percentage, or an empty string
+func (h *Handler) creditWarning(paymentMethodID int64, translator *i18n.Translator) string {
+	method, err := h.paymentMethodService.GetPaymentMethodByID(paymentMethodID)
+	if err != nil || method == nil {
+		return ""
+	}
+	credit, err := h.creditStatus(method)
+	if err != nil {
+		log.Printf("Error computing credit of payment method %d: %v", method.ID, err)
+		return ""
+	}
+	if credit == nil || !credit.Warn() {
+		return ""
+	}
+	if credit.Available.IsNegative() {
+		return translator.T("expense_credit_exceeded", method.Name, credit.Available.Abs().String(), credit.Limit.String())
+	}
+	return translator.T("expense_credit_warning", method.Name, credit.Percent, credit.Available.String(), credit.Limit.String())
+}
+
+// formatCreditStatus formats how much of a card's credit limit is in use
+func formatCreditStatus(credit *service.CreditStatus, translator *i18n.Translator) string {
+	msg := translator.T("payment_method_limit_status", credit.Method.Name, credit.Limit.String(),
+		credit.Outstanding.String(), credit.Percent, credit.Available.String(), credit.Method.CreditWarnPercent)
+	if credit.Available.IsNegative() {
+		msg += translator.T("payment_method_limit_exceeded", credit.Available.Abs().String())
+	}
+	return msg
+}
+
+// handleDefaultPaymentMethod handles /payment_methods default [<name>|none] [category]: lists or sets the
+// payment method the user's expenses get when they don't name one, for any category or for one
+func (h *Handler) handleDefaultPaymentMethod(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	if len(args) == 0 {
+		defaults, err := handler.paymentMethodService.GetDefaultPaymentMethods(lobbyID, userID)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		if len(defaults) == 0 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_defaults_none")
+			return
+		}
+		msg := translator.T("payment_method_defaults_header")
+		for _, def := range defaults {
+			if def.CategoryID.Valid {
+				msg += translator.T("payment_method_defaults_category_item", def.CategoryName, def.PaymentMethodName)
+			} else {
+				msg += translator.T("payment_method_defaults_item", def.PaymentMethodName)
+			}
+		}
+		handler.sendMessage(message.Chat.ID, msg)
+		return
+	}
+
+	// An optional category follows the payment method name
+	var categoryID *int64
+	categoryName := ""
+	if len(args) > 1 {
+		name, isNew, ok := handler.resolveCategoryArg(lobbyID, userID, message.Chat.ID, strings.Join(args[1:], " "))
+		if !ok {
+			return
+		}
+		category, err := handler.categoryService.GetCategoryByName(lobbyID, name)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		if isNew || category == nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "category_not_found", fmt.Errorf("%w: %s", service.ErrCategoryNotFound, name))
+			return
+		}
+		categoryID = &category.ID
+		categoryName = category.Name
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "none", "off", "clear", "ninguno":
+		err := handler.paymentMethodService.ClearDefaultPaymentMethod(lobbyID, userID, categoryID)
+		switch {
+		case errors.Is(err, service.ErrDefaultNotFound):
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_default_not_set")
+		case err != nil:
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_update_error", err)
+		case categoryID != nil:
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_default_category_cleared", categoryName)
+		default:
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_default_cleared")
+		}
+		return
+	}
+
+	paymentMethod := handler.findPaymentMethod(lobbyID, args[0], true)
+	if paymentMethod == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_not_found", args[0])
+		return
+	}
+
+	if err := handler.paymentMethodService.SetDefaultPaymentMethod(lobbyID, userID, categoryID, paymentMethod.ID); err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_update_error", err)
+		return
+	}
+	if categoryID != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_default_category_set", paymentMethod.Name, categoryName)
+		return
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_default_set", paymentMethod.Name)
+}
+
+// findPaymentMethod finds a payment method of a lobby by name, ignoring case, or returns nil
+func (h *Handler) findPaymentMethod(lobbyID int64, name string, activeOnly bool) *database.PaymentMethod {
+	methods, err := h.paymentMethodService.GetPaymentMethodsByLobby(lobbyID, activeOnly)
+	if err != nil {
+		return nil
+	}
+	for _, method := range methods {
+		if strings.EqualFold(method.Name, name) {
+			return method
+		}
+	}
+	return nil
+}
+
+// isPaymentMethodArg reports whether an argument names an active payment method of the lobby rather than a category
+func (h *Handler) isPaymentMethodArg(lobbyID int64, arg string) bool {
+	if h.findPaymentMethod(lobbyID, arg, true) == nil {
+		return false
+	}
+	category, err := h.categoryService.GetCategoryByName(lobbyID, arg)
+	return err == nil && category == nil
+}
+
+// defaultPaymentMethodFor returns the default payment method of a member for a category name, or nil
+func (h *Handler) defaultPaymentMethodFor(lobbyID int64, userID int64, categoryName string) *database.PaymentMethodDefault {
+	var categoryID sql.NullInt64
+	if categoryName != "" {
+		if category, err := h.categoryService.GetCategoryByName(lobbyID, categoryName); err == nil && category != nil {
+			categoryID = sql.NullInt64{Int64: category.ID, Valid: true}
+		}
+	}
+	def, err := h.paymentMethodService.DefaultPaymentMethod(lobbyID, userID, categoryID)
+	if err != nil {
+		log.Printf("Error getting default payment method of user %d: %v", userID, err)
+		return nil
+	}
+	return def
+}
+
+// formatDefaultApplied formats the note saying which default payment method a new expense got
+func formatDefaultApplied(def *database.PaymentMethodDefault, translator *i18n.Translator) string {
+	if def.CategoryID.Valid {
+		return translator.T("expense_default_category_applied", def.PaymentMethodName, def.CategoryName)
+	}
+	return translator.T("expense_default_applied", def.PaymentMethodName)
+}
+
+// handleHolidays handles /payment_methods holidays [add <date> [name] | delete <date>]: the lobby's
+// non-business days, to which closing days set to shift don't fall
+func (h *Handler) handleHolidays(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	if len(args) == 0 {
+		holidays, err := handler.paymentMethodService.GetHolidays(lobbyID)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		if len(holidays) == 0 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "holidays_none")
+			return
+		}
+		msg := translator.T("holidays_header")
+		for _, holiday := range holidays {
+			msg += translator.T("holidays_item", utils.FormatDate(holiday.Date), holiday.Name.String)
+		}
+		handler.sendMessage(message.Chat.ID, msg)
+		return
+	}
+
+	if len(args) < 2 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "holidays_usage")
+		return
+	}
+	date, err := utils.ParseDate(args[1])
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "holidays_usage")
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add", "agregar":
+		moved, err := handler.paymentMethodService.AddHoliday(lobbyID, date, strings.Join(args[2:], " "))
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "holiday_added", utils.FormatDate(date), moved)
+	case "delete", "remove", "borrar":
+		moved, err := handler.paymentMethodService.DeleteHoliday(lobbyID, date)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+			return
+		}
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "holiday_deleted", utils.FormatDate(date), moved)
+	default:
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "holidays_usage")
+	}
+}
+
+// parseClosingShiftArg parses where a closing on a weekend or holiday moves to
+func parseClosingShiftArg(arg string) (utils.ClosingShift, bool) {
+	switch utils.FoldText(arg) {
+	case "none", "off", "ninguno", "no":
+		return utils.ShiftNone, true
+	case "before", "antes", "previous", "anterior":
+		return utils.ShiftBefore, true
+	case "after", "despues", "next", "siguiente":
+		return utils.ShiftAfter, true
+	}
+	return "", false
+}
+
+// parseDueDateArg parses a statement due date: a day of the month ("5") or days after the closing ("+10")
+func parseDueDateArg(arg string) (dueDay *int64, offsetDays *int64, ok bool) {
+	if strings.HasPrefix(arg, "+") {
+		days, err := strconv.ParseInt(arg[1:], 10, 64)
+		if err != nil || days < 1 || days > 60 {
+			return nil, nil, false
+		}
+		return nil, &days, true
+	}
+	day, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || day < 1 || day > 31 {
+		return nil, nil, false
+	}
+	return &day, nil, true
+}
@@ -0,0 +1,357 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// quickCaptureMaxWords is the longest message considered an expense; longer ones are regular chat
+const quickCaptureMaxWords = 8
+
+// registerQuickCaptureCallbacks registers the buttons of free-text expense confirmations
+func (h *Handler) registerQuickCaptureCallbacks() {
+	h.router.RegisterCallback("qc_undo", h.handleQuickCaptureUndo)
+	h.router.RegisterCallback("qc_edit", h.handleQuickCaptureEdit)
+	h.router.RegisterCallback("qc_add", h.handleQuickCaptureAdd)
+	h.router.RegisterCallback("qc_ignore", h.handleQuickCaptureIgnore)
+}
+
+// quickExpense is an expense parsed from a plain chat message
+type quickExpense struct {
+	Amount          utils.Money
+	Description     string
+	Category        string
+	PaymentMethodID *int64
+	Date            time.Time
+	Unsure          bool // Only a number marks it as an expense ("2 personas"), so it is confirmed before adding
+}
+
+// parseQuickExpense parses messages like "1500 super visa" or "ayer 3200 nafta".
+// The amount must be the first word, or come right after a date word, so ordinary chat is not taken for an expense.
+// The other words are matched against date words, currencies, the lobby's payment methods and known categories;
+// every word but dates, currencies and payment methods makes up the description.
+// Messages without a currency, decimals, payment method or category are parsed as Unsure.
+func parseQuickExpense(text string, categories []string, methods []*database.PaymentMethod, now time.Time) (*quickExpense, bool) {
+	words := strings.Fields(text)
+	if len(words) > quickCaptureMaxWords {
+		return nil, false
+	}
+
+	parsed := &quickExpense{Date: now}
+	hasDate := false
+	if len(words) > 0 {
+		if date, ok := utils.ParseDateWord(words[0], now); ok {
+			parsed.Date = date
+			hasDate = true
+			words = words[1:]
+		}
+	}
+
+	// A bare number is too likely to be an answer to something else
+	if len(words) < 2 {
+		return nil, false
+	}
+	amount, err := utils.ParseMoney(words[0])
+	if err != nil || !amount.IsPositive() {
+		return nil, false
+	}
+	parsed.Amount = amount
+
+	var description []string
+	for _, word := range words[1:] {
+		if !hasDate {
+			if date, ok := utils.ParseDateWord(word, now); ok {
+				parsed.Date = date
+				hasDate = true
+				continue
+			}
+		}
+		if code := utils.NormalizeCurrency(word); code != "" && parsed.Amount.Currency == "" {
+			parsed.Amount = parsed.Amount.WithCurrency(code)
+			continue
+		}
+		if parsed.PaymentMethodID == nil {
+			if method := matchPaymentMethod(word, methods); method != nil {
+				parsed.PaymentMethodID = &method.ID
+				continue
+			}
+		}
+		if parsed.Category == "" {
+			parsed.Category = matchCategory(word, categories)
+		}
+		description = append(description, word)
+	}
+
+	parsed.Description = strings.Join(description, " ")
+	parsed.Unsure = amount.Currency == "" && parsed.Amount.Currency == "" && !strings.ContainsAny(words[0], ".,") &&
+		parsed.PaymentMethodID == nil && parsed.Category == ""
+	return parsed, true
+}
+
+// matchPaymentMethod finds the payment method a word refers to: its exact name,
+// or the only method with that word in its name ("galicia" for "Visa Galicia")
+func matchPaymentMethod(word string, methods []*database.PaymentMethod) *database.PaymentMethod {
+	var match *database.PaymentMethod
+	matches := 0
+	for _, method := range methods {
+		if strings.EqualFold(method.Name, word) {
+			return method
+		}
+		for _, part := range strings.Fields(method.Name) {
+			if strings.EqualFold(part, word) {
+				match = method
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 1 {
+		return match
+	}
+	return nil
+}
+
+// matchCategory finds the category a word refers to: its exact name,
+// or the only category starting with the word ("super" for "Supermercado"). Returns "" when none matches.
+func matchCategory(word string, categories []string) string {
+	lower := strings.ToLower(word)
+	match := ""
+	matches := 0
+	for _, category := range categories {
+		if strings.EqualFold(category, word) {
+			return category
+		}
+		if len(lower) >= 3 && strings.HasPrefix(strings.ToLower(category), lower) {
+			match = category
+			matches++
+		}
+	}
+	if matches == 1 {
+		return match
+	}
+	return ""
+}
+
+// handleQuickCapture creates an expense from a plain chat message that looks like one,
+// when the lobby has quick capture enabled. Anything else is ignored silently.
+// Messages that may not be expenses ask for confirmation first.
+func (h *Handler) handleQuickCapture(message *tgbotapi.Message) {
+	if message.From == nil || message.Text == "" || message.ForwardDate != 0 {
+		return
+	}
+	userID := message.From.ID
+
+	lobby, err := h.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		return
+	}
+
+	enabled, err := h.lobbyService.IsQuickCaptureEnabled(lobby.ID)
+	if err != nil || !enabled {
+		return
+	}
+
+	parsed, force, ok := h.parseQuickCapture(lobby.ID, message.Text)
+	if !ok {
+		return
+	}
+	if parsed.Unsure {
+		h.askQuickCaptureConfirm(message, parsed)
+		return
+	}
+	h.addQuickExpense(userID, message.Chat.ID, 0, lobby, parsed, force, message.Text)
+}
+
+// parseQuickCapture parses a plain chat message as an expense of a lobby. A trailing "confirm" sets force,
+// to add the expense even to a reconciled statement.
+func (h *Handler) parseQuickCapture(lobbyID int64, text string) (*quickExpense, bool, bool) {
+	categories, err := h.expenseService.GetCategoryNames(lobbyID)
+	if err != nil {
+		log.Printf("Error loading categories for quick capture: %v", err)
+		return nil, false, false
+	}
+	methods, err := h.paymentMethodService.GetPaymentMethodsByLobby(lobbyID, true)
+	if err != nil {
+		log.Printf("Error loading payment methods for quick capture: %v", err)
+		return nil, false, false
+	}
+
+	words, force := stripConfirm(strings.Fields(text))
+	parsed, ok := parseQuickExpense(strings.Join(words, " "), categories, methods, time.Now())
+	return parsed, force, ok
+}
+
+// addQuickExpense adds a captured expense and confirms it with Undo/Edit buttons, in place of the message
+// asking to add it when confirmID is not zero
+func (h *Handler) addQuickExpense(userID, chatID int64, confirmID int, lobby *database.Lobby, parsed *quickExpense, force bool, text string) {
+	expense, err := h.expenseService.CreateExpense(
+		lobby.ID,
+		userID,
+		parsed.Amount,
+		parsed.Description,
+		parsed.Category,
+		parsed.Date,
+		parsed.PaymentMethodID,
+		nil,
+		force,
+	)
+	if errors.Is(err, service.ErrStatementReconciled) {
+		h.askReconciledConfirm(userID, chatID, nil, text)
+		return
+	}
+	if err != nil {
+		h.sendTranslatedMessage(userID, chatID, "expense_add_error", err)
+		return
+	}
+
+	translator := h.getTranslator(userID)
+	msg := h.formatExpenseAdded(expense, translator)
+	msg += translator.T("quick_capture_date", utils.FormatDate(expense.ExpenseDate))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translator.T("quick_capture_undo"), fmt.Sprintf("qc_undo:%d", expense.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(translator.T("quick_capture_edit"), fmt.Sprintf("qc_edit:%d", expense.ID)),
+		),
+	)
+	if confirmID != 0 {
+		h.editMessageWithKeyboard(chatID, confirmID, msg, keyboard)
+		return
+	}
+	h.sendMessageWithKeyboard(chatID, msg, keyboard)
+}
+
+// askQuickCaptureConfirm asks whether a message that may not be an expense is one, replying to it so the
+// buttons can read it again
+func (h *Handler) askQuickCaptureConfirm(message *tgbotapi.Message, parsed *quickExpense) {
+	translator := h.getTranslator(message.From.ID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translator.T("quick_capture_add"), "qc_add"),
+			tgbotapi.NewInlineKeyboardButtonData(translator.T("quick_capture_ignore"), "qc_ignore"),
+		),
+	)
+	h.replyWithKeyboard(message, translator.T("quick_capture_confirm", parsed.Amount.String(), parsed.Description), keyboard)
+}
+
+// quickCaptureMessage returns the message a quick capture confirmation replied to, answering the callback
+// when it is gone or someone other than its author pressed the button
+func (h *Handler) quickCaptureMessage(query *tgbotapi.CallbackQuery, translator *i18n.Translator) *tgbotapi.Message {
+	if query.Message == nil || query.Message.ReplyToMessage == nil || query.Message.ReplyToMessage.From == nil {
+		h.answerCallback(query.ID, translator.T("quick_capture_gone"))
+		return nil
+	}
+	original := query.Message.ReplyToMessage
+	if original.From.ID != query.From.ID {
+		h.answerCallback(query.ID, translator.T("quick_capture_not_yours"))
+		return nil
+	}
+	return original
+}
+
+// handleQuickCaptureAdd adds the expense of a message once its author confirms it is one
+func (h *Handler) handleQuickCaptureAdd(handler *Handler, query *tgbotapi.CallbackQuery) {
+	translator := handler.getTranslator(query.From.ID)
+
+	original := handler.quickCaptureMessage(query, translator)
+	if original == nil {
+		return
+	}
+	lobby, err := handler.getLobbyForCallback(query)
+	if err != nil || lobby == nil {
+		handler.answerCallback(query.ID, translator.T("error_lobby_not_found"))
+		return
+	}
+	parsed, force, ok := handler.parseQuickCapture(lobby.ID, original.Text)
+	if !ok {
+		handler.answerCallback(query.ID, translator.T("quick_capture_gone"))
+		return
+	}
+
+	handler.answerCallback(query.ID, "")
+	handler.addQuickExpense(query.From.ID, query.Message.Chat.ID, query.Message.MessageID, lobby, parsed, force, original.Text)
+}
+
+// handleQuickCaptureIgnore leaves a message that was not an expense alone
+func (h *Handler) handleQuickCaptureIgnore(handler *Handler, query *tgbotapi.CallbackQuery) {
+	translator := handler.getTranslator(query.From.ID)
+
+	original := handler.quickCaptureMessage(query, translator)
+	if original == nil {
+		return
+	}
+	handler.answerCallback(query.ID, "")
+	handler.editMessage(query.Message.Chat.ID, query.Message.MessageID, translator.T("quick_capture_ignored"))
+}
+
+// quickCaptureExpense returns the expense a quick capture button refers to,
+// or nil if it no longer exists or belongs to another lobby than the user's in that chat
+func (h *Handler) quickCaptureExpense(query *tgbotapi.CallbackQuery) *database.Expense {
+	_, payload, _ := strings.Cut(query.Data, ":")
+	expenseID, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	lobby, err := h.getLobbyForCallback(query)
+	if err != nil || lobby == nil {
+		return nil
+	}
+
+	expense, err := h.expenseService.GetExpenseByID(expenseID)
+	if err != nil || expense == nil || expense.LobbyID != lobby.ID {
+		return nil
+	}
+	return expense
+}
+
+// handleQuickCaptureUndo deletes a captured expense and strikes the confirmation
+func (h *Handler) handleQuickCaptureUndo(handler *Handler, query *tgbotapi.CallbackQuery) {
+	translator := handler.getTranslator(query.From.ID)
+
+	expense := handler.quickCaptureExpense(query)
+	if expense == nil {
+		handler.answerCallback(query.ID, translator.T("expense_delete_not_found"))
+		return
+	}
+
+	_, err := handler.expenseService.DeleteExpense(expense.ID, false)
+	if errors.Is(err, service.ErrStatementReconciled) {
+		handler.answerCallback(query.ID, "")
+		handler.askReconciledConfirm(query.From.ID, query.Message.Chat.ID, expense, fmt.Sprintf("/delete %d", expense.ID))
+		return
+	}
+	if err != nil {
+		handler.answerCallback(query.ID, translator.T("expense_delete_error", err))
+		return
+	}
+
+	msg := translator.T("quick_capture_undone", expense.Amount.String(), expense.Description.String)
+	handler.answerCallback(query.ID, msg)
+	handler.editMessage(query.Message.Chat.ID, query.Message.MessageID, msg)
+}
+
+// handleQuickCaptureEdit explains how to correct a captured expense
+func (h *Handler) handleQuickCaptureEdit(handler *Handler, query *tgbotapi.CallbackQuery) {
+	translator := handler.getTranslator(query.From.ID)
+
+	expense := handler.quickCaptureExpense(query)
+	if expense == nil {
+		handler.answerCallback(query.ID, translator.T("expense_edit_not_found"))
+		return
+	}
+
+	handler.answerCallback(query.ID, "")
+	handler.sendMessage(query.Message.Chat.ID,
+		translator.T("quick_capture_edit_hint", expense.ID, expense.ID, expense.ID, expense.ID, expense.ID))
+}
@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"testing"
+	"time"
+)
+
+func TestParseQuickExpense(t *testing.T) {
+	now := time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC) // Wednesday
+	methods := []*database.PaymentMethod{{ID: 1, Name: "Visa"}, {ID: 2, Name: "Master Galicia"}, {ID: 3, Name: "Visa Galicia"}}
+	categories := []string{"Supermercado", "Comida", "Salidas", "Salud"}
+
+	tests := []struct {
+		text        string
+		ok          bool
+		amount      string
+		description string
+		category    string
+		method      int64
+		date        string
+		unsure      bool
+	}{
+		{text: "1500 super visa", ok: true, amount: "1500.00", description: "super", category: "Supermercado", method: 1, date: "2026-10-14"},
+		{text: "ayer 3200 nafta", ok: true, amount: "3200.00", description: "nafta", date: "2026-10-13", unsure: true},
+		{text: "15/03 20usd taxi", ok: true, amount: "20.00 USD", description: "taxi", date: "2026-03-15"},
+		{text: "300 usd cena master", ok: true, amount: "300.00 USD", description: "cena", method: 2, date: "2026-10-14"},
+		{text: "lunes 100,50 sal", ok: true, amount: "100.50", description: "sal", date: "2026-10-12"},
+		{text: "1500 galicia regalo", ok: true, amount: "1500.00", description: "galicia regalo", date: "2026-10-14", unsure: true},
+		{text: "12.5 cafe", ok: true, amount: "12.50", description: "cafe", date: "2026-10-14"},
+		{text: "comida 1500", ok: false},
+		{text: "2 personas", ok: true, amount: "2.00", description: "personas", date: "2026-10-14", unsure: true},
+		{text: "10 minutos", ok: true, amount: "10.00", description: "minutos", date: "2026-10-14", unsure: true},
+		{text: "3 comidas visa", ok: true, amount: "3.00", description: "comidas", method: 1, date: "2026-10-14"},
+		{text: "1500", ok: false},
+		{text: "ayer 1500", ok: false},
+		{text: "hola 1500 super", ok: false},
+		{text: "llego en 10", ok: false},
+		{text: "0 super", ok: false},
+		{text: "1.234 super", ok: false},
+		{text: "1500 super visa con los chicos del trabajo el sabado", ok: false},
+		{text: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			parsed, ok := parseQuickExpense(tt.text, categories, methods, now)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if got := parsed.Amount.String(); got != tt.amount {
+				t.Errorf("amount = %s, want %s", got, tt.amount)
+			}
+			if parsed.Description != tt.description {
+				t.Errorf("description = %q, want %q", parsed.Description, tt.description)
+			}
+			if parsed.Category != tt.category {
+				t.Errorf("category = %q, want %q", parsed.Category, tt.category)
+			}
+			var method int64
+			if parsed.PaymentMethodID != nil {
+				method = *parsed.PaymentMethodID
+			}
+			if method != tt.method {
+				t.Errorf("payment method = %d, want %d", method, tt.method)
+			}
+			if got := parsed.Date.Format("2006-01-02"); got != tt.date {
+				t.Errorf("date = %s, want %s", got, tt.date)
+			}
+			if parsed.Unsure != tt.unsure {
+				t.Errorf("unsure = %v, want %v", parsed.Unsure, tt.unsure)
+			}
+		})
+	}
+}
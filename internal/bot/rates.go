@@ -0,0 +1,257 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerRateCommands registers exchange rate commands
+func (h *Handler) registerRateCommands() {
+	h.router.RegisterCommand("rate", h.handleRate)
+}
+
+// handleRate handles the /rate command
+func (h *Handler) handleRate(handler *Handler, message *tgbotapi.Message, args string) {
+	if message.From == nil {
+		handler.sendMessage(message.Chat.ID, "❌ Error: This command must be used by a user.")
+		return
+	}
+	userID := message.From.ID
+
+	// Get user's lobby for this specific chat (group/private)
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) == 0 {
+		h.handleListRates(handler, message, lobby)
+		return
+	}
+
+	switch strings.ToLower(argsParts[0]) {
+	case "base":
+		h.handleSetBaseCurrency(handler, message, lobby, argsParts[1:])
+	case "type":
+		h.handleSetRateType(handler, message, lobby, argsParts[1:])
+	case "delete", "remove":
+		h.handleDeleteRate(handler, message, lobby, argsParts[1:])
+	case "history":
+		if len(argsParts) < 2 {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_usage")
+			return
+		}
+		h.handleRateHistory(handler, message, lobby, argsParts[1])
+	default:
+		if len(argsParts) == 1 {
+			h.handleRateHistory(handler, message, lobby, argsParts[0])
+			return
+		}
+		h.handleSetRate(handler, message, lobby, argsParts)
+	}
+}
+
+// handleListRates shows the base currency and the latest rate of every currency and type
+func (h *Handler) handleListRates(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	baseCurrency, rateType, err := handler.lobbyService.GetCurrencySettings(lobby.ID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	rates, err := handler.exchangeRateService.GetLatestRates(lobby.ID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	msg := translator.T("rate_header", baseCurrency, rateType)
+	if len(rates) == 0 {
+		msg += translator.T("rate_none")
+	}
+	for _, rate := range rates {
+		msg += translator.T("rate_item",
+			rate.ID,
+			rate.Currency,
+			utils.FormatCurrency(rate.Rate),
+			rate.BaseCurrency,
+			rate.RateType,
+			utils.FormatDate(rate.RateDate))
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleRateHistory shows the most recent rates of a currency
+func (h *Handler) handleRateHistory(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, currencyArg string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	currency := utils.NormalizeCurrency(currencyArg)
+	if currency == "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_invalid_currency", currencyArg)
+		return
+	}
+
+	rates, err := handler.exchangeRateService.GetRates(lobby.ID, currency, 15)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	msg := translator.T("rate_history_header", currency)
+	if len(rates) == 0 {
+		msg += translator.T("rate_none")
+	}
+	for _, rate := range rates {
+		msg += translator.T("rate_item",
+			rate.ID,
+			rate.Currency,
+			utils.FormatCurrency(rate.Rate),
+			rate.BaseCurrency,
+			rate.RateType,
+			utils.FormatDate(rate.RateDate))
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleSetRate handles /rate <currency> <value> [type] [date]
+func (h *Handler) handleSetRate(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	currency := utils.NormalizeCurrency(args[0])
+	if currency == "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_invalid_currency", args[0])
+		return
+	}
+
+	value, err := strconv.ParseFloat(strings.Replace(args[1], ",", ".", 1), 64)
+	if err != nil || value <= 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_invalid_value")
+		return
+	}
+
+	// Type and date are both optional and may come in any order
+	rateType := ""
+	rateDate := time.Now()
+	for _, arg := range args[2:] {
+		if date, err := utils.ParseDate(arg); err == nil {
+			rateDate = date
+			continue
+		}
+		rateType = service.NormalizeRateType(arg)
+		if rateType == "" {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_invalid_type", arg, strings.Join(service.RateTypes, ", "))
+			return
+		}
+	}
+
+	rate, err := handler.exchangeRateService.SetRate(lobby.ID, currency, rateType, rateDate, value)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_error", err)
+		return
+	}
+
+	handler.sendMessage(message.Chat.ID, translator.T("rate_set",
+		rate.Currency,
+		utils.FormatCurrency(rate.Rate),
+		rate.BaseCurrency,
+		rate.RateType,
+		utils.FormatDate(rate.RateDate)))
+}
+
+// handleSetBaseCurrency handles /rate base <currency>
+func (h *Handler) handleSetBaseCurrency(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+
+	if len(args) < 1 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_usage")
+		return
+	}
+
+	currency := utils.NormalizeCurrency(args[0])
+	if currency == "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_invalid_currency", args[0])
+		return
+	}
+
+	if err := handler.lobbyService.UpdateCurrencySettings(lobby.ID, &currency, nil); err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_base_set", currency, currency)
+}
+
+// handleSetRateType handles /rate type <type>
+func (h *Handler) handleSetRateType(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+
+	if len(args) < 1 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_usage")
+		return
+	}
+
+	rateType := service.NormalizeRateType(args[0])
+	if rateType == "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_invalid_type", args[0], strings.Join(service.RateTypes, ", "))
+		return
+	}
+
+	if err := handler.lobbyService.UpdateCurrencySettings(lobby.ID, nil, &rateType); err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_type_set", rateType)
+}
+
+// handleDeleteRate handles /rate delete <id>
+func (h *Handler) handleDeleteRate(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+
+	if len(args) < 1 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_usage")
+		return
+	}
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_not_found")
+		return
+	}
+
+	err = handler.exchangeRateService.DeleteRate(lobby.ID, id)
+	if errors.Is(err, service.ErrExchangeRateNotFound) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_not_found")
+		return
+	}
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "rate_deleted", id)
+}
+
+// sendConversionError reports an error converting amounts to the base currency,
+// pointing to /rate when the problem is a missing exchange rate
+func (h *Handler) sendConversionError(userID, chatID int64, fallbackKey string, err error) {
+	if errors.Is(err, service.ErrExchangeRateNotFound) {
+		h.sendTranslatedMessage(userID, chatID, "rate_missing", err)
+		return
+	}
+	h.sendTranslatedMessage(userID, chatID, fallbackKey, err)
+}
@@ -0,0 +1,257 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerReconcileCommands registers statement reconciliation commands
+func (h *Handler) registerReconcileCommands() {
+	h.router.RegisterCommand("reconcile", h.handleReconcile)
+	h.router.RegisterCallback("reconcile_adjust", h.handleReconcileAdjust)
+}
+
+// handleReconcile handles /reconcile <card> <YYYY-MM> <total> [item amounts...]: compares a card statement
+// with the bank's total and marks it as reconciled
+func (h *Handler) handleReconcile(handler *Handler, message *tgbotapi.Message, args string) {
+	if message.From == nil {
+		handler.sendMessage(message.Chat.ID, "❌ Error: This command must be used by a user.")
+		return
+	}
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	// Get user's lobby for this specific chat (group/private)
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) < 3 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "reconcile_usage")
+		return
+	}
+
+	methods, err := handler.paymentMethodService.GetPaymentMethodsByLobby(lobby.ID, false)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	var paymentMethod *database.PaymentMethod
+	for _, method := range methods {
+		if strings.EqualFold(method.Name, argsParts[0]) {
+			paymentMethod = method
+			break
+		}
+	}
+	if paymentMethod == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_not_found", argsParts[0])
+		return
+	}
+	if !paymentMethod.ClosingDay.Valid {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_billing_no_cycle")
+		return
+	}
+
+	month, err := utils.ParseMonth(argsParts[1])
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_invalid_period")
+		return
+	}
+
+	total, err := utils.ParseMoney(argsParts[2])
+	if err != nil || total.IsNegative() {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "reconcile_usage")
+		return
+	}
+
+	// Line items may be separated by spaces or commas
+	var items []utils.Money
+	for _, arg := range argsParts[3:] {
+		for _, part := range strings.Split(arg, ",") {
+			if part == "" {
+				continue
+			}
+			item, err := utils.ParseMoney(part)
+			if err != nil {
+				handler.sendTranslatedMessage(userID, message.Chat.ID, "reconcile_usage")
+				return
+			}
+			items = append(items, item)
+		}
+	}
+
+	report, err := handler.reconciliationService.Reconcile(paymentMethod, month, total, items, userID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "reconcile_error", err)
+		return
+	}
+
+	msg, difference := formatReconciliation(report, items != nil, translator)
+	if !difference.IsPositive() {
+		handler.sendMessage(message.Chat.ID, msg)
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(translator.T("reconcile_adjust_button", difference.String()),
+				fmt.Sprintf("reconcile_adjust:%d", report.Reconciliation.ID)),
+		),
+	)
+	handler.sendMessageWithKeyboard(message.Chat.ID, msg, keyboard)
+}
+
+// formatReconciliation formats a reconciliation report and returns the difference between the bank and the bot
+func formatReconciliation(report *service.ReconciliationReport, withItems bool, translator *i18n.Translator) (string, utils.Money) {
+	rec := report.Reconciliation
+	difference := report.Difference
+
+	msg := translator.T("reconcile_header", report.Method.Name, rec.Month,
+		utils.FormatDate(rec.PeriodStart), utils.FormatDate(rec.PeriodEnd))
+	msg += translator.T("reconcile_totals", rec.StatementTotal.String(), rec.LoggedTotal.String(), len(report.Expenses))
+	switch {
+	case difference.IsZero():
+		msg += translator.T("reconcile_match")
+	case difference.IsPositive():
+		msg += translator.T("reconcile_missing", difference.String())
+	default:
+		msg += translator.T("reconcile_extra", difference.Abs().String())
+	}
+	if len(report.Others) > 0 {
+		msg += translator.T("reconcile_other_currencies", formatCurrencyTotals(report.Others))
+	}
+
+	if len(report.OutOfCycle) > 0 {
+		msg += translator.T("reconcile_out_of_cycle_header")
+		for _, exp := range report.OutOfCycle {
+			msg += formatReconcileExpense(exp, translator)
+		}
+	}
+	if withItems {
+		if len(report.MissingItems) > 0 {
+			amounts := make([]string, len(report.MissingItems))
+			for i, item := range report.MissingItems {
+				amounts[i] = item.String()
+			}
+			msg += translator.T("reconcile_items_missing", strings.Join(amounts, ", "))
+		}
+		if len(report.Unmatched) > 0 {
+			msg += translator.T("reconcile_items_unmatched_header")
+			for _, exp := range report.Unmatched {
+				msg += formatReconcileExpense(exp, translator)
+			}
+		}
+	}
+
+	msg += translator.T("reconcile_marked")
+	return msg, difference
+}
+
+// formatReconcileExpense formats an expense line of a reconciliation report
+func formatReconcileExpense(exp *database.Expense, translator *i18n.Translator) string {
+	desc := exp.Description.String
+	if !exp.Description.Valid {
+		desc = translator.T("expense_no_description")
+	}
+	return fmt.Sprintf("[ID: %d] • %s - %s%s (%s)\n",
+		exp.ID,
+		exp.Amount.String(),
+		desc,
+		installmentLabel(exp, translator),
+		utils.FormatDate(exp.ExpenseDate))
+}
+
+// handleReconcileAdjust adds the adjustment expense offered by a reconciliation
+func (h *Handler) handleReconcileAdjust(handler *Handler, query *tgbotapi.CallbackQuery) {
+	translator := handler.getTranslator(query.From.ID)
+
+	_, payload, _ := strings.Cut(query.Data, ":")
+	reconciliationID, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		handler.answerCallback(query.ID, "")
+		return
+	}
+
+	// The statement's card must belong to the lobby of the user pressing the button
+	lobby, err := handler.getLobbyForCallback(query)
+	if err != nil || lobby == nil {
+		handler.answerCallback(query.ID, translator.T("error_lobby_not_found"))
+		return
+	}
+	rec, err := handler.reconciliationService.GetReconciliationByID(reconciliationID)
+	if err != nil {
+		handler.answerCallback(query.ID, translator.T("reconcile_error", err))
+		return
+	}
+	method, err := handler.paymentMethodService.GetPaymentMethodByID(rec.PaymentMethodID)
+	if err != nil || method == nil || method.LobbyID != lobby.ID {
+		handler.answerCallback(query.ID, translator.T("reconcile_error", service.ErrReconciliationNotFound))
+		return
+	}
+
+	expense, err := handler.reconciliationService.AddAdjustment(rec.ID, query.From.ID,
+		translator.T("reconcile_adjust_description", method.Name, rec.Month))
+	switch {
+	case errors.Is(err, service.ErrAlreadyAdjusted):
+		handler.answerCallback(query.ID, translator.T("reconcile_already_adjusted"))
+		return
+	case errors.Is(err, service.ErrNothingToAdjust):
+		handler.answerCallback(query.ID, translator.T("reconcile_nothing_to_adjust"))
+		return
+	case err != nil:
+		handler.answerCallback(query.ID, translator.T("reconcile_error", err))
+		return
+	}
+
+	msg := translator.T("reconcile_adjusted", expense.Amount.String(), method.Name, rec.Month, expense.ID)
+	handler.answerCallback(query.ID, "")
+	handler.sendMessage(query.Message.Chat.ID, msg)
+}
+
+// stripConfirm removes a trailing "confirm" from a command's arguments; it goes ahead with a change of a
+// reconciled statement. It returns the other arguments and whether the confirmation was there.
+func stripConfirm(args []string) ([]string, bool) {
+	if n := len(args); n > 0 {
+		switch strings.ToLower(args[n-1]) {
+		case "confirm", "confirmar":
+			return args[:n-1], true
+		}
+	}
+	return args, false
+}
+
+// askReconciledConfirm asks to send a change that would alter a reconciled statement again with a trailing
+// "confirm", naming the statement when the expense (nil for a new one) is on a reconciled one
+func (h *Handler) askReconciledConfirm(userID, chatID int64, expense *database.Expense, command string) {
+	var rec *database.StatementReconciliation
+	if expense != nil {
+		var err error
+		rec, err = h.reconciliationService.ReconciledStatement(expense)
+		if err != nil {
+			h.sendTranslatedMessage(userID, chatID, "error_generic", err)
+			return
+		}
+	}
+	if rec == nil {
+		h.sendTranslatedMessage(userID, chatID, "reconcile_confirm_change", command)
+		return
+	}
+
+	method, _ := h.paymentMethodService.GetPaymentMethodByID(rec.PaymentMethodID)
+	name := ""
+	if method != nil {
+		name = method.Name
+	}
+	h.sendTranslatedMessage(userID, chatID, "reconcile_confirm_edit", name, rec.Month, command)
+}
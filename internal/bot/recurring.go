@@ -0,0 +1,262 @@
+package bot
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/internal/service"
+	"botGastosPareja/pkg/i18n"
+	"botGastosPareja/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerRecurringCommands registers recurring expense commands
+func (h *Handler) registerRecurringCommands() {
+	h.router.RegisterCommand("recurring", h.handleRecurring)
+}
+
+// handleRecurring handles the /recurring command
+func (h *Handler) handleRecurring(handler *Handler, message *tgbotapi.Message, args string) {
+	if message.From == nil {
+		handler.sendMessage(message.Chat.ID, "❌ Error: This command must be used by a user.")
+		return
+	}
+	userID := message.From.ID
+
+	// Get user's lobby for this specific chat (group/private)
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) == 0 || strings.ToLower(argsParts[0]) == "list" {
+		h.handleListRecurring(handler, message, lobby)
+		return
+	}
+
+	action := strings.ToLower(argsParts[0])
+	switch action {
+	case "add":
+		h.handleAddRecurring(handler, message, lobby, argsParts[1:])
+	case "delete", "remove", "stop":
+		h.handleDeleteRecurring(handler, message, lobby, argsParts[1:])
+	default:
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_usage")
+	}
+}
+
+// handleListRecurring lists the lobby's active recurring expenses
+func (h *Handler) handleListRecurring(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	rules, err := handler.recurringService.GetRecurringExpensesByLobby(lobby.ID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	if len(rules) == 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_none")
+		return
+	}
+
+	msg := translator.T("recurring_list_header")
+	for _, rule := range rules {
+		desc := rule.Description.String
+		if !rule.Description.Valid {
+			desc = translator.T("expense_no_description")
+		}
+		msg += translator.T("recurring_item",
+			rule.ID,
+			rule.Amount.String(),
+			desc,
+			formatRecurringSchedule(rule, translator),
+			utils.FormatDate(rule.NextRunDate))
+		if rule.Category.Valid {
+			msg += translator.T("expense_list_category", rule.Category.String)
+		}
+		if rule.PaymentMethodID.Valid {
+			pm, _ := handler.paymentMethodService.GetPaymentMethodByID(rule.PaymentMethodID.Int64)
+			if pm != nil {
+				msg += "  " + translator.T("expense_payment_method", pm.Name)
+			}
+		}
+		if rule.EndDate.Valid {
+			msg += translator.T("recurring_until", utils.FormatDate(rule.EndDate.Time))
+		}
+		msg += "\n"
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleAddRecurring handles /recurring add <amount> <description> <cadence> [day] [category] [payment_method] [end_date] [currency] [spender]
+func (h *Handler) handleAddRecurring(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	if len(args) < 3 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_add_usage")
+		return
+	}
+
+	amount, err := utils.ParseMoney(args[0])
+	if err != nil || !amount.IsPositive() {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "expense_invalid_amount")
+		return
+	}
+	description := args[1]
+
+	cadence := service.NormalizeCadence(args[2])
+	if cadence == "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_invalid_cadence")
+		return
+	}
+
+	rest := args[3:]
+
+	// Spender is always the last optional argument
+	spenderArg := ""
+	if len(rest) > 0 && isSpenderArg(rest[len(rest)-1]) {
+		spenderArg = rest[len(rest)-1]
+		rest = rest[:len(rest)-1]
+	}
+	spenderID, errKey := h.resolveSpender(lobby, userID, spenderArg)
+	if errKey != "" {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, errKey)
+		return
+	}
+
+	// An end date and a currency may appear anywhere among the optional arguments
+	var endDate *time.Time
+	remaining := make([]string, 0, len(rest))
+	for _, arg := range rest {
+		if date, err := utils.ParseDate(arg); err == nil && endDate == nil {
+			endDate = &date
+			continue
+		}
+		if code := utils.NormalizeCurrency(arg); code != "" && amount.Currency == "" {
+			amount = amount.WithCurrency(code)
+			continue
+		}
+		remaining = append(remaining, arg)
+	}
+	rest = remaining
+
+	startDate := time.Now()
+	dayOfMonth := startDate.Day()
+	if cadence != "weekly" && len(rest) > 0 {
+		if day, err := strconv.Atoi(rest[0]); err == nil {
+			if day < 1 || day > 31 {
+				handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_invalid_day")
+				return
+			}
+			dayOfMonth = day
+			rest = rest[1:]
+		}
+	}
+
+	category := ""
+	if len(rest) > 0 {
+		var ok bool
+		category, _, ok = handler.resolveCategoryArg(lobby.ID, userID, message.Chat.ID, rest[0])
+		if !ok {
+			return
+		}
+	}
+
+	var paymentMethodID *int64
+	if len(rest) > 1 {
+		paymentMethodName := rest[1]
+		methods, _ := handler.paymentMethodService.GetPaymentMethodsByLobby(lobby.ID, true)
+		for _, method := range methods {
+			if strings.EqualFold(method.Name, paymentMethodName) {
+				paymentMethodID = &method.ID
+				break
+			}
+		}
+		if paymentMethodID == nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "payment_method_not_found", paymentMethodName)
+			return
+		}
+	}
+
+	rule, err := handler.recurringService.CreateRecurringExpense(
+		lobby.ID,
+		spenderID,
+		amount,
+		description,
+		category,
+		paymentMethodID,
+		cadence,
+		dayOfMonth,
+		startDate,
+		endDate,
+	)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_add_error", err)
+		return
+	}
+
+	msg := translator.T("recurring_added",
+		rule.ID,
+		rule.Amount.String(),
+		rule.Description.String,
+		formatRecurringSchedule(rule, translator),
+		utils.FormatDate(rule.NextRunDate))
+	if rule.EndDate.Valid {
+		msg += translator.T("recurring_until", utils.FormatDate(rule.EndDate.Time))
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+
+	// A rule starting today is materialized right away instead of waiting for the next tick
+	if !rule.NextRunDate.After(time.Now()) {
+		handler.processRecurringExpenses(time.Now())
+	}
+}
+
+// handleDeleteRecurring handles /recurring delete <id>
+func (h *Handler) handleDeleteRecurring(handler *Handler, message *tgbotapi.Message, lobby *database.Lobby, args []string) {
+	userID := message.From.ID
+
+	if len(args) < 1 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_delete_usage")
+		return
+	}
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_delete_usage")
+		return
+	}
+
+	// Verify rule belongs to lobby
+	rule, err := handler.recurringService.GetRecurringExpenseByID(id)
+	if err != nil || rule == nil || rule.LobbyID != lobby.ID {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_not_found")
+		return
+	}
+
+	if err := handler.recurringService.DeactivateRecurringExpense(id); err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "recurring_deleted", id)
+}
+
+// formatRecurringSchedule describes when a recurring expense runs
+func formatRecurringSchedule(rule *database.RecurringExpense, translator *i18n.Translator) string {
+	switch rule.Cadence {
+	case "weekly":
+		return translator.T("recurring_cadence_weekly")
+	case "yearly":
+		return translator.T("recurring_cadence_yearly", rule.NextRunDate.Format("01-02"))
+	default:
+		return translator.T("recurring_cadence_monthly", rule.DayOfMonth.Int64)
+	}
+}
@@ -63,7 +63,11 @@ func (h *Handler) handleSummary(handler *Handler, message *tgbotapi.Message, arg
 		return
 	}
 
-	msg := h.formatSummary(expenses, lobby, startDate, endDate, translator)
+	msg, err := h.formatSummary(expenses, lobby, startDate, endDate, translator)
+	if err != nil {
+		handler.sendConversionError(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
 	handler.sendMessage(message.Chat.ID, msg)
 }
 
@@ -111,19 +115,19 @@ func (h *Handler) handleSummaryBilling(handler *Handler, message *tgbotapi.Messa
 	}
 
 	// Parse period or use current
-	var periodStart, periodEnd time.Time
+	month := time.Now()
 	if len(argsParts) >= 2 {
 		monthTime, err := utils.ParseMonth(argsParts[1])
 		if err != nil {
 			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_invalid_period")
 			return
 		}
-		periodStart, periodEnd = utils.GetBillingPeriodForMonth(
-			monthTime.Year(), monthTime.Month(), int(paymentMethod.ClosingDay.Int64))
-	} else {
-		now := time.Now()
-		periodStart, periodEnd = utils.GetBillingPeriodForMonth(
-			now.Year(), now.Month(), int(paymentMethod.ClosingDay.Int64))
+		month = monthTime
+	}
+	periodStart, periodEnd, err := handler.paymentMethodService.BillingPeriodForMonth(paymentMethod, month)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
 	}
 
 	expenses, err := handler.expenseService.GetExpensesByBillingPeriod(
@@ -133,20 +137,33 @@ func (h *Handler) handleSummaryBilling(handler *Handler, message *tgbotapi.Messa
 		return
 	}
 
-	msg := h.formatSummary(expenses, lobby, &periodStart, &periodEnd, translator)
+	msg, err := h.formatSummary(expenses, lobby, &periodStart, &periodEnd, translator)
+	if err != nil {
+		handler.sendConversionError(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	if dueDate, ok, err := handler.paymentMethodService.StatementDueDate(paymentMethod, periodEnd); err == nil && ok {
+		msg += translator.T("billing_due_date", utils.FormatDate(dueDate))
+	}
 	handler.sendMessage(message.Chat.ID, msg)
 }
 
-// formatSummary formats a summary report
-func (h *Handler) formatSummary(expenses []*database.Expense, lobby *database.Lobby, startDate, endDate *time.Time, translator *i18n.Translator) string {
+// formatSummary formats a summary report, with every amount converted to the lobby's base currency
+func (h *Handler) formatSummary(expenses []*database.Expense, lobby *database.Lobby, startDate, endDate *time.Time, translator *i18n.Translator) (string, error) {
 	if len(expenses) == 0 {
 		periodStr := translator.T("summary_period")
 		if startDate != nil && endDate != nil {
 			periodStr = fmt.Sprintf("%s to %s",
 				utils.FormatDate(*startDate), utils.FormatDate(*endDate))
 		}
-		return translator.T("summary_none", periodStr)
+		return translator.T("summary_none", periodStr), nil
+	}
+
+	converter, err := h.exchangeRateService.NewConverter(lobby.ID)
+	if err != nil {
+		return "", err
 	}
+	base := converter.BaseCurrency
 
 	periodStr := translator.T("summary_period")
 	if startDate != nil && endDate != nil {
@@ -154,64 +171,90 @@ func (h *Handler) formatSummary(expenses []*database.Expense, lobby *database.Lo
 			utils.FormatDate(*startDate), utils.FormatDate(*endDate))
 	}
 
-	// Get user names
-	user1Name := h.getUserDisplayName(lobby.User1TelegramID, "Usuario 1")
-	user2Name := h.getUserDisplayName(lobby.User2TelegramID, "Usuario 2")
+	total := utils.NewMoney(0, base)
+	memberTotals := make(map[int64]utils.Money)
+	categoryTotals := make(map[string]utils.Money)
+	paymentMethodTotals := make(map[string]utils.Money)
 
-	var total float64
-	user1Total := 0.0
-	user2Total := 0.0
-	categoryTotals := make(map[string]float64)
-	paymentMethodTotals := make(map[string]float64)
+	var foreign []*database.Expense
 
 	for _, exp := range expenses {
-		total += exp.Amount
+		amount, err := converter.ExpenseAmount(exp)
+		if err != nil {
+			return "", err
+		}
+		if total, err = total.Add(amount); err != nil {
+			return "", err
+		}
+		if exp.Amount.Currency != base {
+			foreign = append(foreign, exp)
+		}
 
-		if exp.SpenderTelegramID == lobby.User1TelegramID {
-			user1Total += exp.Amount
-		} else if exp.SpenderTelegramID == lobby.User2TelegramID {
-			user2Total += exp.Amount
+		if lobby.IsMember(exp.SpenderTelegramID) {
+			if memberTotals[exp.SpenderTelegramID], err = memberTotals[exp.SpenderTelegramID].Add(amount); err != nil {
+				return "", err
+			}
 		}
 
 		if exp.Category.Valid {
-			categoryTotals[exp.Category.String] += exp.Amount
+			if categoryTotals[exp.Category.String], err = categoryTotals[exp.Category.String].Add(amount); err != nil {
+				return "", err
+			}
 		}
 
 		if exp.PaymentMethodID.Valid {
 			pm, _ := h.paymentMethodService.GetPaymentMethodByID(exp.PaymentMethodID.Int64)
 			if pm != nil {
-				paymentMethodTotals[pm.Name] += exp.Amount
+				if paymentMethodTotals[pm.Name], err = paymentMethodTotals[pm.Name].Add(amount); err != nil {
+					return "", err
+				}
 			}
 		}
 	}
 
 	msg := translator.T("summary_header",
 		periodStr,
-		utils.FormatCurrency(total),
+		total.String(),
 		len(expenses))
 
+	// Amounts spent in other currencies before conversion
+	if len(foreign) > 0 {
+		msg += translator.T("summary_converted", formatCurrencyTotals(currencyTotals(foreign)), base)
+	}
+
 	// Per-person breakdown
 	msg += translator.T("summary_by_person")
-	msg += fmt.Sprintf("%s: %s (%.1f%%)\n", user1Name, utils.FormatCurrency(user1Total), user1Total/total*100)
-	msg += fmt.Sprintf("%s: %s (%.1f%%)\n\n", user2Name, utils.FormatCurrency(user2Total), user2Total/total*100)
+	for _, memberID := range lobby.MemberIDs() {
+		memberTotal := memberTotals[memberID].WithCurrency(base)
+		ratio, err := memberTotal.Ratio(total)
+		if err != nil {
+			return "", err
+		}
+		msg += fmt.Sprintf("%s: %s (%.1f%%)\n", h.getMemberName(lobby, memberID), memberTotal.String(), ratio*100)
+	}
+	msg += "\n"
 
 	// Category breakdown
 	if len(categoryTotals) > 0 {
 		msg += translator.T("summary_by_category")
 		type catTotal struct {
 			name  string
-			total float64
+			total utils.Money
 		}
 		cats := make([]catTotal, 0, len(categoryTotals))
 		for name, total := range categoryTotals {
 			cats = append(cats, catTotal{name, total})
 		}
 		sort.Slice(cats, func(i, j int) bool {
-			return cats[i].total > cats[j].total
+			return cats[i].total.Amount > cats[j].total.Amount
 		})
 		for _, cat := range cats {
+			ratio, err := cat.total.Ratio(total)
+			if err != nil {
+				return "", err
+			}
 			msg += translator.T("summary_category_item",
-				cat.name, utils.FormatCurrency(cat.total), cat.total/total*100)
+				cat.name, cat.total.String(), ratio*100)
 		}
 		msg += "\n"
 	}
@@ -221,20 +264,27 @@ func (h *Handler) formatSummary(expenses []*database.Expense, lobby *database.Lo
 		msg += translator.T("summary_by_payment")
 		type pmTotal struct {
 			name  string
-			total float64
+			total utils.Money
 		}
 		pms := make([]pmTotal, 0, len(paymentMethodTotals))
 		for name, total := range paymentMethodTotals {
 			pms = append(pms, pmTotal{name, total})
 		}
 		sort.Slice(pms, func(i, j int) bool {
-			return pms[i].total > pms[j].total
+			return pms[i].total.Amount > pms[j].total.Amount
 		})
 		for _, pm := range pms {
+			ratio, err := pm.total.Ratio(total)
+			if err != nil {
+				return "", err
+			}
 			msg += fmt.Sprintf("• %s: %s (%.1f%%)\n",
-				pm.name, utils.FormatCurrency(pm.total), pm.total/total*100)
+				pm.name, pm.total.String(), ratio*100)
 		}
 	}
 
-	return msg
+	// Installment charges falling in this period
+	msg += formatInstallmentLines(expenses, translator)
+
+	return msg, nil
 }
@@ -1,6 +1,8 @@
 package bot
 
 import (
+	"strings"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -40,8 +42,13 @@ func (r *Router) GetCommandHandler(command string) CommandHandler {
 	return r.commandHandlers[command]
 }
 
-// GetCallbackHandler returns the handler for a callback
+// GetCallbackHandler returns the handler for a callback.
+// Callback data carrying a payload ("name:payload") is routed to the handler registered for its name.
 func (r *Router) GetCallbackHandler(callback string) CallbackHandler {
-	return r.callbackHandlers[callback]
+	if handler, ok := r.callbackHandlers[callback]; ok {
+		return handler
+	}
+	name, _, _ := strings.Cut(callback, ":")
+	return r.callbackHandlers[name]
 }
 
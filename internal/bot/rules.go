@@ -0,0 +1,255 @@
+package bot
+
+import (
+	"botGastosPareja/internal/service"
+	"errors"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerRuleCommands registers categorization rule commands
+func (h *Handler) registerRuleCommands() {
+	h.router.RegisterCommand("rules", h.handleRules)
+}
+
+// handleRules handles the /rules command
+func (h *Handler) handleRules(handler *Handler, message *tgbotapi.Message, args string) {
+	if message.From == nil {
+		handler.sendMessage(message.Chat.ID, "❌ Error: This command must be used by a user.")
+		return
+	}
+	userID := message.From.ID
+
+	// Get user's lobby for this specific chat (group/private)
+	lobby, err := handler.getLobbyForMessage(message)
+	if err != nil || lobby == nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_lobby_not_found")
+		return
+	}
+
+	argsParts := parseCommandArgs(args)
+	if len(argsParts) == 0 {
+		h.handleListRules(handler, message, lobby.ID)
+		return
+	}
+
+	action := strings.ToLower(argsParts[0])
+	switch action {
+	case "add":
+		h.handleAddRule(handler, message, lobby.ID, argsParts[1:])
+	case "delete", "remove":
+		h.handleDeleteRule(handler, message, lobby.ID, argsParts[1:])
+	case "learn", "aprender":
+		h.handleLearnRules(handler, message, lobby.ID)
+	case "accept", "aceptar":
+		h.handleAcceptRules(handler, message, lobby.ID, argsParts[1:])
+	case "apply", "aplicar":
+		_, force := stripConfirm(argsParts[1:])
+		h.handleApplyRules(handler, message, lobby.ID, force)
+	default:
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_usage")
+	}
+}
+
+// handleListRules lists the lobby's rules
+func (h *Handler) handleListRules(handler *Handler, message *tgbotapi.Message, lobbyID int64) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	rules, err := handler.ruleService.GetRules(lobbyID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	if len(rules) == 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_none")
+		return
+	}
+
+	var items []string
+	for _, rule := range rules {
+		pattern := rule.Pattern
+		if rule.IsRegex {
+			pattern = "re:" + pattern
+		}
+		item := translator.T("rule_item", rule.ID, pattern, rule.CategoryName)
+		if rule.PaymentMethodID.Valid {
+			pm, _ := handler.paymentMethodService.GetPaymentMethodByID(rule.PaymentMethodID.Int64)
+			if pm != nil {
+				item += translator.T("rule_item_payment", pm.Name)
+			}
+		}
+		items = append(items, item)
+	}
+	handler.sendMessage(message.Chat.ID, translator.T("rule_list", strings.Join(items, "\n")))
+}
+
+// handleAddRule handles /rules add <keyword|re:regex> <category> [payment_method]
+func (h *Handler) handleAddRule(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	if len(args) < 2 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_add_usage")
+		return
+	}
+
+	pattern := args[0]
+	isRegex := false
+	for _, prefix := range []string{"re:", "regex:"} {
+		if len(pattern) > len(prefix) && strings.EqualFold(pattern[:len(prefix)], prefix) {
+			pattern = pattern[len(prefix):]
+			isRegex = true
+			break
+		}
+	}
+
+	// A trailing payment method name is optional; everything else is the category
+	rest := args[1:]
+	var paymentMethodID *int64
+	if len(rest) > 1 {
+		methods, err := handler.paymentMethodService.GetPaymentMethodsByLobby(lobbyID, true)
+		if err == nil {
+			for _, method := range methods {
+				if strings.EqualFold(method.Name, rest[len(rest)-1]) {
+					paymentMethodID = &method.ID
+					rest = rest[:len(rest)-1]
+					break
+				}
+			}
+		}
+	}
+
+	category, _, ok := handler.resolveCategoryArg(lobbyID, userID, message.Chat.ID, strings.Join(rest, " "))
+	if !ok {
+		return
+	}
+
+	rule, err := handler.ruleService.CreateRule(lobbyID, pattern, isRegex, category, paymentMethodID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_error", err)
+		return
+	}
+
+	msg := translator.T("rule_added", rule.ID, rule.Pattern, rule.CategoryName)
+	msg += translator.T("rule_apply_hint")
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleDeleteRule handles /rules delete <id>
+func (h *Handler) handleDeleteRule(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	if len(args) < 1 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_usage")
+		return
+	}
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_not_found")
+		return
+	}
+
+	err = handler.ruleService.DeleteRule(lobbyID, id)
+	if errors.Is(err, service.ErrRuleNotFound) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_not_found")
+		return
+	}
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_deleted", id)
+}
+
+// handleLearnRules proposes rules from past expenses
+func (h *Handler) handleLearnRules(handler *Handler, message *tgbotapi.Message, lobbyID int64) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	suggestions, err := handler.ruleService.SuggestRules(lobbyID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_error", err)
+		return
+	}
+	if len(suggestions) == 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_learn_none")
+		return
+	}
+
+	msg := translator.T("rule_learn_header")
+	for i, suggestion := range suggestions {
+		msg += translator.T("rule_learn_item", i+1, suggestion.Keyword, suggestion.Category, suggestion.Matches)
+		if suggestion.PaymentMethodID != nil {
+			msg += translator.T("rule_item_payment", suggestion.PaymentMethodName)
+		}
+		msg += "\n"
+	}
+	msg += translator.T("rule_learn_footer")
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleAcceptRules creates the rules proposed by /rules learn: /rules accept <n...|all>
+func (h *Handler) handleAcceptRules(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	if len(args) == 0 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_usage")
+		return
+	}
+
+	suggestions, err := handler.ruleService.SuggestRules(lobbyID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_error", err)
+		return
+	}
+
+	var chosen []service.RuleSuggestion
+	if strings.EqualFold(args[0], "all") || strings.EqualFold(args[0], "todas") {
+		chosen = suggestions
+	} else {
+		for _, arg := range args {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > len(suggestions) {
+				handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_learn_invalid", arg)
+				return
+			}
+			chosen = append(chosen, suggestions[n-1])
+		}
+	}
+
+	var created []string
+	for _, suggestion := range chosen {
+		rule, err := handler.ruleService.CreateRule(lobbyID, suggestion.Keyword, false, suggestion.Category, suggestion.PaymentMethodID)
+		if err != nil {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_error", err)
+			return
+		}
+		created = append(created, translator.T("rule_item", rule.ID, rule.Pattern, rule.CategoryName))
+	}
+
+	msg := translator.T("rule_accepted", len(created), strings.Join(created, "\n"))
+	msg += translator.T("rule_apply_hint")
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleApplyRules categorizes past uncategorized expenses with the lobby's rules; those on reconciled
+// statements only when forced with "confirm"
+func (h *Handler) handleApplyRules(handler *Handler, message *tgbotapi.Message, lobbyID int64, force bool) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	applied, held, err := handler.ruleService.ApplyToUncategorized(lobbyID, force)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "rule_error", err)
+		return
+	}
+	msg := translator.T("rule_applied", applied)
+	if held > 0 {
+		msg += translator.T("rule_applied_held", held)
+	}
+	handler.sendMessage(message.Chat.ID, msg)
+}
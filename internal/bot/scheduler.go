@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"botGastosPareja/pkg/utils"
+	"log"
+	"time"
+)
+
+// paymentReminderDays is how many days before a statement's due date its reminder is posted
+const paymentReminderDays = 3
+
+// RunScheduler runs the background jobs once immediately and then every interval until stop is closed
+func (h *Handler) RunScheduler(stop <-chan struct{}, interval time.Duration) {
+	h.runScheduledJobs()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.runScheduledJobs()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// runScheduledJobs runs every background job once
+func (h *Handler) runScheduledJobs() {
+	h.processRecurringExpenses(time.Now())
+	h.processPaymentReminders(time.Now())
+}
+
+// processRecurringExpenses materializes due recurring expenses and notifies their lobbies
+func (h *Handler) processRecurringExpenses(now time.Time) {
+	runs, err := h.recurringService.MaterializeDue(now)
+	if err != nil {
+		log.Printf("Error processing recurring expenses: %v", err)
+		return
+	}
+
+	for _, run := range runs {
+		if run.Expense == nil {
+			// Falls on a reconciled statement, so it was not added
+			h.notifyLobby(run.Rule.LobbyID, "recurring_held",
+				run.Rule.Amount.String(),
+				run.Rule.Description.String,
+				utils.FormatDate(run.Date),
+				run.Rule.ID)
+			continue
+		}
+		desc := run.Expense.Description.String
+		h.notifyLobby(run.Rule.LobbyID, "recurring_materialized",
+			run.Expense.Amount.String(),
+			desc,
+			utils.FormatDate(run.Expense.ExpenseDate),
+			run.Rule.ID)
+	}
+}
+
+// processPaymentReminders posts a reminder to the lobby of every closed statement that is about to be due,
+// with the statement total per currency
+func (h *Handler) processPaymentReminders(now time.Time) {
+	statements, err := h.paymentMethodService.GetDueReminders(now, paymentReminderDays)
+	if err != nil {
+		log.Printf("Error processing payment reminders: %v", err)
+		return
+	}
+
+	today := utils.CalendarDate(now)
+	for _, statement := range statements {
+		totals := currencyTotals(statement.Expenses)
+
+		days := int(statement.DueDate.Sub(today).Hours()+12) / 24
+		if days <= 0 {
+			h.notifyLobby(statement.Method.LobbyID, "payment_due_today",
+				statement.Method.Name,
+				formatCurrencyTotals(totals),
+				utils.FormatDate(statement.PeriodStart),
+				utils.FormatDate(statement.PeriodEnd))
+			continue
+		}
+		h.notifyLobby(statement.Method.LobbyID, "payment_due_reminder",
+			statement.Method.Name,
+			formatCurrencyTotals(totals),
+			days,
+			utils.FormatDate(statement.DueDate),
+			utils.FormatDate(statement.PeriodStart),
+			utils.FormatDate(statement.PeriodEnd))
+	}
+}
+
+// notifyLobby sends a translated message to a lobby's chat: its group when linked to one,
+// otherwise a private message to each member in their own language
+func (h *Handler) notifyLobby(lobbyID int64, key string, args ...interface{}) {
+	lobby, err := h.lobbyService.GetLobbyByID(lobbyID)
+	if err != nil || lobby == nil {
+		log.Printf("Error notifying lobby %d: lobby not found (%v)", lobbyID, err)
+		return
+	}
+
+	if lobby.GroupChatID.Valid {
+		h.sendTranslatedMessage(lobby.OwnerTelegramID, lobby.GroupChatID.Int64, key, args...)
+		return
+	}
+
+	for _, memberID := range lobby.MemberIDs() {
+		// A private chat's ID is the user's Telegram ID
+		h.sendTranslatedMessage(memberID, memberID, key, args...)
+	}
+}
@@ -1,8 +1,11 @@
 package bot
 
 import (
+	"botGastosPareja/pkg/utils"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -32,19 +35,25 @@ func (h *Handler) handleSettings(handler *Handler, message *tgbotapi.Message, ar
 	argsParts := parseCommandArgs(args)
 	if len(argsParts) == 0 {
 		// Show current settings
+		quickCapture := translator.T("settings_off")
+		if enabled, _ := handler.lobbyService.IsQuickCaptureEnabled(lobby.ID); enabled {
+			quickCapture = translator.T("settings_on")
+		}
 		settingsMsg := translator.T("settings_current",
 			lobby.ID,
 			lobby.AccountType,
 			lobby.User1SalaryPercentage*100,
-			lobby.User2SalaryPercentage*100)
+			lobby.User2SalaryPercentage*100,
+			quickCapture)
 		handler.sendMessage(message.Chat.ID, settingsMsg)
 		return
 	}
 
-	// Parse settings update
+	// Parse settings update, in force from today or from a given date
 	settingType := strings.ToLower(argsParts[0])
 	var accountType *string
 	var user1Pct, user2Pct *float64
+	var dateArg string
 
 	switch settingType {
 	case "account_type", "accounttype":
@@ -58,6 +67,9 @@ func (h *Handler) handleSettings(handler *Handler, message *tgbotapi.Message, ar
 			return
 		}
 		accountType = &at
+		if len(argsParts) > 2 {
+			dateArg = argsParts[2]
+		}
 
 	case "salary":
 		if len(argsParts) < 3 {
@@ -76,18 +88,119 @@ func (h *Handler) handleSettings(handler *Handler, message *tgbotapi.Message, ar
 		}
 		user1Pct = &pct1
 		user2Pct = &pct2
+		if len(argsParts) > 3 {
+			dateArg = argsParts[3]
+		}
+
+	case "history", "historial":
+		h.handleSettingsHistory(handler, message, lobby.ID)
+		return
+
+	case "quick_capture", "quickcapture":
+		h.handleQuickCaptureSetting(handler, message, lobby.ID, argsParts[1:])
+		return
 
 	default:
 		handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_unknown")
 		return
 	}
 
+	effectiveFrom := time.Now()
+	if dateArg != "" {
+		date, ok := parseEffectiveDate(dateArg)
+		if !ok {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_invalid_date")
+			return
+		}
+		if date.After(time.Now()) {
+			handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_future_date")
+			return
+		}
+		effectiveFrom = date
+	}
+
 	// Update settings
-	err = handler.lobbyService.UpdateLobbySettings(lobby.ID, accountType, user1Pct, user2Pct)
+	err = handler.lobbyService.UpdateLobbySettings(lobby.ID, accountType, user1Pct, user2Pct, effectiveFrom, userID)
 	if err != nil {
 		handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_error", err)
 		return
 	}
 
+	if utils.FormatDate(effectiveFrom) != utils.FormatDate(time.Now()) {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_updated_from", utils.FormatDate(effectiveFrom))
+		return
+	}
 	handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_updated")
 }
+
+// parseEffectiveDate parses the date settings take effect from: a date, or a month (YYYY-MM) for its first day
+func parseEffectiveDate(arg string) (time.Time, bool) {
+	if month, err := utils.ParseMonth(arg); err == nil {
+		return month, true
+	}
+	return utils.ParseDateWord(arg, time.Now())
+}
+
+// handleSettingsHistory handles /settings history: every settings change, with the dates it was in force
+func (h *Handler) handleSettingsHistory(handler *Handler, message *tgbotapi.Message, lobbyID int64) {
+	userID := message.From.ID
+	translator := handler.getTranslator(userID)
+
+	history, err := handler.lobbyService.GetSettingsHistory(lobbyID)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
+	}
+
+	msg := translator.T("settings_history_header")
+	for i, settings := range history {
+		until := translator.T("settings_history_now")
+		if i+1 < len(history) {
+			until = utils.FormatDate(history[i+1].EffectiveFrom.AddDate(0, 0, -1))
+		}
+		msg += translator.T("settings_history_item",
+			utils.FormatDate(settings.EffectiveFrom),
+			until,
+			settings.AccountType,
+			settings.User1SalaryPercentage*100,
+			settings.User2SalaryPercentage*100)
+		if settings.ChangedBy.Valid {
+			msg += translator.T("settings_history_changed_by",
+				handler.getUserDisplayName(settings.ChangedBy.Int64, fmt.Sprint(settings.ChangedBy.Int64)))
+		}
+	}
+	msg += translator.T("settings_history_hint")
+	handler.sendMessage(message.Chat.ID, msg)
+}
+
+// handleQuickCaptureSetting handles /settings quick_capture <on|off>
+func (h *Handler) handleQuickCaptureSetting(handler *Handler, message *tgbotapi.Message, lobbyID int64, args []string) {
+	userID := message.From.ID
+
+	if len(args) < 1 {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_quick_capture_usage")
+		return
+	}
+
+	var enabled bool
+	switch strings.ToLower(args[0]) {
+	case "on", "yes", "si", "sí", "true", "1":
+		enabled = true
+	case "off", "no", "false", "0":
+		enabled = false
+	default:
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_quick_capture_usage")
+		return
+	}
+
+	if err := handler.lobbyService.SetQuickCapture(lobbyID, enabled); err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_error", err)
+		return
+	}
+
+	if enabled {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_quick_capture_on")
+		return
+	}
+	handler.sendTranslatedMessage(userID, message.Chat.ID, "settings_quick_capture_off")
+}
@@ -6,6 +6,7 @@ import (
 	"botGastosPareja/pkg/i18n"
 	"botGastosPareja/pkg/utils"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -16,6 +17,8 @@ import (
 func (h *Handler) registerSettlementCommands() {
 	h.router.RegisterCommand("settle", h.handleSettle)
 	h.router.RegisterCommand("settle_billing", h.handleSettleBilling)
+	h.router.RegisterCommand("paid", h.handlePaid)
+	h.router.RegisterCommand("balance", h.handleBalance)
 }
 
 // handleSettle handles the /settle command
@@ -63,11 +66,26 @@ func (h *Handler) handleSettle(handler *Handler, message *tgbotapi.Message, args
 
 	result, err := handler.settlementService.CalculateSettlement(lobby.ID, startDate, endDate)
 	if err != nil {
-		handler.sendTranslatedMessage(userID, message.Chat.ID, "settle_error", err)
+		handler.sendConversionError(userID, message.Chat.ID, "settle_error", err)
 		return
 	}
 
-	msg := h.formatSettlementResult(result, startDate, endDate, translator)
+	msg := h.formatSettlementResult(lobby, result, startDate, endDate, translator)
+
+	// What is still owed once earlier months and payments are taken into account
+	balance, err := handler.settlementService.CalculateBalance(lobby.ID, *endDate)
+	if err == nil {
+		msg += translator.T("settle_running_balance", utils.FormatDate(*endDate))
+		msg += h.formatBalanceLine(lobby, balance, translator)
+	}
+
+	// In shared lobbies, what each member deposited against their share of what the account paid
+	if lobby.AccountType == "shared" {
+		account, err := handler.settlementService.CalculateSharedAccount(lobby.ID, *startDate, *endDate)
+		if err == nil {
+			msg += h.formatSharedAccount(lobby, account, translator)
+		}
+	}
 	handler.sendMessage(message.Chat.ID, msg)
 }
 
@@ -115,48 +133,51 @@ func (h *Handler) handleSettleBilling(handler *Handler, message *tgbotapi.Messag
 	}
 
 	// Parse period or use current
-	var periodStart, periodEnd time.Time
+	month := time.Now()
 	if len(argsParts) >= 2 {
 		monthTime, err := utils.ParseMonth(argsParts[1])
 		if err != nil {
 			handler.sendTranslatedMessage(userID, message.Chat.ID, "error_invalid_period")
 			return
 		}
-		periodStart, periodEnd = utils.GetBillingPeriodForMonth(
-			monthTime.Year(), monthTime.Month(), int(paymentMethod.ClosingDay.Int64))
-	} else {
-		now := time.Now()
-		periodStart, periodEnd = utils.GetBillingPeriodForMonth(
-			now.Year(), now.Month(), int(paymentMethod.ClosingDay.Int64))
+		month = monthTime
+	}
+	periodStart, periodEnd, err := handler.paymentMethodService.BillingPeriodForMonth(paymentMethod, month)
+	if err != nil {
+		handler.sendTranslatedMessage(userID, message.Chat.ID, "error_generic", err)
+		return
 	}
 
 	result, err := handler.settlementService.CalculateBillingSettlement(
 		lobby.ID, paymentMethod.ID, periodStart, periodEnd)
 	if err != nil {
-		handler.sendTranslatedMessage(userID, message.Chat.ID, "settle_error", err)
+		handler.sendConversionError(userID, message.Chat.ID, "settle_error", err)
 		return
 	}
 
-	msg := h.formatSettlementResult(result, &periodStart, &periodEnd, translator)
+	msg := h.formatSettlementResult(lobby, result, &periodStart, &periodEnd, translator)
+	msg += formatInstallmentLines(result.Expenses, translator)
 	handler.sendMessage(message.Chat.ID, msg)
 }
 
 // formatSettlementResult formats a settlement result for display
-func (h *Handler) formatSettlementResult(result *service.SettlementResult, startDate, endDate *time.Time, translator *i18n.Translator) string {
+func (h *Handler) formatSettlementResult(lobby *database.Lobby, result *service.SettlementResult, startDate, endDate *time.Time, translator *i18n.Translator) string {
 	periodStr := translator.T("summary_period")
 	if startDate != nil && endDate != nil {
 		periodStr = fmt.Sprintf("%s to %s",
 			utils.FormatDate(*startDate), utils.FormatDate(*endDate))
 	}
 
-	// Get user names
-	user1Name := h.getUserDisplayName(result.User1ID, "Usuario 1")
-	user2Name := h.getUserDisplayName(result.User2ID, "Usuario 2")
+	// Get member names, in the order of the result's members
+	names := make([]string, len(result.Members))
+	for i, member := range result.Members {
+		names[i] = h.getMemberName(lobby, member.UserID)
+	}
 
 	msg := translator.T("settle_report",
 		periodStr,
 		result.AccountType,
-		utils.FormatCurrency(result.TotalExpenses))
+		result.TotalExpenses.String())
 
 	if result.AccountType == "separate" {
 		msg += translator.T("settle_separate")
@@ -164,35 +185,118 @@ func (h *Handler) formatSettlementResult(result *service.SettlementResult, start
 		msg += translator.T("settle_shared")
 	}
 
-	msg += fmt.Sprintf("%s Gastó: %s\n", user1Name, utils.FormatCurrency(result.User1TotalSpent))
-	msg += fmt.Sprintf("%s Gastó: %s\n\n", user2Name, utils.FormatCurrency(result.User2TotalSpent))
+	for i, member := range result.Members {
+		msg += fmt.Sprintf("%s Gastó: %s\n", names[i], member.TotalSpent.String())
+	}
+	msg += "\n"
+
+	// Who pays the bills differs from who spent when cards of other members or the shared account were used
+	if len(result.OwnerPaidItems) > 0 || !result.SharedAccountPaid.IsZero() {
+		msg += translator.T("settle_paid_header")
+		for i, member := range result.Members {
+			msg += translator.T("settle_paid_line", names[i], member.Paid.String())
+		}
+		if !result.SharedAccountPaid.IsZero() {
+			msg += translator.T("settle_paid_shared_account", result.SharedAccountPaid.String())
+		}
+		msg += "\n"
+	}
 
-	// Check if salary percentages are being used (not default 0.5/0.5)
-	useSalaryPercentages := (result.User1SalaryPercentage != 0.5 || result.User2SalaryPercentage != 0.5) ||
-		result.AccountType == "shared"
+	// The ratio comes from the members' incomes when both logged them for the period
+	if result.IncomeRatio != nil {
+		msg += translator.T("settle_income_ratio",
+			strings.Join(result.IncomeRatio.Months, ", "),
+			names[0], result.IncomeRatio.User1Income.String(),
+			names[1], result.IncomeRatio.User2Income.String())
+	}
+
+	// Check if uneven percentages are being used (not an equal split)
+	useSalaryPercentages := result.IncomeRatio != nil || result.AccountType == "shared"
+	for _, member := range result.Members {
+		if math.Abs(member.Percentage-1/float64(len(result.Members))) > 1e-9 {
+			useSalaryPercentages = true
+		}
+	}
 
 	if useSalaryPercentages {
-		// Use actual salary percentages from lobby
-		msg += fmt.Sprintf("%s Esperado (%.1f%%): %s\n", user1Name, result.User1SalaryPercentage*100, utils.FormatCurrency(result.User1Expected))
-		msg += fmt.Sprintf("%s Esperado (%.1f%%): %s\n\n", user2Name, result.User2SalaryPercentage*100, utils.FormatCurrency(result.User2Expected))
-	} else {
-		expectedPerPerson := result.TotalExpenses / 2.0
-		msg += translator.T("settle_expected_per", utils.FormatCurrency(expectedPerPerson))
+		// Use actual percentages from the lobby
+		for i, member := range result.Members {
+			msg += fmt.Sprintf("%s Esperado (%.1f%%): %s\n", names[i], member.Percentage*100, member.Expected.String())
+		}
+		msg += "\n"
+	} else if len(result.SplitItems) > 0 {
+		// Personal and custom-split items make the expected amounts differ
+		for i, member := range result.Members {
+			msg += fmt.Sprintf("%s Esperado: %s\n", names[i], member.Expected.String())
+		}
+		msg += "\n"
+	} else if len(result.Members) > 0 {
+		msg += translator.T("settle_expected_per", result.Members[0].Expected.String())
 	}
 
-	// Determine who owes whom
-	if result.User1Debt > 0 {
-		msg += fmt.Sprintf("➡️ %s le debe a %s: %s\n", user1Name, user2Name, utils.FormatCurrency(result.User1Debt))
-	} else if result.User1Debt < 0 {
-		msg += fmt.Sprintf("➡️ %s le debe a %s: %s\n", user2Name, user1Name, utils.FormatCurrency(-result.User1Debt))
-	} else {
+	// A settings or membership change during the period splits it in parts, each among the members then
+	if len(result.SettingsPeriods) > 1 && result.IncomeRatio == nil {
+		msg += translator.T("settle_settings_header")
+		for _, period := range result.SettingsPeriods {
+			shares := make([]string, 0, len(period.Shares))
+			for i, share := range period.Shares {
+				if period.Sharing[i] {
+					shares = append(shares, translator.T("settle_settings_share", names[i], period.Percentages[i]*100, share.String()))
+				}
+			}
+			msg += translator.T("settle_settings_item",
+				utils.FormatDate(period.From),
+				period.SharedTotal.String(),
+				strings.Join(shares, " | "))
+		}
+		msg += "\n"
+	}
+
+	// Determine who owes whom, with as few payments as possible
+	if len(result.Transfers) == 0 {
 		msg += translator.T("settle_all_settled")
 	}
+	for _, transfer := range result.Transfers {
+		msg += fmt.Sprintf("➡️ %s le debe a %s: %s\n",
+			h.getMemberName(lobby, transfer.FromID),
+			h.getMemberName(lobby, transfer.ToID),
+			transfer.Amount.String())
+	}
 
-	if result.User2Debt > 0 {
-		msg += fmt.Sprintf("➡️ %s le debe a %s: %s\n", user2Name, user1Name, utils.FormatCurrency(result.User2Debt))
-	} else if result.User2Debt < 0 {
-		msg += fmt.Sprintf("➡️ %s le debe a %s: %s\n", user1Name, user2Name, utils.FormatCurrency(-result.User2Debt))
+	// Items charged to one member or split their own way
+	if len(result.SplitItems) > 0 {
+		msg += translator.T("settle_split_header", result.SharedTotal.String())
+		for _, item := range result.SplitItems {
+			desc := item.Expense.Description.String
+			if !item.Expense.Description.Valid {
+				desc = translator.T("expense_no_description")
+			}
+			shares := make([]string, len(item.Shares))
+			for i, share := range item.Shares {
+				shares[i] = fmt.Sprintf("%s: %s", names[i], share.String())
+			}
+			msg += translator.T("settle_split_item",
+				desc,
+				item.Amount.String(),
+				formatSplitLabel(item.Expense, translator),
+				strings.Join(shares, " | "))
+		}
+	}
+
+	// Items whose bill someone other than the spender pays
+	if len(result.OwnerPaidItems) > 0 {
+		msg += translator.T("settle_owner_paid_header")
+		for _, item := range result.OwnerPaidItems {
+			desc := item.Expense.Description.String
+			if !item.Expense.Description.Valid {
+				desc = translator.T("expense_no_description")
+			}
+			msg += translator.T("settle_owner_paid_item",
+				desc,
+				item.Amount.String(),
+				h.getMemberName(lobby, item.Expense.SpenderTelegramID),
+				h.getMemberName(lobby, item.PayerID))
+		}
 	}
 
 	return msg
@@ -1,10 +1,13 @@
 package database
 
 import (
+	"botGastosPareja/pkg/utils"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -22,7 +25,11 @@ func NewDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	conn, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=1")
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	conn, err := sql.Open("sqlite3", dbPath+separator+"_foreign_keys=1")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -79,6 +86,17 @@ func (db *DB) migrate() error {
 		FOREIGN KEY (user2_telegram_id) REFERENCES users(telegram_id)
 	);
 
+	-- Memberships of each lobby, one per time a member was in it; user1/user2 on lobbies are kept for the owner and old data
+	CREATE TABLE IF NOT EXISTS lobby_members (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		user_telegram_id INTEGER NOT NULL,
+		share_weight REAL NOT NULL DEFAULT 1,
+		joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id)
+	);
+
 	-- Categories (predefined + custom)
 	CREATE TABLE IF NOT EXISTS categories (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -109,7 +127,7 @@ func (db *DB) migrate() error {
 		lobby_id INTEGER,
 		spender_telegram_id INTEGER,
 		payment_method_id INTEGER,
-		amount REAL NOT NULL,
+		amount_minor INTEGER NOT NULL,
 		description TEXT,
 		category TEXT,
 		expense_date DATE NOT NULL,
@@ -120,6 +138,259 @@ func (db *DB) migrate() error {
 		FOREIGN KEY (spender_telegram_id) REFERENCES users(telegram_id),
 		FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id)
 	);
+
+	-- Recurring expenses (rent, subscriptions, etc.)
+	CREATE TABLE IF NOT EXISTS recurring_expenses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		spender_telegram_id INTEGER NOT NULL,
+		payment_method_id INTEGER,
+		amount_minor INTEGER NOT NULL,
+		description TEXT,
+		category TEXT,
+		cadence TEXT CHECK(cadence IN ('weekly', 'monthly', 'yearly')) NOT NULL,
+		day_of_month INTEGER,
+		start_date DATE NOT NULL,
+		end_date DATE,
+		next_run_date DATE NOT NULL,
+		last_run_date DATE,
+		is_active BOOLEAN DEFAULT 1,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (spender_telegram_id) REFERENCES users(telegram_id),
+		FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id)
+	);
+
+	-- One row per materialized run, so missed runs are caught up exactly once
+	CREATE TABLE IF NOT EXISTS recurring_expense_runs (
+		recurring_expense_id INTEGER NOT NULL,
+		run_date DATE NOT NULL,
+		expense_id INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (recurring_expense_id, run_date),
+		FOREIGN KEY (recurring_expense_id) REFERENCES recurring_expenses(id)
+	);
+
+	-- Exchange rates: 1 unit of currency = rate units of base_currency on rate_date
+	CREATE TABLE IF NOT EXISTS exchange_rates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		currency TEXT NOT NULL,
+		base_currency TEXT NOT NULL,
+		rate_type TEXT NOT NULL DEFAULT 'oficial',
+		rate_date DATE NOT NULL,
+		rate REAL NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (lobby_id, currency, base_currency, rate_type, rate_date),
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id)
+	);
+
+	-- Settlement payments: money actually transferred between members to settle their balance
+	CREATE TABLE IF NOT EXISTS settlement_payments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		payer_telegram_id INTEGER NOT NULL,
+		payee_telegram_id INTEGER NOT NULL,
+		amount_minor INTEGER NOT NULL,
+		currency TEXT NOT NULL,
+		payment_date DATE NOT NULL,
+		note TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (payer_telegram_id) REFERENCES users(telegram_id),
+		FOREIGN KEY (payee_telegram_id) REFERENCES users(telegram_id)
+	);
+
+	-- Money members put into the shared account of a lobby
+	CREATE TABLE IF NOT EXISTS contributions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		user_telegram_id INTEGER NOT NULL,
+		amount_minor INTEGER NOT NULL,
+		currency TEXT NOT NULL,
+		contribution_date DATE NOT NULL,
+		note TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id)
+	);
+
+	-- Categorization rules: a keyword or regex on the description picks the category of new expenses
+	CREATE TABLE IF NOT EXISTS category_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		pattern TEXT NOT NULL,
+		is_regex BOOLEAN NOT NULL DEFAULT 0,
+		category_id INTEGER NOT NULL,
+		payment_method_id INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (category_id) REFERENCES categories(id),
+		FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id)
+	);
+
+	-- Monthly budgets: a limit per category, or for the whole lobby when category_id is NULL
+	CREATE TABLE IF NOT EXISTS budgets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		category_id INTEGER,
+		amount_minor INTEGER NOT NULL,
+		currency TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (category_id) REFERENCES categories(id)
+	);
+
+	-- Budget thresholds already announced, so each one warns once per month
+	CREATE TABLE IF NOT EXISTS budget_alerts (
+		budget_id INTEGER NOT NULL,
+		month TEXT NOT NULL,
+		threshold INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (budget_id, month, threshold),
+		FOREIGN KEY (budget_id) REFERENCES budgets(id)
+	);
+
+	-- Statement due reminders already posted, one per payment method and billing cycle
+	CREATE TABLE IF NOT EXISTS payment_reminders (
+		payment_method_id INTEGER NOT NULL,
+		period_end DATE NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (payment_method_id, period_end),
+		FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS billing_cycles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		payment_method_id INTEGER NOT NULL,
+		month TEXT NOT NULL,
+		closing_date DATE NOT NULL,
+		due_date DATE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (payment_method_id, month),
+		FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS holidays (
+		lobby_id INTEGER NOT NULL,
+		date DATE NOT NULL,
+		name TEXT,
+		PRIMARY KEY (lobby_id, date),
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS statement_reconciliations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		payment_method_id INTEGER NOT NULL,
+		month TEXT NOT NULL,
+		period_start DATE NOT NULL,
+		period_end DATE NOT NULL,
+		statement_total_minor INTEGER NOT NULL,
+		logged_total_minor INTEGER NOT NULL,
+		currency TEXT NOT NULL,
+		adjustment_expense_id INTEGER,
+		reconciled_by INTEGER NOT NULL,
+		reconciled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (payment_method_id, month),
+		FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id),
+		FOREIGN KEY (adjustment_expense_id) REFERENCES expenses(id) ON DELETE SET NULL,
+		FOREIGN KEY (reconciled_by) REFERENCES users(telegram_id)
+	);
+
+	-- Payment method used when an expense doesn't name one: a member's default, or their default for a category
+	CREATE TABLE IF NOT EXISTS payment_method_defaults (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		user_telegram_id INTEGER NOT NULL,
+		category_id INTEGER,
+		payment_method_id INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id),
+		FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+		FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id) ON DELETE CASCADE
+	);
+
+	-- Account type and ratio of a lobby in force from a date until the next change, so past periods keep theirs
+	CREATE TABLE IF NOT EXISTS lobby_settings_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		effective_from DATE NOT NULL,
+		account_type TEXT NOT NULL,
+		user1_salary_percentage REAL NOT NULL,
+		user2_salary_percentage REAL NOT NULL,
+		changed_by INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (lobby_id, effective_from),
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (changed_by) REFERENCES users(telegram_id)
+	);
+
+	-- Net income of each member per month; when both members logged one, it sets that month's ratio
+	CREATE TABLE IF NOT EXISTS incomes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		user_telegram_id INTEGER NOT NULL,
+		month TEXT NOT NULL,
+		amount_minor INTEGER NOT NULL,
+		currency TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (lobby_id, user_telegram_id, month),
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id)
+	);
+
+	-- Invite tokens: each expires, allows a number of joins and can be revoked
+	CREATE TABLE IF NOT EXISTS invite_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lobby_id INTEGER NOT NULL,
+		token TEXT NOT NULL UNIQUE,
+		created_by INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NOT NULL,
+		max_uses INTEGER NOT NULL DEFAULT 1,
+		revoked_at TIMESTAMP,
+		revoked_reason TEXT,
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (created_by) REFERENCES users(telegram_id)
+	);
+
+	-- Who joined with each invite token
+	CREATE TABLE IF NOT EXISTS invite_token_uses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		invite_token_id INTEGER NOT NULL,
+		user_telegram_id INTEGER NOT NULL,
+		used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (invite_token_id) REFERENCES invite_tokens(id),
+		FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id)
+	);
+
+	-- Security events: joins, failed joins, lockouts, token regenerations, members leaving or removed, and ownership transfers
+	CREATE TABLE IF NOT EXISTS security_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_type TEXT NOT NULL,
+		lobby_id INTEGER,
+		user_telegram_id INTEGER,
+		target_telegram_id INTEGER,
+		detail TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+		FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id),
+		FOREIGN KEY (target_telegram_id) REFERENCES users(telegram_id)
+	);
+
+	-- Failed join attempts per user ("user:<id>"), and the lockout they caused
+	CREATE TABLE IF NOT EXISTS join_lockouts (
+		scope TEXT PRIMARY KEY,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_failure_at TIMESTAMP,
+		locked_until TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		name TEXT PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
 	if _, err := conn.Exec(schemaSQL); err != nil {
@@ -131,12 +402,86 @@ func (db *DB) migrate() error {
 		return fmt.Errorf("failed to migrate columns: %w", err)
 	}
 
+	// Amounts used to be stored as REAL; move them to integer minor units (cents)
+	for _, table := range []string{"expenses", "recurring_expenses"} {
+		if err := db.migrateAmountToMinorUnits(table); err != nil {
+			return fmt.Errorf("failed to migrate %s amounts: %w", table, err)
+		}
+	}
+
+	// Categories used to be free text on each expense; point expenses to category rows instead
+	if err := db.migrateCategoryIDs(); err != nil {
+		return fmt.Errorf("failed to migrate categories: %w", err)
+	}
+
+	// Billing periods used to be computed by two functions that disagreed; store the unified cycles instead
+	if err := db.runOnce("unified_billing_cycles", db.migrateBillingPeriods); err != nil {
+		return fmt.Errorf("failed to migrate billing periods: %w", err)
+	}
+
+	// Settings used to be overwritten in place; start every lobby's history with its current settings
+	if err := db.runOnce("lobby_settings_history", db.migrateSettingsHistory); err != nil {
+		return fmt.Errorf("failed to migrate lobby settings: %w", err)
+	}
+
+	// Members used to be the two user columns of the lobby; move them to lobby_members
+	if err := db.runOnce("lobby_members", db.migrateLobbyMembers); err != nil {
+		return fmt.Errorf("failed to migrate lobby members: %w", err)
+	}
+
+	// Invite tokens used to be a column of the lobby that never expired; move the ones still useful to invite_tokens
+	if err := db.runOnce("invite_tokens", db.migrateInviteTokens); err != nil {
+		return fmt.Errorf("failed to migrate invite tokens: %w", err)
+	}
+
+	// Quick capture used to be on in every lobby; keep it on only in private chats, where groups chatting can't add expenses
+	if err := db.runOnce("quick_capture_private_chats", db.migrateQuickCapture); err != nil {
+		return fmt.Errorf("failed to migrate quick capture: %w", err)
+	}
+
+	// Members used to have one row per lobby, overwritten when they came back; keep a row per membership instead
+	if err := db.runOnce("lobby_member_intervals", db.migrateMemberIntervals); err != nil {
+		return fmt.Errorf("failed to migrate lobby member intervals: %w", err)
+	}
+
+	// Billing periods used to be stored in the time zone of the expense date, which the UTC periods queried
+	// never matched as text; keep their calendar days in UTC
+	if err := db.runOnce("utc_billing_periods", db.migrateUTCBillingPeriods); err != nil {
+		return fmt.Errorf("failed to migrate billing periods to UTC: %w", err)
+	}
+
+	// Dates used to be stored in the server's time zone, off by a day from the UTC bounds of the months queried
+	if err := db.runOnce("utc_calendar_dates", db.migrateCalendarDates); err != nil {
+		return fmt.Errorf("failed to migrate dates to UTC: %w", err)
+	}
+
+	// Rate dates used to be stored as local midnights, which the dates of other time zones compared wrong
+	if err := db.runOnce("plain_rate_dates", db.migrateRateDates); err != nil {
+		return fmt.Errorf("failed to migrate exchange rate dates: %w", err)
+	}
+
 	// Create indexes (after ensuring columns exist)
 	indexSQL := `
 	CREATE INDEX IF NOT EXISTS idx_expenses_lobby_date ON expenses(lobby_id, expense_date);
 	CREATE INDEX IF NOT EXISTS idx_expenses_billing_period ON expenses(billing_period_start, billing_period_end);
 	CREATE INDEX IF NOT EXISTS idx_expenses_payment_method ON expenses(payment_method_id);
 	CREATE INDEX IF NOT EXISTS idx_payment_methods_lobby ON payment_methods(lobby_id, is_active);
+	CREATE INDEX IF NOT EXISTS idx_recurring_expenses_next_run ON recurring_expenses(is_active, next_run_date);
+	CREATE INDEX IF NOT EXISTS idx_exchange_rates_lookup ON exchange_rates(lobby_id, currency, base_currency, rate_type, rate_date);
+	CREATE INDEX IF NOT EXISTS idx_settlement_payments_lobby_date ON settlement_payments(lobby_id, payment_date);
+	CREATE INDEX IF NOT EXISTS idx_contributions_lobby_date ON contributions(lobby_id, contribution_date);
+	CREATE INDEX IF NOT EXISTS idx_categories_lobby ON categories(lobby_id);
+	CREATE INDEX IF NOT EXISTS idx_expenses_category ON expenses(category_id);
+	CREATE INDEX IF NOT EXISTS idx_category_rules_lobby ON category_rules(lobby_id);
+	CREATE INDEX IF NOT EXISTS idx_budgets_lobby ON budgets(lobby_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_method_defaults_unique ON payment_method_defaults(lobby_id, user_telegram_id, IFNULL(category_id, 0));
+	CREATE INDEX IF NOT EXISTS idx_incomes_lobby_month ON incomes(lobby_id, month);
+	CREATE INDEX IF NOT EXISTS idx_lobby_members_user ON lobby_members(user_telegram_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_lobby_members_current ON lobby_members(lobby_id, user_telegram_id) WHERE left_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_invite_tokens_lobby ON invite_tokens(lobby_id);
+	CREATE INDEX IF NOT EXISTS idx_invite_token_uses_token ON invite_token_uses(invite_token_id);
+	CREATE INDEX IF NOT EXISTS idx_security_events_lobby ON security_events(lobby_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_security_events_user ON security_events(user_telegram_id, event_type, created_at);
 	`
 
 	if _, err := conn.Exec(indexSQL); err != nil {
@@ -209,5 +554,525 @@ func (db *DB) migrateAddColumns() error {
 		_, _ = conn.Exec(`CREATE INDEX IF NOT EXISTS idx_lobbies_invite_token ON lobbies(invite_token)`)
 	}
 
+	// Installment (cuotas) columns on expenses
+	db.addColumnIfNotExists("expenses", "parent_expense_id", "INTEGER REFERENCES expenses(id)")
+	db.addColumnIfNotExists("expenses", "installment_number", "INTEGER")
+	db.addColumnIfNotExists("expenses", "installment_count", "INTEGER")
+	db.addColumnIfNotExists("expenses", "installment_interest", "REAL")
+	_, _ = conn.Exec(`CREATE INDEX IF NOT EXISTS idx_expenses_parent ON expenses(parent_expense_id)`)
+
+	// Multi-currency: currency per expense and base currency / default rate type per lobby
+	db.addColumnIfNotExists("expenses", "currency", "TEXT NOT NULL DEFAULT 'ARS'")
+	db.addColumnIfNotExists("recurring_expenses", "currency", "TEXT NOT NULL DEFAULT 'ARS'")
+	db.addColumnIfNotExists("lobbies", "base_currency", "TEXT NOT NULL DEFAULT 'ARS'")
+	db.addColumnIfNotExists("lobbies", "rate_type", "TEXT NOT NULL DEFAULT 'oficial'")
+
+	// Free-text expense capture from plain chat messages; CreateLobby turns it on for private chats
+	db.addColumnIfNotExists("lobbies", "quick_capture", "BOOLEAN NOT NULL DEFAULT 0")
+
+	// Per-expense split mode; existing expenses stay shared by the lobby ratio
+	db.addColumnIfNotExists("expenses", "split_mode", "TEXT NOT NULL DEFAULT 'shared'")
+	db.addColumnIfNotExists("expenses", "split_percent", "REAL")
+	db.addColumnIfNotExists("expenses", "split_amount_minor", "INTEGER")
+
+	// Managed categories: expenses point to a lobby category, which can be archived
+	db.addColumnIfNotExists("expenses", "category_id", "INTEGER REFERENCES categories(id)")
+	db.addColumnIfNotExists("categories", "is_archived", "BOOLEAN NOT NULL DEFAULT 0")
+	db.addColumnIfNotExists("lobbies", "categories_seeded", "BOOLEAN NOT NULL DEFAULT 0")
+
+	// Budget alert thresholds (percent of the limit), shared by all the lobby's budgets
+	db.addColumnIfNotExists("lobbies", "budget_thresholds", "TEXT NOT NULL DEFAULT '80,100'")
+
+	// Statement due date: a day of the month, or a number of days after the closing
+	db.addColumnIfNotExists("payment_methods", "due_day", "INTEGER")
+	db.addColumnIfNotExists("payment_methods", "due_offset_days", "INTEGER")
+
+	// Where a closing day on a weekend or holiday moves to
+	db.addColumnIfNotExists("payment_methods", "closing_shift", "TEXT NOT NULL DEFAULT 'none'")
+
+	// Credit limit of a card, and the usage percentage that warns when adding expenses
+	db.addColumnIfNotExists("payment_methods", "credit_limit_minor", "INTEGER")
+	db.addColumnIfNotExists("payment_methods", "credit_limit_currency", "TEXT")
+	db.addColumnIfNotExists("payment_methods", "credit_warn_percent", "INTEGER NOT NULL DEFAULT 80")
+
+	// How many members can join a lobby: two for couples, more for roommates and families
+	db.addColumnIfNotExists("lobbies", "max_members", "INTEGER NOT NULL DEFAULT 2")
+
+	// Lobby names, and the lobby each user's private chat acts on when they are in several
+	db.addColumnIfNotExists("lobbies", "name", "TEXT")
+	db.addColumnIfNotExists("users", "active_lobby_id", "INTEGER REFERENCES lobbies(id)")
+
+	// Members who left or were removed keep their row, so their expenses are still settled as theirs
+	db.addColumnIfNotExists("lobby_members", "left_at", "TIMESTAMP")
+	db.addColumnIfNotExists("lobby_members", "removed_by", "INTEGER REFERENCES users(telegram_id)")
+
+	return nil
+}
+
+// migrateAmountToMinorUnits replaces the REAL amount column of a table with an INTEGER
+// amount_minor column holding the same value in cents. It does nothing once migrated.
+func (db *DB) migrateAmountToMinorUnits(table string) error {
+	var hasAmount int
+	err := db.conn.QueryRow(
+		`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'amount'`, table,
+	).Scan(&hasAmount)
+	if err != nil || hasAmount == 0 {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hasMinor int
+	err = tx.QueryRow(
+		`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'amount_minor'`, table,
+	).Scan(&hasMinor)
+	if err != nil {
+		return err
+	}
+
+	steps := []string{
+		fmt.Sprintf("UPDATE %s SET amount_minor = CAST(ROUND(amount * 100) AS INTEGER)", table),
+		fmt.Sprintf("ALTER TABLE %s DROP COLUMN amount", table),
+	}
+	if hasMinor == 0 {
+		steps = append([]string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN amount_minor INTEGER NOT NULL DEFAULT 0", table),
+		}, steps...)
+	}
+
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("failed to run %q: %w", step, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// migrateCategoryIDs links expenses that only have a free-text category to a category of their lobby.
+// Spellings differing only in case, accents or spacing ("Super", "super ", "Súper") share one category,
+// named after the most used spelling. Expenses already linked are left alone, so it is safe to run every time.
+func (db *DB) migrateCategoryIDs() error {
+	rows, err := db.conn.Query(`
+		SELECT lobby_id, category, COUNT(*) AS uses FROM expenses
+		WHERE category_id IS NULL AND category IS NOT NULL AND TRIM(category) != '' AND lobby_id IS NOT NULL
+		GROUP BY lobby_id, category
+		ORDER BY lobby_id, uses DESC, category`)
+	if err != nil {
+		return fmt.Errorf("failed to query expense categories: %w", err)
+	}
+
+	type freeText struct {
+		lobbyID int64
+		name    string
+	}
+	var pending []freeText
+	for rows.Next() {
+		var item freeText
+		var uses int
+		if err := rows.Scan(&item.lobbyID, &item.name, &uses); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan expense category: %w", err)
+		}
+		pending = append(pending, item)
+	}
+	rows.Close()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Folded name -> category, per lobby
+	known := make(map[int64]map[string]Category)
+	for _, item := range pending {
+		categories, ok := known[item.lobbyID]
+		if !ok {
+			categories = make(map[string]Category)
+			catRows, err := tx.Query(`SELECT id, name FROM categories WHERE lobby_id = ? ORDER BY id`, item.lobbyID)
+			if err != nil {
+				return fmt.Errorf("failed to query categories: %w", err)
+			}
+			for catRows.Next() {
+				var category Category
+				if err := catRows.Scan(&category.ID, &category.Name); err != nil {
+					catRows.Close()
+					return fmt.Errorf("failed to scan category: %w", err)
+				}
+				if _, exists := categories[utils.FoldText(category.Name)]; !exists {
+					categories[utils.FoldText(category.Name)] = category
+				}
+			}
+			catRows.Close()
+			known[item.lobbyID] = categories
+		}
+
+		key := utils.FoldText(item.name)
+		category, ok := categories[key]
+		if !ok {
+			category = Category{LobbyID: item.lobbyID, Name: strings.Join(strings.Fields(item.name), " ")}
+			result, err := tx.Exec(`INSERT INTO categories (lobby_id, name, is_default) VALUES (?, ?, 0)`, item.lobbyID, category.Name)
+			if err != nil {
+				return fmt.Errorf("failed to create category %q: %w", category.Name, err)
+			}
+			if category.ID, err = result.LastInsertId(); err != nil {
+				return fmt.Errorf("failed to get category ID: %w", err)
+			}
+			categories[key] = category
+		}
+
+		_, err := tx.Exec(`UPDATE expenses SET category_id = ?, category = ?
+			WHERE lobby_id = ? AND category = ? AND category_id IS NULL`,
+			category.ID, category.Name, item.lobbyID, item.name)
+		if err != nil {
+			return fmt.Errorf("failed to link expenses to category %q: %w", category.Name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// runOnce runs a data migration unless schema_migrations records it as applied, and records it when it succeeds
+func (db *DB) runOnce(name string, migration func() error) error {
+	var applied int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE name = ?`, name).Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("failed to query schema migrations: %w", err)
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	if err := migration(); err != nil {
+		return err
+	}
+
+	if _, err := db.conn.Exec(`INSERT INTO schema_migrations (name) VALUES (?)`, name); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", name, err)
+	}
+	return nil
+}
+
+// migrateSettingsHistory records each lobby's current settings as in force since the lobby was created,
+// the best guess for lobbies whose earlier settings were overwritten
+func (db *DB) migrateSettingsHistory() error {
+	_, err := db.conn.Exec(`INSERT INTO lobby_settings_history
+	          (lobby_id, effective_from, account_type, user1_salary_percentage, user2_salary_percentage)
+	          SELECT id, substr(created_at, 1, 10), account_type, user1_salary_percentage, user2_salary_percentage
+	          FROM lobbies WHERE id NOT IN (SELECT lobby_id FROM lobby_settings_history)`)
+	if err != nil {
+		return fmt.Errorf("failed to seed settings history: %w", err)
+	}
+	return nil
+}
+
+// migrateLobbyMembers adds the two members of each lobby to lobby_members, the creator first
+func (db *DB) migrateLobbyMembers() error {
+	_, err := db.conn.Exec(`INSERT OR IGNORE INTO lobby_members (lobby_id, user_telegram_id, joined_at)
+	          SELECT lobby_id, user_telegram_id, joined_at FROM (
+	           SELECT id AS lobby_id, user1_telegram_id AS user_telegram_id, created_at AS joined_at, 1 AS position
+	           FROM lobbies WHERE user1_telegram_id IS NOT NULL
+	           UNION ALL
+	           SELECT id, user2_telegram_id, created_at, 2 FROM lobbies WHERE user2_telegram_id IS NOT NULL)
+	          ORDER BY lobby_id, position`)
+	if err != nil {
+		return fmt.Errorf("failed to seed lobby members: %w", err)
+	}
+	return nil
+}
+
+// migrateQuickCapture turns quick capture on for private lobbies and off for group lobbies, which have to opt in
+func (db *DB) migrateQuickCapture() error {
+	_, err := db.conn.Exec(`UPDATE lobbies SET quick_capture = (group_chat_id IS NULL)`)
+	if err != nil {
+		return fmt.Errorf("failed to set quick capture: %w", err)
+	}
+	return nil
+}
+
+// migrateMemberIntervals rebuilds lobby_members without its one-row-per-member constraint, so a member who
+// comes back starts a new row and keeps the dates of their earlier memberships
+func (db *DB) migrateMemberIntervals() error {
+	var schema string
+	err := db.conn.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'lobby_members'`).Scan(&schema)
+	if err != nil {
+		return fmt.Errorf("failed to query lobby members table: %w", err)
+	}
+	if !strings.Contains(schema, "UNIQUE") {
+		return nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	steps := []string{
+		`CREATE TABLE lobby_members_intervals (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			lobby_id INTEGER NOT NULL,
+			user_telegram_id INTEGER NOT NULL,
+			share_weight REAL NOT NULL DEFAULT 1,
+			joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			left_at TIMESTAMP,
+			removed_by INTEGER REFERENCES users(telegram_id),
+			FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+			FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id)
+		)`,
+		`INSERT INTO lobby_members_intervals (id, lobby_id, user_telegram_id, share_weight, joined_at, left_at, removed_by)
+		 SELECT id, lobby_id, user_telegram_id, share_weight, joined_at, left_at, removed_by FROM lobby_members`,
+		`DROP TABLE lobby_members`,
+		`ALTER TABLE lobby_members_intervals RENAME TO lobby_members`,
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("failed to rebuild lobby members: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// legacyInviteTTL is how long invite tokens from before invite_tokens stay valid after the migration
+const legacyInviteTTL = 7 * 24 * time.Hour
+
+// migrateInviteTokens copies the invite token of each lobby that still has room to invite_tokens, valid for
+// the members missing and for legacyInviteTTL
+func (db *DB) migrateInviteTokens() error {
+	rows, err := db.conn.Query(`SELECT l.id, l.invite_token, l.user1_telegram_id,
+	          l.max_members - (SELECT COUNT(*) FROM lobby_members m WHERE m.lobby_id = l.id)
+	          FROM lobbies l WHERE l.invite_token IS NOT NULL AND l.invite_token != ''`)
+	if err != nil {
+		return fmt.Errorf("failed to query lobby invite tokens: %w", err)
+	}
+	type legacyToken struct {
+		lobbyID   int64
+		token     string
+		createdBy sql.NullInt64
+		missing   int
+	}
+	var tokens []legacyToken
+	for rows.Next() {
+		var token legacyToken
+		if err := rows.Scan(&token.lobbyID, &token.token, &token.createdBy, &token.missing); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan lobby invite token: %w", err)
+		}
+		if token.missing > 0 {
+			tokens = append(tokens, token)
+		}
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, token := range tokens {
+		_, err := db.conn.Exec(`INSERT OR IGNORE INTO invite_tokens (lobby_id, token, created_by, created_at, expires_at, max_uses)
+		          VALUES (?, ?, ?, ?, ?, ?)`,
+			token.lobbyID, token.token, token.createdBy, now, now.Add(legacyInviteTTL), token.missing)
+		if err != nil {
+			return fmt.Errorf("failed to migrate invite token of lobby %d: %w", token.lobbyID, err)
+		}
+	}
 	return nil
 }
+
+// migrateBillingPeriods recomputes the stored billing period of every expense on a payment method with a
+// closing day. Installments go on consecutive statements starting with the purchase's, as when created.
+func (db *DB) migrateBillingPeriods() error {
+	type method struct {
+		id     int64
+		cycles utils.BillingCycles
+	}
+	rows, err := db.conn.Query(`SELECT id, closing_day, closing_shift FROM payment_methods WHERE closing_day IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to query payment methods: %w", err)
+	}
+	var methods []method
+	for rows.Next() {
+		var m method
+		var shift string
+		if err := rows.Scan(&m.id, &m.cycles.ClosingDay, &shift); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan payment method: %w", err)
+		}
+		m.cycles.Shift = utils.ClosingShift(shift)
+		methods = append(methods, m)
+	}
+	rows.Close()
+	if len(methods) == 0 {
+		return nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, m := range methods {
+		m.cycles.Calendar = utils.ClosingCalendar{}
+		calRows, err := tx.Query(`SELECT month, closing_date FROM billing_cycles WHERE payment_method_id = ?`, m.id)
+		if err != nil {
+			return fmt.Errorf("failed to query billing cycles: %w", err)
+		}
+		for calRows.Next() {
+			var month string
+			var closing time.Time
+			if err := calRows.Scan(&month, &closing); err != nil {
+				calRows.Close()
+				return fmt.Errorf("failed to scan billing cycle: %w", err)
+			}
+			m.cycles.Calendar[month] = closing
+		}
+		calRows.Close()
+
+		// Single payments and installment parents; each installment is placed from its parent
+		expRows, err := tx.Query(`SELECT id, expense_date, COALESCE(installment_count, 1) FROM expenses
+			WHERE payment_method_id = ? AND parent_expense_id IS NULL`, m.id)
+		if err != nil {
+			return fmt.Errorf("failed to query expenses: %w", err)
+		}
+		type purchase struct {
+			id    int64
+			date  time.Time
+			count int
+		}
+		var purchases []purchase
+		for expRows.Next() {
+			var p purchase
+			if err := expRows.Scan(&p.id, &p.date, &p.count); err != nil {
+				expRows.Close()
+				return fmt.Errorf("failed to scan expense: %w", err)
+			}
+			purchases = append(purchases, p)
+		}
+		expRows.Close()
+
+		for _, p := range purchases {
+			periods := m.cycles.Periods(p.date, p.count)
+			_, err := tx.Exec(`UPDATE expenses SET billing_period_start = ?, billing_period_end = ? WHERE id = ?`,
+				periods[0].Start, periods[0].End, p.id)
+			if err != nil {
+				return fmt.Errorf("failed to update expense %d: %w", p.id, err)
+			}
+			for i := 1; i < p.count; i++ {
+				_, err := tx.Exec(`UPDATE expenses SET expense_date = ?, billing_period_start = ?, billing_period_end = ?
+					WHERE parent_expense_id = ? AND installment_number = ?`,
+					periods[i].Start, periods[i].Start, periods[i].End, p.id, i+1)
+				if err != nil {
+					return fmt.Errorf("failed to update installment %d of expense %d: %w", i+1, p.id, err)
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// migrateUTCBillingPeriods moves the stored billing periods to UTC, keeping their calendar days
+func (db *DB) migrateUTCBillingPeriods() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := relabelUTC(tx, "expenses", "billing_period_start", "billing_period_end"); err != nil {
+		return err
+	}
+	if err := relabelUTC(tx, "statement_reconciliations", "period_start", "period_end"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrateCalendarDates stores the dates of expenses, payments, contributions and settings changes as
+// midnight UTC of their calendar day. Settings changes that end up on the same day keep the latest.
+func (db *DB) migrateCalendarDates() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM lobby_settings_history WHERE id NOT IN
+		(SELECT MAX(id) FROM lobby_settings_history GROUP BY lobby_id, substr(effective_from, 1, 10))`)
+	if err != nil {
+		return fmt.Errorf("failed to drop overridden settings: %w", err)
+	}
+
+	dates := []struct{ table, column string }{
+		{"expenses", "expense_date"},
+		{"settlement_payments", "payment_date"},
+		{"contributions", "contribution_date"},
+		{"lobby_settings_history", "effective_from"},
+	}
+	for _, date := range dates {
+		query := fmt.Sprintf(`UPDATE %[1]s SET %[2]s = substr(%[2]s, 1, 10) || ' 00:00:00+00:00'
+			WHERE %[2]s GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]*' AND %[2]s != substr(%[2]s, 1, 10) || ' 00:00:00+00:00'`,
+			date.table, date.column)
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to move %s.%s to UTC: %w", date.table, date.column, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// migrateRateDates stores the dates of exchange rates as plain "YYYY-MM-DD" days. Rates that end up
+// on the same day keep the latest.
+func (db *DB) migrateRateDates() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM exchange_rates WHERE id NOT IN
+		(SELECT MAX(id) FROM exchange_rates GROUP BY lobby_id, currency, base_currency, rate_type, substr(rate_date, 1, 10))`)
+	if err != nil {
+		return fmt.Errorf("failed to drop replaced rates: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE exchange_rates SET rate_date = substr(rate_date, 1, 10) WHERE length(rate_date) > 10`); err != nil {
+		return fmt.Errorf("failed to migrate exchange rate dates: %w", err)
+	}
+	return tx.Commit()
+}
+
+// relabelUTC rewrites the times stored in some columns of a table with a UTC offset instead of their own,
+// keeping the wall clock: "2026-09-21 00:00:00-03:00" becomes "2026-09-21 00:00:00+00:00", the form
+// utils.CalendarDate stores. Values without an offset are left alone.
+func relabelUTC(tx *sql.Tx, table string, columns ...string) error {
+	for _, column := range columns {
+		query := fmt.Sprintf(`UPDATE %[1]s SET %[2]s = substr(%[2]s, 1, length(%[2]s) - 6) || '+00:00'
+			WHERE %[2]s GLOB '*[+-][0-9][0-9]:[0-9][0-9]' AND substr(%[2]s, -6) != '+00:00'`, table, column)
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to move %s.%s to UTC: %w", table, column, err)
+		}
+	}
+	return nil
+}
+
+// addColumnIfNotExists adds a column to a table unless it is already present
+func (db *DB) addColumnIfNotExists(table, column, definition string) {
+	var count int
+	err := db.conn.QueryRow(
+		`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column,
+	).Scan(&count)
+	if err != nil || count > 0 {
+		return
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+	if _, err := db.conn.Exec(query); err != nil {
+		// Log but don't fail - column might already exist
+		fmt.Printf("Warning: Could not add %s.%s column: %v\n", table, column, err)
+	}
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"botGastosPareja/pkg/utils"
 	"database/sql"
 	"time"
 )
@@ -14,51 +15,322 @@ type User struct {
 	CreatedAt   time.Time
 }
 
-// Lobby represents a couple's shared expense tracking space
+// Lobby represents a shared expense tracking space of a couple, roommates or a family
 type Lobby struct {
 	ID                    int64
-	User1TelegramID       int64
-	User2TelegramID       int64
+	Name                  sql.NullString // Set by the members to tell their lobbies apart
+	OwnerTelegramID       int64          // Member who created the lobby, or was given it with a transfer
+	Members               []*LobbyMember // In joining order
+	MaxMembers            int
+	AccountType           string  // "separate" or "shared"
+	User1SalaryPercentage float64 // Ratio of the first two members, used while the lobby has exactly two
+	User2SalaryPercentage float64
+	GroupChatID           sql.NullInt64 // Telegram group/channel ID (optional)
+	CreatedAt             time.Time
+}
+
+// LobbyMember is a membership of a lobby: one of the times a member was in it
+type LobbyMember struct {
+	ID             int64
+	LobbyID        int64
+	UserTelegramID int64
+	ShareWeight    float64 // Relative share of the shared expenses when the lobby has more than two members
+	JoinedAt       time.Time
+	LeftAt         sql.NullTime // Set once the member left or was removed
+}
+
+// InLobbyOn reports whether a membership covers a day: the member had joined by then and had not left before
+func (m *LobbyMember) InLobbyOn(date time.Time) bool {
+	day := utils.CalendarDate(date)
+	return !utils.CalendarDate(m.JoinedAt).After(day) && (!m.LeftAt.Valid || !utils.CalendarDate(m.LeftAt.Time).Before(day))
+}
+
+// InLobbyBetween reports whether a membership overlaps the days from "from" to "to" (unbounded when zero)
+func (m *LobbyMember) InLobbyBetween(from, to time.Time) bool {
+	if !to.IsZero() && utils.CalendarDate(m.JoinedAt).After(utils.CalendarDate(to)) {
+		return false
+	}
+	return from.IsZero() || !m.LeftAt.Valid || !utils.CalendarDate(m.LeftAt.Time).Before(utils.CalendarDate(from))
+}
+
+// MemberIDs returns the Telegram IDs of the lobby members, in joining order
+func (l *Lobby) MemberIDs() []int64 {
+	ids := make([]int64, len(l.Members))
+	for i, member := range l.Members {
+		ids[i] = member.UserTelegramID
+	}
+	return ids
+}
+
+// Member returns the lobby member with a Telegram ID, or nil if they are not a member
+func (l *Lobby) Member(userID int64) *LobbyMember {
+	for _, member := range l.Members {
+		if member.UserTelegramID == userID {
+			return member
+		}
+	}
+	return nil
+}
+
+// IsMember reports whether a user is a member of the lobby
+func (l *Lobby) IsMember(userID int64) bool {
+	return l.Member(userID) != nil
+}
+
+// IsFull reports whether the lobby has as many members as it allows
+func (l *Lobby) IsFull() bool {
+	return len(l.Members) >= l.MaxMembers
+}
+
+// Partner returns the other member of a two-member lobby, or 0 when the lobby has another number of members
+func (l *Lobby) Partner(userID int64) int64 {
+	if len(l.Members) != 2 || !l.IsMember(userID) {
+		return 0
+	}
+	if l.Members[0].UserTelegramID == userID {
+		return l.Members[1].UserTelegramID
+	}
+	return l.Members[0].UserTelegramID
+}
+
+// LobbySettings are the account type and ratio of a lobby in force from EffectiveFrom until the next change
+type LobbySettings struct {
+	ID                    int64 // 0 for a lobby's current settings when it has no history
+	LobbyID               int64
+	EffectiveFrom         time.Time
 	AccountType           string // "separate" or "shared"
 	User1SalaryPercentage float64
 	User2SalaryPercentage float64
-	InviteToken           sql.NullString // Secure invitation token
-	GroupChatID           sql.NullInt64  // Telegram group/channel ID (optional)
+	ChangedBy             sql.NullInt64 // NULL for the settings the lobby was created with
 	CreatedAt             time.Time
 }
 
 // Category represents an expense category
 type Category struct {
-	ID        int64
-	LobbyID   int64
-	Name      string
-	IsDefault bool
+	ID         int64
+	LobbyID    int64
+	Name       string
+	IsDefault  bool // Seeded when the lobby was set up
+	IsArchived bool // Hidden from lists and suggestions; its expenses keep it
+}
+
+// CategoryRule assigns a category (and optionally a payment method) to new expenses whose description matches
+type CategoryRule struct {
+	ID              int64
+	LobbyID         int64
+	Pattern         string // Keyword matched as whole words, or a regular expression when IsRegex
+	IsRegex         bool
+	CategoryID      int64
+	CategoryName    string // Current name of CategoryID
+	PaymentMethodID sql.NullInt64
+	CreatedAt       time.Time
+}
+
+// Budget is a monthly spending limit for a category, or for the whole lobby when CategoryID is NULL
+type Budget struct {
+	ID           int64
+	LobbyID      int64
+	CategoryID   sql.NullInt64
+	CategoryName string // Current name of CategoryID; empty for the overall budget
+	Amount       utils.Money
+	CreatedAt    time.Time
 }
 
 // PaymentMethod represents a payment method (credit card, cash, etc.)
 type PaymentMethod struct {
-	ID               int64
-	LobbyID          int64
-	Name             string
-	Type             string        // "credit_card", "debit_card", "cash", "bank_transfer", "other"
-	OwnerTelegramID  sql.NullInt64 // NULL if shared
-	ClosingDay       sql.NullInt64 // Day of month when statement closes (1-31)
-	BillingCycleDays int64
-	IsActive         bool
-	CreatedAt        time.Time
+	ID                int64
+	LobbyID           int64
+	Name              string
+	Type              string        // "credit_card", "debit_card", "cash", "bank_transfer", "other"
+	OwnerTelegramID   sql.NullInt64 // NULL if shared
+	ClosingDay        sql.NullInt64 // Day of month when statement closes (1-31)
+	DueDay            sql.NullInt64 // Day of month when the statement is due (1-31)
+	DueOffsetDays     sql.NullInt64 // Or days after the closing when the statement is due
+	ClosingShift      string        // Where a closing on a weekend or holiday moves to: "none", "before" or "after"
+	CreditLimit       utils.Money   // Zero if no limit is set
+	CreditWarnPercent int64         // Usage of the limit, in percent, that warns when adding expenses
+	BillingCycleDays  int64
+	IsActive          bool
+	CreatedAt         time.Time
+}
+
+// HasCreditLimit reports whether the payment method has a credit limit set
+func (m *PaymentMethod) HasCreditLimit() bool {
+	return m.CreditLimit.IsPositive()
+}
+
+// PaymentMethodDefault is the payment method a member's expenses get when they don't name one,
+// for any category or for a single one when CategoryID is set
+type PaymentMethodDefault struct {
+	ID                int64
+	LobbyID           int64
+	UserTelegramID    int64
+	CategoryID        sql.NullInt64
+	CategoryName      string // Current name of CategoryID; empty for the member's general default
+	PaymentMethodID   int64
+	PaymentMethodName string // Current name of PaymentMethodID
+	CreatedAt         time.Time
+}
+
+// BillingCycle is the closing (and optionally due) date the bank published for one statement of a payment method
+type BillingCycle struct {
+	ID              int64
+	PaymentMethodID int64
+	Month           string // Statement month, "2006-01"
+	ClosingDate     time.Time
+	DueDate         sql.NullTime
+	CreatedAt       time.Time
+}
+
+// StatementReconciliation records that a card statement was checked against the total on the bank's statement
+type StatementReconciliation struct {
+	ID                  int64
+	PaymentMethodID     int64
+	Month               string // Statement month, "2006-01"
+	PeriodStart         time.Time
+	PeriodEnd           time.Time
+	StatementTotal      utils.Money
+	LoggedTotal         utils.Money   // Logged expenses in the statement's currency when reconciled
+	AdjustmentExpenseID sql.NullInt64 // Expense added for the difference, if any
+	ReconciledBy        int64
+	ReconciledAt        time.Time
+}
+
+// Holiday is a non-business day of a lobby; closing days falling on one can be shifted like on weekends
+type Holiday struct {
+	LobbyID int64
+	Date    time.Time
+	Name    sql.NullString
 }
 
 // Expense represents a single expense entry
 type Expense struct {
-	ID                 int64
-	LobbyID            int64
-	SpenderTelegramID  int64
-	PaymentMethodID    sql.NullInt64
-	Amount             float64
-	Description        sql.NullString
-	Category           sql.NullString
-	ExpenseDate        time.Time
-	BillingPeriodStart sql.NullTime
-	BillingPeriodEnd   sql.NullTime
-	CreatedAt          time.Time
+	ID                  int64
+	LobbyID             int64
+	SpenderTelegramID   int64
+	PaymentMethodID     sql.NullInt64
+	Amount              utils.Money // Amount in minor units, with its currency
+	Description         sql.NullString
+	Category            sql.NullString // Name of the category, kept in sync with CategoryID
+	CategoryID          sql.NullInt64
+	ExpenseDate         time.Time
+	BillingPeriodStart  sql.NullTime
+	BillingPeriodEnd    sql.NullTime
+	ParentExpenseID     sql.NullInt64   // First installment of the purchase (NULL for the parent itself)
+	InstallmentNumber   sql.NullInt64   // 1..InstallmentCount for installment purchases
+	InstallmentCount    sql.NullInt64   // Total installments (cuotas), NULL for single payments
+	InstallmentInterest sql.NullFloat64 // Interest in percent added to the purchase, NULL if unknown (older purchases)
+	SplitMode           string          // How the cost is shared: SplitShared, SplitPersonal, SplitPartner or SplitCustom
+	SplitPercent        sql.NullFloat64 // Spender's share in percent, for SplitCustom
+	SplitAmount         sql.NullInt64   // Spender's share in minor units of the expense currency, for SplitCustom
+	CreatedAt           time.Time
+}
+
+// Split modes of an expense
+const (
+	SplitShared   = "shared"   // Split by the lobby's salary percentages (or equally)
+	SplitPersonal = "personal" // Entirely the spender's
+	SplitPartner  = "partner"  // Entirely the other member's
+	SplitCustom   = "custom"   // Spender's share given by SplitPercent or SplitAmount
+)
+
+// IsInstallment reports whether the expense is part of an installment purchase
+func (e *Expense) IsInstallment() bool {
+	return e.InstallmentCount.Valid && e.InstallmentCount.Int64 > 1
+}
+
+// IsShared reports whether the expense is split by the lobby ratio
+func (e *Expense) IsShared() bool {
+	return e.SplitMode == "" || e.SplitMode == SplitShared
+}
+
+// RecurringExpense represents a rule that creates the same expense on a schedule
+type RecurringExpense struct {
+	ID                int64
+	LobbyID           int64
+	SpenderTelegramID int64
+	PaymentMethodID   sql.NullInt64
+	Amount            utils.Money
+	Description       sql.NullString
+	Category          sql.NullString
+	Cadence           string        // "weekly", "monthly" or "yearly"
+	DayOfMonth        sql.NullInt64 // Day of month for monthly/yearly cadences (1-31)
+	StartDate         time.Time
+	EndDate           sql.NullTime // NULL if the rule never ends
+	NextRunDate       time.Time
+	LastRunDate       sql.NullTime
+	IsActive          bool
+	CreatedAt         time.Time
+}
+
+// Income is a member's net income for one month, used to derive the lobby ratio of that month
+type Income struct {
+	ID             int64
+	LobbyID        int64
+	UserTelegramID int64
+	Month          string // "2006-01"
+	Amount         utils.Money
+	CreatedAt      time.Time
+}
+
+// ExchangeRate converts one unit of Currency into Rate units of BaseCurrency from RateDate on
+type ExchangeRate struct {
+	ID           int64
+	LobbyID      int64
+	Currency     string
+	BaseCurrency string
+	RateType     string // "oficial", "mep", "tarjeta", ...
+	RateDate     time.Time
+	Rate         float64
+	CreatedAt    time.Time
+}
+
+// SettlementPayment is money transferred from one lobby member to the other to settle their balance
+type SettlementPayment struct {
+	ID              int64
+	LobbyID         int64
+	PayerTelegramID int64
+	PayeeTelegramID int64
+	Amount          utils.Money
+	PaymentDate     time.Time
+	Note            sql.NullString
+	CreatedAt       time.Time
+}
+
+// InviteToken lets users join a lobby until it expires, is used up, is revoked or the lobby is full
+type InviteToken struct {
+	ID            int64
+	LobbyID       int64
+	Token         string
+	CreatedBy     sql.NullInt64
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	MaxUses       int
+	Uses          int
+	UsedBy        []int64 // Telegram IDs of the users who joined with it, oldest first
+	RevokedAt     sql.NullTime
+	RevokedReason sql.NullString // "revoked", "replaced" or "lobby_full"
+}
+
+// SecurityEvent records a join, a failed join, a lockout, a token regeneration, a member leaving or being
+// removed, or an ownership transfer
+type SecurityEvent struct {
+	ID               int64
+	EventType        string
+	LobbyID          sql.NullInt64
+	UserTelegramID   sql.NullInt64 // Who acted
+	TargetTelegramID sql.NullInt64 // Who was acted on: the removed member, or the new owner
+	Detail           sql.NullString
+	CreatedAt        time.Time
+}
+
+// Contribution is money a lobby member deposited into the shared account
+type Contribution struct {
+	ID               int64
+	LobbyID          int64
+	UserTelegramID   int64
+	Amount           utils.Money
+	ContributionDate time.Time
+	Note             sql.NullString
+	CreatedAt        time.Time
 }
@@ -13,8 +13,9 @@ type AnalysisResult struct {
 	LobbyID              int64
 	CurrentPeriod        time.Time
 	PreviousPeriod       time.Time
-	CurrentTotal         float64
-	PreviousTotal        float64
+	Currency             string // Base currency every amount is converted to
+	CurrentTotal         utils.Money
+	PreviousTotal        utils.Money
 	ChangePercent        float64
 	CategoryChanges      map[string]CategoryChange
 	SpendingSpikes       []SpendingSpike
@@ -25,15 +26,15 @@ type AnalysisResult struct {
 // CategoryChange represents changes in category spending
 type CategoryChange struct {
 	Name         string
-	CurrentTotal float64
-	PreviousTotal float64
+	CurrentTotal utils.Money
+	PreviousTotal utils.Money
 	ChangePercent float64
 }
 
 // SpendingSpike represents a detected spending spike
 type SpendingSpike struct {
 	Category    string
-	Amount      float64
+	Amount      utils.Money
 	ChangePercent float64
 	Period      time.Time
 }
@@ -42,13 +43,15 @@ type SpendingSpike struct {
 type AnalysisService struct {
 	db            *database.DB
 	expenseService *ExpenseService
+	exchangeRateService *ExchangeRateService
 }
 
 // NewAnalysisService creates a new analysis service
-func NewAnalysisService(db *database.DB, expenseService *ExpenseService) *AnalysisService {
+func NewAnalysisService(db *database.DB, expenseService *ExpenseService, exchangeRateService *ExchangeRateService) *AnalysisService {
 	return &AnalysisService{
 		db:            db,
 		expenseService: expenseService,
+		exchangeRateService: exchangeRateService,
 	}
 }
 
@@ -70,10 +73,26 @@ func (s *AnalysisService) AnalyzeMonthly(lobbyID int64) (*AnalysisResult, error)
 		return nil, fmt.Errorf("failed to get previous expenses: %w", err)
 	}
 
+	converter, err := s.exchangeRateService.NewConverter(lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+
+	// Amounts in the lobby's base currency, using the rate effective on each expense date
+	currentAmounts, err := convertExpenses(converter, currentExpenses)
+	if err != nil {
+		return nil, err
+	}
+	previousAmounts, err := convertExpenses(converter, previousExpenses)
+	if err != nil {
+		return nil, err
+	}
+
 	result := &AnalysisResult{
 		LobbyID:        lobbyID,
 		CurrentPeriod:  currentStart,
 		PreviousPeriod: prevStart,
+		Currency:       converter.BaseCurrency,
 		CategoryChanges: make(map[string]CategoryChange),
 		SpendingSpikes: []SpendingSpike{},
 		NewCategories:  []string{},
@@ -81,34 +100,26 @@ func (s *AnalysisService) AnalyzeMonthly(lobbyID int64) (*AnalysisResult, error)
 	}
 
 	// Calculate totals
-	for _, exp := range currentExpenses {
-		result.CurrentTotal += exp.Amount
+	if result.CurrentTotal, err = utils.Sum(result.Currency, currentAmounts...); err != nil {
+		return nil, err
 	}
-	for _, exp := range previousExpenses {
-		result.PreviousTotal += exp.Amount
+	if result.PreviousTotal, err = utils.Sum(result.Currency, previousAmounts...); err != nil {
+		return nil, err
 	}
 
 	// Calculate change percentage
-	if result.PreviousTotal > 0 {
-		result.ChangePercent = ((result.CurrentTotal - result.PreviousTotal) / result.PreviousTotal) * 100
-	} else if result.CurrentTotal > 0 {
-		result.ChangePercent = 100 // New spending
+	if result.ChangePercent, err = changePercent(result.CurrentTotal, result.PreviousTotal); err != nil {
+		return nil, err
 	}
 
 	// Analyze by category
-	currentCategories := make(map[string]float64)
-	previousCategories := make(map[string]float64)
-
-	for _, exp := range currentExpenses {
-		if exp.Category.Valid {
-			currentCategories[exp.Category.String] += exp.Amount
-		}
+	currentCategories, err := categoryTotals(currentExpenses, currentAmounts)
+	if err != nil {
+		return nil, err
 	}
-
-	for _, exp := range previousExpenses {
-		if exp.Category.Valid {
-			previousCategories[exp.Category.String] += exp.Amount
-		}
+	previousCategories, err := categoryTotals(previousExpenses, previousAmounts)
+	if err != nil {
+		return nil, err
 	}
 
 	// Find category changes
@@ -121,35 +132,33 @@ func (s *AnalysisService) AnalyzeMonthly(lobbyID int64) (*AnalysisResult, error)
 	}
 
 	for cat := range allCategories {
-		currentTotal := currentCategories[cat]
-		previousTotal := previousCategories[cat]
+		currentTotal := currentCategories[cat].WithCurrency(result.Currency)
+		previousTotal := previousCategories[cat].WithCurrency(result.Currency)
 		
-		if currentTotal > 0 && previousTotal == 0 {
+		if currentTotal.IsPositive() && previousTotal.IsZero() {
 			result.NewCategories = append(result.NewCategories, cat)
-		} else if currentTotal == 0 && previousTotal > 0 {
+		} else if currentTotal.IsZero() && previousTotal.IsPositive() {
 			result.DiscontinuedCategories = append(result.DiscontinuedCategories, cat)
 		}
 
-		var changePercent float64
-		if previousTotal > 0 {
-			changePercent = ((currentTotal - previousTotal) / previousTotal) * 100
-		} else if currentTotal > 0 {
-			changePercent = 100
+		change, err := changePercent(currentTotal, previousTotal)
+		if err != nil {
+			return nil, err
 		}
 
 		result.CategoryChanges[cat] = CategoryChange{
 			Name:         cat,
 			CurrentTotal: currentTotal,
 			PreviousTotal: previousTotal,
-			ChangePercent: changePercent,
+			ChangePercent: change,
 		}
 
 		// Detect spikes (>20% increase)
-		if changePercent > 20 && previousTotal > 0 {
+		if change > 20 && previousTotal.IsPositive() {
 			result.SpendingSpikes = append(result.SpendingSpikes, SpendingSpike{
 				Category:     cat,
 				Amount:       currentTotal,
-				ChangePercent: changePercent,
+				ChangePercent: change,
 				Period:       currentStart,
 			})
 		}
@@ -163,3 +172,46 @@ func (s *AnalysisService) AnalyzeMonthly(lobbyID int64) (*AnalysisResult, error)
 	return result, nil
 }
 
+// changePercent returns the percentage change from previous to current (100 for new spending)
+func changePercent(current, previous utils.Money) (float64, error) {
+	if previous.IsPositive() {
+		difference, err := current.Sub(previous)
+		if err != nil {
+			return 0, err
+		}
+		ratio, err := difference.Ratio(previous)
+		return ratio * 100, err
+	} else if current.IsPositive() {
+		return 100, nil // New spending
+	}
+	return 0, nil
+}
+
+// categoryTotals adds up the amounts of the expenses of each category
+func categoryTotals(expenses []*database.Expense, amounts []utils.Money) (map[string]utils.Money, error) {
+	totals := make(map[string]utils.Money)
+	for i, exp := range expenses {
+		if !exp.Category.Valid {
+			continue
+		}
+		total, err := totals[exp.Category.String].Add(amounts[i])
+		if err != nil {
+			return nil, err
+		}
+		totals[exp.Category.String] = total
+	}
+	return totals, nil
+}
+
+// convertExpenses returns the amount of each expense in the converter's base currency
+func convertExpenses(converter *CurrencyConverter, expenses []*database.Expense) ([]utils.Money, error) {
+	amounts := make([]utils.Money, len(expenses))
+	for i, exp := range expenses {
+		amount, err := converter.ExpenseAmount(exp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert expense %d: %w", exp.ID, err)
+		}
+		amounts[i] = amount
+	}
+	return amounts, nil
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSettlementPaymentNotFound is returned when a settlement payment does not exist in the lobby
+var ErrSettlementPaymentNotFound = errors.New("settlement payment not found")
+
+// BalancePeriod is one month of a lobby's running balance
+type BalancePeriod struct {
+	Start   time.Time
+	End     time.Time
+	Members []MemberBalance // In joining order
+}
+
+// MemberBalance is one member's part of a month of the running balance.
+// Amounts are positive when they make the member owe the others.
+type MemberBalance struct {
+	UserID  int64
+	Opening utils.Money // Balance carried forward from the previous month
+	Debt    utils.Money // What the month's expenses add (minus the Balance of its settlement)
+	Paid    utils.Money // Net payments to the other members during the month: sent minus received
+	Closing utils.Money // Opening + Debt - Paid
+}
+
+// BalanceResult is a lobby's running balance, month by month
+type BalanceResult struct {
+	LobbyID   int64
+	Currency  string
+	MemberIDs []int64         // In joining order
+	Balances  []utils.Money   // What each member owes the others (negative when owed), in the order of MemberIDs
+	Periods   []BalancePeriod // Oldest first
+	Transfers []Transfer      // Fewest payments between members that settle the balances
+}
+
+// settlementPaymentColumns lists the columns selected for a payment, in scanSettlementPayment order
+const settlementPaymentColumns = `id, lobby_id, payer_telegram_id, payee_telegram_id, amount_minor, currency, payment_date, note, created_at`
+
+// scanSettlementPayment scans a row selected with settlementPaymentColumns
+func scanSettlementPayment(row rowScanner) (*database.SettlementPayment, error) {
+	var payment database.SettlementPayment
+	err := row.Scan(
+		&payment.ID,
+		&payment.LobbyID,
+		&payment.PayerTelegramID,
+		&payment.PayeeTelegramID,
+		&payment.Amount.Amount,
+		&payment.Amount.Currency,
+		&payment.PaymentDate,
+		&payment.Note,
+		&payment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// RecordPayment records money transferred by a lobby member (or former member) to another one.
+// An amount without currency is in the lobby's base currency.
+func (s *SettlementService) RecordPayment(lobbyID int64, payerTelegramID int64, payeeTelegramID int64, amount utils.Money, paymentDate time.Time, note string) (*database.SettlementPayment, error) {
+	conn := s.db.GetConn()
+
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	lobby, err := s.lobbyService.GetLobbyByID(lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lobby: %w", err)
+	}
+	if lobby == nil {
+		return nil, fmt.Errorf("lobby not found")
+	}
+
+	// Former members can still settle what they owe or are owed
+	if lobby.Members, err = s.lobbyService.GetMembersBetween(lobbyID, time.Time{}, time.Time{}); err != nil {
+		return nil, err
+	}
+	if !lobby.IsMember(payerTelegramID) || !lobby.IsMember(payeeTelegramID) {
+		return nil, fmt.Errorf("payer and payee must be members of the lobby")
+	}
+	if payerTelegramID == payeeTelegramID {
+		return nil, fmt.Errorf("payer and payee must be different members")
+	}
+
+	currency, err := s.expenseService.resolveCurrency(lobbyID, amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
+
+	noteValue := sql.NullString{String: note, Valid: note != ""}
+	day := utils.CalendarDate(paymentDate)
+	now := time.Now()
+
+	query := `INSERT INTO settlement_payments (lobby_id, payer_telegram_id, payee_telegram_id, amount_minor, currency, payment_date, note, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := conn.Exec(query, lobbyID, payerTelegramID, payeeTelegramID, amount.Amount, amount.Currency, day, noteValue, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record settlement payment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment ID: %w", err)
+	}
+
+	return &database.SettlementPayment{
+		ID:              id,
+		LobbyID:         lobbyID,
+		PayerTelegramID: payerTelegramID,
+		PayeeTelegramID: payeeTelegramID,
+		Amount:          amount,
+		PaymentDate:     day,
+		Note:            noteValue,
+		CreatedAt:       now,
+	}, nil
+}
+
+// GetPayments gets the settlement payments of a lobby within an optional date range, newest first
+func (s *SettlementService) GetPayments(lobbyID int64, startDate *time.Time, endDate *time.Time) ([]*database.SettlementPayment, error) {
+	conn := s.db.GetConn()
+
+	query := `SELECT ` + settlementPaymentColumns + `
+	          FROM settlement_payments WHERE lobby_id = ?`
+	args := []interface{}{lobbyID}
+
+	if startDate != nil {
+		query += " AND payment_date >= ?"
+		args = append(args, utils.CalendarDate(*startDate))
+	}
+	if endDate != nil {
+		query += " AND payment_date <= ?"
+		args = append(args, utils.CalendarDayEnd(*endDate))
+	}
+
+	query += " ORDER BY payment_date DESC, id DESC"
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settlement payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*database.SettlementPayment
+	for rows.Next() {
+		payment, err := scanSettlementPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settlement payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
+// DeletePayment deletes a settlement payment of the lobby
+func (s *SettlementService) DeletePayment(lobbyID int64, id int64) error {
+	conn := s.db.GetConn()
+
+	result, err := conn.Exec(`DELETE FROM settlement_payments WHERE id = ? AND lobby_id = ?`, id, lobbyID)
+	if err != nil {
+		return fmt.Errorf("failed to delete settlement payment: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrSettlementPaymentNotFound
+	}
+	return nil
+}
+
+// CalculateBalance computes the running balance of a lobby up to the given date.
+// Starting with the month of the first expense or payment, every calendar month adds its settlement debt
+// and subtracts the payments made during it, so unpaid and partially paid months carry forward.
+func (s *SettlementService) CalculateBalance(lobbyID int64, until time.Time) (*BalanceResult, error) {
+	lobby, err := s.lobbyService.GetLobbyByID(lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lobby: %w", err)
+	}
+	if lobby == nil {
+		return nil, fmt.Errorf("lobby not found")
+	}
+
+	converter, err := s.exchangeRateService.NewConverter(lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+
+	first, err := s.firstActivityDate(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	// The months are bounded in UTC like the stored dates, so the balance runs through the local day of until
+	until = utils.CalendarDayEnd(until)
+
+	// Former members keep what they owe or are owed from the months they were in the lobby
+	if first != nil {
+		if lobby.Members, err = s.lobbyService.GetMembersBetween(lobbyID, *first, until); err != nil {
+			return nil, err
+		}
+	}
+	memberIDs := lobby.MemberIDs()
+	result := &BalanceResult{
+		LobbyID:   lobbyID,
+		Currency:  converter.BaseCurrency,
+		MemberIDs: memberIDs,
+		Balances:  make([]utils.Money, len(memberIDs)),
+	}
+	index := make(map[int64]int, len(memberIDs))
+	for i, memberID := range memberIDs {
+		index[memberID] = i
+		result.Balances[i] = utils.NewMoney(0, result.Currency)
+	}
+
+	if first == nil || first.After(until) {
+		return result, nil
+	}
+
+	for month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(until); month = month.AddDate(0, 1, 0) {
+		start, end := utils.GetMonthStartEnd(month.Year(), month.Month())
+		if end.After(until) {
+			end = until
+		}
+
+		settlement, err := s.CalculateSettlement(lobbyID, &start, &end)
+		if err != nil {
+			return nil, err
+		}
+
+		period := BalancePeriod{Start: start, End: end, Members: make([]MemberBalance, len(memberIDs))}
+		for i, memberID := range memberIDs {
+			period.Members[i] = MemberBalance{
+				UserID:  memberID,
+				Opening: result.Balances[i],
+				Debt:    utils.NewMoney(0, result.Currency),
+				Paid:    utils.NewMoney(0, result.Currency),
+			}
+			// Members who had left before the month are not in its settlement
+			if member := settlement.Member(memberID); member != nil {
+				period.Members[i].Debt = member.Balance.Neg()
+			}
+		}
+
+		payments, err := s.GetPayments(lobbyID, &start, &end)
+		if err != nil {
+			return nil, err
+		}
+		for _, payment := range payments {
+			amount, err := converter.Convert(payment.Amount, payment.PaymentDate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert payment %d: %w", payment.ID, err)
+			}
+			// Only payments between members count
+			if payer, ok := index[payment.PayerTelegramID]; ok {
+				if period.Members[payer].Paid, err = period.Members[payer].Paid.Add(amount); err != nil {
+					return nil, err
+				}
+			}
+			if payee, ok := index[payment.PayeeTelegramID]; ok {
+				if period.Members[payee].Paid, err = period.Members[payee].Paid.Sub(amount); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		for i := range period.Members {
+			member := &period.Members[i]
+			if member.Closing, err = utils.Sum(result.Currency, member.Opening, member.Debt, member.Paid.Neg()); err != nil {
+				return nil, err
+			}
+			result.Balances[i] = member.Closing
+		}
+		result.Periods = append(result.Periods, period)
+	}
+
+	// Transfers settle what each member is owed, the opposite of what they owe
+	owed := make([]utils.Money, len(result.Balances))
+	for i, balance := range result.Balances {
+		owed[i] = balance.Neg()
+	}
+	if result.Transfers, err = settleBalances(memberIDs, owed); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// firstActivityDate returns the date of a lobby's first expense or settlement payment, or nil if it has neither
+func (s *SettlementService) firstActivityDate(lobbyID int64) (*time.Time, error) {
+	conn := s.db.GetConn()
+
+	var first *time.Time
+	queries := []string{
+		`SELECT expense_date FROM expenses WHERE lobby_id = ? ORDER BY expense_date LIMIT 1`,
+		`SELECT payment_date FROM settlement_payments WHERE lobby_id = ? ORDER BY payment_date LIMIT 1`,
+	}
+	for _, query := range queries {
+		var date time.Time
+		err := conn.QueryRow(query, lobbyID).Scan(&date)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query first activity: %w", err)
+		}
+		if first == nil || date.Before(*first) {
+			first = &date
+		}
+	}
+	return first, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"botGastosPareja/pkg/utils"
+	"testing"
+	"time"
+)
+
+func TestCalculateBalanceMonthBoundsOutsideUTC(t *testing.T) {
+	loc := inLocation(t, "America/Argentina/Buenos_Aires")
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 2)
+	expenses := NewExpenseService(db)
+	settlements := newTestSettlementService(db)
+	// Both members were in the lobby through the months below
+	if _, err := db.GetConn().Exec(`UPDATE lobby_members SET joined_at = ? WHERE lobby_id = ?`, day("2026-01-01"), lobby.ID); err != nil {
+		t.Fatalf("backdate memberships: %v", err)
+	}
+
+	// Late on the last day of a month is already the next month in UTC, and early on the first is
+	// still the previous one in a zone east of it
+	spent := []struct {
+		date   time.Time
+		amount int64
+	}{
+		{time.Date(2026, 9, 30, 22, 0, 0, 0, loc), 1000},
+		{time.Date(2026, 10, 1, 0, 30, 0, 0, loc), 2000},
+		{time.Date(2026, 10, 31, 23, 0, 0, 0, loc), 4000},
+		{time.Date(2026, 11, 1, 0, 0, 0, 0, loc), 8000},
+	}
+	for _, expense := range spent {
+		if _, err := expenses.CreateExpense(lobby.ID, 1, utils.NewMoney(expense.amount, "ARS"), "", "", expense.date, nil, nil, false); err != nil {
+			t.Fatalf("CreateExpense: %v", err)
+		}
+	}
+	if _, err := settlements.RecordPayment(lobby.ID, 2, 1, utils.NewMoney(500, "ARS"), time.Date(2026, 10, 31, 23, 30, 0, 0, loc), ""); err != nil {
+		t.Fatalf("RecordPayment: %v", err)
+	}
+
+	// Already November in UTC, but the balance runs through October
+	balance, err := settlements.CalculateBalance(lobby.ID, time.Date(2026, 10, 31, 23, 45, 0, 0, loc))
+	if err != nil {
+		t.Fatalf("CalculateBalance: %v", err)
+	}
+
+	tests := []struct {
+		month string
+		debt  int64 // Member 2's half of the month's expenses
+		paid  int64
+	}{
+		{"2026-09", 500, 0},
+		{"2026-10", 3000, 500},
+	}
+	if len(balance.Periods) != len(tests) {
+		t.Fatalf("got %d months, want %d", len(balance.Periods), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.month, func(t *testing.T) {
+			period := balance.Periods[i]
+			if got := utils.FormatMonth(period.Start); got != tt.month {
+				t.Fatalf("month = %s, want %s", got, tt.month)
+			}
+			member := period.Members[1]
+			if member.Debt.Amount != tt.debt {
+				t.Errorf("debt = %s, want %s", member.Debt, utils.NewMoney(tt.debt, "ARS"))
+			}
+			if member.Paid.Amount != tt.paid {
+				t.Errorf("paid = %s, want %s", member.Paid, utils.NewMoney(tt.paid, "ARS"))
+			}
+		})
+	}
+	if got := balance.Balances[1].Amount; got != 3000 {
+		t.Errorf("member 2 owes %s, want 30.00", balance.Balances[1])
+	}
+}
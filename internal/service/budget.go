@@ -0,0 +1,405 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBudgetNotFound is returned when a lobby has no budget for the requested category
+var ErrBudgetNotFound = errors.New("budget not found")
+
+// BudgetService manages the monthly budgets of each lobby and the warnings they raise
+type BudgetService struct {
+	db                  *database.DB
+	exchangeRateService *ExchangeRateService
+}
+
+// NewBudgetService creates a new budget service
+func NewBudgetService(db *database.DB, exchangeRateService *ExchangeRateService) *BudgetService {
+	return &BudgetService{
+		db:                  db,
+		exchangeRateService: exchangeRateService,
+	}
+}
+
+// BudgetStatus is how much of a budget has been spent in a month, in the lobby's base currency
+type BudgetStatus struct {
+	Budget    *database.Budget
+	Limit     utils.Money
+	Spent     utils.Money
+	Remaining utils.Money // What is left of the budget; negative when it is exceeded
+	Percent   float64     // Spent as a percentage of Limit
+}
+
+// BudgetReport is the status of every budget of a lobby for one month
+type BudgetReport struct {
+	LobbyID  int64
+	Month    time.Time // First day of the month
+	Currency string
+	DaysLeft int            // Days left in the month counting today; 0 for past months
+	Budgets  []BudgetStatus // Overall budget first, then by category name
+}
+
+// BudgetAlert is a threshold a budget has just reached
+type BudgetAlert struct {
+	Status    BudgetStatus
+	Month     time.Time
+	Threshold int // Percent of the limit
+	DaysLeft  int
+}
+
+// budgetColumns lists the columns selected for a budget, in scanBudget order
+const budgetColumns = `b.id, b.lobby_id, b.category_id, COALESCE(c.name, ''), b.amount_minor, b.currency, b.created_at`
+
+// scanBudget scans a row selected with budgetColumns
+func scanBudget(row rowScanner) (*database.Budget, error) {
+	var budget database.Budget
+	err := row.Scan(
+		&budget.ID,
+		&budget.LobbyID,
+		&budget.CategoryID,
+		&budget.CategoryName,
+		&budget.Amount.Amount,
+		&budget.Amount.Currency,
+		&budget.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// SetBudget sets the monthly limit of a category, or of the whole lobby when category is empty.
+// An amount without currency is in the lobby's base currency. Changing a limit lets its
+// thresholds warn again.
+func (s *BudgetService) SetBudget(lobbyID int64, category string, amount utils.Money) (*database.Budget, error) {
+	conn := s.db.GetConn()
+
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("budget must be positive")
+	}
+
+	currency, err := NewExpenseService(s.db).resolveCurrency(lobbyID, amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
+
+	existing, err := s.GetBudget(lobbyID, category)
+	if err != nil && !errors.Is(err, ErrBudgetNotFound) {
+		return nil, err
+	}
+
+	if existing != nil {
+		tx, err := conn.Begin()
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`UPDATE budgets SET amount_minor = ?, currency = ? WHERE id = ?`,
+			amount.Amount, amount.Currency, existing.ID); err != nil {
+			return nil, fmt.Errorf("failed to update budget: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM budget_alerts WHERE budget_id = ?`, existing.ID); err != nil {
+			return nil, fmt.Errorf("failed to reset budget alerts: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit budget: %w", err)
+		}
+		existing.Amount = amount
+		return existing, nil
+	}
+
+	var categoryID sql.NullInt64
+	categoryName := ""
+	if strings.TrimSpace(category) != "" {
+		cat, err := NewCategoryService(s.db).getExistingCategory(lobbyID, category)
+		if err != nil {
+			return nil, err
+		}
+		categoryID = sql.NullInt64{Int64: cat.ID, Valid: true}
+		categoryName = cat.Name
+	}
+
+	now := time.Now()
+	result, err := conn.Exec(`INSERT INTO budgets (lobby_id, category_id, amount_minor, currency, created_at)
+	          VALUES (?, ?, ?, ?, ?)`, lobbyID, categoryID, amount.Amount, amount.Currency, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create budget: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget ID: %w", err)
+	}
+
+	return &database.Budget{
+		ID:           id,
+		LobbyID:      lobbyID,
+		CategoryID:   categoryID,
+		CategoryName: categoryName,
+		Amount:       amount,
+		CreatedAt:    now,
+	}, nil
+}
+
+// GetBudgets gets the budgets of a lobby: the overall one first, then by category name
+func (s *BudgetService) GetBudgets(lobbyID int64) ([]*database.Budget, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT `+budgetColumns+`
+	          FROM budgets b LEFT JOIN categories c ON c.id = b.category_id
+	          WHERE b.lobby_id = ?`, lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query budgets: %w", err)
+	}
+	defer rows.Close()
+
+	var budgets []*database.Budget
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		budgets = append(budgets, budget)
+	}
+
+	sort.SliceStable(budgets, func(i, j int) bool {
+		if budgets[i].CategoryID.Valid != budgets[j].CategoryID.Valid {
+			return !budgets[i].CategoryID.Valid
+		}
+		return utils.FoldText(budgets[i].CategoryName) < utils.FoldText(budgets[j].CategoryName)
+	})
+	return budgets, nil
+}
+
+// GetBudget gets the budget of a category, or the overall budget when category is empty
+func (s *BudgetService) GetBudget(lobbyID int64, category string) (*database.Budget, error) {
+	budgets, err := s.GetBudgets(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := utils.FoldText(category)
+	for _, budget := range budgets {
+		if key == "" && !budget.CategoryID.Valid {
+			return budget, nil
+		}
+		if key != "" && budget.CategoryID.Valid && utils.FoldText(budget.CategoryName) == key {
+			return budget, nil
+		}
+	}
+	return nil, ErrBudgetNotFound
+}
+
+// DeleteBudget deletes the budget of a category, or the overall budget when category is empty
+func (s *BudgetService) DeleteBudget(lobbyID int64, category string) (*database.Budget, error) {
+	budget, err := s.GetBudget(lobbyID, category)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.GetConn().Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM budget_alerts WHERE budget_id = ?`, budget.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete budget alerts: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM budgets WHERE id = ?`, budget.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete budget: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit budget deletion: %w", err)
+	}
+	return budget, nil
+}
+
+// GetReport computes how much of each budget of the lobby was spent in the month of the given date
+func (s *BudgetService) GetReport(lobbyID int64, month time.Time) (*BudgetReport, error) {
+	budgets, err := s.GetBudgets(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildReport(lobbyID, month, budgets, time.Now())
+}
+
+// buildReport computes the status of the given budgets for the month of a date, as seen on "now"
+func (s *BudgetService) buildReport(lobbyID int64, month time.Time, budgets []*database.Budget, now time.Time) (*BudgetReport, error) {
+	start, end := utils.GetMonthStartEnd(month.Year(), month.Month())
+
+	converter, err := s.exchangeRateService.NewConverter(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BudgetReport{
+		LobbyID:  lobbyID,
+		Month:    start,
+		Currency: converter.BaseCurrency,
+		DaysLeft: daysLeftInMonth(start, end, now),
+	}
+	if len(budgets) == 0 {
+		return report, nil
+	}
+
+	total, byCategory, err := s.monthSpending(lobbyID, start, end, converter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Limits in another currency are converted at today's rate (or the month's end for past months)
+	rateDate := now
+	if end.Before(now) {
+		rateDate = end
+	}
+
+	for _, budget := range budgets {
+		limit, err := converter.Convert(budget.Amount, rateDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert budget: %w", err)
+		}
+
+		spent := total
+		if budget.CategoryID.Valid {
+			spent = byCategory[budget.CategoryID.Int64]
+		}
+		spent = spent.WithCurrency(converter.BaseCurrency)
+		remaining, err := limit.Sub(spent)
+		if err != nil {
+			return nil, err
+		}
+		ratio, err := spent.Ratio(limit)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Budgets = append(report.Budgets, BudgetStatus{
+			Budget:    budget,
+			Limit:     limit,
+			Spent:     spent,
+			Remaining: remaining,
+			Percent:   ratio * 100,
+		})
+	}
+	return report, nil
+}
+
+// monthSpending sums a lobby's expenses dated within a month in the base currency, in total and per category.
+// Installments count in the month they are charged.
+func (s *BudgetService) monthSpending(lobbyID int64, start, end time.Time, converter *CurrencyConverter) (utils.Money, map[int64]utils.Money, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT `+expenseColumns+` FROM expenses
+	          WHERE lobby_id = ? AND expense_date >= ? AND expense_date <= ?`,
+		lobbyID, utils.CalendarDate(start), utils.CalendarDayEnd(end))
+	if err != nil {
+		return utils.Money{}, nil, fmt.Errorf("failed to query expenses: %w", err)
+	}
+	defer rows.Close()
+
+	expenses, err := scanExpenses(rows)
+	if err != nil {
+		return utils.Money{}, nil, err
+	}
+
+	total := utils.NewMoney(0, converter.BaseCurrency)
+	byCategory := make(map[int64]utils.Money)
+	for _, expense := range expenses {
+		amount, err := converter.ExpenseAmount(expense)
+		if err != nil {
+			return utils.Money{}, nil, fmt.Errorf("failed to convert expense %d: %w", expense.ID, err)
+		}
+		if total, err = total.Add(amount); err != nil {
+			return utils.Money{}, nil, err
+		}
+		if expense.CategoryID.Valid {
+			if byCategory[expense.CategoryID.Int64], err = byCategory[expense.CategoryID.Int64].Add(amount); err != nil {
+				return utils.Money{}, nil, err
+			}
+		}
+	}
+	return total, byCategory, nil
+}
+
+// daysLeftInMonth returns how many days of a month remain on "now", counting today:
+// every day for a future month, none for a past one
+func daysLeftInMonth(start, end, now time.Time) int {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if today.Before(start) {
+		return end.Day()
+	}
+	if today.After(end) {
+		return 0
+	}
+	return end.Day() - today.Day() + 1
+}
+
+// CheckExpense checks the budgets an expense counts against (the overall one and its category's)
+// and returns the thresholds they have just reached in the expense's month.
+// Each threshold is reported once per budget and month; when several are crossed at once only
+// the highest is returned.
+func (s *BudgetService) CheckExpense(expense *database.Expense) ([]BudgetAlert, error) {
+	conn := s.db.GetConn()
+
+	budgets, err := s.GetBudgets(expense.LobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var relevant []*database.Budget
+	for _, budget := range budgets {
+		if !budget.CategoryID.Valid || (expense.CategoryID.Valid && budget.CategoryID.Int64 == expense.CategoryID.Int64) {
+			relevant = append(relevant, budget)
+		}
+	}
+	if len(relevant) == 0 {
+		return nil, nil
+	}
+
+	thresholds, err := NewLobbyService(s.db).GetBudgetThresholds(expense.LobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.buildReport(expense.LobbyID, expense.ExpenseDate, relevant, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	month := utils.FormatMonth(report.Month)
+
+	var alerts []BudgetAlert
+	for _, status := range report.Budgets {
+		reached := 0
+		for _, threshold := range thresholds {
+			if status.Percent < float64(threshold) {
+				break
+			}
+			result, err := conn.Exec(`INSERT OR IGNORE INTO budget_alerts (budget_id, month, threshold, created_at)
+			          VALUES (?, ?, ?, ?)`, status.Budget.ID, month, threshold, time.Now())
+			if err != nil {
+				return nil, fmt.Errorf("failed to record budget alert: %w", err)
+			}
+			if affected, _ := result.RowsAffected(); affected > 0 {
+				reached = threshold
+			}
+		}
+		if reached > 0 {
+			alerts = append(alerts, BudgetAlert{
+				Status:    status,
+				Month:     report.Month,
+				Threshold: reached,
+				DaysLeft:  report.DaysLeft,
+			})
+		}
+	}
+	return alerts, nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"botGastosPareja/pkg/utils"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCheckExpenseThresholds(t *testing.T) {
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 2)
+	expenses := NewExpenseService(db)
+	lobbies := NewLobbyService(db)
+	budgets := NewBudgetService(db, NewExchangeRateService(db, lobbies))
+	today := utils.CalendarDate(time.Now())
+	lastMonth := today.AddDate(0, 0, -today.Day())
+
+	// Reports the alerts an expense raises, e.g. "overall 80" or "Food 100"
+	add := func(t *testing.T, amount int64, category string, date time.Time) []string {
+		t.Helper()
+		expense, err := expenses.CreateExpense(lobby.ID, 1, utils.NewMoney(amount, "ARS"), "", category, date, nil, nil, false)
+		if err != nil {
+			t.Fatalf("CreateExpense: %v", err)
+		}
+		alerts, err := budgets.CheckExpense(expense)
+		if err != nil {
+			t.Fatalf("CheckExpense: %v", err)
+		}
+		var got []string
+		for _, alert := range alerts {
+			name := alert.Status.Budget.CategoryName
+			if name == "" {
+				name = "overall"
+			}
+			got = append(got, fmt.Sprintf("%s %d", name, alert.Threshold))
+		}
+		return got
+	}
+	setBudget := func(t *testing.T, category string, amount int64) {
+		t.Helper()
+		if _, err := budgets.SetBudget(lobby.ID, category, utils.NewMoney(amount, "ARS")); err != nil {
+			t.Fatalf("SetBudget: %v", err)
+		}
+	}
+
+	setBudget(t, "", 10000)
+	setBudget(t, "Food", 5000)
+
+	// Thresholds are 80% and 100% unless the lobby changes them
+	tests := []struct {
+		name     string
+		amount   int64
+		category string
+		date     time.Time
+		setup    func(t *testing.T)
+		want     []string
+	}{
+		{"below every threshold", 5000, "", today, nil, nil},
+		{"reaches 80%", 3000, "", today, nil, []string{"overall 80"}},
+		{"80% again", 500, "", today, nil, nil},
+		{"category counts towards overall", 2000, "Food", today, nil, []string{"overall 100"}},
+		{"category reaches 80%", 2500, "Food", today, nil, []string{"Food 80"}},
+		{"other months have their own alerts", 9000, "", lastMonth, nil, []string{"overall 80"}},
+		{"several at once report the highest", 3000, "Food", today, nil, []string{"Food 100"}},
+		{"changing the limit warns again", 0, "", today, func(t *testing.T) { setBudget(t, "", 20000) }, nil},
+		{"new limit reached", 100, "", today, nil, []string{"overall 80"}},
+		{"custom thresholds", 9000, "", today, func(t *testing.T) {
+			if err := lobbies.SetBudgetThresholds(lobby.ID, []int{150, 90, 120}); err != nil {
+				t.Fatalf("SetBudgetThresholds: %v", err)
+			}
+		}, []string{"overall 120"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			if tt.amount == 0 {
+				return
+			}
+			if got := add(t, tt.amount, tt.category, tt.date); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("alerts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrCategoryNotFound is returned when a lobby has no category with the given name
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryExists is returned when a lobby already has a category with the given name
+	ErrCategoryExists = errors.New("category already exists")
+)
+
+// maxCategoryNameLength is the longest category name accepted
+const maxCategoryNameLength = 40
+
+// maxCategorySuggestions is how many similar categories are suggested for an unknown name
+const maxCategorySuggestions = 3
+
+// DefaultCategories are the categories every lobby starts with, by language of the lobby creator
+var DefaultCategories = map[string][]string{
+	"en":    {"Groceries", "Food", "Home", "Utilities", "Transport", "Health", "Entertainment", "Clothing", "Travel", "Gifts", "Other"},
+	"es_AR": {"Supermercado", "Comida", "Hogar", "Servicios", "Transporte", "Salud", "Entretenimiento", "Ropa", "Viajes", "Regalos", "Otros"},
+}
+
+// CategoryService manages the expense categories of each lobby
+type CategoryService struct {
+	db *database.DB
+}
+
+// NewCategoryService creates a new category service
+func NewCategoryService(db *database.DB) *CategoryService {
+	return &CategoryService{db: db}
+}
+
+// rowQuerier is implemented by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// ensureDefaults adds the default categories to a lobby the first time its categories are used.
+// Defaults whose name the lobby already has are skipped.
+func (s *CategoryService) ensureDefaults(lobbyID int64) error {
+	conn := s.db.GetConn()
+
+	var seeded bool
+	var language sql.NullString
+	err := conn.QueryRow(`SELECT l.categories_seeded, u.language
+	          FROM lobbies l LEFT JOIN users u ON u.telegram_id = l.user1_telegram_id
+	          WHERE l.id = ?`, lobbyID).Scan(&seeded, &language)
+	if err == sql.ErrNoRows || seeded {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check default categories: %w", err)
+	}
+
+	defaults, ok := DefaultCategories[language.String]
+	if !ok {
+		defaults = DefaultCategories["en"]
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := queryCategories(tx, lobbyID, true)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, category := range existing {
+		known[utils.FoldText(category.Name)] = true
+	}
+
+	for _, name := range defaults {
+		if known[utils.FoldText(name)] {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO categories (lobby_id, name, is_default) VALUES (?, ?, 1)`, lobbyID, name); err != nil {
+			return fmt.Errorf("failed to add default category: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE lobbies SET categories_seeded = 1 WHERE id = ?`, lobbyID); err != nil {
+		return fmt.Errorf("failed to mark default categories: %w", err)
+	}
+	return tx.Commit()
+}
+
+// queryCategories loads the categories of a lobby, sorted by name
+func queryCategories(q rowQuerier, lobbyID int64, includeArchived bool) ([]*database.Category, error) {
+	query := `SELECT id, lobby_id, name, is_default, is_archived FROM categories WHERE lobby_id = ?`
+	if !includeArchived {
+		query += " AND is_archived = 0"
+	}
+	query += " ORDER BY name COLLATE NOCASE"
+
+	rows, err := q.Query(query, lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []*database.Category
+	for rows.Next() {
+		var category database.Category
+		var isDefault sql.NullBool
+		if err := rows.Scan(&category.ID, &category.LobbyID, &category.Name, &isDefault, &category.IsArchived); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		category.IsDefault = isDefault.Bool
+		categories = append(categories, &category)
+	}
+	return categories, nil
+}
+
+// GetCategories gets the categories of a lobby sorted by name, seeding the defaults on first use
+func (s *CategoryService) GetCategories(lobbyID int64, includeArchived bool) ([]*database.Category, error) {
+	if err := s.ensureDefaults(lobbyID); err != nil {
+		return nil, err
+	}
+	return queryCategories(s.db.GetConn(), lobbyID, includeArchived)
+}
+
+// GetCategoryByName finds a lobby category by name, ignoring case, accents and extra spaces.
+// Archived categories are included. Returns nil if there is none.
+func (s *CategoryService) GetCategoryByName(lobbyID int64, name string) (*database.Category, error) {
+	categories, err := s.GetCategories(lobbyID, true)
+	if err != nil {
+		return nil, err
+	}
+	key := utils.FoldText(name)
+	for _, category := range categories {
+		if utils.FoldText(category.Name) == key {
+			return category, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetCategoryUsage counts the expenses of each category of a lobby, by category ID
+func (s *CategoryService) GetCategoryUsage(lobbyID int64) (map[int64]int, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT category_id, COUNT(*) FROM expenses
+	          WHERE lobby_id = ? AND category_id IS NOT NULL GROUP BY category_id`, lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count category usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan category usage: %w", err)
+		}
+		usage[id] = count
+	}
+	return usage, nil
+}
+
+// cleanCategoryName trims and validates a category name
+func cleanCategoryName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return "", fmt.Errorf("category name cannot be empty")
+	}
+	if len([]rune(name)) > maxCategoryNameLength {
+		return "", fmt.Errorf("category name must be at most %d characters", maxCategoryNameLength)
+	}
+	return name, nil
+}
+
+// CreateCategory adds a category to a lobby
+func (s *CategoryService) CreateCategory(lobbyID int64, name string) (*database.Category, error) {
+	name, err := cleanCategoryName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.GetCategoryByName(lobbyID, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCategoryExists, existing.Name)
+	}
+
+	result, err := s.db.GetConn().Exec(`INSERT INTO categories (lobby_id, name, is_default) VALUES (?, ?, 0)`, lobbyID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create category: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category ID: %w", err)
+	}
+
+	return &database.Category{ID: id, LobbyID: lobbyID, Name: name}, nil
+}
+
+// ResolveCategory returns the lobby category with the given name, creating it if the lobby has none.
+// An empty name resolves to nil.
+func (s *CategoryService) ResolveCategory(lobbyID int64, name string) (*database.Category, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, nil
+	}
+	existing, err := s.GetCategoryByName(lobbyID, name)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+	return s.CreateCategory(lobbyID, name)
+}
+
+// MatchCategory finds the category a user most likely means by a name: the category with that name,
+// or the only active one starting with it ("super" for "Supermercado").
+// When nothing matches it returns up to three similar active category names as suggestions.
+func (s *CategoryService) MatchCategory(lobbyID int64, name string) (*database.Category, []string, error) {
+	categories, err := s.GetCategories(lobbyID, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := utils.FoldText(name)
+	if key == "" {
+		return nil, nil, nil
+	}
+
+	var prefixed []*database.Category
+	for _, category := range categories {
+		folded := utils.FoldText(category.Name)
+		if folded == key {
+			return category, nil, nil
+		}
+		if !category.IsArchived && len([]rune(key)) >= 3 && strings.HasPrefix(folded, key) {
+			prefixed = append(prefixed, category)
+		}
+	}
+	if len(prefixed) == 1 {
+		return prefixed[0], nil, nil
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	threshold := max(1, len([]rune(key))/3)
+	for _, category := range categories {
+		if category.IsArchived {
+			continue
+		}
+		folded := utils.FoldText(category.Name)
+		distance := utils.EditDistance(key, folded)
+		related := len([]rune(key)) >= 3 && (strings.Contains(folded, key) || strings.Contains(key, folded))
+		if distance <= threshold || related {
+			candidates = append(candidates, candidate{name: category.Name, distance: distance})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < maxCategorySuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return nil, suggestions, nil
+}
+
+// getExistingCategory finds a category by name, or returns ErrCategoryNotFound
+func (s *CategoryService) getExistingCategory(lobbyID int64, name string) (*database.Category, error) {
+	category, err := s.GetCategoryByName(lobbyID, name)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCategoryNotFound, name)
+	}
+	return category, nil
+}
+
+// RenameCategory renames a category of a lobby. Its expenses and recurring expenses follow the new name.
+func (s *CategoryService) RenameCategory(lobbyID int64, name string, newName string) (*database.Category, error) {
+	category, err := s.getExistingCategory(lobbyID, name)
+	if err != nil {
+		return nil, err
+	}
+	newName, err = cleanCategoryName(newName)
+	if err != nil {
+		return nil, err
+	}
+
+	other, err := s.GetCategoryByName(lobbyID, newName)
+	if err != nil {
+		return nil, err
+	}
+	if other != nil && other.ID != category.ID {
+		return nil, fmt.Errorf("%w: %s", ErrCategoryExists, other.Name)
+	}
+
+	tx, err := s.db.GetConn().Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE categories SET name = ? WHERE id = ?`, newName, category.ID); err != nil {
+		return nil, fmt.Errorf("failed to rename category: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE expenses SET category = ? WHERE category_id = ?`, newName, category.ID); err != nil {
+		return nil, fmt.Errorf("failed to rename category on expenses: %w", err)
+	}
+	if err := renameRecurringCategory(tx, lobbyID, category.Name, newName); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit rename: %w", err)
+	}
+
+	category.Name = newName
+	return category, nil
+}
+
+// MergeCategories moves every expense of one category to another and deletes the first one.
+// It returns how many expenses were moved.
+func (s *CategoryService) MergeCategories(lobbyID int64, from string, into string) (int64, *database.Category, error) {
+	source, err := s.getExistingCategory(lobbyID, from)
+	if err != nil {
+		return 0, nil, err
+	}
+	target, err := s.getExistingCategory(lobbyID, into)
+	if err != nil {
+		return 0, nil, err
+	}
+	if source.ID == target.ID {
+		return 0, nil, fmt.Errorf("cannot merge a category into itself")
+	}
+
+	tx, err := s.db.GetConn().Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE expenses SET category_id = ?, category = ? WHERE category_id = ?`,
+		target.ID, target.Name, source.ID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to move expenses: %w", err)
+	}
+	moved, _ := result.RowsAffected()
+
+	if err := renameRecurringCategory(tx, lobbyID, source.Name, target.Name); err != nil {
+		return 0, nil, err
+	}
+	if _, err := tx.Exec(`UPDATE category_rules SET category_id = ? WHERE category_id = ?`, target.ID, source.ID); err != nil {
+		return 0, nil, fmt.Errorf("failed to move categorization rules: %w", err)
+	}
+	if err := mergeBudgets(tx, source.ID, target.ID); err != nil {
+		return 0, nil, err
+	}
+	// Default payment methods for the merged category carry over unless the member has one for the target
+	if _, err := tx.Exec(`UPDATE OR IGNORE payment_method_defaults SET category_id = ? WHERE category_id = ?`, target.ID, source.ID); err != nil {
+		return 0, nil, fmt.Errorf("failed to move default payment methods: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM payment_method_defaults WHERE category_id = ?`, source.ID); err != nil {
+		return 0, nil, fmt.Errorf("failed to delete default payment methods: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM categories WHERE id = ?`, source.ID); err != nil {
+		return 0, nil, fmt.Errorf("failed to delete merged category: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	return moved, target, nil
+}
+
+// SetArchived archives or restores a category. Archived categories stay on their expenses
+// but are no longer listed or suggested.
+func (s *CategoryService) SetArchived(lobbyID int64, name string, archived bool) (*database.Category, error) {
+	category, err := s.getExistingCategory(lobbyID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.GetConn().Exec(`UPDATE categories SET is_archived = ? WHERE id = ?`, archived, category.ID); err != nil {
+		return nil, fmt.Errorf("failed to archive category: %w", err)
+	}
+	category.IsArchived = archived
+	return category, nil
+}
+
+// mergeBudgets moves the budget of a merged category to its target, unless the target already has one;
+// in that case the target's budget is kept and the merged one is dropped
+func mergeBudgets(q rowQuerier, sourceID int64, targetID int64) error {
+	rows, err := q.Query(`SELECT id FROM budgets WHERE category_id = ?`, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to query budgets: %w", err)
+	}
+	targetHasBudget := rows.Next()
+	rows.Close()
+
+	if !targetHasBudget {
+		if _, err := q.Exec(`UPDATE budgets SET category_id = ? WHERE category_id = ?`, targetID, sourceID); err != nil {
+			return fmt.Errorf("failed to move budget: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := q.Exec(`DELETE FROM budget_alerts WHERE budget_id IN (SELECT id FROM budgets WHERE category_id = ?)`, sourceID); err != nil {
+		return fmt.Errorf("failed to delete budget alerts: %w", err)
+	}
+	if _, err := q.Exec(`DELETE FROM budgets WHERE category_id = ?`, sourceID); err != nil {
+		return fmt.Errorf("failed to delete merged budget: %w", err)
+	}
+	return nil
+}
+
+// renameRecurringCategory points the recurring expenses of a lobby using a category name to a new name,
+// so the expenses they create keep landing in the right category
+func renameRecurringCategory(q rowQuerier, lobbyID int64, oldName string, newName string) error {
+	rows, err := q.Query(`SELECT id, category FROM recurring_expenses WHERE lobby_id = ? AND category IS NOT NULL`, lobbyID)
+	if err != nil {
+		return fmt.Errorf("failed to query recurring expenses: %w", err)
+	}
+
+	key := utils.FoldText(oldName)
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var category string
+		if err := rows.Scan(&id, &category); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan recurring expense: %w", err)
+		}
+		if utils.FoldText(category) == key {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if _, err := q.Exec(`UPDATE recurring_expenses SET category = ? WHERE id = ?`, newName, id); err != nil {
+			return fmt.Errorf("failed to update recurring expense category: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrContributionNotFound is returned when a deposit into the shared account does not exist in the lobby
+var ErrContributionNotFound = errors.New("contribution not found")
+
+// SharedAccountResult compares what each member deposited into the shared account during a period with
+// their share of what the account paid, and carries the account's running balance
+type SharedAccountResult struct {
+	LobbyID     int64
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Currency    string
+	Members     []*AccountMember // In joining order
+	Spent       utils.Money      // Paid from the shared account during the period
+	Opening     utils.Money      // Balance of the account before the period: earlier deposits minus earlier spending
+	Closing     utils.Money      // Opening + deposits - Spent
+}
+
+// AccountMember is one member's deposits into the shared account during a period, and their share of what it paid
+type AccountMember struct {
+	UserID     int64
+	Share      utils.Money // By the lobby ratio or the expenses' own split
+	Deposited  utils.Money
+	Difference utils.Money // Deposited minus Share; negative when they put in less than their share
+}
+
+// contributionColumns lists the columns selected for a contribution, in scanContribution order
+const contributionColumns = `id, lobby_id, user_telegram_id, amount_minor, currency, contribution_date, note, created_at`
+
+// scanContribution scans a row selected with contributionColumns
+func scanContribution(row rowScanner) (*database.Contribution, error) {
+	var contribution database.Contribution
+	err := row.Scan(
+		&contribution.ID,
+		&contribution.LobbyID,
+		&contribution.UserTelegramID,
+		&contribution.Amount.Amount,
+		&contribution.Amount.Currency,
+		&contribution.ContributionDate,
+		&contribution.Note,
+		&contribution.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &contribution, nil
+}
+
+// RecordContribution records money a lobby member deposited into the shared account.
+// An amount without currency is in the lobby's base currency.
+func (s *SettlementService) RecordContribution(lobbyID int64, userTelegramID int64, amount utils.Money, date time.Time, note string) (*database.Contribution, error) {
+	conn := s.db.GetConn()
+
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	lobby, err := s.lobbyService.GetLobbyByID(lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lobby: %w", err)
+	}
+	if lobby == nil {
+		return nil, fmt.Errorf("lobby not found")
+	}
+	if !lobby.IsMember(userTelegramID) {
+		return nil, fmt.Errorf("depositor is not a member of the lobby")
+	}
+
+	currency, err := s.expenseService.resolveCurrency(lobbyID, amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
+
+	noteValue := sql.NullString{String: note, Valid: note != ""}
+	day := utils.CalendarDate(date)
+	now := time.Now()
+
+	result, err := conn.Exec(`INSERT INTO contributions (lobby_id, user_telegram_id, amount_minor, currency, contribution_date, note, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		lobbyID, userTelegramID, amount.Amount, amount.Currency, day, noteValue, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record contribution: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contribution ID: %w", err)
+	}
+
+	return &database.Contribution{
+		ID:               id,
+		LobbyID:          lobbyID,
+		UserTelegramID:   userTelegramID,
+		Amount:           amount,
+		ContributionDate: day,
+		Note:             noteValue,
+		CreatedAt:        now,
+	}, nil
+}
+
+// GetContributions gets the deposits into a lobby's shared account within an optional date range, newest first
+func (s *SettlementService) GetContributions(lobbyID int64, startDate *time.Time, endDate *time.Time) ([]*database.Contribution, error) {
+	conn := s.db.GetConn()
+
+	query := `SELECT ` + contributionColumns + `
+	          FROM contributions WHERE lobby_id = ?`
+	args := []interface{}{lobbyID}
+
+	if startDate != nil {
+		query += " AND contribution_date >= ?"
+		args = append(args, utils.CalendarDate(*startDate))
+	}
+	if endDate != nil {
+		query += " AND contribution_date <= ?"
+		args = append(args, utils.CalendarDayEnd(*endDate))
+	}
+
+	query += " ORDER BY contribution_date DESC, id DESC"
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contributions: %w", err)
+	}
+	defer rows.Close()
+
+	var contributions []*database.Contribution
+	for rows.Next() {
+		contribution, err := scanContribution(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan contribution: %w", err)
+		}
+		contributions = append(contributions, contribution)
+	}
+	return contributions, nil
+}
+
+// DeleteContribution deletes a deposit into the lobby's shared account
+func (s *SettlementService) DeleteContribution(lobbyID int64, id int64) error {
+	conn := s.db.GetConn()
+
+	result, err := conn.Exec(`DELETE FROM contributions WHERE id = ? AND lobby_id = ?`, id, lobbyID)
+	if err != nil {
+		return fmt.Errorf("failed to delete contribution: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrContributionNotFound
+	}
+	return nil
+}
+
+// CalculateSharedAccount compares the members' deposits into the shared account during a period with
+// their share of what the account paid in it, and computes the account balance before and after the period
+func (s *SettlementService) CalculateSharedAccount(lobbyID int64, startDate, endDate time.Time) (*SharedAccountResult, error) {
+	settlement, err := s.CalculateSettlement(lobbyID, &startDate, &endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Spending before the period lowers the opening balance
+	beforeStart := startDate.Add(-time.Nanosecond)
+	earlier, err := s.CalculateSettlement(lobbyID, nil, &beforeStart)
+	if err != nil {
+		return nil, err
+	}
+
+	converter, err := s.exchangeRateService.NewConverter(lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+	zero := utils.NewMoney(0, converter.BaseCurrency)
+
+	result := &SharedAccountResult{
+		LobbyID:     lobbyID,
+		PeriodStart: startDate,
+		PeriodEnd:   endDate,
+		Currency:    converter.BaseCurrency,
+		Spent:       settlement.SharedAccountPaid,
+		Opening:     earlier.SharedAccountPaid.Neg(),
+	}
+	deposited := zero
+	for _, member := range settlement.Members {
+		result.Members = append(result.Members, &AccountMember{UserID: member.UserID, Share: member.AccountShare, Deposited: zero})
+	}
+
+	contributions, err := s.GetContributions(lobbyID, nil, &endDate)
+	if err != nil {
+		return nil, err
+	}
+	for _, contribution := range contributions {
+		amount, err := converter.Convert(contribution.Amount, contribution.ContributionDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert contribution %d: %w", contribution.ID, err)
+		}
+		if contribution.ContributionDate.Before(startDate) {
+			if result.Opening, err = result.Opening.Add(amount); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// Deposits of former members still count towards the account balance
+		if deposited, err = deposited.Add(amount); err != nil {
+			return nil, err
+		}
+		for _, member := range result.Members {
+			if member.UserID == contribution.UserTelegramID {
+				if member.Deposited, err = member.Deposited.Add(amount); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, member := range result.Members {
+		if member.Difference, err = member.Deposited.Sub(member.Share); err != nil {
+			return nil, err
+		}
+	}
+	if result.Closing, err = utils.Sum(result.Currency, result.Opening, deposited, result.Spent.Neg()); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
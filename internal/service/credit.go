@@ -0,0 +1,128 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"fmt"
+	"time"
+)
+
+// maxUnpaidStatements bounds how many closed statements back are searched for ones still to be paid
+const maxUnpaidStatements = 12
+
+// CreditStatus is how much of a card's credit limit is in use, in the lobby's base currency
+type CreditStatus struct {
+	Method      *database.PaymentMethod
+	Limit       utils.Money
+	Outstanding utils.Money // Unpaid statements, the open one and future installments
+	Available   utils.Money // Credit left on the card; negative when the limit is exceeded
+	Percent     float64     // Outstanding as a percentage of Limit
+}
+
+// Warn reports whether the card's usage reached the percentage of its limit that warns
+func (c CreditStatus) Warn() bool {
+	return c.Percent >= float64(c.Method.CreditWarnPercent)
+}
+
+// unpaidSince returns the start of the oldest statement of a card that is still to be paid on "now":
+// the open one, and the closed ones whose due date has not passed. Without a due date, the last
+// closed statement counts as unpaid until the next one closes.
+func (s *ExpenseService) unpaidSince(method *database.PaymentMethod, now time.Time) (time.Time, error) {
+	pmService := NewPaymentMethodService(s.db)
+	cycles, err := pmService.BillingCycles(method)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	today := utils.CalendarDate(now)
+	since := cycles.PeriodOf(now).Start
+	period := cycles.LastClosed(now)
+	for i := 0; i < maxUnpaidStatements; i++ {
+		dueDate, ok, err := pmService.StatementDueDate(method, period.End)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if ok && dueDate.Before(today) {
+			break
+		}
+		since = period.Start
+		if !ok {
+			break
+		}
+		period = cycles.LastClosed(period.Start)
+	}
+	return since, nil
+}
+
+// OutstandingBalance returns what is owed on a card on "now", in the lobby's base currency: the expenses of
+// its unpaid statements, of the open one, and the installments still to be billed
+func (s *ExpenseService) OutstandingBalance(method *database.PaymentMethod, converter *CurrencyConverter, now time.Time) (utils.Money, error) {
+	conn := s.db.GetConn()
+
+	if !method.ClosingDay.Valid {
+		return utils.Money{}, fmt.Errorf("payment method %s has no billing cycle", method.Name)
+	}
+	since, err := s.unpaidSince(method, now)
+	if err != nil {
+		return utils.Money{}, err
+	}
+
+	rows, err := conn.Query(`SELECT `+expenseColumns+` FROM expenses
+	          WHERE lobby_id = ? AND payment_method_id = ? AND billing_period_end >= ?`,
+		method.LobbyID, method.ID, since)
+	if err != nil {
+		return utils.Money{}, fmt.Errorf("failed to query expenses: %w", err)
+	}
+	defer rows.Close()
+
+	expenses, err := scanExpenses(rows)
+	if err != nil {
+		return utils.Money{}, err
+	}
+
+	total := utils.NewMoney(0, converter.BaseCurrency)
+	for _, expense := range expenses {
+		amount, err := converter.ExpenseAmount(expense)
+		if err != nil {
+			return utils.Money{}, fmt.Errorf("failed to convert expense %d: %w", expense.ID, err)
+		}
+		if total, err = total.Add(amount); err != nil {
+			return utils.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// CreditStatus returns how much of a card's credit limit is in use on "now".
+// It returns nil when the card has no credit limit.
+func (s *ExpenseService) CreditStatus(method *database.PaymentMethod, converter *CurrencyConverter, now time.Time) (*CreditStatus, error) {
+	if !method.HasCreditLimit() {
+		return nil, nil
+	}
+
+	limit, err := converter.Convert(method.CreditLimit, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert credit limit: %w", err)
+	}
+	outstanding, err := s.OutstandingBalance(method, converter, now)
+	if err != nil {
+		return nil, err
+	}
+
+	available, err := limit.Sub(outstanding)
+	if err != nil {
+		return nil, err
+	}
+	ratio, err := outstanding.Ratio(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreditStatus{
+		Method:      method,
+		Limit:       limit,
+		Outstanding: outstanding,
+		Available:   available,
+		Percent:     ratio * 100,
+	}, nil
+}
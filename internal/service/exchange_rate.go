@@ -0,0 +1,317 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrExchangeRateNotFound is returned when no rate is effective for a currency on a date
+var ErrExchangeRateNotFound = errors.New("exchange rate not found")
+
+// RateTypes lists the accepted exchange rate types
+var RateTypes = []string{"oficial", "mep", "blue", "ccl", "tarjeta", "cripto"}
+
+// cardRateType is the rate type preferred for expenses paid with a credit card
+const cardRateType = "tarjeta"
+
+// NormalizeRateType returns the canonical rate type, or "" if it is not one of RateTypes
+func NormalizeRateType(rateType string) string {
+	rateType = strings.ToLower(strings.TrimSpace(rateType))
+	if rateType == "official" {
+		rateType = "oficial"
+	}
+	for _, t := range RateTypes {
+		if t == rateType {
+			return t
+		}
+	}
+	return ""
+}
+
+// ExchangeRateService manages the lobby's local exchange rate table
+type ExchangeRateService struct {
+	db           *database.DB
+	lobbyService *LobbyService
+}
+
+// NewExchangeRateService creates a new exchange rate service
+func NewExchangeRateService(db *database.DB, lobbyService *LobbyService) *ExchangeRateService {
+	return &ExchangeRateService{
+		db:           db,
+		lobbyService: lobbyService,
+	}
+}
+
+// exchangeRateColumns lists the columns selected for an exchange rate, in scanExchangeRate order
+const exchangeRateColumns = `id, lobby_id, currency, base_currency, rate_type, rate_date, rate, created_at`
+
+// scanExchangeRate scans a row selected with exchangeRateColumns
+func scanExchangeRate(row rowScanner) (*database.ExchangeRate, error) {
+	var rate database.ExchangeRate
+	err := row.Scan(
+		&rate.ID,
+		&rate.LobbyID,
+		&rate.Currency,
+		&rate.BaseCurrency,
+		&rate.RateType,
+		&rate.RateDate,
+		&rate.Rate,
+		&rate.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// SetRate stores the rate of a currency against the lobby's base currency from the given date on.
+// An empty rate type means the lobby's default rate type. An existing rate for the same day is replaced.
+func (s *ExchangeRateService) SetRate(lobbyID int64, currency string, rateType string, rateDate time.Time, rate float64) (*database.ExchangeRate, error) {
+	conn := s.db.GetConn()
+
+	code := utils.NormalizeCurrency(currency)
+	if code == "" {
+		return nil, fmt.Errorf("unknown currency: %s", currency)
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+
+	baseCurrency, defaultRateType, err := s.lobbyService.GetCurrencySettings(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	if code == baseCurrency {
+		return nil, fmt.Errorf("%s is already the base currency", code)
+	}
+
+	if rateType == "" {
+		rateType = defaultRateType
+	}
+	normalizedType := NormalizeRateType(rateType)
+	if normalizedType == "" {
+		return nil, fmt.Errorf("invalid rate type: %s", rateType)
+	}
+
+	query := `INSERT INTO exchange_rates (lobby_id, currency, base_currency, rate_type, rate_date, rate, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)
+	          ON CONFLICT (lobby_id, currency, base_currency, rate_type, rate_date)
+	          DO UPDATE SET rate = excluded.rate, created_at = excluded.created_at`
+
+	// Rate dates are plain days, compared as text with the day of the amounts converted
+	day := utils.FormatDate(rateDate)
+	now := time.Now()
+	_, err = conn.Exec(query, lobbyID, code, baseCurrency, normalizedType, day, rate, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+
+	row := conn.QueryRow(`SELECT `+exchangeRateColumns+`
+	          FROM exchange_rates
+	          WHERE lobby_id = ? AND currency = ? AND base_currency = ? AND rate_type = ? AND rate_date = ?`,
+		lobbyID, code, baseCurrency, normalizedType, day)
+	saved, err := scanExchangeRate(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exchange rate: %w", err)
+	}
+	return saved, nil
+}
+
+// GetRates gets the most recent rates of a lobby, newest first. An empty currency returns every currency.
+func (s *ExchangeRateService) GetRates(lobbyID int64, currency string, limit int) ([]*database.ExchangeRate, error) {
+	conn := s.db.GetConn()
+
+	query := `SELECT ` + exchangeRateColumns + `
+	          FROM exchange_rates WHERE lobby_id = ?`
+	args := []interface{}{lobbyID}
+
+	if currency != "" {
+		query += " AND currency = ?"
+		args = append(args, currency)
+	}
+
+	query += " ORDER BY rate_date DESC, currency, rate_type LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []*database.ExchangeRate
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan exchange rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// GetLatestRates gets the newest rate of every currency and rate type of a lobby
+func (s *ExchangeRateService) GetLatestRates(lobbyID int64) ([]*database.ExchangeRate, error) {
+	conn := s.db.GetConn()
+
+	query := `SELECT ` + exchangeRateColumns + `
+	          FROM exchange_rates r
+	          WHERE lobby_id = ? AND rate_date = (
+	              SELECT MAX(rate_date) FROM exchange_rates
+	              WHERE lobby_id = r.lobby_id AND currency = r.currency
+	              AND base_currency = r.base_currency AND rate_type = r.rate_type)
+	          ORDER BY currency, rate_type`
+
+	rows, err := conn.Query(query, lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []*database.ExchangeRate
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan exchange rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// DeleteRate deletes a rate of the lobby
+func (s *ExchangeRateService) DeleteRate(lobbyID int64, id int64) error {
+	conn := s.db.GetConn()
+
+	result, err := conn.Exec(`DELETE FROM exchange_rates WHERE id = ? AND lobby_id = ?`, id, lobbyID)
+	if err != nil {
+		return fmt.Errorf("failed to delete exchange rate: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrExchangeRateNotFound
+	}
+	return nil
+}
+
+// GetEffectiveRate returns how many units of "to" one unit of "from" is worth on the given date,
+// using the newest rate of that type on or before its calendar day. Rates stored the other way round are inverted.
+func (s *ExchangeRateService) GetEffectiveRate(lobbyID int64, from, to, rateType string, date time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	conn := s.db.GetConn()
+	query := `SELECT rate FROM exchange_rates
+	          WHERE lobby_id = ? AND currency = ? AND base_currency = ? AND rate_type = ? AND rate_date <= ?
+	          ORDER BY rate_date DESC LIMIT 1`
+
+	day := utils.FormatDate(date)
+	var rate float64
+	err := conn.QueryRow(query, lobbyID, from, to, rateType, day).Scan(&rate)
+	if err == nil {
+		return rate, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to query exchange rate: %w", err)
+	}
+
+	err = conn.QueryRow(query, lobbyID, to, from, rateType, day).Scan(&rate)
+	if err == nil {
+		return 1 / rate, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to query exchange rate: %w", err)
+	}
+
+	return 0, fmt.Errorf("%w: %s/%s (%s) on %s", ErrExchangeRateNotFound, from, to, rateType, day)
+}
+
+// CurrencyConverter converts expense amounts to a lobby's base currency
+type CurrencyConverter struct {
+	service      *ExchangeRateService
+	lobbyID      int64
+	BaseCurrency string
+	RateType     string
+	cardMethods  map[int64]bool // Credit card payment methods, converted with the "tarjeta" rate when available
+	cache        map[string]float64
+}
+
+// NewConverter creates a converter to the lobby's base currency
+func (s *ExchangeRateService) NewConverter(lobbyID int64) (*CurrencyConverter, error) {
+	baseCurrency, rateType, err := s.lobbyService.GetCurrencySettings(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	methods, err := NewPaymentMethodService(s.db).GetPaymentMethodsByLobby(lobbyID, false)
+	if err != nil {
+		return nil, err
+	}
+	cardMethods := make(map[int64]bool)
+	for _, method := range methods {
+		if method.Type == "credit_card" {
+			cardMethods[method.ID] = true
+		}
+	}
+
+	return &CurrencyConverter{
+		service:      s,
+		lobbyID:      lobbyID,
+		BaseCurrency: baseCurrency,
+		RateType:     rateType,
+		cardMethods:  cardMethods,
+		cache:        make(map[string]float64),
+	}, nil
+}
+
+// rate returns the effective rate from a currency to the base currency, caching lookups per day
+func (c *CurrencyConverter) rate(currency, rateType string, date time.Time) (float64, error) {
+	key := currency + "|" + rateType + "|" + utils.FormatDate(date)
+	if rate, ok := c.cache[key]; ok {
+		return rate, nil
+	}
+	rate, err := c.service.GetEffectiveRate(c.lobbyID, currency, c.BaseCurrency, rateType, date)
+	if err != nil {
+		return 0, err
+	}
+	c.cache[key] = rate
+	return rate, nil
+}
+
+// Convert converts an amount to the base currency using the lobby's default rate type
+func (c *CurrencyConverter) Convert(amount utils.Money, date time.Time) (utils.Money, error) {
+	if amount.Currency == "" || amount.Currency == c.BaseCurrency {
+		return amount.WithCurrency(c.BaseCurrency), nil
+	}
+	rate, err := c.rate(amount.Currency, c.RateType, date)
+	if err != nil {
+		return utils.Money{}, err
+	}
+	return amount.Convert(rate, c.BaseCurrency), nil
+}
+
+// ExpenseAmount returns an expense's amount in the base currency, using the rate effective on its date.
+// Credit card expenses use the "tarjeta" rate when one is available.
+func (c *CurrencyConverter) ExpenseAmount(expense *database.Expense) (utils.Money, error) {
+	amount := expense.Amount
+	if amount.Currency == "" || amount.Currency == c.BaseCurrency {
+		return amount.WithCurrency(c.BaseCurrency), nil
+	}
+
+	if expense.PaymentMethodID.Valid && c.cardMethods[expense.PaymentMethodID.Int64] && c.RateType != cardRateType {
+		rate, err := c.rate(amount.Currency, cardRateType, expense.ExpenseDate)
+		if err == nil {
+			return amount.Convert(rate, c.BaseCurrency), nil
+		}
+		if !errors.Is(err, ErrExchangeRateNotFound) {
+			return utils.Money{}, err
+		}
+	}
+
+	return c.Convert(amount, expense.ExpenseDate)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetEffectiveRateOnExpenseDate(t *testing.T) {
+	loc := inLocation(t, "America/Argentina/Buenos_Aires")
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 2)
+	rates := NewExchangeRateService(db, NewLobbyService(db))
+
+	// Rates are entered on local days, the way the bot parses them
+	for _, set := range []struct {
+		date string
+		rate float64
+	}{
+		{"2026-10-01", 1000},
+		{"2026-10-15", 1250},
+	} {
+		date, err := time.ParseInLocation("2006-01-02", set.date, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rates.SetRate(lobby.ID, "USD", "oficial", date, set.rate); err != nil {
+			t.Fatalf("SetRate %s: %v", set.date, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		date     time.Time
+		want     float64 // 0 when no rate is effective yet
+	}{
+		{"day before the first rate", "USD", "ARS", utils.CalendarDate(day("2026-09-30")), 0},
+		{"expense on the rate's day", "USD", "ARS", utils.CalendarDate(day("2026-10-01")), 1000},
+		{"expense before the next rate", "USD", "ARS", utils.CalendarDate(day("2026-10-14")), 1000},
+		{"late local time before the next rate", "USD", "ARS", time.Date(2026, 10, 14, 23, 30, 0, 0, loc), 1000},
+		{"expense on the next rate's day", "USD", "ARS", utils.CalendarDate(day("2026-10-15")), 1250},
+		{"inverted pair", "ARS", "USD", utils.CalendarDate(day("2026-10-01")), 0.001},
+		{"inverted pair on the next rate's day", "ARS", "USD", time.Date(2026, 10, 15, 0, 0, 0, 0, loc), 0.0008},
+		{"inverted pair before any rate", "ARS", "USD", utils.CalendarDate(day("2026-09-30")), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rates.GetEffectiveRate(lobby.ID, tt.from, tt.to, "oficial", tt.date)
+			if tt.want == 0 {
+				if !errors.Is(err, ErrExchangeRateNotFound) {
+					t.Fatalf("got rate %v, err %v; want ErrExchangeRateNotFound", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetEffectiveRate: %v", err)
+			}
+			if diff := got - tt.want; diff > 1e-12 || diff < -1e-12 {
+				t.Errorf("rate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The converter caches rates per day, and must not hand the first rate on to the next rate's day
+	converter, err := rates.NewConverter(lobby.ID)
+	if err != nil {
+		t.Fatalf("NewConverter: %v", err)
+	}
+	for _, tt := range []struct {
+		date time.Time
+		want int64
+	}{
+		{utils.CalendarDate(day("2026-10-14")), 100000},
+		{time.Date(2026, 10, 14, 22, 0, 0, 0, loc), 100000},
+		{utils.CalendarDate(day("2026-10-15")), 125000},
+	} {
+		got, err := converter.Convert(utils.NewMoney(100, "USD"), tt.date)
+		if err != nil {
+			t.Fatalf("Convert on %s: %v", tt.date, err)
+		}
+		if got.Amount != tt.want || got.Currency != "ARS" {
+			t.Errorf("Convert on %s = %s, want %s", tt.date, got, utils.NewMoney(tt.want, "ARS"))
+		}
+	}
+}
+
+func TestExchangeRateDatesMigrated(t *testing.T) {
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 1)
+
+	// Rates saved before were local midnights; two of them fell on the same day
+	conn := db.GetConn()
+	for _, old := range []struct {
+		date string
+		rate float64
+	}{
+		{"2026-10-01 00:00:00-03:00", 1000},
+		{"2026-10-01 00:00:00+00:00", 1100},
+	} {
+		if _, err := conn.Exec(`INSERT INTO exchange_rates (lobby_id, currency, base_currency, rate_type, rate_date, rate)
+			VALUES (?, 'USD', 'ARS', 'oficial', ?, ?)`, lobby.ID, old.date, old.rate); err != nil {
+			t.Fatalf("insert old rate: %v", err)
+		}
+	}
+	if _, err := conn.Exec(`DELETE FROM schema_migrations WHERE name = 'plain_rate_dates'`); err != nil {
+		t.Fatalf("forget migration: %v", err)
+	}
+	reopened := newTestDB(t)
+
+	var count int
+	var date string
+	if err := reopened.GetConn().QueryRow(`SELECT COUNT(*), MAX(rate_date) FROM exchange_rates`).Scan(&count, &date); err != nil {
+		t.Fatalf("query rates: %v", err)
+	}
+	if count != 1 || date != "2026-10-01" {
+		t.Errorf("got %d rates dated %q, want 1 dated 2026-10-01", count, date)
+	}
+	rate, err := NewExchangeRateService(reopened, NewLobbyService(reopened)).
+		GetEffectiveRate(lobby.ID, "USD", "ARS", "oficial", utils.CalendarDate(day("2026-10-01")))
+	if err != nil {
+		t.Fatalf("GetEffectiveRate: %v", err)
+	}
+	if rate != 1100 {
+		t.Errorf("rate = %v, want the latest one saved (1100)", rate)
+	}
+}
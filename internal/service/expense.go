@@ -4,14 +4,81 @@ import (
 	"botGastosPareja/internal/database"
 	"botGastosPareja/pkg/utils"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 )
 
+// MaxInstallments is the largest number of installments (cuotas) accepted for a purchase
+const MaxInstallments = 48
+
+var (
+	// ErrInterestUnknown is returned when the total of an installment purchase saved without its interest changes
+	ErrInterestUnknown = errors.New("the interest of this purchase is unknown; delete it and add it again")
+	// ErrBelowBilled is returned when the new total of an installment purchase is less than its billed installments
+	ErrBelowBilled = errors.New("the new total is less than the installments already billed")
+)
+
 // ExpenseService handles expense operations
 type ExpenseService struct {
-	db *database.DB
+	db        *database.DB
+	listeners []func(expense *database.Expense)
+}
+
+// expenseColumns lists the columns selected for an expense, in scanExpense order
+const expenseColumns = `id, lobby_id, spender_telegram_id, payment_method_id, amount_minor,
+	          currency, description, category, category_id, expense_date, billing_period_start,
+	          billing_period_end, parent_expense_id, installment_number,
+	          installment_count, installment_interest, split_mode, split_percent, split_amount_minor, created_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanExpense scans a row selected with expenseColumns
+func scanExpense(row rowScanner) (*database.Expense, error) {
+	var expense database.Expense
+	err := row.Scan(
+		&expense.ID,
+		&expense.LobbyID,
+		&expense.SpenderTelegramID,
+		&expense.PaymentMethodID,
+		&expense.Amount.Amount,
+		&expense.Amount.Currency,
+		&expense.Description,
+		&expense.Category,
+		&expense.CategoryID,
+		&expense.ExpenseDate,
+		&expense.BillingPeriodStart,
+		&expense.BillingPeriodEnd,
+		&expense.ParentExpenseID,
+		&expense.InstallmentNumber,
+		&expense.InstallmentCount,
+		&expense.InstallmentInterest,
+		&expense.SplitMode,
+		&expense.SplitPercent,
+		&expense.SplitAmount,
+		&expense.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &expense, nil
+}
+
+// scanExpenses scans all rows selected with expenseColumns
+func scanExpenses(rows *sql.Rows) ([]*database.Expense, error) {
+	var expenses []*database.Expense
+	for rows.Next() {
+		expense, err := scanExpense(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		expenses = append(expenses, expense)
+	}
+	return expenses, nil
 }
 
 // NewExpenseService creates a new expense service
@@ -19,23 +86,82 @@ func NewExpenseService(db *database.DB) *ExpenseService {
 	return &ExpenseService{db: db}
 }
 
-// CreateExpense creates a new expense
-func (s *ExpenseService) CreateExpense(lobbyID int64, spenderTelegramID int64, amount float64, description string, category string, expenseDate time.Time, paymentMethodID *int64) (*database.Expense, error) {
-	conn := s.db.GetConn()
+// OnExpenseCreated registers a function called after every expense created through this service
+// (an installment purchase notifies its first installment). Used to check budgets.
+func (s *ExpenseService) OnExpenseCreated(listener func(expense *database.Expense)) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// notifyCreated calls the registered listeners with a new expense
+func (s *ExpenseService) notifyCreated(expense *database.Expense) {
+	for _, listener := range s.listeners {
+		listener(expense)
+	}
+}
+
+// CreateExpense creates a new expense. An amount without currency is in the lobby's base currency.
+// A nil split means the expense is shared by the lobby ratio. Without a category, the lobby's
+// categorization rules fill in the category and, when none was given, the payment method.
+// Still without a payment method, the spender's default payment method is used.
+// An expense falling on a reconciled statement returns ErrStatementReconciled unless forced.
+func (s *ExpenseService) CreateExpense(lobbyID int64, spenderTelegramID int64, amount utils.Money, description string, category string, expenseDate time.Time, paymentMethodID *int64, split *ExpenseSplit, force bool) (*database.Expense, error) {
+	return s.createExpense(lobbyID, spenderTelegramID, amount, description, category, expenseDate, paymentMethodID, split, force, nil)
+}
+
+// createExpense creates an expense like CreateExpense. A non-nil record runs in the same transaction as the
+// insert, to store what the expense came from: if it fails, the expense is not created either.
+func (s *ExpenseService) createExpense(lobbyID int64, spenderTelegramID int64, amount utils.Money, description string, category string, expenseDate time.Time, paymentMethodID *int64, split *ExpenseSplit, force bool, record func(q rowQuerier, expense *database.Expense) error) (*database.Expense, error) {
+	expenseDate = utils.CalendarDate(expenseDate)
+	currency, err := s.resolveCurrency(lobbyID, amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
+
+	if split != nil {
+		if err := split.validate(amount); err != nil {
+			return nil, err
+		}
+	}
+	splitMode, splitPercent, splitAmount := split.columns()
+
+	// Without a category, the lobby's rules may pick one (and a payment method) from the description
+	if strings.TrimSpace(category) == "" {
+		rule, err := NewRuleService(s.db).MatchRule(lobbyID, description)
+		if err != nil {
+			return nil, err
+		}
+		if rule != nil {
+			category = rule.CategoryName
+			if paymentMethodID == nil && rule.PaymentMethodID.Valid {
+				paymentMethodID = &rule.PaymentMethodID.Int64
+			}
+		}
+	}
+
+	catNull, catID, err := s.resolveCategory(lobbyID, category)
+	if err != nil {
+		return nil, err
+	}
+
+	// Still without a payment method, the spender's default for the category (or their general one) applies
+	if paymentMethodID == nil {
+		def, err := NewPaymentMethodService(s.db).DefaultPaymentMethod(lobbyID, spenderTelegramID, catID)
+		if err != nil {
+			return nil, err
+		}
+		if def != nil {
+			paymentMethodID = &def.PaymentMethodID
+		}
+	}
 
 	var billingPeriodStart, billingPeriodEnd sql.NullTime
 
 	// Calculate billing period if payment method is provided
 	if paymentMethodID != nil {
-		pmService := NewPaymentMethodService(s.db)
-		pm, err := pmService.GetPaymentMethodByID(*paymentMethodID)
+		billingPeriodStart, billingPeriodEnd, err = s.billingPeriod(*paymentMethodID, expenseDate)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get payment method: %w", err)
-		}
-		if pm != nil && pm.ClosingDay.Valid {
-			start, end := utils.CalculateBillingPeriod(expenseDate, pm.ClosingDay.Int64)
-			billingPeriodStart = sql.NullTime{Time: start, Valid: true}
-			billingPeriodEnd = sql.NullTime{Time: end, Valid: true}
+			return nil, err
 		}
 	}
 
@@ -44,32 +170,45 @@ func (s *ExpenseService) CreateExpense(lobbyID int64, spenderTelegramID int64, a
 		descNull = sql.NullString{String: description, Valid: true}
 	}
 
-	var catNull sql.NullString
-	if category != "" {
-		catNull = sql.NullString{String: category, Valid: true}
-	}
-
 	var pmIDNull sql.NullInt64
 	if paymentMethodID != nil {
 		pmIDNull = sql.NullInt64{Int64: *paymentMethodID, Valid: true}
 	}
 
+	if !force {
+		if err := s.checkReconciled(pmIDNull, billingPeriodEnd); err != nil {
+			return nil, err
+		}
+	}
+
 	query := `INSERT INTO expenses 
-	          (lobby_id, spender_telegram_id, payment_method_id, amount, description, 
-	           category, expense_date, billing_period_start, billing_period_end, created_at)
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	          (lobby_id, spender_telegram_id, payment_method_id, amount_minor, currency, description, 
+	           category, category_id, expense_date, billing_period_start, billing_period_end,
+	           split_mode, split_percent, split_amount_minor, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	tx, err := s.db.GetConn().Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
 	now := time.Now()
-	result, err := conn.Exec(query,
+	result, err := tx.Exec(query,
 		lobbyID,
 		spenderTelegramID,
 		pmIDNull,
-		amount,
+		amount.Amount,
+		amount.Currency,
 		descNull,
 		catNull,
+		catID,
 		expenseDate,
 		billingPeriodStart,
 		billingPeriodEnd,
+		splitMode,
+		splitPercent,
+		splitAmount,
 		now,
 	)
 	if err != nil {
@@ -81,40 +220,259 @@ func (s *ExpenseService) CreateExpense(lobbyID int64, spenderTelegramID int64, a
 		return nil, fmt.Errorf("failed to get expense ID: %w", err)
 	}
 
-	return &database.Expense{
-		ID:                id,
-		LobbyID:           lobbyID,
-		SpenderTelegramID: spenderTelegramID,
-		PaymentMethodID:   pmIDNull,
-		Amount:            amount,
-		Description:       descNull,
-		Category:          catNull,
-		ExpenseDate:       expenseDate,
+	expense := &database.Expense{
+		ID:                 id,
+		LobbyID:            lobbyID,
+		SpenderTelegramID:  spenderTelegramID,
+		PaymentMethodID:    pmIDNull,
+		Amount:             amount,
+		Description:        descNull,
+		Category:           catNull,
+		CategoryID:         catID,
+		ExpenseDate:        expenseDate,
 		BillingPeriodStart: billingPeriodStart,
 		BillingPeriodEnd:   billingPeriodEnd,
-		CreatedAt:         now,
-	}, nil
+		SplitMode:          splitMode,
+		SplitPercent:       splitPercent,
+		SplitAmount:        splitAmount,
+		CreatedAt:          now,
+	}
+	if record != nil {
+		if err := record(tx, expense); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit expense: %w", err)
+	}
+	s.notifyCreated(expense)
+
+	return expense, nil
+}
+
+// billingPeriod returns the start and end of the statement of a payment method that an expense made on a date
+// falls in, or NULL when the payment method has no billing cycle
+func (s *ExpenseService) billingPeriod(paymentMethodID int64, date time.Time) (sql.NullTime, sql.NullTime, error) {
+	pmService := NewPaymentMethodService(s.db)
+	pm, err := pmService.GetPaymentMethodByID(paymentMethodID)
+	if err != nil {
+		return sql.NullTime{}, sql.NullTime{}, fmt.Errorf("failed to get payment method: %w", err)
+	}
+	if pm == nil || !pm.ClosingDay.Valid {
+		return sql.NullTime{}, sql.NullTime{}, nil
+	}
+	cycles, err := pmService.BillingCycles(pm)
+	if err != nil {
+		return sql.NullTime{}, sql.NullTime{}, err
+	}
+	period := cycles.PeriodOf(date)
+	return sql.NullTime{Time: period.Start, Valid: true}, sql.NullTime{Time: period.End, Valid: true}, nil
+}
+
+// resolveCurrency validates a currency code, defaulting to the lobby's base currency when empty
+func (s *ExpenseService) resolveCurrency(lobbyID int64, currency string) (string, error) {
+	if currency == "" {
+		baseCurrency, _, err := NewLobbyService(s.db).GetCurrencySettings(lobbyID)
+		if err != nil {
+			return "", err
+		}
+		return baseCurrency, nil
+	}
+	code := utils.NormalizeCurrency(currency)
+	if code == "" {
+		return "", fmt.Errorf("unknown currency: %s", currency)
+	}
+	return code, nil
+}
+
+// resolveCategory returns the category name and ID to store for a category typed by a user,
+// creating the lobby category if it does not exist yet
+func (s *ExpenseService) resolveCategory(lobbyID int64, name string) (sql.NullString, sql.NullInt64, error) {
+	category, err := NewCategoryService(s.db).ResolveCategory(lobbyID, name)
+	if err != nil || category == nil {
+		return sql.NullString{}, sql.NullInt64{}, err
+	}
+	return sql.NullString{String: category.Name, Valid: true}, sql.NullInt64{Int64: category.ID, Valid: true}, nil
+}
+
+// CreateInstallmentExpense creates a purchase paid in installments (cuotas) on a credit card.
+// The first installment is the parent row; every following installment is a child charge
+// placed on the next billing period of the payment method.
+// A split by amount refers to the whole purchase and is stored as the equivalent percentage on every installment.
+// Installments falling on a reconciled statement return ErrStatementReconciled unless forced.
+func (s *ExpenseService) CreateInstallmentExpense(lobbyID int64, spenderTelegramID int64, amount utils.Money, installments int, interestPct float64, description string, category string, expenseDate time.Time, paymentMethodID int64, split *ExpenseSplit, force bool) ([]*database.Expense, error) {
+	if installments < 2 || installments > MaxInstallments {
+		return nil, fmt.Errorf("installments must be between 2 and %d", MaxInstallments)
+	}
+	if interestPct < 0 {
+		return nil, fmt.Errorf("interest cannot be negative")
+	}
+	expenseDate = utils.CalendarDate(expenseDate)
+
+	currency, err := s.resolveCurrency(lobbyID, amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
+
+	if split != nil {
+		if err := split.validate(amount); err != nil {
+			return nil, err
+		}
+	}
+	installmentSplit, err := split.asPercentOf(amount)
+	if err != nil {
+		return nil, err
+	}
+	splitMode, splitPercent, splitAmount := installmentSplit.columns()
+
+	pmService := NewPaymentMethodService(s.db)
+	pm, err := pmService.GetPaymentMethodByID(paymentMethodID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment method: %w", err)
+	}
+	if pm == nil || !pm.ClosingDay.Valid {
+		return nil, fmt.Errorf("installments require a payment method with a billing cycle")
+	}
+
+	if strings.TrimSpace(category) == "" {
+		rule, err := NewRuleService(s.db).MatchRule(lobbyID, description)
+		if err != nil {
+			return nil, err
+		}
+		if rule != nil {
+			category = rule.CategoryName
+		}
+	}
+	catNull, catID, err := s.resolveCategory(lobbyID, category)
+	if err != nil {
+		return nil, err
+	}
+
+	// Installments add up exactly to the total plus interest
+	charges := amount.MulRatio(1 + interestPct/100).Split(installments)
+
+	cycles, err := pmService.BillingCycles(pm)
+	if err != nil {
+		return nil, err
+	}
+	periods := cycles.Periods(expenseDate, installments)
+	if !force {
+		for _, period := range periods {
+			if err := s.checkReconciled(sql.NullInt64{Int64: paymentMethodID, Valid: true}, sql.NullTime{Time: period.End, Valid: true}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	tx, err := s.db.GetConn().Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var descNull sql.NullString
+	if description != "" {
+		descNull = sql.NullString{String: description, Valid: true}
+	}
+
+	query := `INSERT INTO expenses 
+	          (lobby_id, spender_telegram_id, payment_method_id, amount_minor, currency, description, 
+	           category, category_id, expense_date, billing_period_start, billing_period_end,
+	           parent_expense_id, installment_number, installment_count, installment_interest,
+	           split_mode, split_percent, split_amount_minor, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	var parentID sql.NullInt64
+	expenses := make([]*database.Expense, 0, installments)
+	for i, period := range periods {
+		chargeDate := period.Start
+		if i == 0 {
+			chargeDate = expenseDate
+		}
+
+		expense := &database.Expense{
+			LobbyID:             lobbyID,
+			SpenderTelegramID:   spenderTelegramID,
+			PaymentMethodID:     sql.NullInt64{Int64: paymentMethodID, Valid: true},
+			Amount:              charges[i],
+			Description:         descNull,
+			Category:            catNull,
+			CategoryID:          catID,
+			ExpenseDate:         chargeDate,
+			BillingPeriodStart:  sql.NullTime{Time: period.Start, Valid: true},
+			BillingPeriodEnd:    sql.NullTime{Time: period.End, Valid: true},
+			ParentExpenseID:     parentID,
+			InstallmentNumber:   sql.NullInt64{Int64: int64(i + 1), Valid: true},
+			InstallmentCount:    sql.NullInt64{Int64: int64(installments), Valid: true},
+			InstallmentInterest: sql.NullFloat64{Float64: interestPct, Valid: true},
+			SplitMode:           splitMode,
+			SplitPercent:        splitPercent,
+			SplitAmount:         splitAmount,
+			CreatedAt:           now,
+		}
+
+		result, err := tx.Exec(query,
+			expense.LobbyID,
+			expense.SpenderTelegramID,
+			expense.PaymentMethodID,
+			expense.Amount.Amount,
+			expense.Amount.Currency,
+			expense.Description,
+			expense.Category,
+			expense.CategoryID,
+			expense.ExpenseDate,
+			expense.BillingPeriodStart,
+			expense.BillingPeriodEnd,
+			expense.ParentExpenseID,
+			expense.InstallmentNumber,
+			expense.InstallmentCount,
+			expense.InstallmentInterest,
+			expense.SplitMode,
+			expense.SplitPercent,
+			expense.SplitAmount,
+			expense.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create installment %d: %w", i+1, err)
+		}
+
+		expense.ID, err = result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get expense ID: %w", err)
+		}
+		if i == 0 {
+			parentID = sql.NullInt64{Int64: expense.ID, Valid: true}
+		}
+		expenses = append(expenses, expense)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit installments: %w", err)
+	}
+	s.notifyCreated(expenses[0])
+
+	return expenses, nil
 }
 
 // GetExpensesByLobby gets expenses for a lobby with optional filters
 func (s *ExpenseService) GetExpensesByLobby(lobbyID int64, startDate *time.Time, endDate *time.Time, paymentMethodID *int64) ([]*database.Expense, error) {
 	conn := s.db.GetConn()
 
-	query := `SELECT id, lobby_id, spender_telegram_id, payment_method_id, amount, 
-	          description, category, expense_date, billing_period_start, 
-	          billing_period_end, created_at
+	query := `SELECT ` + expenseColumns + `
 	          FROM expenses WHERE lobby_id = ?`
 
 	args := []interface{}{lobbyID}
 
 	if startDate != nil {
 		query += " AND expense_date >= ?"
-		args = append(args, *startDate)
+		args = append(args, utils.CalendarDate(*startDate))
 	}
 
 	if endDate != nil {
 		query += " AND expense_date <= ?"
-		args = append(args, *endDate)
+		args = append(args, utils.CalendarDayEnd(*endDate))
 	}
 
 	if paymentMethodID != nil {
@@ -130,98 +488,37 @@ func (s *ExpenseService) GetExpensesByLobby(lobbyID int64, startDate *time.Time,
 	}
 	defer rows.Close()
 
-	var expenses []*database.Expense
-	for rows.Next() {
-		var expense database.Expense
-		err := rows.Scan(
-			&expense.ID,
-			&expense.LobbyID,
-			&expense.SpenderTelegramID,
-			&expense.PaymentMethodID,
-			&expense.Amount,
-			&expense.Description,
-			&expense.Category,
-			&expense.ExpenseDate,
-			&expense.BillingPeriodStart,
-			&expense.BillingPeriodEnd,
-			&expense.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan expense: %w", err)
-		}
-		expenses = append(expenses, &expense)
-	}
-
-	return expenses, nil
+	return scanExpenses(rows)
 }
 
 // GetExpensesByBillingPeriod gets expenses for a specific billing period
 func (s *ExpenseService) GetExpensesByBillingPeriod(lobbyID int64, paymentMethodID int64, periodStart, periodEnd time.Time) ([]*database.Expense, error) {
 	conn := s.db.GetConn()
 
-	query := `SELECT id, lobby_id, spender_telegram_id, payment_method_id, amount, 
-	          description, category, expense_date, billing_period_start, 
-	          billing_period_end, created_at
+	query := `SELECT ` + expenseColumns + `
 	          FROM expenses 
 	          WHERE lobby_id = ? AND payment_method_id = ?
 	          AND billing_period_start >= ? AND billing_period_end <= ?
 	          ORDER BY expense_date DESC`
 
-	rows, err := conn.Query(query, lobbyID, paymentMethodID, periodStart, periodEnd)
+	// Stored periods are in UTC; bounds in another location would compare wrong as text
+	rows, err := conn.Query(query, lobbyID, paymentMethodID, utils.CalendarDate(periodStart), utils.CalendarDayEnd(periodEnd))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query expenses: %w", err)
 	}
 	defer rows.Close()
 
-	var expenses []*database.Expense
-	for rows.Next() {
-		var expense database.Expense
-		err := rows.Scan(
-			&expense.ID,
-			&expense.LobbyID,
-			&expense.SpenderTelegramID,
-			&expense.PaymentMethodID,
-			&expense.Amount,
-			&expense.Description,
-			&expense.Category,
-			&expense.ExpenseDate,
-			&expense.BillingPeriodStart,
-			&expense.BillingPeriodEnd,
-			&expense.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan expense: %w", err)
-		}
-		expenses = append(expenses, &expense)
-	}
-
-	return expenses, nil
+	return scanExpenses(rows)
 }
 
 // GetExpenseByID gets an expense by ID
 func (s *ExpenseService) GetExpenseByID(id int64) (*database.Expense, error) {
 	conn := s.db.GetConn()
 
-	var expense database.Expense
-	query := `SELECT id, lobby_id, spender_telegram_id, payment_method_id, amount, 
-	          description, category, expense_date, billing_period_start, 
-	          billing_period_end, created_at
+	query := `SELECT ` + expenseColumns + `
 	          FROM expenses WHERE id = ?`
 
-	err := conn.QueryRow(query, id).Scan(
-		&expense.ID,
-		&expense.LobbyID,
-		&expense.SpenderTelegramID,
-		&expense.PaymentMethodID,
-		&expense.Amount,
-		&expense.Description,
-		&expense.Category,
-		&expense.ExpenseDate,
-		&expense.BillingPeriodStart,
-		&expense.BillingPeriodEnd,
-		&expense.CreatedAt,
-	)
-
+	expense, err := scanExpense(conn.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -229,29 +526,130 @@ func (s *ExpenseService) GetExpenseByID(id int64) (*database.Expense, error) {
 		return nil, fmt.Errorf("failed to query expense: %w", err)
 	}
 
-	return &expense, nil
+	return expense, nil
 }
 
-// UpdateExpense updates an expense
-func (s *ExpenseService) UpdateExpense(id int64, amount *float64, description *string, category *string, expenseDate *time.Time, paymentMethodID *int64) error {
+// GetInstallments gets every installment of the purchase the given expense belongs to, ordered by number
+func (s *ExpenseService) GetInstallments(expenseID int64) ([]*database.Expense, error) {
 	conn := s.db.GetConn()
 
-	updates := []string{}
-	args := []interface{}{}
+	expense, err := s.GetExpenseByID(expenseID)
+	if err != nil {
+		return nil, err
+	}
+	if expense == nil || !expense.IsInstallment() {
+		return nil, nil
+	}
 
-	if amount != nil {
-		updates = append(updates, "amount = ?")
-		args = append(args, *amount)
+	parentID := expense.ID
+	if expense.ParentExpenseID.Valid {
+		parentID = expense.ParentExpenseID.Int64
+	}
+
+	query := `SELECT ` + expenseColumns + `
+	          FROM expenses WHERE id = ? OR parent_expense_id = ?
+	          ORDER BY installment_number`
+
+	rows, err := conn.Query(query, parentID, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query installments: %w", err)
+	}
+	defer rows.Close()
+
+	return scanExpenses(rows)
+}
+
+// GetCategoryNames gets the names of the active categories of a lobby
+func (s *ExpenseService) GetCategoryNames(lobbyID int64) ([]string, error) {
+	categories, err := NewCategoryService(s.db).GetCategories(lobbyID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(categories))
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+	return names, nil
+}
+
+// UpdateExpense updates an expense. The amount of an installment purchase's parent is the purchase total before
+// interest: the installments still to be billed share what is left of it with the purchase's interest. Changing an
+// expense on a reconciled statement, or moving it onto one, returns ErrStatementReconciled unless forced.
+func (s *ExpenseService) UpdateExpense(id int64, amount *utils.Money, description *string, category *string, expenseDate *time.Time, paymentMethodID *int64, split *ExpenseSplit, force bool) error {
+	conn := s.db.GetConn()
+
+	if amount == nil && description == nil && category == nil && expenseDate == nil && paymentMethodID == nil && split == nil {
+		return nil // Nothing to update
+	}
+	if expenseDate != nil {
+		date := utils.CalendarDate(*expenseDate)
+		expenseDate = &date
+	}
+
+	expense, err := s.GetExpenseByID(id)
+	if err != nil {
+		return err
+	}
+	if expense == nil {
+		return fmt.Errorf("expense not found")
+	}
+	isParent := expense.IsInstallment() && !expense.ParentExpenseID.Valid
+
+	if !force {
+		if err := s.checkReconciledUpdate(expense, isParent, expenseDate, paymentMethodID); err != nil {
+			return err
+		}
+	}
+
+	var installments []*database.Expense
+	var charges []utils.Money
+	if isParent {
+		if installments, err = s.GetInstallments(id); err != nil {
+			return err
+		}
+		if amount != nil {
+			if charges, err = installmentCharges(installments, *amount); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Columns every installment of a purchase shares, and those of the expense alone
+	shared, sharedArgs := []string{}, []interface{}{}
+	updates, args := []string{}, []interface{}{}
+
+	if split != nil {
+		resolved, err := s.resolveSplit(id, amount, split)
+		if err != nil {
+			return err
+		}
+		mode, percent, splitAmount := resolved.columns()
+		shared = append(shared, "split_mode = ?", "split_percent = ?", "split_amount_minor = ?")
+		sharedArgs = append(sharedArgs, mode, percent, splitAmount)
+	}
+
+	if amount != nil && !isParent {
+		updates = append(updates, "amount_minor = ?")
+		args = append(args, amount.Amount)
+		if amount.Currency != "" {
+			updates = append(updates, "currency = ?")
+			args = append(args, amount.Currency)
+		}
 	}
 
 	if description != nil {
-		updates = append(updates, "description = ?")
-		args = append(args, sql.NullString{String: *description, Valid: *description != ""})
+		shared = append(shared, "description = ?")
+		sharedArgs = append(sharedArgs, sql.NullString{String: *description, Valid: *description != ""})
 	}
 
 	if category != nil {
-		updates = append(updates, "category = ?")
-		args = append(args, sql.NullString{String: *category, Valid: *category != ""})
+		catNull, catID, err := s.resolveCategory(expense.LobbyID, *category)
+		if err != nil {
+			return err
+		}
+		shared = append(shared, "category = ?", "category_id = ?")
+		sharedArgs = append(sharedArgs, catNull, catID)
 	}
 
 	if expenseDate != nil {
@@ -260,54 +658,425 @@ func (s *ExpenseService) UpdateExpense(id int64, amount *float64, description *s
 	}
 
 	if paymentMethodID != nil {
-		updates = append(updates, "payment_method_id = ?")
-		args = append(args, sql.NullInt64{Int64: *paymentMethodID, Valid: true})
-		
-		// Recalculate billing period
-		pmService := NewPaymentMethodService(s.db)
-		pm, err := pmService.GetPaymentMethodByID(*paymentMethodID)
-		if err == nil && pm != nil && pm.ClosingDay.Valid {
-			date := expenseDate
-			if date == nil {
-				// Get current expense date
-				expense, err := s.GetExpenseByID(id)
-				if err == nil && expense != nil {
-					date = &expense.ExpenseDate
-				}
+		shared = append(shared, "payment_method_id = ?")
+		sharedArgs = append(sharedArgs, sql.NullInt64{Int64: *paymentMethodID, Valid: true})
+	}
+
+	// A new date or payment method can put the expense on another statement, and an installment purchase
+	// on the statements following it
+	var cycles *utils.BillingCycles
+	moved := *expense
+	if expenseDate != nil || paymentMethodID != nil {
+		if expenseDate != nil {
+			moved.ExpenseDate = *expenseDate
+		}
+		if paymentMethodID != nil {
+			moved.PaymentMethodID = sql.NullInt64{Int64: *paymentMethodID, Valid: true}
+		}
+		if moved.PaymentMethodID.Valid {
+			start, end, err := s.billingPeriod(moved.PaymentMethodID.Int64, moved.ExpenseDate)
+			if err != nil {
+				return err
 			}
-			if date != nil {
-				start, end := utils.CalculateBillingPeriod(*date, pm.ClosingDay.Int64)
-				updates = append(updates, "billing_period_start = ?")
-				updates = append(updates, "billing_period_end = ?")
-				args = append(args, start, end)
+			updates = append(updates, "billing_period_start = ?", "billing_period_end = ?")
+			args = append(args, start, end)
+		}
+		if isParent && moved.PaymentMethodID.Valid {
+			pmService := NewPaymentMethodService(s.db)
+			pm, err := pmService.GetPaymentMethodByID(moved.PaymentMethodID.Int64)
+			if err != nil {
+				return fmt.Errorf("failed to get payment method: %w", err)
+			}
+			if pm != nil && pm.ClosingDay.Valid {
+				c, err := pmService.BillingCycles(pm)
+				if err != nil {
+					return err
+				}
+				cycles = &c
 			}
 		}
 	}
 
-	if len(updates) == 0 {
-		return nil // Nothing to update
+	// The purchase and its installments change together or not at all
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	args = append(args, id)
-	query := fmt.Sprintf("UPDATE expenses SET %s WHERE id = ?", 
-		strings.Join(updates, ", "))
+	if columns := append(updates, shared...); len(columns) > 0 {
+		query := fmt.Sprintf("UPDATE expenses SET %s WHERE id = ?", strings.Join(columns, ", "))
+		if _, err := tx.Exec(query, append(append(args, sharedArgs...), id)...); err != nil {
+			return fmt.Errorf("failed to update expense: %w", err)
+		}
+	}
+	if isParent {
+		if err := s.cascadeInstallmentUpdate(tx, &moved, installments, charges, shared, sharedArgs, cycles); err != nil {
+			return err
+		}
+	}
 
-	_, err := conn.Exec(query, args...)
-	if err != nil {
-		return fmt.Errorf("failed to update expense: %w", err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit expense update: %w", err)
+	}
+	return nil
+}
+
+// installmentCharges returns the amounts of the installments of a purchase, in order, once its total before
+// interest becomes amount. Installments already billed keep theirs; the others share the rest of the total
+// with the interest the purchase was made with.
+func installmentCharges(installments []*database.Expense, amount utils.Money) ([]utils.Money, error) {
+	parent := installments[0]
+	if !parent.InstallmentInterest.Valid {
+		return nil, ErrInterestUnknown
+	}
+	currency := parent.Amount.Currency
+	if amount.Currency != "" {
+		currency = amount.Currency
+	}
+	rest := amount.WithCurrency(currency).MulRatio(1 + parent.InstallmentInterest.Float64/100)
+
+	charges := make([]utils.Money, len(installments))
+	var unbilled []int
+	today := utils.CalendarDate(time.Now())
+	for i, installment := range installments {
+		if !isBilled(installment, today) {
+			unbilled = append(unbilled, i)
+			continue
+		}
+		charges[i] = installment.Amount
+		var err error
+		if rest, err = rest.Sub(installment.Amount); err != nil {
+			return nil, fmt.Errorf("the currency of a purchase cannot change once an installment is billed: %w", err)
+		}
+	}
+	if rest.IsNegative() {
+		return nil, ErrBelowBilled
+	}
+	if len(unbilled) == 0 && !rest.IsZero() {
+		return nil, fmt.Errorf("every installment of the purchase is already billed")
+	}
+	for i, charge := range rest.Split(len(unbilled)) {
+		charges[unbilled[i]] = charge
+	}
+	return charges, nil
+}
+
+// isBilled reports whether an expense is on a statement that closed before today
+func isBilled(expense *database.Expense, today time.Time) bool {
+	return expense.BillingPeriodEnd.Valid && expense.BillingPeriodEnd.Time.Before(today)
+}
+
+// checkReconciledUpdate returns ErrStatementReconciled when an update of an expense (and of its installments,
+// for the parent of a purchase) changes a reconciled statement: one they are on, or one a new date or payment
+// method moves them to
+func (s *ExpenseService) checkReconciledUpdate(expense *database.Expense, isParent bool, expenseDate *time.Time, paymentMethodID *int64) error {
+	affected := []*database.Expense{expense}
+	if isParent {
+		installments, err := s.GetInstallments(expense.ID)
+		if err != nil {
+			return err
+		}
+		affected = installments
+	}
+	for _, e := range affected {
+		if err := s.checkReconciled(e.PaymentMethodID, e.BillingPeriodEnd); err != nil {
+			return err
+		}
 	}
 
+	if expenseDate == nil && paymentMethodID == nil {
+		return nil
+	}
+	date := expense.ExpenseDate
+	if expenseDate != nil {
+		date = *expenseDate
+	}
+	method := expense.PaymentMethodID
+	if paymentMethodID != nil {
+		method = sql.NullInt64{Int64: *paymentMethodID, Valid: true}
+	}
+	if !method.Valid {
+		return nil
+	}
+	if !isParent {
+		_, end, err := s.billingPeriod(method.Int64, date)
+		if err != nil {
+			return err
+		}
+		return s.checkReconciled(method, end)
+	}
+
+	pmService := NewPaymentMethodService(s.db)
+	pm, err := pmService.GetPaymentMethodByID(method.Int64)
+	if err != nil || pm == nil || !pm.ClosingDay.Valid {
+		return err
+	}
+	cycles, err := pmService.BillingCycles(pm)
+	if err != nil {
+		return err
+	}
+	for _, period := range cycles.Periods(date, len(affected)) {
+		if err := s.checkReconciled(method, sql.NullTime{Time: period.End, Valid: true}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// DeleteExpense deletes an expense
-func (s *ExpenseService) DeleteExpense(id int64) error {
+// resolveSplit validates a new split for an expense. Installment purchases are split as a whole,
+// so a split by amount becomes the equivalent percentage of the purchase total.
+func (s *ExpenseService) resolveSplit(id int64, amount *utils.Money, split *ExpenseSplit) (*ExpenseSplit, error) {
+	expense, err := s.GetExpenseByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if expense == nil {
+		return nil, fmt.Errorf("expense not found")
+	}
+
+	total := expense.Amount
+	if amount != nil {
+		total = amount.WithCurrency(total.Currency)
+	}
+	if expense.IsInstallment() && amount == nil {
+		installments, err := s.GetInstallments(id)
+		if err != nil {
+			return nil, err
+		}
+		total = utils.NewMoney(0, total.Currency)
+		for _, installment := range installments {
+			if total, err = total.Add(installment.Amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := split.validate(total); err != nil {
+		return nil, err
+	}
+	if expense.IsInstallment() {
+		return split.asPercentOf(total)
+	}
+	return split, nil
+}
+
+// cascadeInstallmentUpdate propagates an edit of an installment purchase's parent to its other installments, in
+// the transaction of the parent's update: the shared columns, the new charges (nil when the amount is unchanged),
+// and with the cycles of its payment method after a new date or payment method, the statements following the
+// purchase's. parent is the purchase as updated.
+func (s *ExpenseService) cascadeInstallmentUpdate(tx *sql.Tx, parent *database.Expense, installments []*database.Expense, charges []utils.Money, shared []string, sharedArgs []interface{}, cycles *utils.BillingCycles) error {
+	if len(shared) > 0 {
+		query := fmt.Sprintf("UPDATE expenses SET %s WHERE parent_expense_id = ?", strings.Join(shared, ", "))
+		if _, err := tx.Exec(query, append(sharedArgs, parent.ID)...); err != nil {
+			return fmt.Errorf("failed to update installments: %w", err)
+		}
+	}
+
+	for i, installment := range installments {
+		if charges == nil || charges[i] == installment.Amount {
+			continue
+		}
+		_, err := tx.Exec(`UPDATE expenses SET amount_minor = ?, currency = ? WHERE id = ?`,
+			charges[i].Amount, charges[i].Currency, installment.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update installment amount: %w", err)
+		}
+	}
+
+	if cycles == nil {
+		return nil
+	}
+	_, err := s.placeInstallments(tx, parent, installments, *cycles, false)
+	return err
+}
+
+// placeInstallments puts the installments of a purchase on consecutive statements of its payment method's cycles,
+// starting with the one the purchase falls in. With keepReconciled, installments on a reconciled statement,
+// or that would move onto one, stay where they are. It returns how many installments moved.
+func (s *ExpenseService) placeInstallments(q rowQuerier, parent *database.Expense, installments []*database.Expense, cycles utils.BillingCycles, keepReconciled bool) (int, error) {
+	periods := cycles.Periods(parent.ExpenseDate, len(installments))
+	moved := 0
+	for i, installment := range installments {
+		expenseDate := parent.ExpenseDate
+		if i > 0 {
+			expenseDate = periods[i].Start
+		}
+		same := samePeriod(installment, periods[i])
+		if !same && keepReconciled {
+			frozen, err := s.onReconciledStatement(installment, periods[i])
+			if err != nil {
+				return moved, err
+			}
+			if frozen {
+				continue
+			}
+		}
+		_, err := q.Exec(`UPDATE expenses SET expense_date = ?, billing_period_start = ?, billing_period_end = ? WHERE id = ?`,
+			expenseDate, periods[i].Start, periods[i].End, installment.ID)
+		if err != nil {
+			return moved, fmt.Errorf("failed to update installment billing period: %w", err)
+		}
+		if !same {
+			moved++
+		}
+	}
+
+	return moved, nil
+}
+
+// onReconciledStatement reports whether an expense is on a reconciled statement, or moving it to period
+// would put it on one
+func (s *ExpenseService) onReconciledStatement(expense *database.Expense, period utils.BillingPeriod) (bool, error) {
+	for _, end := range []sql.NullTime{expense.BillingPeriodEnd, {Time: period.End, Valid: true}} {
+		rec, err := reconciledStatement(s.db, expense.PaymentMethodID, end)
+		if err != nil {
+			return false, err
+		}
+		if rec != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RecomputeBillingPeriods places every expense of a payment method on the statement its closing dates
+// now give it, e.g. after a cycle's closing date changed. Reconciled statements keep the expenses they
+// had: none is moved off or onto one. It returns how many expenses moved.
+func (s *ExpenseService) RecomputeBillingPeriods(paymentMethodID int64) (int, error) {
 	conn := s.db.GetConn()
-	query := `DELETE FROM expenses WHERE id = ?`
-	_, err := conn.Exec(query, id)
+
+	pm, err := NewPaymentMethodService(s.db).GetPaymentMethodByID(paymentMethodID)
+	if err != nil || pm == nil || !pm.ClosingDay.Valid {
+		return 0, err
+	}
+	cycles, err := NewPaymentMethodService(s.db).BillingCycles(pm)
 	if err != nil {
-		return fmt.Errorf("failed to delete expense: %w", err)
+		return 0, err
 	}
-	return nil
+
+	// Installments follow their parent purchase, so only single payments and parents are read
+	rows, err := conn.Query(`SELECT `+expenseColumns+`
+	          FROM expenses WHERE payment_method_id = ? AND parent_expense_id IS NULL`, paymentMethodID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expenses: %w", err)
+	}
+	expenses, err := scanExpenses(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, expense := range expenses {
+		if expense.IsInstallment() {
+			installments, err := s.GetInstallments(expense.ID)
+			if err != nil {
+				return moved, err
+			}
+			n, err := s.placeInstallments(conn, expense, installments, cycles, true)
+			moved += n
+			if err != nil {
+				return moved, err
+			}
+			continue
+		}
+
+		period := cycles.PeriodOf(expense.ExpenseDate)
+		if samePeriod(expense, period) {
+			continue
+		}
+		frozen, err := s.onReconciledStatement(expense, period)
+		if err != nil {
+			return moved, err
+		}
+		if frozen {
+			continue
+		}
+		_, err = conn.Exec(`UPDATE expenses SET billing_period_start = ?, billing_period_end = ? WHERE id = ?`,
+			period.Start, period.End, expense.ID)
+		if err != nil {
+			return moved, fmt.Errorf("failed to update expense billing period: %w", err)
+		}
+		moved++
+	}
+
+	return moved, nil
 }
 
+// samePeriod reports whether an expense is already on the given statement
+func samePeriod(expense *database.Expense, period utils.BillingPeriod) bool {
+	return expense.BillingPeriodStart.Valid && expense.BillingPeriodEnd.Valid &&
+		utils.FormatDate(expense.BillingPeriodStart.Time) == utils.FormatDate(period.Start) &&
+		utils.FormatDate(expense.BillingPeriodEnd.Time) == utils.FormatDate(period.End)
+}
+
+// DeleteExpense deletes an expense. Deleting the parent of an installment purchase also deletes its remaining
+// installments, the ones whose statement has not closed yet; those already billed are kept, the earliest
+// becoming the parent. Deleting from a reconciled statement returns ErrStatementReconciled unless forced.
+// It returns how many installments were deleted with the expense.
+func (s *ExpenseService) DeleteExpense(id int64, force bool) (int, error) {
+	expense, err := s.GetExpenseByID(id)
+	if err != nil {
+		return 0, err
+	}
+	if expense == nil {
+		return 0, fmt.Errorf("expense not found")
+	}
+
+	deleted := []*database.Expense{expense}
+	var remaining, billed []int64
+	if expense.IsInstallment() && !expense.ParentExpenseID.Valid {
+		installments, err := s.GetInstallments(id)
+		if err != nil {
+			return 0, err
+		}
+		today := utils.CalendarDate(time.Now())
+		for _, installment := range installments {
+			switch {
+			case installment.ID == id:
+			case isBilled(installment, today):
+				billed = append(billed, installment.ID)
+			default:
+				remaining = append(remaining, installment.ID)
+				deleted = append(deleted, installment)
+			}
+		}
+	}
+
+	if !force {
+		for _, e := range deleted {
+			if err := s.checkReconciled(e.PaymentMethodID, e.BillingPeriodEnd); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	tx, err := s.db.GetConn().Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, installmentID := range remaining {
+		if _, err := tx.Exec(`DELETE FROM expenses WHERE id = ?`, installmentID); err != nil {
+			return 0, fmt.Errorf("failed to delete installment: %w", err)
+		}
+	}
+	if len(billed) > 0 {
+		if _, err := tx.Exec(`UPDATE expenses SET parent_expense_id = NULL WHERE id = ?`, billed[0]); err != nil {
+			return 0, fmt.Errorf("failed to update installments: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE expenses SET parent_expense_id = ? WHERE parent_expense_id = ?`, billed[0], id); err != nil {
+			return 0, fmt.Errorf("failed to update installments: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM expenses WHERE id = ?`, id); err != nil {
+		return 0, fmt.Errorf("failed to delete expense: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit expense deletion: %w", err)
+	}
+	return len(remaining), nil
+}
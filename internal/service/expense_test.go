@@ -0,0 +1,328 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGetExpensesByBillingPeriodOutsideUTC(t *testing.T) {
+	loc := inLocation(t, "America/Argentina/Buenos_Aires")
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 2)
+	card := newTestCard(t, db, lobby.ID, 20)
+	expenses := NewExpenseService(db)
+	methods := NewPaymentMethodService(db)
+
+	tests := []struct {
+		name  string
+		date  time.Time
+		month string
+	}{
+		{"local midnight", time.Date(2026, 9, 21, 0, 0, 0, 0, loc), "2026-10"},
+		{"late on closing day", time.Date(2026, 10, 20, 23, 30, 0, 0, loc), "2026-10"},
+		{"day after closing", time.Date(2026, 10, 21, 0, 30, 0, 0, loc), "2026-11"},
+		{"UTC date", day("2026-11-05"), "2026-11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expense, err := expenses.CreateExpense(lobby.ID, 1, utils.NewMoney(1000, "ARS"), tt.name, "", tt.date, &card.ID, nil, false)
+			if err != nil {
+				t.Fatalf("CreateExpense: %v", err)
+			}
+
+			month, _ := utils.ParseMonth(tt.month)
+			start, end, err := methods.BillingPeriodForMonth(card, month)
+			if err != nil {
+				t.Fatalf("BillingPeriodForMonth: %v", err)
+			}
+			if !expense.BillingPeriodEnd.Time.Equal(end) {
+				t.Errorf("stored period ends %v, want %v", expense.BillingPeriodEnd.Time, end)
+			}
+
+			found, err := expenses.GetExpensesByBillingPeriod(lobby.ID, card.ID, start, end)
+			if err != nil {
+				t.Fatalf("GetExpensesByBillingPeriod: %v", err)
+			}
+			for _, got := range found {
+				if got.ID == expense.ID {
+					return
+				}
+			}
+			t.Errorf("expense dated %v not in the %s statement (%v - %v)", tt.date, tt.month, start, end)
+		})
+	}
+}
+
+func TestBillingPeriodsMigratedToUTC(t *testing.T) {
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 2)
+	card := newTestCard(t, db, lobby.ID, 20)
+
+	// Stored as before periods were kept in UTC, with the offset of the expense date
+	conn := db.GetConn()
+	_, err := conn.Exec(`INSERT INTO expenses (lobby_id, spender_telegram_id, amount_minor, currency, expense_date,
+		payment_method_id, billing_period_start, billing_period_end)
+		VALUES (?, 1, 1000, 'ARS', '2026-10-05 00:00:00-03:00', ?, '2026-09-21 00:00:00-03:00', '2026-10-20 23:59:59.999999999-03:00')`,
+		lobby.ID, card.ID)
+	if err != nil {
+		t.Fatalf("failed to insert old expense: %v", err)
+	}
+	if _, err := conn.Exec(`DELETE FROM schema_migrations WHERE name = 'utc_billing_periods'`); err != nil {
+		t.Fatalf("failed to reset migration: %v", err)
+	}
+	reopened, err := database.NewDB(testDSN(t))
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	defer reopened.Close()
+
+	month, _ := utils.ParseMonth("2026-10")
+	start, end, err := NewPaymentMethodService(db).BillingPeriodForMonth(card, month)
+	if err != nil {
+		t.Fatalf("BillingPeriodForMonth: %v", err)
+	}
+	found, err := NewExpenseService(db).GetExpensesByBillingPeriod(lobby.ID, card.ID, start, end)
+	if err != nil {
+		t.Fatalf("GetExpensesByBillingPeriod: %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("found %d expenses in the migrated statement, want 1", len(found))
+	}
+	if got := found[0].BillingPeriodStart.Time; !got.Equal(day("2026-09-21")) {
+		t.Errorf("period starts %v, want 2026-09-21 UTC", got)
+	}
+}
+
+// newTestPurchase buys 600.00 ARS with 10% interest in 6 installments on a card, starting two months ago
+// so that the first installments are already billed and the last ones are not
+func newTestPurchase(t *testing.T, db *database.DB) []*database.Expense {
+	t.Helper()
+	lobby := newTestLobby(t, db, 2)
+	card := newTestCard(t, db, lobby.ID, 20)
+	installments, err := NewExpenseService(db).CreateInstallmentExpense(lobby.ID, 1, utils.NewMoney(60000, "ARS"), 6, 10,
+		"tv", "", time.Now().AddDate(0, -2, 0), card.ID, nil, false)
+	if err != nil {
+		t.Fatalf("CreateInstallmentExpense: %v", err)
+	}
+	today := utils.CalendarDate(time.Now())
+	if !isBilled(installments[0], today) || isBilled(installments[len(installments)-1], today) {
+		t.Fatalf("want billed and unbilled installments")
+	}
+	return installments
+}
+
+func TestUpdateInstallmentPurchase(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		prepare func(t *testing.T, db *database.DB, parentID int64)
+		want    error
+	}{
+		{"new total keeps the interest", 120000, nil, nil},
+		{"lower total", 45000, nil, nil},
+		{"below what was billed", 1000, nil, ErrBelowBilled},
+		{"purchase saved without its interest", 120000, func(t *testing.T, db *database.DB, parentID int64) {
+			if _, err := db.GetConn().Exec(`UPDATE expenses SET installment_interest = NULL
+				WHERE id = ? OR parent_expense_id = ?`, parentID, parentID); err != nil {
+				t.Fatal(err)
+			}
+		}, ErrInterestUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			before := newTestPurchase(t, db)
+			parent := before[0]
+			if tt.prepare != nil {
+				tt.prepare(t, db, parent.ID)
+			}
+			expenses := NewExpenseService(db)
+
+			amount := utils.NewMoney(tt.amount, "")
+			err := expenses.UpdateExpense(parent.ID, &amount, nil, nil, nil, nil, nil, false)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("UpdateExpense = %v, want %v", err, tt.want)
+			}
+
+			after, err := expenses.GetInstallments(parent.ID)
+			if err != nil {
+				t.Fatalf("GetInstallments: %v", err)
+			}
+			if len(after) != len(before) {
+				t.Fatalf("got %d installments, want %d", len(after), len(before))
+			}
+			today := utils.CalendarDate(time.Now())
+			total := utils.NewMoney(0, "ARS")
+			var unbilled []utils.Money
+			for i, installment := range after {
+				if total, err = total.Add(installment.Amount); err != nil {
+					t.Fatalf("Add: %v", err)
+				}
+				switch {
+				case tt.want != nil || isBilled(before[i], today):
+					if installment.Amount != before[i].Amount {
+						t.Errorf("installment %d = %s, want it unchanged at %s", i+1, installment.Amount, before[i].Amount)
+					}
+				default:
+					unbilled = append(unbilled, installment.Amount)
+				}
+			}
+			if tt.want != nil {
+				return
+			}
+			if want := amount.WithCurrency("ARS").MulRatio(1.1); total != want {
+				t.Errorf("installments add up to %s, want %s with the interest", total, want)
+			}
+			for _, charge := range unbilled {
+				if diff := charge.Amount - unbilled[0].Amount; diff > 1 || diff < -1 {
+					t.Errorf("unbilled installments %v are not even", unbilled)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateInstallmentPurchaseIsAtomic(t *testing.T) {
+	db := newTestDB(t)
+	before := newTestPurchase(t, db)
+	expenses := NewExpenseService(db)
+
+	// Fail moving the last installment, after the parent and the first ones moved
+	_, err := db.GetConn().Exec(`CREATE TRIGGER fail_last_installment BEFORE UPDATE OF expense_date ON expenses
+		WHEN OLD.id = ` + fmt.Sprint(before[len(before)-1].ID) + ` BEGIN SELECT RAISE(ABORT, 'disk full'); END`)
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	date := before[0].ExpenseDate.AddDate(0, 1, 0)
+	description := "tv 55"
+	if err := expenses.UpdateExpense(before[0].ID, nil, &description, nil, &date, nil, nil, false); err == nil {
+		t.Fatal("UpdateExpense succeeded despite the failing installment")
+	}
+
+	after, err := expenses.GetInstallments(before[0].ID)
+	if err != nil {
+		t.Fatalf("GetInstallments: %v", err)
+	}
+	for i, installment := range after {
+		if !installment.ExpenseDate.Equal(before[i].ExpenseDate) || installment.Description != before[i].Description {
+			t.Errorf("installment %d changed to %v %q though the update failed", i+1, installment.ExpenseDate, installment.Description.String)
+		}
+	}
+}
+
+func TestDeleteInstallmentPurchase(t *testing.T) {
+	db := newTestDB(t)
+	before := newTestPurchase(t, db)
+	expenses := NewExpenseService(db)
+
+	today := utils.CalendarDate(time.Now())
+	var billed []int64
+	unbilled := 0
+	for _, installment := range before[1:] {
+		if isBilled(installment, today) {
+			billed = append(billed, installment.ID)
+		} else {
+			unbilled++
+		}
+	}
+
+	deleted, err := expenses.DeleteExpense(before[0].ID, false)
+	if err != nil {
+		t.Fatalf("DeleteExpense: %v", err)
+	}
+	if deleted != unbilled {
+		t.Errorf("deleted %d installments with the purchase, want the %d unbilled", deleted, unbilled)
+	}
+
+	// The billed installments stay, the earliest as the parent of the rest
+	if len(billed) == 0 {
+		return
+	}
+	left, err := expenses.GetInstallments(billed[0])
+	if err != nil {
+		t.Fatalf("GetInstallments: %v", err)
+	}
+	if len(left) != len(billed) {
+		t.Fatalf("%d installments left, want the %d billed", len(left), len(billed))
+	}
+	for i, installment := range left {
+		if installment.ID != billed[i] {
+			t.Errorf("installment %d is #%d, want #%d", i+1, installment.ID, billed[i])
+		}
+		if parent := installment.ParentExpenseID; (i == 0) == parent.Valid || (i > 0 && parent.Int64 != billed[0]) {
+			t.Errorf("installment #%d has parent %v, want #%d", installment.ID, parent, billed[0])
+		}
+	}
+}
+
+func TestAmountsMigratedToMinorUnits(t *testing.T) {
+	// A database created before amounts were minor units, with REAL amounts that don't fit a float exactly
+	conn, err := sql.Open("sqlite3", testDSN(t))
+	if err != nil {
+		t.Fatalf("open old database: %v", err)
+	}
+	defer conn.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE users (telegram_id INTEGER PRIMARY KEY, username TEXT, display_name TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE lobbies (id INTEGER PRIMARY KEY AUTOINCREMENT, user1_telegram_id INTEGER, user2_telegram_id INTEGER,
+			account_type TEXT CHECK(account_type IN ('separate', 'shared')), user1_salary_percentage REAL DEFAULT 0.5,
+			user2_salary_percentage REAL DEFAULT 0.5, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE expenses (id INTEGER PRIMARY KEY AUTOINCREMENT, lobby_id INTEGER, spender_telegram_id INTEGER,
+			payment_method_id INTEGER, amount REAL NOT NULL, description TEXT, category TEXT, expense_date DATE NOT NULL,
+			billing_period_start DATE, billing_period_end DATE, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE recurring_expenses (id INTEGER PRIMARY KEY AUTOINCREMENT, lobby_id INTEGER NOT NULL,
+			spender_telegram_id INTEGER NOT NULL, payment_method_id INTEGER, amount REAL NOT NULL, description TEXT,
+			category TEXT, cadence TEXT CHECK(cadence IN ('weekly', 'monthly', 'yearly')) NOT NULL, day_of_month INTEGER,
+			start_date DATE NOT NULL, end_date DATE, next_run_date DATE NOT NULL, last_run_date DATE,
+			is_active BOOLEAN DEFAULT 1, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`,
+		`INSERT INTO users (telegram_id) VALUES (1)`,
+		`INSERT INTO lobbies (user1_telegram_id, account_type) VALUES (1, 'separate')`,
+		`INSERT INTO expenses (lobby_id, spender_telegram_id, amount, expense_date) VALUES
+			(1, 1, 0.29, '2026-01-05'), (1, 1, 19.99, '2026-01-06'), (1, 1, 1234.5, '2026-01-07'), (1, 1, 0.1 + 0.2, '2026-01-08')`,
+		`INSERT INTO recurring_expenses (lobby_id, spender_telegram_id, amount, cadence, start_date, next_run_date)
+			VALUES (1, 1, 4.35, 'monthly', '2026-01-01', '2026-02-01')`,
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatalf("create old schema: %v\n%s", err, stmt)
+		}
+	}
+
+	db := newTestDB(t)
+
+	for _, table := range []string{"expenses", "recurring_expenses"} {
+		var hasAmount int
+		if err := db.GetConn().QueryRow(
+			`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'amount'`, table,
+		).Scan(&hasAmount); err != nil {
+			t.Fatalf("inspect %s: %v", table, err)
+		}
+		if hasAmount != 0 {
+			t.Errorf("%s still has the REAL amount column", table)
+		}
+	}
+
+	expenses := NewExpenseService(db)
+	for id, want := range map[int64]int64{1: 29, 2: 1999, 3: 123450, 4: 30} {
+		expense, err := expenses.GetExpenseByID(id)
+		if err != nil {
+			t.Fatalf("GetExpenseByID(%d): %v", id, err)
+		}
+		if expense.Amount.Amount != want {
+			t.Errorf("expense %d = %d minor units, want %d", id, expense.Amount.Amount, want)
+		}
+	}
+	var recurring int64
+	if err := db.GetConn().QueryRow(`SELECT amount_minor FROM recurring_expenses`).Scan(&recurring); err != nil {
+		t.Fatalf("query recurring expense: %v", err)
+	}
+	if recurring != 435 {
+		t.Errorf("recurring expense = %d minor units, want 435", recurring)
+	}
+}
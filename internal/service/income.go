@@ -0,0 +1,213 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrIncomeNotFound is returned when a member has no income logged for the requested month
+var ErrIncomeNotFound = errors.New("income not found")
+
+// IncomeService manages the monthly incomes of the lobby members
+type IncomeService struct {
+	db *database.DB
+}
+
+// NewIncomeService creates a new income service
+func NewIncomeService(db *database.DB) *IncomeService {
+	return &IncomeService{db: db}
+}
+
+// IncomeRatio is the ratio of a two-member lobby derived from the members' incomes, in the lobby's base currency.
+// User1 and User2 are the members in joining order.
+type IncomeRatio struct {
+	Months          []string // Months where both members logged an income, "2006-01"
+	User1Income     utils.Money
+	User2Income     utils.Money
+	User1Percentage float64 // 0..1
+	User2Percentage float64
+}
+
+// incomeColumns lists the columns selected for an income, in scanIncome order
+const incomeColumns = `id, lobby_id, user_telegram_id, month, amount_minor, currency, created_at`
+
+// scanIncome scans a row selected with incomeColumns
+func scanIncome(row rowScanner) (*database.Income, error) {
+	var income database.Income
+	err := row.Scan(
+		&income.ID,
+		&income.LobbyID,
+		&income.UserTelegramID,
+		&income.Month,
+		&income.Amount.Amount,
+		&income.Amount.Currency,
+		&income.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &income, nil
+}
+
+// SetIncome sets a member's income for a month, replacing the one logged before.
+// An amount without currency is in the lobby's base currency.
+func (s *IncomeService) SetIncome(lobbyID, userID int64, month time.Time, amount utils.Money) (*database.Income, error) {
+	conn := s.db.GetConn()
+
+	if amount.IsNegative() {
+		return nil, fmt.Errorf("income cannot be negative")
+	}
+	lobby, err := NewLobbyService(s.db).GetLobbyByID(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	if lobby == nil || !lobby.IsMember(userID) {
+		return nil, fmt.Errorf("the user is not a member of the lobby")
+	}
+
+	currency, err := NewExpenseService(s.db).resolveCurrency(lobbyID, amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
+
+	_, err = conn.Exec(`INSERT INTO incomes (lobby_id, user_telegram_id, month, amount_minor, currency, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?)
+	          ON CONFLICT (lobby_id, user_telegram_id, month) DO UPDATE SET
+	           amount_minor = excluded.amount_minor, currency = excluded.currency, created_at = excluded.created_at`,
+		lobbyID, userID, utils.FormatMonth(month), amount.Amount, amount.Currency, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to save income: %w", err)
+	}
+
+	return s.GetIncome(lobbyID, userID, month)
+}
+
+// GetIncome gets a member's income for a month
+func (s *IncomeService) GetIncome(lobbyID, userID int64, month time.Time) (*database.Income, error) {
+	conn := s.db.GetConn()
+
+	income, err := scanIncome(conn.QueryRow(`SELECT `+incomeColumns+` FROM incomes
+	          WHERE lobby_id = ? AND user_telegram_id = ? AND month = ?`,
+		lobbyID, userID, utils.FormatMonth(month)))
+	if err == sql.ErrNoRows {
+		return nil, ErrIncomeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query income: %w", err)
+	}
+	return income, nil
+}
+
+// GetIncomes gets the incomes of a lobby for the months from "from" to "to", by month
+func (s *IncomeService) GetIncomes(lobbyID int64, from, to time.Time) ([]*database.Income, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT `+incomeColumns+` FROM incomes
+	          WHERE lobby_id = ? AND month >= ? AND month <= ?
+	          ORDER BY month, user_telegram_id`,
+		lobbyID, utils.FormatMonth(from), utils.FormatMonth(to))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incomes: %w", err)
+	}
+	defer rows.Close()
+
+	var incomes []*database.Income
+	for rows.Next() {
+		income, err := scanIncome(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan income: %w", err)
+		}
+		incomes = append(incomes, income)
+	}
+	return incomes, rows.Err()
+}
+
+// DeleteIncome deletes a member's income for a month
+func (s *IncomeService) DeleteIncome(lobbyID, userID int64, month time.Time) error {
+	conn := s.db.GetConn()
+
+	result, err := conn.Exec(`DELETE FROM incomes WHERE lobby_id = ? AND user_telegram_id = ? AND month = ?`,
+		lobbyID, userID, utils.FormatMonth(month))
+	if err != nil {
+		return fmt.Errorf("failed to delete income: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrIncomeNotFound
+	}
+	return nil
+}
+
+// IncomeRatio derives the lobby ratio from the incomes of the months from "from" to "to". Only months where
+// both members logged an income count, each converted on the month's last day. It returns nil when no month
+// counts, the incomes add up to zero or the lobby does not have exactly two members, so the lobby settings apply.
+func (s *IncomeService) IncomeRatio(lobby *database.Lobby, from, to time.Time, converter *CurrencyConverter) (*IncomeRatio, error) {
+	if len(lobby.Members) != 2 {
+		return nil, nil
+	}
+	user1ID, user2ID := lobby.Members[0].UserTelegramID, lobby.Members[1].UserTelegramID
+	incomes, err := s.GetIncomes(lobby.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byMonth := make(map[string]map[int64]*database.Income)
+	var months []string
+	for _, income := range incomes {
+		if byMonth[income.Month] == nil {
+			byMonth[income.Month] = make(map[int64]*database.Income)
+			months = append(months, income.Month)
+		}
+		byMonth[income.Month][income.UserTelegramID] = income
+	}
+
+	ratio := &IncomeRatio{
+		User1Income: utils.NewMoney(0, converter.BaseCurrency),
+		User2Income: utils.NewMoney(0, converter.BaseCurrency),
+	}
+	for _, month := range months {
+		user1, ok1 := byMonth[month][user1ID]
+		user2, ok2 := byMonth[month][user2ID]
+		if !ok1 || !ok2 {
+			continue
+		}
+		monthStart, err := utils.ParseMonth(month)
+		if err != nil {
+			return nil, err
+		}
+		_, monthEnd := utils.GetMonthStartEnd(monthStart.Year(), monthStart.Month())
+
+		amount1, err := converter.Convert(user1.Amount, monthEnd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert income of %s: %w", month, err)
+		}
+		amount2, err := converter.Convert(user2.Amount, monthEnd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert income of %s: %w", month, err)
+		}
+		if ratio.User1Income, err = ratio.User1Income.Add(amount1); err != nil {
+			return nil, err
+		}
+		if ratio.User2Income, err = ratio.User2Income.Add(amount2); err != nil {
+			return nil, err
+		}
+		ratio.Months = append(ratio.Months, month)
+	}
+
+	total, err := ratio.User1Income.Add(ratio.User2Income)
+	if err != nil {
+		return nil, err
+	}
+	if len(ratio.Months) == 0 || !total.IsPositive() {
+		return nil, nil
+	}
+	if ratio.User1Percentage, err = ratio.User1Income.Ratio(total); err != nil {
+		return nil, err
+	}
+	ratio.User2Percentage = 1 - ratio.User1Percentage
+	return ratio, nil
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// DefaultInviteTTL is how long an invite token is valid when no duration is given
+	DefaultInviteTTL = 48 * time.Hour
+	// MinInviteTTL and MaxInviteTTL bound how long an invite token can be valid
+	MinInviteTTL = time.Hour
+	MaxInviteTTL = 30 * 24 * time.Hour
+)
+
+// Reasons an invite token stopped working before expiring or being used up
+const (
+	inviteRevokedByOwner   = "revoked"
+	inviteRevokedReplaced  = "replaced"
+	inviteRevokedLobbyFull = "lobby_full"
+)
+
+var (
+	// ErrInviteNotFound is returned when an invite token does not exist
+	ErrInviteNotFound = errors.New("invalid invitation token")
+	// ErrInviteExpired is returned when an invite token is past its expiry
+	ErrInviteExpired = errors.New("this invitation token has expired")
+	// ErrInviteUsedUp is returned when an invite token was already used as many times as it allows
+	ErrInviteUsedUp = errors.New("this invitation token has already been used")
+	// ErrInviteRevoked is returned when an invite token was revoked or replaced by a new one
+	ErrInviteRevoked = errors.New("this invitation token was revoked")
+	// ErrInviteOtherChat is returned when an invite token is used in a different chat than its lobby's
+	ErrInviteOtherChat = errors.New("this invitation token is for a different chat")
+	// ErrInviteGroupOnly is returned when an invite token of a group lobby is used in a private chat
+	ErrInviteGroupOnly = errors.New("this invitation token is for a group chat. Please join from that group")
+)
+
+// joinFailureReason returns why joining with an invite token failed, as logged, or "" when the failure does
+// not count towards a lockout (the user is already a member, or the database failed)
+func joinFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrInviteNotFound):
+		return "not_found"
+	case errors.Is(err, ErrInviteExpired):
+		return "expired"
+	case errors.Is(err, ErrInviteUsedUp):
+		return "used_up"
+	case errors.Is(err, ErrInviteRevoked):
+		return "revoked"
+	case errors.Is(err, ErrLobbyFull):
+		return "lobby_full"
+	case errors.Is(err, ErrInviteOtherChat), errors.Is(err, ErrInviteGroupOnly):
+		return "other_chat"
+	}
+	return ""
+}
+
+// inviteTokenColumns lists the columns selected for an invite token, in scanInviteToken order
+const inviteTokenColumns = `id, lobby_id, token, created_by, created_at, expires_at, max_uses,
+	          (SELECT COUNT(*) FROM invite_token_uses u WHERE u.invite_token_id = invite_tokens.id),
+	          revoked_at, revoked_reason`
+
+// scanInviteToken scans a row selected with inviteTokenColumns; who used it is loaded separately
+func scanInviteToken(row rowScanner) (*database.InviteToken, error) {
+	var token database.InviteToken
+	err := row.Scan(
+		&token.ID,
+		&token.LobbyID,
+		&token.Token,
+		&token.CreatedBy,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.MaxUses,
+		&token.Uses,
+		&token.RevokedAt,
+		&token.RevokedReason,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// inviteTokenError returns why an invite token cannot be used at a time, or nil if it can
+func inviteTokenError(token *database.InviteToken, now time.Time) error {
+	switch {
+	case token.RevokedAt.Valid && token.RevokedReason.String == inviteRevokedLobbyFull:
+		return ErrLobbyFull
+	case token.RevokedAt.Valid:
+		return ErrInviteRevoked
+	case !now.Before(token.ExpiresAt):
+		return ErrInviteExpired
+	case token.Uses >= token.MaxUses:
+		return ErrInviteUsedUp
+	}
+	return nil
+}
+
+// revokeInviteTokens stops every active invite token of a lobby from working, and returns how many it revoked
+func revokeInviteTokens(q rowQuerier, lobbyID int64, reason string) (int64, error) {
+	result, err := q.Exec(`UPDATE invite_tokens SET revoked_at = ?, revoked_reason = ?
+	          WHERE lobby_id = ? AND revoked_at IS NULL`, time.Now(), reason, lobbyID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke invite tokens: %w", err)
+	}
+	revoked, _ := result.RowsAffected()
+	return revoked, nil
+}
+
+// CreateInviteToken creates an invite token for a lobby, valid for a duration (DefaultInviteTTL when zero)
+// and for a number of joins
+func (s *LobbyService) CreateInviteToken(lobbyID int64, createdBy int64, ttl time.Duration, maxUses int) (*database.InviteToken, error) {
+	conn := s.db.GetConn()
+
+	if ttl == 0 {
+		ttl = DefaultInviteTTL
+	}
+	if ttl < MinInviteTTL || ttl > MaxInviteTTL {
+		return nil, fmt.Errorf("an invitation can be valid between 1 hour and %d days", int(MaxInviteTTL.Hours()/24))
+	}
+
+	lobby, err := s.GetLobbyByID(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	if lobby == nil || !lobby.IsMember(createdBy) {
+		return nil, ErrNotMember
+	}
+	if lobby.IsFull() {
+		return nil, ErrLobbyFull
+	}
+	if free := lobby.MaxMembers - len(lobby.Members); maxUses < 1 || maxUses > free {
+		return nil, fmt.Errorf("the lobby has room for %d more member(s)", free)
+	}
+
+	token, err := utils.GenerateInviteToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invite token: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	result, err := conn.Exec(`INSERT INTO invite_tokens (lobby_id, token, created_by, created_at, expires_at, max_uses)
+	          VALUES (?, ?, ?, ?, ?, ?)`, lobbyID, token, createdBy, now, expiresAt, maxUses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invite token: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite token ID: %w", err)
+	}
+
+	return &database.InviteToken{
+		ID:        id,
+		LobbyID:   lobbyID,
+		Token:     token,
+		CreatedBy: sql.NullInt64{Int64: createdBy, Valid: true},
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+		MaxUses:   maxUses,
+	}, nil
+}
+
+// GetActiveInviteTokens gets the invite tokens of a lobby that can still be used, newest first,
+// with who already joined with them
+func (s *LobbyService) GetActiveInviteTokens(lobbyID int64) ([]*database.InviteToken, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT `+inviteTokenColumns+` FROM invite_tokens
+	          WHERE lobby_id = ? AND revoked_at IS NULL ORDER BY created_at DESC, id DESC`, lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invite tokens: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var tokens []*database.InviteToken
+	for rows.Next() {
+		token, err := scanInviteToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invite token: %w", err)
+		}
+		if inviteTokenError(token, now) == nil {
+			tokens = append(tokens, token)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query invite tokens: %w", err)
+	}
+
+	for _, token := range tokens {
+		if token.UsedBy, err = s.getInviteTokenUsers(token.ID); err != nil {
+			return nil, err
+		}
+	}
+	return tokens, nil
+}
+
+// getInviteTokenUsers gets the Telegram IDs of the users who joined with an invite token, oldest first
+func (s *LobbyService) getInviteTokenUsers(tokenID int64) ([]int64, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT user_telegram_id FROM invite_token_uses WHERE invite_token_id = ? ORDER BY used_at, id`, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invite token uses: %w", err)
+	}
+	defer rows.Close()
+
+	var users []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan invite token use: %w", err)
+		}
+		users = append(users, userID)
+	}
+	return users, rows.Err()
+}
+
+// RevokeInviteToken stops an active invite token of a lobby from working
+func (s *LobbyService) RevokeInviteToken(lobbyID int64, tokenID int64) error {
+	conn := s.db.GetConn()
+
+	result, err := conn.Exec(`UPDATE invite_tokens SET revoked_at = ?, revoked_reason = ?
+	          WHERE id = ? AND lobby_id = ? AND revoked_at IS NULL`, time.Now(), inviteRevokedByOwner, tokenID, lobbyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke invite token: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+// RevokeInviteTokens stops every active invite token of a lobby from working, and returns how many it revoked
+func (s *LobbyService) RevokeInviteTokens(lobbyID int64) (int64, error) {
+	return revokeInviteTokens(s.db.GetConn(), lobbyID, inviteRevokedByOwner)
+}
+
+// RegenerateInviteToken replaces every active invite token of a lobby with a new one, valid for
+// DefaultInviteTTL and one join
+func (s *LobbyService) RegenerateInviteToken(lobbyID int64, createdBy int64) (*database.InviteToken, error) {
+	if _, err := revokeInviteTokens(s.db.GetConn(), lobbyID, inviteRevokedReplaced); err != nil {
+		return nil, err
+	}
+	token, err := s.CreateInviteToken(lobbyID, createdBy, DefaultInviteTTL, 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.security.LogEvent(SecurityEventTokenRegenerated, lobbyID, createdBy, 0, fmt.Sprintf("invite #%d", token.ID)); err != nil {
+		log.Printf("Error logging invite token regeneration of lobby %d: %v", lobbyID, err)
+	}
+	return token, nil
+}
+
+// OnRepeatedJoinFailures registers a function called when a user fails to join a lobby for the
+// OwnerAlertJoinFailures-th time within OwnerAlertWindow. Used to alert the lobby owner.
+func (s *LobbyService) OnRepeatedJoinFailures(listener func(lobby *database.Lobby, userID int64, failures int)) {
+	s.joinFailureListeners = append(s.joinFailureListeners, listener)
+}
+
+// JoinLobbyByToken allows a user to join an existing lobby by token, and returns the lobby joined.
+// groupChatID is used to validate that the lobby is for the same group (or private).
+// Unusable tokens return ErrInviteNotFound, ErrInviteExpired, ErrInviteUsedUp, ErrInviteRevoked, ErrLobbyFull,
+// ErrInviteOtherChat or ErrInviteGroupOnly, and count towards locking the user out of joining,
+// after which it returns ErrJoinLocked without looking at the token. Joins and failed joins are logged.
+func (s *LobbyService) JoinLobbyByToken(inviteToken string, userID int64, groupChatID *int64) (*database.Lobby, error) {
+	lockedUntil, err := s.security.JoinLockedUntil(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !lockedUntil.IsZero() {
+		return nil, ErrJoinLocked
+	}
+
+	lobby, tokenID, err := s.joinLobbyByToken(inviteToken, userID, groupChatID)
+	if err == nil {
+		if err := s.security.RecordJoin(userID, lobby.ID, fmt.Sprintf("invite #%d", tokenID)); err != nil {
+			log.Printf("Error logging join of user %d to lobby %d: %v", userID, lobby.ID, err)
+		}
+		return s.GetLobbyByID(lobby.ID)
+	}
+
+	reason := joinFailureReason(err)
+	if reason == "" {
+		return nil, err
+	}
+	var lobbyID int64
+	if lobby != nil {
+		lobbyID = lobby.ID
+	}
+	failures, logErr := s.security.RecordFailedJoin(userID, lobbyID, reason)
+	if logErr != nil {
+		log.Printf("Error recording failed join of user %d: %v", userID, logErr)
+	}
+	if lobby != nil && failures == OwnerAlertJoinFailures {
+		for _, listener := range s.joinFailureListeners {
+			listener(lobby, userID, failures)
+		}
+	}
+	return nil, err
+}
+
+// joinLobbyByToken adds a user to the lobby of an invite token, and returns the lobby and the ID of the token.
+// The lobby is also returned when joining fails after the token was found, so the failure can be logged against it.
+func (s *LobbyService) joinLobbyByToken(inviteToken string, userID int64, groupChatID *int64) (*database.Lobby, int64, error) {
+	conn := s.db.GetConn()
+
+	// Remove formatting if present
+	cleanToken := utils.ParseInviteToken(inviteToken)
+
+	token, err := scanInviteToken(conn.QueryRow(`SELECT `+inviteTokenColumns+` FROM invite_tokens WHERE token = ?`, cleanToken))
+	if err == sql.ErrNoRows {
+		return nil, 0, ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get invite token: %w", err)
+	}
+
+	lobby, err := s.GetLobbyByID(token.LobbyID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get lobby: %w", err)
+	}
+	if lobby == nil {
+		return nil, 0, ErrInviteNotFound
+	}
+	if lobby.IsMember(userID) {
+		return lobby, token.ID, ErrAlreadyMember
+	}
+	if err := inviteTokenError(token, time.Now()); err != nil {
+		return lobby, token.ID, err
+	}
+
+	// Validate group chat ID matches
+	if groupChatID != nil {
+		// Joining in a group - lobby must be for this group
+		if !lobby.GroupChatID.Valid || lobby.GroupChatID.Int64 != *groupChatID {
+			return lobby, token.ID, ErrInviteOtherChat
+		}
+	} else {
+		// Joining in private - lobby must be private (no group)
+		if lobby.GroupChatID.Valid {
+			return lobby, token.ID, ErrInviteGroupOnly
+		}
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return lobby, token.ID, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The uses are checked again on insert, so two users cannot use a single-use token at once
+	result, err := tx.Exec(`INSERT INTO invite_token_uses (invite_token_id, user_telegram_id, used_at)
+	          SELECT ?, ?, ? WHERE (SELECT COUNT(*) FROM invite_token_uses WHERE invite_token_id = ?) < ?
+	          AND EXISTS (SELECT 1 FROM invite_tokens WHERE id = ? AND revoked_at IS NULL)`,
+		token.ID, userID, time.Now(), token.ID, token.MaxUses, token.ID)
+	if err != nil {
+		return lobby, token.ID, fmt.Errorf("failed to use invite token: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return lobby, token.ID, ErrInviteUsedUp
+	}
+
+	if err := s.addMember(tx, lobby, userID); err != nil {
+		return lobby, token.ID, err
+	}
+	if err := tx.Commit(); err != nil {
+		return lobby, token.ID, fmt.Errorf("failed to commit join: %w", err)
+	}
+	return lobby, token.ID, nil
+}
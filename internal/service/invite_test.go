@@ -0,0 +1,147 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJoinLobbyByTokenErrors(t *testing.T) {
+	db := newTestDB(t)
+	users := NewUserService(db)
+	for id := int64(1); id <= 30; id++ {
+		if _, err := users.GetOrCreateUser(id, "", ""); err != nil {
+			t.Fatalf("failed to create user %d: %v", id, err)
+		}
+	}
+	lobbies := NewLobbyService(db)
+
+	// newLobby creates a lobby of user owner with room for max members, in a group chat when groupChatID is not zero
+	newLobby := func(t *testing.T, owner int64, max int, groupChatID int64) *database.Lobby {
+		t.Helper()
+		var chat *int64
+		if groupChatID != 0 {
+			chat = &groupChatID
+		}
+		lobby, err := lobbies.CreateLobby(owner, "separate", chat)
+		if err != nil {
+			t.Fatalf("CreateLobby: %v", err)
+		}
+		if err := lobbies.SetMaxMembers(lobby.ID, max); err != nil {
+			t.Fatalf("SetMaxMembers: %v", err)
+		}
+		return lobby
+	}
+	newToken := func(t *testing.T, lobby *database.Lobby, maxUses int) string {
+		t.Helper()
+		invite, err := lobbies.CreateInviteToken(lobby.ID, lobby.OwnerTelegramID, 0, maxUses)
+		if err != nil {
+			t.Fatalf("CreateInviteToken: %v", err)
+		}
+		return invite.Token
+	}
+	group := int64(-100)
+
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T) (token string, groupChatID *int64)
+		user   int64
+		want   error
+		reason string // Logged for the failure, "" when not counted
+	}{
+		{"valid", func(t *testing.T) (string, *int64) {
+			return newToken(t, newLobby(t, 1, 5, 0), 1), nil
+		}, 11, nil, ""},
+		{"formatted", func(t *testing.T) (string, *int64) {
+			return utils.FormatInviteToken(newToken(t, newLobby(t, 1, 5, 0), 1)), nil
+		}, 12, nil, ""},
+		{"unknown", func(t *testing.T) (string, *int64) {
+			return "no-such-token", nil
+		}, 13, ErrInviteNotFound, "not_found"},
+		{"expired", func(t *testing.T) (string, *int64) {
+			token := newToken(t, newLobby(t, 1, 5, 0), 1)
+			if _, err := db.GetConn().Exec(`UPDATE invite_tokens SET expires_at = ? WHERE token = ?`,
+				time.Now().Add(-time.Minute), token); err != nil {
+				t.Fatalf("expire token: %v", err)
+			}
+			return token, nil
+		}, 14, ErrInviteExpired, "expired"},
+		{"used up", func(t *testing.T) (string, *int64) {
+			token := newToken(t, newLobby(t, 1, 5, 0), 1)
+			if _, err := lobbies.JoinLobbyByToken(token, 2, nil); err != nil {
+				t.Fatalf("first join: %v", err)
+			}
+			return token, nil
+		}, 15, ErrInviteUsedUp, "used_up"},
+		{"revoked", func(t *testing.T) (string, *int64) {
+			lobby := newLobby(t, 1, 5, 0)
+			invite, err := lobbies.CreateInviteToken(lobby.ID, 1, 0, 1)
+			if err != nil {
+				t.Fatalf("CreateInviteToken: %v", err)
+			}
+			if err := lobbies.RevokeInviteToken(lobby.ID, invite.ID); err != nil {
+				t.Fatalf("RevokeInviteToken: %v", err)
+			}
+			return invite.Token, nil
+		}, 16, ErrInviteRevoked, "revoked"},
+		{"replaced", func(t *testing.T) (string, *int64) {
+			lobby := newLobby(t, 1, 5, 0)
+			token := newToken(t, lobby, 1)
+			if _, err := lobbies.RegenerateInviteToken(lobby.ID, 1); err != nil {
+				t.Fatalf("RegenerateInviteToken: %v", err)
+			}
+			return token, nil
+		}, 17, ErrInviteRevoked, "revoked"},
+		{"lobby full", func(t *testing.T) (string, *int64) {
+			lobby := newLobby(t, 1, 2, 0)
+			token := newToken(t, lobby, 1)
+			if err := lobbies.JoinLobbyDirectly(lobby.ID, 3); err != nil {
+				t.Fatalf("JoinLobbyDirectly: %v", err)
+			}
+			return token, nil
+		}, 18, ErrLobbyFull, "lobby_full"},
+		{"other group", func(t *testing.T) (string, *int64) {
+			other := int64(-200)
+			return newToken(t, newLobby(t, 1, 5, group), 1), &other
+		}, 19, ErrInviteOtherChat, "other_chat"},
+		{"group lobby from a private chat", func(t *testing.T) (string, *int64) {
+			return newToken(t, newLobby(t, 4, 5, group-1), 1), nil
+		}, 20, ErrInviteGroupOnly, "other_chat"},
+		{"private lobby from a group", func(t *testing.T) (string, *int64) {
+			return newToken(t, newLobby(t, 1, 5, 0), 1), &group
+		}, 21, ErrInviteOtherChat, "other_chat"},
+		{"already a member", func(t *testing.T) (string, *int64) {
+			return newToken(t, newLobby(t, 22, 5, 0), 1), nil
+		}, 22, ErrAlreadyMember, ""},
+	}
+
+	security := NewSecurityService(db)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, groupChatID := tt.setup(t)
+
+			lobby, err := lobbies.JoinLobbyByToken(token, tt.user, groupChatID)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && !lobby.IsMember(tt.user) {
+				t.Errorf("user %d did not join", tt.user)
+			}
+
+			var reason string
+			err = db.GetConn().QueryRow(`SELECT IFNULL(MAX(detail), '') FROM security_events
+				WHERE event_type = ? AND user_telegram_id = ?`, SecurityEventJoinFailed, tt.user).Scan(&reason)
+			if err != nil {
+				t.Fatalf("query security events: %v", err)
+			}
+			if reason != tt.reason {
+				t.Errorf("logged reason = %q, want %q", reason, tt.reason)
+			}
+			if until, err := security.JoinLockedUntil(tt.user); err != nil || !until.IsZero() {
+				t.Errorf("one failure locked user %d out until %v (%v)", tt.user, until, err)
+			}
+		})
+	}
+}
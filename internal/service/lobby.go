@@ -4,323 +4,233 @@ import (
 	"botGastosPareja/internal/database"
 	"botGastosPareja/pkg/utils"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// MaxLobbyMembers is the most members a lobby can be set to allow
+const MaxLobbyMembers = 20
+
+var (
+	// ErrLobbyFull is returned when a user tries to join a lobby that has as many members as it allows
+	ErrLobbyFull = errors.New("lobby is already full")
+	// ErrAlreadyMember is returned when a user tries to join a lobby they are already in
+	ErrAlreadyMember = errors.New("you are already in this lobby")
+	// ErrNotMember is returned when a user acts on a lobby they are not in
+	ErrNotMember = errors.New("you are not a member of this lobby")
+)
+
+// MaxLobbyNameLength is the longest name a lobby can have, in characters
+const MaxLobbyNameLength = 40
+
 // LobbyService handles lobby-related operations
 type LobbyService struct {
-	db *database.DB
+	db                   *database.DB
+	security             *SecurityService
+	joinFailureListeners []func(lobby *database.Lobby, userID int64, failures int)
 }
 
 // NewLobbyService creates a new lobby service
 func NewLobbyService(db *database.DB) *LobbyService {
-	return &LobbyService{db: db}
+	return &LobbyService{db: db, security: NewSecurityService(db)}
 }
 
-// GetLobbyByID gets a lobby by ID
-func (s *LobbyService) GetLobbyByID(lobbyID int64) (*database.Lobby, error) {
-	conn := s.db.GetConn()
+// lobbyColumns lists the columns selected for a lobby, in scanLobby order
+const lobbyColumns = `id, name, user1_telegram_id, max_members, account_type, user1_salary_percentage,
+	          user2_salary_percentage, group_chat_id, created_at`
 
-	// Check if new columns exist
-	var hasInviteToken, hasGroupChatID bool
-	_ = conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('lobbies') WHERE name='invite_token'`).Scan(&hasInviteToken)
-	_ = conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('lobbies') WHERE name='group_chat_id'`).Scan(&hasGroupChatID)
+// memberOfLobby restricts a lobby query to the lobbies a user (the argument) is a member of
+const memberOfLobby = `id IN (SELECT lobby_id FROM lobby_members WHERE user_telegram_id = ? AND left_at IS NULL)`
 
+// scanLobby scans a row selected with lobbyColumns; its members are loaded separately
+func scanLobby(row rowScanner) (*database.Lobby, error) {
 	var lobby database.Lobby
-	var err error
-
-	var user2ID sql.NullInt64
-
-	if hasInviteToken && hasGroupChatID {
-		// New schema with all columns
-		query := `SELECT id, user1_telegram_id, user2_telegram_id, account_type, 
-		          user1_salary_percentage, user2_salary_percentage, invite_token, 
-		          group_chat_id, created_at
-		          FROM lobbies WHERE id = ?`
-		err = conn.QueryRow(query, lobbyID).Scan(
-			&lobby.ID,
-			&lobby.User1TelegramID,
-			&user2ID,
-			&lobby.AccountType,
-			&lobby.User1SalaryPercentage,
-			&lobby.User2SalaryPercentage,
-			&lobby.InviteToken,
-			&lobby.GroupChatID,
-			&lobby.CreatedAt,
-		)
-	} else {
-		// Old schema without new columns
-		query := `SELECT id, user1_telegram_id, user2_telegram_id, account_type, 
-		          user1_salary_percentage, user2_salary_percentage, created_at
-		          FROM lobbies WHERE id = ?`
-		err = conn.QueryRow(query, lobbyID).Scan(
-			&lobby.ID,
-			&lobby.User1TelegramID,
-			&user2ID,
-			&lobby.AccountType,
-			&lobby.User1SalaryPercentage,
-			&lobby.User2SalaryPercentage,
-			&lobby.CreatedAt,
-		)
-		// Set defaults for missing columns
-		lobby.InviteToken = sql.NullString{Valid: false}
-		lobby.GroupChatID = sql.NullInt64{Valid: false}
-	}
-
-	// Convert NullInt64 to int64 (0 if NULL)
-	if user2ID.Valid {
-		lobby.User2TelegramID = user2ID.Int64
-	} else {
-		lobby.User2TelegramID = 0
+	var ownerID sql.NullInt64
+	err := row.Scan(
+		&lobby.ID,
+		&lobby.Name,
+		&ownerID,
+		&lobby.MaxMembers,
+		&lobby.AccountType,
+		&lobby.User1SalaryPercentage,
+		&lobby.User2SalaryPercentage,
+		&lobby.GroupChatID,
+		&lobby.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
 	}
+	lobby.OwnerTelegramID = ownerID.Int64
+	return &lobby, nil
+}
 
+// queryLobby gets the first lobby a query (selecting lobbyColumns) returns, with its members, or nil if none
+func (s *LobbyService) queryLobby(query string, args ...interface{}) (*database.Lobby, error) {
+	lobby, err := scanLobby(s.db.GetConn().QueryRow(query, args...))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query lobby: %w", err)
 	}
-
-	return &lobby, nil
+	if lobby.Members, err = s.GetMembers(lobby.ID); err != nil {
+		return nil, err
+	}
+	return lobby, nil
 }
 
-// GetLobbyByUserID gets a lobby for a user (if they're in one)
-func (s *LobbyService) GetLobbyByUserID(userID int64) (*database.Lobby, error) {
-	conn := s.db.GetConn()
-
-	// Check if new columns exist
-	var hasInviteToken, hasGroupChatID bool
-	_ = conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('lobbies') WHERE name='invite_token'`).Scan(&hasInviteToken)
-	_ = conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('lobbies') WHERE name='group_chat_id'`).Scan(&hasGroupChatID)
+// queryLobbies gets the lobbies a query (selecting lobbyColumns) returns, with their members
+func (s *LobbyService) queryLobbies(query string, args ...interface{}) ([]*database.Lobby, error) {
+	rows, err := s.db.GetConn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lobbies: %w", err)
+	}
+	defer rows.Close()
 
-	var lobby database.Lobby
-	var query string
-
-	var user2ID sql.NullInt64
-
-	if hasInviteToken && hasGroupChatID {
-		// New schema with all columns
-		query = `SELECT id, user1_telegram_id, user2_telegram_id, account_type, 
-		          user1_salary_percentage, user2_salary_percentage, invite_token,
-		          group_chat_id, created_at
-		          FROM lobbies 
-		          WHERE user1_telegram_id = ? OR user2_telegram_id = ?`
-		err := conn.QueryRow(query, userID, userID).Scan(
-			&lobby.ID,
-			&lobby.User1TelegramID,
-			&user2ID,
-			&lobby.AccountType,
-			&lobby.User1SalaryPercentage,
-			&lobby.User2SalaryPercentage,
-			&lobby.InviteToken,
-			&lobby.GroupChatID,
-			&lobby.CreatedAt,
-		)
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
+	var lobbies []*database.Lobby
+	for rows.Next() {
+		lobby, err := scanLobby(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to query lobby: %w", err)
-		}
-	} else {
-		// Old schema without new columns
-		query = `SELECT id, user1_telegram_id, user2_telegram_id, account_type, 
-		          user1_salary_percentage, user2_salary_percentage, created_at
-		          FROM lobbies 
-		          WHERE user1_telegram_id = ? OR user2_telegram_id = ?`
-		err := conn.QueryRow(query, userID, userID).Scan(
-			&lobby.ID,
-			&lobby.User1TelegramID,
-			&user2ID,
-			&lobby.AccountType,
-			&lobby.User1SalaryPercentage,
-			&lobby.User2SalaryPercentage,
-			&lobby.CreatedAt,
-		)
-		if err == sql.ErrNoRows {
-			return nil, nil
+			return nil, fmt.Errorf("failed to scan lobby: %w", err)
 		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to query lobby: %w", err)
-		}
-		// Set defaults for missing columns
-		lobby.InviteToken = sql.NullString{Valid: false}
-		lobby.GroupChatID = sql.NullInt64{Valid: false}
+		lobbies = append(lobbies, lobby)
 	}
-
-	// Convert NullInt64 to int64 (0 if NULL)
-	if user2ID.Valid {
-		lobby.User2TelegramID = user2ID.Int64
-	} else {
-		lobby.User2TelegramID = 0
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query lobbies: %w", err)
 	}
 
-	return &lobby, nil
+	for _, lobby := range lobbies {
+		if lobby.Members, err = s.GetMembers(lobby.ID); err != nil {
+			return nil, err
+		}
+	}
+	return lobbies, nil
 }
 
-// GetLobbyByUserIDAndGroup gets a lobby for a user in a specific group (or private if groupChatID is nil)
-func (s *LobbyService) GetLobbyByUserIDAndGroup(userID int64, groupChatID *int64) (*database.Lobby, error) {
-	conn := s.db.GetConn()
+// GetMembers gets the members of a lobby, in joining order; former members are left out
+func (s *LobbyService) GetMembers(lobbyID int64) ([]*database.LobbyMember, error) {
+	return s.queryMembers(`SELECT id, lobby_id, user_telegram_id, share_weight, joined_at, left_at
+	          FROM lobby_members WHERE lobby_id = ? AND left_at IS NULL ORDER BY joined_at, id`, lobbyID)
+}
 
-	// Check if new columns exist
-	var hasGroupChatID bool
-	_ = conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('lobbies') WHERE name='group_chat_id'`).Scan(&hasGroupChatID)
+// queryMembers gets the lobby members a query returns
+func (s *LobbyService) queryMembers(query string, args ...interface{}) ([]*database.LobbyMember, error) {
+	rows, err := s.db.GetConn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lobby members: %w", err)
+	}
+	defer rows.Close()
 
-	if !hasGroupChatID {
-		// Old schema - fallback to regular lookup
-		return s.GetLobbyByUserID(userID)
+	var members []*database.LobbyMember
+	for rows.Next() {
+		var member database.LobbyMember
+		if err := rows.Scan(&member.ID, &member.LobbyID, &member.UserTelegramID, &member.ShareWeight, &member.JoinedAt, &member.LeftAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lobby member: %w", err)
+		}
+		members = append(members, &member)
 	}
+	return members, rows.Err()
+}
 
-	var lobby database.Lobby
-	var query string
-	var err error
+// GetLobbyByID gets a lobby by ID
+func (s *LobbyService) GetLobbyByID(lobbyID int64) (*database.Lobby, error) {
+	return s.queryLobby(`SELECT `+lobbyColumns+` FROM lobbies WHERE id = ?`, lobbyID)
+}
 
-	if groupChatID != nil {
-		// Look for lobby in this specific group
-		query = `SELECT id, user1_telegram_id, user2_telegram_id, account_type, 
-		          user1_salary_percentage, user2_salary_percentage, invite_token,
-		          group_chat_id, created_at
-		          FROM lobbies 
-		          WHERE (user1_telegram_id = ? OR user2_telegram_id = ?)
-		          AND group_chat_id = ?`
-
-		// Log for debugging
-		log.Printf("DEBUG GetLobbyByUserIDAndGroup: userID=%d, groupChatID=%d", userID, *groupChatID)
-
-		// Scan user2_telegram_id as NullInt64 since it can be NULL
-		var user2ID sql.NullInt64
-		err = conn.QueryRow(query, userID, userID, *groupChatID).Scan(
-			&lobby.ID,
-			&lobby.User1TelegramID,
-			&user2ID,
-			&lobby.AccountType,
-			&lobby.User1SalaryPercentage,
-			&lobby.User2SalaryPercentage,
-			&lobby.InviteToken,
-			&lobby.GroupChatID,
-			&lobby.CreatedAt,
-		)
-
-		// Convert NullInt64 to int64 (0 if NULL)
-		if user2ID.Valid {
-			lobby.User2TelegramID = user2ID.Int64
-		} else {
-			lobby.User2TelegramID = 0
-		}
+// GetLobbyByUserID gets a lobby for a user (if they're in one)
+func (s *LobbyService) GetLobbyByUserID(userID int64) (*database.Lobby, error) {
+	return s.queryLobby(`SELECT `+lobbyColumns+` FROM lobbies WHERE `+memberOfLobby+` ORDER BY id LIMIT 1`, userID)
+}
 
-		if err == sql.ErrNoRows {
-			log.Printf("DEBUG: No lobby found for userID=%d, groupChatID=%d", userID, *groupChatID)
-			// Let's also check what lobbies exist for this user
-			checkQuery := `SELECT id, user1_telegram_id, user2_telegram_id, group_chat_id FROM lobbies WHERE user1_telegram_id = ? OR user2_telegram_id = ?`
-			rows, _ := conn.Query(checkQuery, userID, userID)
-			if rows != nil {
-				defer rows.Close()
-				log.Printf("DEBUG: Checking all lobbies for userID=%d:", userID)
-				for rows.Next() {
-					var lid, u1, u2 int64
-					var gcid sql.NullInt64
-					rows.Scan(&lid, &u1, &u2, &gcid)
-					log.Printf("  Lobby ID=%d, User1=%d, User2=%d, GroupChatID=%v (Valid=%v)", lid, u1, u2, gcid.Int64, gcid.Valid)
-				}
-			}
+// GetLobbiesByUserID gets every lobby a user is a member of, oldest first
+func (s *LobbyService) GetLobbiesByUserID(userID int64) ([]*database.Lobby, error) {
+	return s.queryLobbies(`SELECT `+lobbyColumns+` FROM lobbies WHERE `+memberOfLobby+` ORDER BY id`, userID)
+}
+
+// GetLobbyByUserIDAndGroup gets a lobby for a user in a specific group, or in private chat if groupChatID is nil:
+// the lobby the user switched to with SetActiveLobby, otherwise their first private lobby
+func (s *LobbyService) GetLobbyByUserIDAndGroup(userID int64, groupChatID *int64) (*database.Lobby, error) {
+	if groupChatID == nil {
+		lobby, err := s.GetActiveLobby(userID)
+		if err != nil || lobby != nil {
+			return lobby, err
 		}
-	} else {
 		// Look for private lobby (no group_chat_id)
-		query = `SELECT id, user1_telegram_id, user2_telegram_id, account_type, 
-		          user1_salary_percentage, user2_salary_percentage, invite_token,
-		          group_chat_id, created_at
-		          FROM lobbies 
-		          WHERE (user1_telegram_id = ? OR user2_telegram_id = ?)
-		          AND (group_chat_id IS NULL)`
-		// Scan user2_telegram_id as NullInt64 since it can be NULL
-		var user2ID sql.NullInt64
-		err = conn.QueryRow(query, userID, userID).Scan(
-			&lobby.ID,
-			&lobby.User1TelegramID,
-			&user2ID,
-			&lobby.AccountType,
-			&lobby.User1SalaryPercentage,
-			&lobby.User2SalaryPercentage,
-			&lobby.InviteToken,
-			&lobby.GroupChatID,
-			&lobby.CreatedAt,
-		)
-
-		// Convert NullInt64 to int64 (0 if NULL)
-		if user2ID.Valid {
-			lobby.User2TelegramID = user2ID.Int64
-		} else {
-			lobby.User2TelegramID = 0
-		}
+		return s.queryLobby(`SELECT `+lobbyColumns+` FROM lobbies
+		          WHERE `+memberOfLobby+` AND group_chat_id IS NULL ORDER BY id LIMIT 1`, userID)
 	}
 
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+	// Look for lobby in this specific group
+	lobby, err := s.queryLobby(`SELECT `+lobbyColumns+` FROM lobbies
+	          WHERE `+memberOfLobby+` AND group_chat_id = ? ORDER BY id LIMIT 1`, userID, *groupChatID)
 	if err != nil {
 		log.Printf("DEBUG GetLobbyByUserIDAndGroup ERROR: %v", err)
-		return nil, fmt.Errorf("failed to query lobby: %w", err)
+		return nil, err
+	}
+	if lobby == nil {
+		log.Printf("DEBUG: No lobby found for userID=%d, groupChatID=%d", userID, *groupChatID)
+		return nil, nil
 	}
 
-	log.Printf("DEBUG: Found lobby ID=%d for userID=%d, groupChatID=%v", lobby.ID, userID, groupChatID)
-	return &lobby, nil
+	log.Printf("DEBUG: Found lobby ID=%d for userID=%d, groupChatID=%d", lobby.ID, userID, *groupChatID)
+	return lobby, nil
 }
 
-// GetLobbyByGroupChatID gets a lobby for a specific group/channel
-func (s *LobbyService) GetLobbyByGroupChatID(groupChatID int64) (*database.Lobby, error) {
+// GetActiveLobby gets the lobby a user switched to for private chat, or nil if they did not switch
+// or are no longer a member of it
+func (s *LobbyService) GetActiveLobby(userID int64) (*database.Lobby, error) {
+	return s.queryLobby(`SELECT `+lobbyColumns+` FROM lobbies
+	          WHERE id = (SELECT active_lobby_id FROM users WHERE telegram_id = ?) AND `+memberOfLobby, userID, userID)
+}
+
+// SetActiveLobby makes a lobby the one a user's private chat commands act on
+func (s *LobbyService) SetActiveLobby(userID int64, lobbyID int64) error {
 	conn := s.db.GetConn()
 
-	// Check if new columns exist
-	var hasGroupChatID bool
-	_ = conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('lobbies') WHERE name='group_chat_id'`).Scan(&hasGroupChatID)
+	lobby, err := s.GetLobbyByID(lobbyID)
+	if err != nil {
+		return err
+	}
+	if lobby == nil || !lobby.IsMember(userID) {
+		return ErrNotMember
+	}
 
-	if !hasGroupChatID {
-		return nil, nil
+	_, err = conn.Exec(`UPDATE users SET active_lobby_id = ? WHERE telegram_id = ?`, lobbyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set active lobby: %w", err)
 	}
+	return nil
+}
 
-	var lobby database.Lobby
-	var user2ID sql.NullInt64
-	query := `SELECT id, user1_telegram_id, user2_telegram_id, account_type, 
-	          user1_salary_percentage, user2_salary_percentage, invite_token,
-	          group_chat_id, created_at
-	          FROM lobbies 
-	          WHERE group_chat_id = ?
-	          ORDER BY created_at ASC
-	          LIMIT 1`
-
-	err := conn.QueryRow(query, groupChatID).Scan(
-		&lobby.ID,
-		&lobby.User1TelegramID,
-		&user2ID,
-		&lobby.AccountType,
-		&lobby.User1SalaryPercentage,
-		&lobby.User2SalaryPercentage,
-		&lobby.InviteToken,
-		&lobby.GroupChatID,
-		&lobby.CreatedAt,
-	)
+// SetLobbyName names a lobby so its members can tell it apart from their other lobbies; an empty name clears it
+func (s *LobbyService) SetLobbyName(lobbyID int64, name string) error {
+	conn := s.db.GetConn()
 
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to query lobby: %w", err)
+	name = strings.TrimSpace(name)
+	if len([]rune(name)) > MaxLobbyNameLength {
+		return fmt.Errorf("lobby name cannot be longer than %d characters", MaxLobbyNameLength)
 	}
 
-	// Convert NullInt64 to int64 (0 if NULL)
-	if user2ID.Valid {
-		lobby.User2TelegramID = user2ID.Int64
-	} else {
-		lobby.User2TelegramID = 0
+	_, err := conn.Exec(`UPDATE lobbies SET name = ? WHERE id = ?`, sql.NullString{String: name, Valid: name != ""}, lobbyID)
+	if err != nil {
+		return fmt.Errorf("failed to update lobby name: %w", err)
 	}
+	return nil
+}
 
-	return &lobby, nil
+// GetLobbyByGroupChatID gets a lobby for a specific group/channel
+func (s *LobbyService) GetLobbyByGroupChatID(groupChatID int64) (*database.Lobby, error) {
+	return s.queryLobby(`SELECT `+lobbyColumns+` FROM lobbies
+	          WHERE group_chat_id = ? ORDER BY created_at ASC LIMIT 1`, groupChatID)
 }
 
-// CreateLobby creates a new lobby with one user
+// CreateLobby creates a new lobby with one user; others join it with an invite token (CreateInviteToken)
+// or, in a group, by running /start there
 func (s *LobbyService) CreateLobby(userID int64, accountType string, groupChatID *int64) (*database.Lobby, error) {
 	conn := s.db.GetConn()
 
@@ -329,12 +239,6 @@ func (s *LobbyService) CreateLobby(userID int64, accountType string, groupChatID
 		accountType = "separate" // Default
 	}
 
-	// Generate secure invitation token
-	inviteToken, err := utils.GenerateInviteToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate invite token: %w", err)
-	}
-
 	var groupChatIDNull sql.NullInt64
 	if groupChatID != nil {
 		groupChatIDNull = sql.NullInt64{Int64: *groupChatID, Valid: true}
@@ -343,20 +247,25 @@ func (s *LobbyService) CreateLobby(userID int64, accountType string, groupChatID
 		log.Printf("DEBUG CreateLobby: Creating private lobby for userID=%d", userID)
 	}
 
-	query := `INSERT INTO lobbies (user1_telegram_id, user2_telegram_id, account_type, 
-	          user1_salary_percentage, user2_salary_percentage, invite_token, 
-	          group_chat_id, created_at)
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO lobbies (user1_telegram_id, account_type,
+	          user1_salary_percentage, user2_salary_percentage,
+	          group_chat_id, quick_capture, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`
 
 	now := time.Now()
-	result, err := conn.Exec(query,
+	result, err := tx.Exec(query,
 		userID,
-		nil, // user2 will be set when they join
 		accountType,
 		0.5, // Default equal split
 		0.5,
-		inviteToken,
 		groupChatIDNull,
+		groupChatID == nil, // Plain messages in a group are mostly chat, so groups opt in to quick capture
 		now,
 	)
 	if err != nil {
@@ -368,245 +277,380 @@ func (s *LobbyService) CreateLobby(userID int64, accountType string, groupChatID
 		return nil, fmt.Errorf("failed to get lobby ID: %w", err)
 	}
 
+	// The creator is the first member
+	memberResult, err := tx.Exec(`INSERT INTO lobby_members (lobby_id, user_telegram_id, joined_at) VALUES (?, ?, ?)`,
+		lobbyID, userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add lobby member: %w", err)
+	}
+	memberID, err := memberResult.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member ID: %w", err)
+	}
+
+	// The settings history starts with the lobby
+	_, err = tx.Exec(`INSERT INTO lobby_settings_history
+	          (lobby_id, effective_from, account_type, user1_salary_percentage, user2_salary_percentage, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?)`,
+		lobbyID, utils.FormatDate(now), accountType, 0.5, 0.5, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save lobby settings: %w", err)
+	}
+
+	var maxMembers int
+	if err := tx.QueryRow(`SELECT max_members FROM lobbies WHERE id = ?`, lobbyID).Scan(&maxMembers); err != nil {
+		return nil, fmt.Errorf("failed to query lobby: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit lobby: %w", err)
+	}
+
 	log.Printf("DEBUG CreateLobby: Created lobby ID=%d for userID=%d, groupChatID=%v", lobbyID, userID, groupChatIDNull)
 
 	return &database.Lobby{
-		ID:                    lobbyID,
-		User1TelegramID:       userID,
-		User2TelegramID:       0, // Not set yet
+		ID:              lobbyID,
+		OwnerTelegramID: userID,
+		Members: []*database.LobbyMember{{
+			ID:             memberID,
+			LobbyID:        lobbyID,
+			UserTelegramID: userID,
+			ShareWeight:    1,
+			JoinedAt:       now,
+		}},
+		MaxMembers:            maxMembers,
 		AccountType:           accountType,
 		User1SalaryPercentage: 0.5,
 		User2SalaryPercentage: 0.5,
-		InviteToken:           sql.NullString{String: inviteToken, Valid: true},
 		GroupChatID:           groupChatIDNull,
 		CreatedAt:             now,
 	}, nil
 }
 
-// GetLobbyByInviteToken gets a lobby by invitation token
-func (s *LobbyService) GetLobbyByInviteToken(token string) (*database.Lobby, error) {
-	conn := s.db.GetConn()
+// JoinLobbyDirectly allows a user to join an existing lobby directly (without token)
+// Used when a user joins a group/channel that already has a lobby; members the owner removed get ErrMemberRemoved
+func (s *LobbyService) JoinLobbyDirectly(lobbyID int64, userID int64) error {
+	// Get the lobby
+	lobby, err := s.GetLobbyByID(lobbyID)
+	if err != nil {
+		return fmt.Errorf("failed to get lobby: %w", err)
+	}
+	if lobby == nil {
+		return fmt.Errorf("lobby not found")
+	}
 
-	// Remove formatting if present
-	cleanToken := utils.ParseInviteToken(token)
+	// Removed members need an invitation to come back, unless they came back with one since
+	var removed bool
+	err = s.db.GetConn().QueryRow(`SELECT removed_by IS NOT NULL FROM lobby_members
+	          WHERE lobby_id = ? AND user_telegram_id = ? ORDER BY joined_at DESC, id DESC LIMIT 1`, lobbyID, userID).Scan(&removed)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check lobby member: %w", err)
+	}
+	if removed {
+		return ErrMemberRemoved
+	}
 
-	var lobby database.Lobby
-	var user2ID sql.NullInt64
-	query := `SELECT id, user1_telegram_id, user2_telegram_id, account_type, 
-	          user1_salary_percentage, user2_salary_percentage, invite_token,
-	          group_chat_id, created_at
-	          FROM lobbies WHERE invite_token = ?`
+	if err := s.addMember(s.db.GetConn(), lobby, userID); err != nil {
+		return err
+	}
+	if err := s.security.RecordJoin(userID, lobbyID, "direct"); err != nil {
+		log.Printf("Error logging join of user %d to lobby %d: %v", userID, lobbyID, err)
+	}
+	return nil
+}
 
-	err := conn.QueryRow(query, cleanToken).Scan(
-		&lobby.ID,
-		&lobby.User1TelegramID,
-		&user2ID,
-		&lobby.AccountType,
-		&lobby.User1SalaryPercentage,
-		&lobby.User2SalaryPercentage,
-		&lobby.InviteToken,
-		&lobby.GroupChatID,
-		&lobby.CreatedAt,
-	)
+// JoinLobby allows a user to join an existing lobby (deprecated - use JoinLobbyByToken)
+func (s *LobbyService) JoinLobby(lobbyID int64, userID int64) error {
+	return s.JoinLobbyDirectly(lobbyID, userID)
+}
 
-	if err == sql.ErrNoRows {
-		return nil, nil
+// addMember adds a user to a lobby that has room for them, with the default share weight.
+// A former member starts a new membership, keeping their earlier ones. The invite tokens of the lobby stop
+// working once it is full.
+func (s *LobbyService) addMember(q rowQuerier, lobby *database.Lobby, userID int64) error {
+	if lobby.IsMember(userID) {
+		return ErrAlreadyMember
 	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to query lobby: %w", err)
+	if lobby.IsFull() {
+		return ErrLobbyFull
 	}
 
-	// Convert NullInt64 to int64 (0 if NULL)
-	if user2ID.Valid {
-		lobby.User2TelegramID = user2ID.Int64
-	} else {
-		lobby.User2TelegramID = 0
+	// The count is checked again on insert, so two users joining at once cannot overfill the lobby
+	result, err := q.Exec(`INSERT INTO lobby_members (lobby_id, user_telegram_id, joined_at)
+	          SELECT ?, ?, ? WHERE (SELECT COUNT(*) FROM lobby_members WHERE lobby_id = ? AND left_at IS NULL) < ?`,
+		lobby.ID, userID, time.Now(), lobby.ID, lobby.MaxMembers)
+	if err != nil {
+		return fmt.Errorf("failed to join lobby: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrLobbyFull
 	}
 
-	return &lobby, nil
+	if len(lobby.Members)+1 >= lobby.MaxMembers {
+		if _, err := revokeInviteTokens(q, lobby.ID, inviteRevokedLobbyFull); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// RegenerateInviteToken generates a new invitation token for a lobby
-func (s *LobbyService) RegenerateInviteToken(lobbyID int64) (string, error) {
+// SetMaxMembers sets how many members can join a lobby; it cannot go below the current members
+func (s *LobbyService) SetMaxMembers(lobbyID int64, maxMembers int) error {
 	conn := s.db.GetConn()
 
-	newToken, err := utils.GenerateInviteToken()
+	if maxMembers < 2 || maxMembers > MaxLobbyMembers {
+		return fmt.Errorf("a lobby allows between 2 and %d members", MaxLobbyMembers)
+	}
+	members, err := s.GetMembers(lobbyID)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+		return err
+	}
+	if maxMembers < len(members) {
+		return fmt.Errorf("the lobby already has %d members", len(members))
 	}
 
-	query := `UPDATE lobbies SET invite_token = ? WHERE id = ?`
-	_, err = conn.Exec(query, newToken, lobbyID)
+	_, err = conn.Exec(`UPDATE lobbies SET max_members = ? WHERE id = ?`, maxMembers, lobbyID)
 	if err != nil {
-		return "", fmt.Errorf("failed to update invite token: %w", err)
+		return fmt.Errorf("failed to update max members: %w", err)
 	}
-
-	return newToken, nil
+	return nil
 }
 
-// JoinLobbyByToken allows a second user to join an existing lobby by token
-// groupChatID is used to validate that the lobby is for the same group (or private)
-func (s *LobbyService) JoinLobbyByToken(inviteToken string, userID int64, groupChatID *int64) error {
+// SetShareWeight sets a member's relative share of the shared expenses, used when the lobby has
+// more than two members
+func (s *LobbyService) SetShareWeight(lobbyID, userID int64, weight float64) error {
 	conn := s.db.GetConn()
 
-	// Get lobby by token
-	lobby, err := s.GetLobbyByInviteToken(inviteToken)
+	if weight < 0 {
+		return fmt.Errorf("share weight cannot be negative")
+	}
+
+	result, err := conn.Exec(`UPDATE lobby_members SET share_weight = ?
+	          WHERE lobby_id = ? AND user_telegram_id = ? AND left_at IS NULL`, weight, lobbyID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to get lobby: %w", err)
+		return fmt.Errorf("failed to update share weight: %w", err)
 	}
-	if lobby == nil {
-		return fmt.Errorf("invalid invitation token")
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("the user is not a member of the lobby")
 	}
+	return nil
+}
 
-	// Validate group chat ID matches
-	if groupChatID != nil {
-		// Joining in a group - lobby must be for this group
-		if !lobby.GroupChatID.Valid || lobby.GroupChatID.Int64 != *groupChatID {
-			return fmt.Errorf("this invitation token is for a different chat")
-		}
-	} else {
-		// Joining in private - lobby must be private (no group)
-		if lobby.GroupChatID.Valid {
-			return fmt.Errorf("this invitation token is for a group chat. Please join from that group")
+// UpdateLobbySettings changes a lobby's settings from a date (today or earlier) until the next change already
+// recorded; nil values keep the ones in force on that date. Periods before the date keep their settings.
+func (s *LobbyService) UpdateLobbySettings(lobbyID int64, accountType *string, user1SalaryPct *float64, user2SalaryPct *float64, effectiveFrom time.Time, changedBy int64) error {
+	conn := s.db.GetConn()
+
+	if accountType != nil && *accountType != "separate" && *accountType != "shared" {
+		return fmt.Errorf("invalid account type: %s", *accountType)
+	}
+	for _, pct := range []*float64{user1SalaryPct, user2SalaryPct} {
+		if pct != nil && (*pct < 0 || *pct > 1) {
+			return fmt.Errorf("salary percentage must be between 0 and 1")
 		}
 	}
+	if accountType == nil && user1SalaryPct == nil && user2SalaryPct == nil {
+		return nil // Nothing to update
+	}
 
-	// Check if lobby is full
-	if lobby.User2TelegramID != 0 {
-		return fmt.Errorf("lobby is already full")
+	effectiveFrom = utils.CalendarDate(effectiveFrom)
+	if effectiveFrom.After(time.Now()) {
+		return fmt.Errorf("settings cannot take effect in the future")
 	}
 
-	// Check if user is already user1
-	if lobby.User1TelegramID == userID {
-		return fmt.Errorf("you are already in this lobby")
+	history, err := s.GetSettingsHistory(lobbyID)
+	if err != nil {
+		return err
+	}
+	settings := *SettingsAt(history, effectiveFrom)
+	settings.EffectiveFrom = effectiveFrom
+	settings.ChangedBy = sql.NullInt64{Int64: changedBy, Valid: changedBy != 0}
+	if accountType != nil {
+		settings.AccountType = *accountType
+	}
+	if user1SalaryPct != nil {
+		settings.User1SalaryPercentage = *user1SalaryPct
+	}
+	if user2SalaryPct != nil {
+		settings.User2SalaryPercentage = *user2SalaryPct
 	}
 
-	// Update lobby with user2
-	updateQuery := `UPDATE lobbies SET user2_telegram_id = ? WHERE id = ?`
-	_, err = conn.Exec(updateQuery, userID, lobby.ID)
+	tx, err := conn.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to join lobby: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A lobby without history keeps its current settings before the change. The settings the lobby was created
+	// with never applied before the change when it is backdated further, so the change replaces them.
+	created := history[0]
+	if !created.ChangedBy.Valid && effectiveFrom.Before(created.EffectiveFrom) {
+		if created.ID != 0 {
+			if _, err := tx.Exec(`DELETE FROM lobby_settings_history WHERE id = ?`, created.ID); err != nil {
+				return fmt.Errorf("failed to replace initial settings: %w", err)
+			}
+		}
+	} else if created.ID == 0 {
+		if err := saveSettings(tx, created); err != nil {
+			return err
+		}
+	}
+	if err := saveSettings(tx, &settings); err != nil {
+		return err
+	}
+
+	// The lobby keeps the settings in force today
+	_, err = tx.Exec(`UPDATE lobbies SET (account_type, user1_salary_percentage, user2_salary_percentage) =
+	          (SELECT account_type, user1_salary_percentage, user2_salary_percentage FROM lobby_settings_history
+	           WHERE lobby_id = ? AND effective_from <= ? ORDER BY effective_from DESC LIMIT 1)
+	          WHERE id = ?`, lobbyID, utils.CalendarDate(time.Now()), lobbyID)
+	if err != nil {
+		return fmt.Errorf("failed to update lobby settings: %w", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit settings: %w", err)
+	}
 	return nil
 }
 
-// JoinLobbyDirectly allows a second user to join an existing lobby directly (without token)
-// Used when a user joins a group/channel that already has a lobby
-func (s *LobbyService) JoinLobbyDirectly(lobbyID int64, userID int64) error {
+// GetCurrencySettings returns the lobby's base currency and default exchange rate type
+func (s *LobbyService) GetCurrencySettings(lobbyID int64) (string, string, error) {
 	conn := s.db.GetConn()
 
-	// Get the lobby
-	lobby, err := s.GetLobbyByID(lobbyID)
+	var baseCurrency, rateType string
+	err := conn.QueryRow(`SELECT base_currency, rate_type FROM lobbies WHERE id = ?`, lobbyID).
+		Scan(&baseCurrency, &rateType)
+	if err == sql.ErrNoRows {
+		return "", "", fmt.Errorf("lobby not found")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get lobby: %w", err)
+		return "", "", fmt.Errorf("failed to get currency settings: %w", err)
 	}
-	if lobby == nil {
-		return fmt.Errorf("lobby not found")
+
+	return baseCurrency, rateType, nil
+}
+
+// UpdateCurrencySettings updates the lobby's base currency and/or default exchange rate type
+func (s *LobbyService) UpdateCurrencySettings(lobbyID int64, baseCurrency *string, rateType *string) error {
+	conn := s.db.GetConn()
+
+	updates := []string{}
+	args := []interface{}{}
+
+	if baseCurrency != nil {
+		code := utils.NormalizeCurrency(*baseCurrency)
+		if code == "" {
+			return fmt.Errorf("unknown currency: %s", *baseCurrency)
+		}
+		updates = append(updates, "base_currency = ?")
+		args = append(args, code)
 	}
 
-	// Check if lobby is full
-	if lobby.User2TelegramID != 0 {
-		return fmt.Errorf("lobby is already full")
+	if rateType != nil {
+		normalized := NormalizeRateType(*rateType)
+		if normalized == "" {
+			return fmt.Errorf("invalid rate type: %s", *rateType)
+		}
+		updates = append(updates, "rate_type = ?")
+		args = append(args, normalized)
 	}
 
-	// Check if user is already user1
-	if lobby.User1TelegramID == userID {
-		return fmt.Errorf("you are already in this lobby")
+	if len(updates) == 0 {
+		return nil // Nothing to update
 	}
 
-	// Update lobby with user2
-	updateQuery := `UPDATE lobbies SET user2_telegram_id = ? WHERE id = ?`
-	_, err = conn.Exec(updateQuery, userID, lobby.ID)
+	args = append(args, lobbyID)
+	query := fmt.Sprintf("UPDATE lobbies SET %s WHERE id = ?",
+		strings.Join(updates, ", "))
+
+	_, err := conn.Exec(query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to join lobby: %w", err)
+		return fmt.Errorf("failed to update currency settings: %w", err)
 	}
 
 	return nil
 }
 
-// JoinLobby allows a second user to join an existing lobby (deprecated - use JoinLobbyByToken)
-func (s *LobbyService) JoinLobby(lobbyID int64, userID int64) error {
+// IsQuickCaptureEnabled reports whether plain chat messages are parsed into expenses for the lobby
+func (s *LobbyService) IsQuickCaptureEnabled(lobbyID int64) (bool, error) {
 	conn := s.db.GetConn()
 
-	// Check if lobby exists and has space
-	var currentUser2 sql.NullInt64
-	query := `SELECT user2_telegram_id FROM lobbies WHERE id = ?`
-	err := conn.QueryRow(query, lobbyID).Scan(&currentUser2)
+	var enabled bool
+	err := conn.QueryRow(`SELECT quick_capture FROM lobbies WHERE id = ?`, lobbyID).Scan(&enabled)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("lobby not found")
+		return false, fmt.Errorf("lobby not found")
 	}
 	if err != nil {
-		return fmt.Errorf("failed to query lobby: %w", err)
+		return false, fmt.Errorf("failed to get quick capture setting: %w", err)
 	}
 
-	// Check if lobby is full
-	if currentUser2.Valid {
-		return fmt.Errorf("lobby is already full")
-	}
+	return enabled, nil
+}
 
-	// Check if user is already user1
-	var user1ID int64
-	err = conn.QueryRow(`SELECT user1_telegram_id FROM lobbies WHERE id = ?`, lobbyID).Scan(&user1ID)
-	if err != nil {
-		return fmt.Errorf("failed to query lobby: %w", err)
-	}
-	if user1ID == userID {
-		return fmt.Errorf("you are already in this lobby")
-	}
+// SetQuickCapture turns free-text expense capture on or off for the lobby
+func (s *LobbyService) SetQuickCapture(lobbyID int64, enabled bool) error {
+	conn := s.db.GetConn()
 
-	// Update lobby with user2
-	updateQuery := `UPDATE lobbies SET user2_telegram_id = ? WHERE id = ?`
-	_, err = conn.Exec(updateQuery, userID, lobbyID)
+	_, err := conn.Exec(`UPDATE lobbies SET quick_capture = ? WHERE id = ?`, enabled, lobbyID)
 	if err != nil {
-		return fmt.Errorf("failed to join lobby: %w", err)
+		return fmt.Errorf("failed to update quick capture setting: %w", err)
 	}
 
 	return nil
 }
 
-// UpdateLobbySettings updates lobby configuration
-func (s *LobbyService) UpdateLobbySettings(lobbyID int64, accountType *string, user1SalaryPct *float64, user2SalaryPct *float64) error {
+// GetBudgetThresholds gets the budget usage percentages that post a warning in the lobby, ascending
+func (s *LobbyService) GetBudgetThresholds(lobbyID int64) ([]int, error) {
 	conn := s.db.GetConn()
 
-	updates := []string{}
-	args := []interface{}{}
-
-	if accountType != nil {
-		if *accountType != "separate" && *accountType != "shared" {
-			return fmt.Errorf("invalid account type: %s", *accountType)
-		}
-		updates = append(updates, "account_type = ?")
-		args = append(args, *accountType)
+	var value string
+	err := conn.QueryRow(`SELECT budget_thresholds FROM lobbies WHERE id = ?`, lobbyID).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("lobby not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget thresholds: %w", err)
 	}
 
-	if user1SalaryPct != nil {
-		if *user1SalaryPct < 0 || *user1SalaryPct > 1 {
-			return fmt.Errorf("salary percentage must be between 0 and 1")
+	var thresholds []int
+	for _, part := range strings.Split(value, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && n > 0 {
+			thresholds = append(thresholds, n)
 		}
-		updates = append(updates, "user1_salary_percentage = ?")
-		args = append(args, *user1SalaryPct)
 	}
+	sort.Ints(thresholds)
+	return thresholds, nil
+}
 
-	if user2SalaryPct != nil {
-		if *user2SalaryPct < 0 || *user2SalaryPct > 1 {
-			return fmt.Errorf("salary percentage must be between 0 and 1")
+// SetBudgetThresholds sets the budget usage percentages that post a warning in the lobby
+func (s *LobbyService) SetBudgetThresholds(lobbyID int64, thresholds []int) error {
+	conn := s.db.GetConn()
+
+	if len(thresholds) == 0 {
+		return fmt.Errorf("at least one threshold is required")
+	}
+	seen := make(map[int]bool)
+	var unique []int
+	for _, threshold := range thresholds {
+		if threshold < 1 || threshold > 1000 {
+			return fmt.Errorf("thresholds must be between 1 and 1000 percent")
+		}
+		if !seen[threshold] {
+			seen[threshold] = true
+			unique = append(unique, threshold)
 		}
-		updates = append(updates, "user2_salary_percentage = ?")
-		args = append(args, *user2SalaryPct)
 	}
+	sort.Ints(unique)
 
-	if len(updates) == 0 {
-		return nil // Nothing to update
+	parts := make([]string, len(unique))
+	for i, threshold := range unique {
+		parts[i] = strconv.Itoa(threshold)
 	}
 
-	args = append(args, lobbyID)
-	query := fmt.Sprintf("UPDATE lobbies SET %s WHERE id = ?",
-		strings.Join(updates, ", "))
-
-	_, err := conn.Exec(query, args...)
+	_, err := conn.Exec(`UPDATE lobbies SET budget_thresholds = ? WHERE id = ?`, strings.Join(parts, ","), lobbyID)
 	if err != nil {
-		return fmt.Errorf("failed to update lobby settings: %w", err)
+		return fmt.Errorf("failed to update budget thresholds: %w", err)
 	}
 
 	return nil
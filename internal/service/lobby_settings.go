@@ -0,0 +1,119 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// lobbySettingsColumns lists the columns selected for lobby settings, in scanLobbySettings order
+const lobbySettingsColumns = `id, lobby_id, effective_from, account_type, user1_salary_percentage,
+	          user2_salary_percentage, changed_by, created_at`
+
+// scanLobbySettings scans a row selected with lobbySettingsColumns
+func scanLobbySettings(row rowScanner) (*database.LobbySettings, error) {
+	var settings database.LobbySettings
+	err := row.Scan(
+		&settings.ID,
+		&settings.LobbyID,
+		&settings.EffectiveFrom,
+		&settings.AccountType,
+		&settings.User1SalaryPercentage,
+		&settings.User2SalaryPercentage,
+		&settings.ChangedBy,
+		&settings.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// GetSettingsHistory gets the settings a lobby has had, oldest first. A lobby without history gets
+// its current settings, in force since it was created.
+func (s *LobbyService) GetSettingsHistory(lobbyID int64) ([]*database.LobbySettings, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT `+lobbySettingsColumns+` FROM lobby_settings_history
+	          WHERE lobby_id = ? ORDER BY effective_from`, lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settings history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*database.LobbySettings
+	for rows.Next() {
+		settings, err := scanLobbySettings(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settings: %w", err)
+		}
+		history = append(history, settings)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query settings history: %w", err)
+	}
+	if len(history) > 0 {
+		return history, nil
+	}
+
+	lobby, err := s.GetLobbyByID(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	if lobby == nil {
+		return nil, fmt.Errorf("lobby not found")
+	}
+	return []*database.LobbySettings{{
+		LobbyID:               lobby.ID,
+		EffectiveFrom:         utils.CalendarDate(lobby.CreatedAt),
+		AccountType:           lobby.AccountType,
+		User1SalaryPercentage: lobby.User1SalaryPercentage,
+		User2SalaryPercentage: lobby.User2SalaryPercentage,
+		CreatedAt:             lobby.CreatedAt,
+	}}, nil
+}
+
+// SettingsAt returns the settings of a history (oldest first) in force on a date.
+// Dates before the first change get the first settings.
+func SettingsAt(history []*database.LobbySettings, date time.Time) *database.LobbySettings {
+	if len(history) == 0 {
+		return nil
+	}
+	day := utils.FormatDate(date)
+	settings := history[0]
+	for _, candidate := range history[1:] {
+		if utils.FormatDate(candidate.EffectiveFrom) > day {
+			break
+		}
+		settings = candidate
+	}
+	return settings
+}
+
+// GetSettingsAt gets the settings of a lobby in force on a date
+func (s *LobbyService) GetSettingsAt(lobbyID int64, date time.Time) (*database.LobbySettings, error) {
+	history, err := s.GetSettingsHistory(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	return SettingsAt(history, date), nil
+}
+
+// saveSettings records settings in force from their date, replacing a change made for the same day
+func saveSettings(tx *sql.Tx, settings *database.LobbySettings) error {
+	_, err := tx.Exec(`INSERT INTO lobby_settings_history
+	          (lobby_id, effective_from, account_type, user1_salary_percentage, user2_salary_percentage, changed_by, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)
+	          ON CONFLICT (lobby_id, effective_from) DO UPDATE SET
+	           account_type = excluded.account_type, user1_salary_percentage = excluded.user1_salary_percentage,
+	           user2_salary_percentage = excluded.user2_salary_percentage, changed_by = excluded.changed_by,
+	           created_at = excluded.created_at`,
+		settings.LobbyID, utils.FormatDate(settings.EffectiveFrom), settings.AccountType,
+		settings.User1SalaryPercentage, settings.User2SalaryPercentage, settings.ChangedBy, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+	return nil
+}
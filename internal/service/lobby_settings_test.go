@@ -0,0 +1,125 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSettingsAt(t *testing.T) {
+	loc := inLocation(t, "America/Argentina/Buenos_Aires")
+	history := []*database.LobbySettings{
+		{ID: 1, EffectiveFrom: day("2026-06-10"), AccountType: "separate", User1SalaryPercentage: 0.5},
+		{ID: 2, EffectiveFrom: day("2026-08-01"), AccountType: "separate", User1SalaryPercentage: 0.6},
+		{ID: 3, EffectiveFrom: day("2026-09-15"), AccountType: "shared", User1SalaryPercentage: 0.6},
+	}
+
+	tests := []struct {
+		name string
+		date time.Time
+		want int64
+	}{
+		{"before the first settings", day("2026-01-01"), 1},
+		{"on the first day", day("2026-06-10"), 1},
+		{"the day before a change", day("2026-07-31"), 1},
+		{"on the day of a change", day("2026-08-01"), 2},
+		{"between changes", day("2026-09-01"), 2},
+		{"after the last change", day("2026-12-31"), 3},
+		{"late on the day before a change, locally", time.Date(2026, 7, 31, 23, 30, 0, 0, loc), 1},
+		{"early on the day of a change, locally", time.Date(2026, 9, 15, 0, 10, 0, 0, loc), 3},
+		{"stored calendar day", time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC), 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SettingsAt(history, tt.date); got.ID != tt.want {
+				t.Errorf("settings #%d, want #%d", got.ID, tt.want)
+			}
+		})
+	}
+
+	if got := SettingsAt(nil, day("2026-09-01")); got != nil {
+		t.Errorf("settings of an empty history = %+v, want nil", got)
+	}
+}
+
+func TestUpdateLobbySettingsHistory(t *testing.T) {
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 2)
+	lobbies := NewLobbyService(db)
+	today := time.Now()
+	created := today.AddDate(0, 0, -60)
+	// The lobby was created two months ago
+	if _, err := db.GetConn().Exec(`UPDATE lobbies SET created_at = ? WHERE id = ?`, created, lobby.ID); err != nil {
+		t.Fatalf("backdate lobby: %v", err)
+	}
+
+	shared := "shared"
+	pct := func(p float64) *float64 { return &p }
+	changes := []struct {
+		name        string
+		from        time.Time
+		accountType *string
+		user1       *float64
+		user2       *float64
+	}{
+		{"salaries from a month ago", today.AddDate(0, 0, -30), nil, pct(0.6), pct(0.4)},
+		{"shared from ten days ago", today.AddDate(0, 0, -10), &shared, nil, nil},
+		{"salaries again the same day", today.AddDate(0, 0, -10), nil, pct(0.7), pct(0.3)},
+		{"salaries before the lobby existed", created.AddDate(0, -3, 0), nil, pct(0.55), pct(0.45)},
+	}
+	for _, change := range changes {
+		if err := lobbies.UpdateLobbySettings(lobby.ID, change.accountType, change.user1, change.user2, change.from, 1); err != nil {
+			t.Fatalf("%s: %v", change.name, err)
+		}
+	}
+	if err := lobbies.UpdateLobbySettings(lobby.ID, &shared, nil, nil, today.AddDate(0, 0, 1), 1); err == nil {
+		t.Errorf("a change from tomorrow was accepted")
+	}
+
+	tests := []struct {
+		date time.Time
+		want string
+	}{
+		{created.AddDate(-1, 0, 0), "separate 0.55"},
+		{created, "separate 0.55"},
+		{today.AddDate(0, 0, -31), "separate 0.55"},
+		{today.AddDate(0, 0, -30), "separate 0.6"},
+		{today.AddDate(0, 0, -11), "separate 0.6"},
+		{today.AddDate(0, 0, -10), "shared 0.7"},
+		{today, "shared 0.7"},
+	}
+	for _, tt := range tests {
+		settings, err := lobbies.GetSettingsAt(lobby.ID, tt.date)
+		if err != nil {
+			t.Fatalf("GetSettingsAt: %v", err)
+		}
+		if got := fmt.Sprintf("%s %v", settings.AccountType, settings.User1SalaryPercentage); got != tt.want {
+			t.Errorf("settings on %s = %s, want %s", tt.date.Format("2006-01-02"), got, tt.want)
+		}
+	}
+
+	history, err := lobbies.GetSettingsHistory(lobby.ID)
+	if err != nil {
+		t.Fatalf("GetSettingsHistory: %v", err)
+	}
+	if len(history) != 3 {
+		t.Errorf("got %d settings, want 3", len(history))
+	}
+	for _, settings := range history {
+		if settings.ChangedBy != (sql.NullInt64{Int64: 1, Valid: true}) {
+			t.Errorf("settings from %s changed by %v, want user 1", settings.EffectiveFrom.Format("2006-01-02"), settings.ChangedBy)
+		}
+	}
+
+	// The lobby keeps the settings in force today
+	lobby, err = lobbies.GetLobbyByID(lobby.ID)
+	if err != nil {
+		t.Fatalf("GetLobbyByID: %v", err)
+	}
+	if lobby.AccountType != "shared" || lobby.User1SalaryPercentage != 0.7 || lobby.User2SalaryPercentage != 0.3 {
+		t.Errorf("lobby settings = %s %v/%v, want shared 0.7/0.3", lobby.AccountType, lobby.User1SalaryPercentage, lobby.User2SalaryPercentage)
+	}
+}
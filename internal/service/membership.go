@@ -0,0 +1,187 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	// ErrNotLobbyOwner is returned when a member other than the owner manages the members of a lobby
+	ErrNotLobbyOwner = errors.New("only the owner of the lobby can do this")
+	// ErrOwnerMustTransfer is returned when the owner tries to leave a lobby that still has other members
+	ErrOwnerMustTransfer = errors.New("the owner has to transfer the lobby to another member before leaving")
+	// ErrCannotRemoveSelf is returned when the owner tries to remove themselves instead of leaving
+	ErrCannotRemoveSelf = errors.New("you cannot remove yourself; transfer the lobby and leave instead")
+	// ErrMemberRemoved is returned when a member the owner removed tries to join the lobby again without an invitation
+	ErrMemberRemoved = errors.New("you were removed from this lobby; ask its owner for an invitation")
+	// ErrRemovalNotApproved is returned when a member removal is approved by the owner or by someone outside the lobby
+	ErrRemovalNotApproved = errors.New("another member of the lobby has to approve the removal")
+)
+
+// GetMemberships gets the membership intervals of a lobby that overlap the days from "from" to "to" (unbounded
+// when zero), in joining order. A member who left and came back has one interval for each time they were in.
+func (s *LobbyService) GetMemberships(lobbyID int64, from, to time.Time) ([]*database.LobbyMember, error) {
+	query := `SELECT id, lobby_id, user_telegram_id, share_weight, joined_at, left_at
+	          FROM lobby_members WHERE lobby_id = ?`
+	args := []interface{}{lobbyID}
+	if !from.IsZero() {
+		query += " AND (left_at IS NULL OR left_at >= ?)"
+		args = append(args, utils.CalendarDate(from))
+	}
+	if !to.IsZero() {
+		query += " AND joined_at < ?"
+		args = append(args, utils.CalendarDate(to).AddDate(0, 0, 1))
+	}
+	return s.queryMembers(query+" ORDER BY joined_at, id", args...)
+}
+
+// GetMembersBetween gets everyone who was a member of a lobby at some point from "from" to "to" (unbounded when
+// zero), once each, in joining order. Used to settle a period with whoever was in the lobby then.
+func (s *LobbyService) GetMembersBetween(lobbyID int64, from, to time.Time) ([]*database.LobbyMember, error) {
+	memberships, err := s.GetMemberships(lobbyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return uniqueMembers(memberships), nil
+}
+
+// uniqueMembers returns the latest membership of each member among some, in the order they first joined
+func uniqueMembers(memberships []*database.LobbyMember) []*database.LobbyMember {
+	index := make(map[int64]int, len(memberships))
+	var members []*database.LobbyMember
+	for _, membership := range memberships {
+		if i, ok := index[membership.UserTelegramID]; ok {
+			members[i] = membership
+			continue
+		}
+		index[membership.UserTelegramID] = len(members)
+		members = append(members, membership)
+	}
+	return members
+}
+
+// removeMember ends a membership, keeping the member's expenses and payments, and stops their recurring expenses
+// in the lobby. removedBy is the owner who removed them and approvedBy the member who agreed, or both zero when
+// they left. A lobby left without members stops accepting its invite tokens.
+func (s *LobbyService) removeMember(lobby *database.Lobby, userID int64, removedBy int64, approvedBy int64) error {
+	conn := s.db.GetConn()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE lobby_members SET left_at = ?, removed_by = ?
+	          WHERE lobby_id = ? AND user_telegram_id = ? AND left_at IS NULL`, time.Now(), nullID(removedBy), lobby.ID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove lobby member: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotMember
+	}
+
+	if _, err := tx.Exec(`UPDATE recurring_expenses SET is_active = 0
+	          WHERE lobby_id = ? AND spender_telegram_id = ? AND is_active = 1`, lobby.ID, userID); err != nil {
+		return fmt.Errorf("failed to stop recurring expenses: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE users SET active_lobby_id = NULL
+	          WHERE telegram_id = ? AND active_lobby_id = ?`, userID, lobby.ID); err != nil {
+		return fmt.Errorf("failed to clear active lobby: %w", err)
+	}
+	if len(lobby.Members) == 1 {
+		if _, err := revokeInviteTokens(tx, lobby.ID, inviteRevokedByOwner); err != nil {
+			return err
+		}
+	}
+
+	eventType, actor, detail := SecurityEventMemberLeft, userID, ""
+	if removedBy != 0 {
+		eventType, actor, detail = SecurityEventMemberRemoved, removedBy, fmt.Sprintf("approved by %d", approvedBy)
+	}
+	if err := logSecurityEvent(tx, eventType, lobby.ID, actor, userID, detail); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit member removal: %w", err)
+	}
+	return nil
+}
+
+// LeaveLobby takes a user out of a lobby; their expenses stay theirs in the settlements of the time they were in it.
+// The owner can only leave once they are the last member, or after transferring the lobby.
+func (s *LobbyService) LeaveLobby(lobbyID int64, userID int64) error {
+	lobby, err := s.GetLobbyByID(lobbyID)
+	if err != nil {
+		return err
+	}
+	if lobby == nil || !lobby.IsMember(userID) {
+		return ErrNotMember
+	}
+	if lobby.OwnerTelegramID == userID && len(lobby.Members) > 1 {
+		return ErrOwnerMustTransfer
+	}
+	return s.removeMember(lobby, userID, 0, 0)
+}
+
+// RemoveMember lets the owner of a lobby take another member out of it once a member other than the owner
+// approves, freeing their place; in a couple that is the partner being removed. The removed member needs a
+// new invitation to come back.
+func (s *LobbyService) RemoveMember(lobbyID int64, ownerID int64, memberID int64, approvedBy int64) error {
+	lobby, err := s.GetLobbyByID(lobbyID)
+	if err != nil {
+		return err
+	}
+	if lobby == nil {
+		return fmt.Errorf("lobby not found")
+	}
+	if lobby.OwnerTelegramID != ownerID {
+		return ErrNotLobbyOwner
+	}
+	if memberID == ownerID {
+		return ErrCannotRemoveSelf
+	}
+	if !lobby.IsMember(memberID) {
+		return ErrNotMember
+	}
+	if approvedBy == ownerID || !lobby.IsMember(approvedBy) {
+		return ErrRemovalNotApproved
+	}
+	return s.removeMember(lobby, memberID, ownerID, approvedBy)
+}
+
+// TransferOwnership makes another member the owner of a lobby, who then manages its members and invitations
+func (s *LobbyService) TransferOwnership(lobbyID int64, ownerID int64, newOwnerID int64) error {
+	conn := s.db.GetConn()
+
+	lobby, err := s.GetLobbyByID(lobbyID)
+	if err != nil {
+		return err
+	}
+	if lobby == nil {
+		return fmt.Errorf("lobby not found")
+	}
+	if lobby.OwnerTelegramID != ownerID {
+		return ErrNotLobbyOwner
+	}
+	if newOwnerID == ownerID {
+		return fmt.Errorf("you already own this lobby")
+	}
+	if !lobby.IsMember(newOwnerID) {
+		return ErrNotMember
+	}
+
+	_, err = conn.Exec(`UPDATE lobbies SET user1_telegram_id = ? WHERE id = ? AND user1_telegram_id = ?`, newOwnerID, lobbyID, ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to transfer lobby: %w", err)
+	}
+	if err := s.security.LogEvent(SecurityEventOwnerTransferred, lobbyID, ownerID, newOwnerID, ""); err != nil {
+		log.Printf("Error logging ownership transfer of lobby %d: %v", lobbyID, err)
+	}
+	return nil
+}
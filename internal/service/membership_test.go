@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRemoveMemberNeedsAnotherMembersApproval(t *testing.T) {
+	tests := []struct {
+		name       string
+		members    int
+		owner      int64
+		member     int64
+		approvedBy int64
+		want       error
+	}{
+		{"approved by a third member", 3, 1, 2, 3, nil},
+		{"partner confirms their own removal", 2, 1, 2, 2, nil},
+		{"owner approves", 3, 1, 2, 1, ErrRemovalNotApproved},
+		{"nobody approves", 3, 1, 2, 0, ErrRemovalNotApproved},
+		{"approved by someone outside the lobby", 2, 1, 2, 9, ErrRemovalNotApproved},
+		{"asked by a former owner", 3, 2, 3, 3, ErrNotLobbyOwner},
+		{"owner removes themselves", 3, 1, 1, 2, ErrCannotRemoveSelf},
+		{"not a member", 2, 1, 9, 2, ErrNotMember},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			lobby := newTestLobby(t, db, tt.members)
+			lobbies := NewLobbyService(db)
+
+			err := lobbies.RemoveMember(lobby.ID, tt.owner, tt.member, tt.approvedBy)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("RemoveMember = %v, want %v", err, tt.want)
+			}
+
+			after, err := lobbies.GetLobbyByID(lobby.ID)
+			if err != nil {
+				t.Fatalf("GetLobbyByID: %v", err)
+			}
+			removed := len(after.Members) < len(lobby.Members)
+			if removed != (tt.want == nil) {
+				t.Errorf("removed = %v with %d of %d members left", removed, len(after.Members), len(lobby.Members))
+			}
+			if tt.want == nil && after.IsMember(tt.member) {
+				t.Errorf("member %d is still in the lobby", tt.member)
+			}
+		})
+	}
+}
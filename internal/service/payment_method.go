@@ -2,22 +2,66 @@ package service
 
 import (
 	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 )
 
+// ErrDefaultNotFound is returned when a member has no default payment method to clear
+var ErrDefaultNotFound = errors.New("default payment method not found")
+
 // PaymentMethodService handles payment method operations
 type PaymentMethodService struct {
 	db *database.DB
 }
 
+// defaultCreditWarnPercent is the usage of a card's credit limit that warns when adding expenses, unless changed
+const defaultCreditWarnPercent = 80
+
 // NewPaymentMethodService creates a new payment method service
 func NewPaymentMethodService(db *database.DB) *PaymentMethodService {
 	return &PaymentMethodService{db: db}
 }
 
+// paymentMethodColumns lists the columns selected for a payment method, in scanPaymentMethod order
+const paymentMethodColumns = `id, lobby_id, name, type, owner_telegram_id, closing_day, due_day, due_offset_days,
+	          closing_shift, credit_limit_minor, credit_limit_currency, credit_warn_percent, billing_cycle_days,
+	          is_active, created_at`
+
+// scanPaymentMethod scans a row selected with paymentMethodColumns
+func scanPaymentMethod(row rowScanner) (*database.PaymentMethod, error) {
+	var method database.PaymentMethod
+	var creditLimit sql.NullInt64
+	var creditLimitCurrency sql.NullString
+	err := row.Scan(
+		&method.ID,
+		&method.LobbyID,
+		&method.Name,
+		&method.Type,
+		&method.OwnerTelegramID,
+		&method.ClosingDay,
+		&method.DueDay,
+		&method.DueOffsetDays,
+		&method.ClosingShift,
+		&creditLimit,
+		&creditLimitCurrency,
+		&method.CreditWarnPercent,
+		&method.BillingCycleDays,
+		&method.IsActive,
+		&method.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if creditLimit.Valid {
+		method.CreditLimit = utils.NewMoney(creditLimit.Int64, creditLimitCurrency.String)
+	}
+	return &method, nil
+}
+
 // normalizePaymentMethodType normalizes payment method type (handles Spanish aliases)
 func normalizePaymentMethodType(methodType string) string {
 	methodType = strings.ToLower(methodType)
@@ -101,15 +145,17 @@ func (s *PaymentMethodService) CreatePaymentMethod(lobbyID int64, name string, m
 	}
 
 	return &database.PaymentMethod{
-		ID:               id,
-		LobbyID:          lobbyID,
-		Name:             name,
-		Type:             methodType,
-		OwnerTelegramID:  ownerID,
-		ClosingDay:       closingDayNull,
-		BillingCycleDays: 30,
-		IsActive:         true,
-		CreatedAt:        now,
+		ID:                id,
+		LobbyID:           lobbyID,
+		Name:              name,
+		Type:              methodType,
+		OwnerTelegramID:   ownerID,
+		ClosingDay:        closingDayNull,
+		ClosingShift:      string(utils.ShiftNone),
+		CreditWarnPercent: defaultCreditWarnPercent,
+		BillingCycleDays:  30,
+		IsActive:          true,
+		CreatedAt:         now,
 	}, nil
 }
 
@@ -117,8 +163,7 @@ func (s *PaymentMethodService) CreatePaymentMethod(lobbyID int64, name string, m
 func (s *PaymentMethodService) GetPaymentMethodsByLobby(lobbyID int64, activeOnly bool) ([]*database.PaymentMethod, error) {
 	conn := s.db.GetConn()
 
-	query := `SELECT id, lobby_id, name, type, owner_telegram_id, closing_day, 
-	          billing_cycle_days, is_active, created_at
+	query := `SELECT ` + paymentMethodColumns + `
 	          FROM payment_methods WHERE lobby_id = ?`
 
 	if activeOnly {
@@ -134,22 +179,11 @@ func (s *PaymentMethodService) GetPaymentMethodsByLobby(lobbyID int64, activeOnl
 
 	var methods []*database.PaymentMethod
 	for rows.Next() {
-		var method database.PaymentMethod
-		err := rows.Scan(
-			&method.ID,
-			&method.LobbyID,
-			&method.Name,
-			&method.Type,
-			&method.OwnerTelegramID,
-			&method.ClosingDay,
-			&method.BillingCycleDays,
-			&method.IsActive,
-			&method.CreatedAt,
-		)
+		method, err := scanPaymentMethod(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan payment method: %w", err)
 		}
-		methods = append(methods, &method)
+		methods = append(methods, method)
 	}
 
 	return methods, nil
@@ -159,23 +193,10 @@ func (s *PaymentMethodService) GetPaymentMethodsByLobby(lobbyID int64, activeOnl
 func (s *PaymentMethodService) GetPaymentMethodByID(id int64) (*database.PaymentMethod, error) {
 	conn := s.db.GetConn()
 
-	var method database.PaymentMethod
-	query := `SELECT id, lobby_id, name, type, owner_telegram_id, closing_day,
-	          billing_cycle_days, is_active, created_at
+	query := `SELECT ` + paymentMethodColumns + `
 	          FROM payment_methods WHERE id = ?`
 
-	err := conn.QueryRow(query, id).Scan(
-		&method.ID,
-		&method.LobbyID,
-		&method.Name,
-		&method.Type,
-		&method.OwnerTelegramID,
-		&method.ClosingDay,
-		&method.BillingCycleDays,
-		&method.IsActive,
-		&method.CreatedAt,
-	)
-
+	method, err := scanPaymentMethod(conn.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -183,7 +204,7 @@ func (s *PaymentMethodService) GetPaymentMethodByID(id int64) (*database.Payment
 		return nil, fmt.Errorf("failed to query payment method: %w", err)
 	}
 
-	return &method, nil
+	return method, nil
 }
 
 // UpdatePaymentMethod updates a payment method
@@ -246,9 +267,609 @@ func (s *PaymentMethodService) UpdatePaymentMethod(id int64, name *string, metho
 		return fmt.Errorf("failed to update payment method: %w", err)
 	}
 
+	// A new closing day moves expenses to other statements
+	if closingDay != nil {
+		if _, err := NewExpenseService(s.db).RecomputeBillingPeriods(id); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// SetDueDate sets when a payment method's statements are due: a day of the month, or a number of
+// days after the closing. Setting one clears the other; both nil clears the due date.
+func (s *PaymentMethodService) SetDueDate(id int64, dueDay *int64, offsetDays *int64) error {
+	conn := s.db.GetConn()
+
+	if dueDay != nil && offsetDays != nil {
+		return fmt.Errorf("set either a due day or days after closing, not both")
+	}
+	if dueDay != nil && (*dueDay < 1 || *dueDay > 31) {
+		return fmt.Errorf("due day must be between 1 and 31")
+	}
+	if offsetDays != nil && (*offsetDays < 1 || *offsetDays > 60) {
+		return fmt.Errorf("days after closing must be between 1 and 60")
+	}
+
+	var dueDayNull, offsetNull sql.NullInt64
+	if dueDay != nil {
+		dueDayNull = sql.NullInt64{Int64: *dueDay, Valid: true}
+	}
+	if offsetDays != nil {
+		offsetNull = sql.NullInt64{Int64: *offsetDays, Valid: true}
+	}
+
+	_, err := conn.Exec(`UPDATE payment_methods SET due_day = ?, due_offset_days = ? WHERE id = ?`,
+		dueDayNull, offsetNull, id)
+	if err != nil {
+		return fmt.Errorf("failed to update due date: %w", err)
+	}
+
+	return nil
+}
+
+// SetOwner sets who pays a payment method's bills, or makes it shared when ownerTelegramID is nil.
+// The owner must be a member of the payment method's lobby.
+func (s *PaymentMethodService) SetOwner(id int64, ownerTelegramID *int64) error {
+	conn := s.db.GetConn()
+
+	var ownerID sql.NullInt64
+	if ownerTelegramID != nil {
+		method, err := s.GetPaymentMethodByID(id)
+		if err != nil {
+			return err
+		}
+		if method == nil {
+			return fmt.Errorf("payment method not found")
+		}
+		lobby, err := NewLobbyService(s.db).GetLobbyByID(method.LobbyID)
+		if err != nil {
+			return err
+		}
+		if lobby == nil || !lobby.IsMember(*ownerTelegramID) {
+			return fmt.Errorf("the owner must be a member of the lobby")
+		}
+		ownerID = sql.NullInt64{Int64: *ownerTelegramID, Valid: true}
+	}
+
+	_, err := conn.Exec(`UPDATE payment_methods SET owner_telegram_id = ? WHERE id = ?`, ownerID, id)
+	if err != nil {
+		return fmt.Errorf("failed to update owner: %w", err)
+	}
+	return nil
+}
+
+// SetCreditLimit sets a payment method's credit limit, or clears it when limit is nil, and optionally the usage
+// percentage of the limit that warns when adding expenses
+func (s *PaymentMethodService) SetCreditLimit(id int64, limit *utils.Money, warnPercent *int64) error {
+	conn := s.db.GetConn()
+
+	if limit != nil && !limit.IsPositive() {
+		return fmt.Errorf("credit limit must be positive")
+	}
+	if warnPercent != nil && (*warnPercent < 1 || *warnPercent > 100) {
+		return fmt.Errorf("warning percentage must be between 1 and 100")
+	}
+
+	var limitNull sql.NullInt64
+	var currencyNull sql.NullString
+	if limit != nil {
+		method, err := s.GetPaymentMethodByID(id)
+		if err != nil {
+			return err
+		}
+		if method == nil {
+			return fmt.Errorf("payment method not found")
+		}
+		currency, err := NewExpenseService(s.db).resolveCurrency(method.LobbyID, limit.Currency)
+		if err != nil {
+			return err
+		}
+		limit := limit.WithCurrency(currency)
+		limitNull = sql.NullInt64{Int64: limit.Amount, Valid: true}
+		currencyNull = sql.NullString{String: limit.Currency, Valid: true}
+	}
+
+	_, err := conn.Exec(`UPDATE payment_methods SET credit_limit_minor = ?, credit_limit_currency = ? WHERE id = ?`,
+		limitNull, currencyNull, id)
+	if err != nil {
+		return fmt.Errorf("failed to update credit limit: %w", err)
+	}
+
+	if warnPercent != nil {
+		_, err := conn.Exec(`UPDATE payment_methods SET credit_warn_percent = ? WHERE id = ?`, *warnPercent, id)
+		if err != nil {
+			return fmt.Errorf("failed to update credit warning: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// paymentMethodDefaultColumns lists the columns selected for a default payment method, in
+// scanPaymentMethodDefault order; it expects payment_method_defaults joined as d, payment_methods as pm
+// and categories as c
+const paymentMethodDefaultColumns = `d.id, d.lobby_id, d.user_telegram_id, d.category_id, IFNULL(c.name, ''),
+	          d.payment_method_id, pm.name, d.created_at`
+
+// paymentMethodDefaultJoins joins a default payment method with its payment method and category
+const paymentMethodDefaultJoins = `FROM payment_method_defaults d
+	          JOIN payment_methods pm ON pm.id = d.payment_method_id
+	          LEFT JOIN categories c ON c.id = d.category_id`
+
+// scanPaymentMethodDefault scans a row selected with paymentMethodDefaultColumns
+func scanPaymentMethodDefault(row rowScanner) (*database.PaymentMethodDefault, error) {
+	var def database.PaymentMethodDefault
+	err := row.Scan(
+		&def.ID,
+		&def.LobbyID,
+		&def.UserTelegramID,
+		&def.CategoryID,
+		&def.CategoryName,
+		&def.PaymentMethodID,
+		&def.PaymentMethodName,
+		&def.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// GetDefaultPaymentMethods gets a member's default payment methods in a lobby, the general one first
+func (s *PaymentMethodService) GetDefaultPaymentMethods(lobbyID int64, userID int64) ([]*database.PaymentMethodDefault, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT `+paymentMethodDefaultColumns+` `+paymentMethodDefaultJoins+`
+	          WHERE d.lobby_id = ? AND d.user_telegram_id = ?
+	          ORDER BY d.category_id IS NOT NULL, c.name COLLATE NOCASE`, lobbyID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query default payment methods: %w", err)
+	}
+	defer rows.Close()
+
+	var defaults []*database.PaymentMethodDefault
+	for rows.Next() {
+		def, err := scanPaymentMethodDefault(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan default payment method: %w", err)
+		}
+		defaults = append(defaults, def)
+	}
+	return defaults, nil
+}
+
+// DefaultPaymentMethod returns the payment method a member's expense in a category gets when it doesn't
+// name one: their default for the category, else their general default. Inactive payment methods are
+// skipped. It returns nil when there is none.
+func (s *PaymentMethodService) DefaultPaymentMethod(lobbyID int64, userID int64, categoryID sql.NullInt64) (*database.PaymentMethodDefault, error) {
+	conn := s.db.GetConn()
+
+	def, err := scanPaymentMethodDefault(conn.QueryRow(`SELECT `+paymentMethodDefaultColumns+` `+paymentMethodDefaultJoins+`
+	          WHERE d.lobby_id = ? AND d.user_telegram_id = ? AND pm.is_active = 1
+	          AND (d.category_id IS NULL OR d.category_id = ?)
+	          ORDER BY d.category_id IS NULL LIMIT 1`, lobbyID, userID, categoryID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query default payment method: %w", err)
+	}
+	return def, nil
+}
+
+// SetDefaultPaymentMethod sets a member's default payment method in a lobby, for a category or,
+// when categoryID is nil, for any category
+func (s *PaymentMethodService) SetDefaultPaymentMethod(lobbyID int64, userID int64, categoryID *int64, paymentMethodID int64) error {
+	conn := s.db.GetConn()
+
+	method, err := s.GetPaymentMethodByID(paymentMethodID)
+	if err != nil {
+		return err
+	}
+	if method == nil || method.LobbyID != lobbyID {
+		return fmt.Errorf("payment method not found")
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM payment_method_defaults WHERE lobby_id = ? AND user_telegram_id = ? AND category_id IS ?`,
+		lobbyID, userID, categoryID); err != nil {
+		return fmt.Errorf("failed to replace default payment method: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO payment_method_defaults (lobby_id, user_telegram_id, category_id, payment_method_id, created_at)
+	          VALUES (?, ?, ?, ?, ?)`, lobbyID, userID, categoryID, paymentMethodID, time.Now()); err != nil {
+		return fmt.Errorf("failed to set default payment method: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit default payment method: %w", err)
+	}
+	return nil
+}
+
+// ClearDefaultPaymentMethod removes a member's default payment method for a category or,
+// when categoryID is nil, their general one
+func (s *PaymentMethodService) ClearDefaultPaymentMethod(lobbyID int64, userID int64, categoryID *int64) error {
+	conn := s.db.GetConn()
+
+	result, err := conn.Exec(`DELETE FROM payment_method_defaults WHERE lobby_id = ? AND user_telegram_id = ? AND category_id IS ?`,
+		lobbyID, userID, categoryID)
+	if err != nil {
+		return fmt.Errorf("failed to clear default payment method: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrDefaultNotFound
+	}
+	return nil
+}
+
+// billingCycleColumns lists the columns selected for a billing cycle, in scanBillingCycle order
+const billingCycleColumns = `id, payment_method_id, month, closing_date, due_date, created_at`
+
+// scanBillingCycle scans a row selected with billingCycleColumns
+func scanBillingCycle(row rowScanner) (*database.BillingCycle, error) {
+	var cycle database.BillingCycle
+	err := row.Scan(
+		&cycle.ID,
+		&cycle.PaymentMethodID,
+		&cycle.Month,
+		&cycle.ClosingDate,
+		&cycle.DueDate,
+		&cycle.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &cycle, nil
+}
+
+// GetBillingCycles gets the closing dates published for a payment method's statements, ordered by month
+func (s *PaymentMethodService) GetBillingCycles(paymentMethodID int64) ([]*database.BillingCycle, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT `+billingCycleColumns+`
+	          FROM billing_cycles WHERE payment_method_id = ? ORDER BY month`, paymentMethodID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query billing cycles: %w", err)
+	}
+	defer rows.Close()
+
+	var cycles []*database.BillingCycle
+	for rows.Next() {
+		cycle, err := scanBillingCycle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan billing cycle: %w", err)
+		}
+		cycles = append(cycles, cycle)
+	}
+	return cycles, nil
+}
+
+// GetClosingCalendar gets the closing dates published for a payment method's statements, by month
+func (s *PaymentMethodService) GetClosingCalendar(paymentMethodID int64) (utils.ClosingCalendar, error) {
+	cycles, err := s.GetBillingCycles(paymentMethodID)
+	if err != nil {
+		return nil, err
+	}
+	calendar := make(utils.ClosingCalendar, len(cycles))
+	for _, cycle := range cycles {
+		calendar[cycle.Month] = cycle.ClosingDate
+	}
+	return calendar, nil
+}
+
+// SetBillingCycle sets the closing date (and optionally the due date) the bank published for the statement
+// of the given month, then moves the payment method's expenses to the statements they now fall in.
+// It returns how many expenses moved.
+func (s *PaymentMethodService) SetBillingCycle(paymentMethodID int64, month time.Time, closingDate time.Time, dueDate *time.Time) (int, error) {
+	conn := s.db.GetConn()
+
+	method, err := s.GetPaymentMethodByID(paymentMethodID)
+	if err != nil {
+		return 0, err
+	}
+	if method == nil {
+		return 0, fmt.Errorf("payment method not found")
+	}
+	if !method.ClosingDay.Valid {
+		return 0, fmt.Errorf("payment method %s has no billing cycle", method.Name)
+	}
+
+	cycles, err := s.BillingCycles(method)
+	if err != nil {
+		return 0, err
+	}
+	monthKey := utils.FormatMonth(month)
+	delete(cycles.Calendar, monthKey)
+
+	// The closing must stay between the neighbouring statements' closings, so cycles never overlap
+	prevClosing := cycles.Closing(month.Year(), month.Month()-1, time.UTC)
+	nextClosing := cycles.Closing(month.Year(), month.Month()+1, time.UTC)
+	closing := utils.StartOfDay(closingDate)
+	if !closing.After(prevClosing) || !closing.Before(nextClosing) {
+		return 0, fmt.Errorf("closing date of %s must be between %s and %s", monthKey,
+			utils.FormatDate(prevClosing.AddDate(0, 0, 1)), utils.FormatDate(nextClosing.AddDate(0, 0, -1)))
+	}
+
+	var dueNull sql.NullString
+	if dueDate != nil {
+		if !utils.StartOfDay(*dueDate).After(closing) {
+			return 0, fmt.Errorf("due date must be after the closing date")
+		}
+		dueNull = sql.NullString{String: utils.FormatDate(*dueDate), Valid: true}
+	}
+
+	_, err = conn.Exec(`INSERT INTO billing_cycles (payment_method_id, month, closing_date, due_date, created_at)
+	          VALUES (?, ?, ?, ?, ?)
+	          ON CONFLICT (payment_method_id, month) DO UPDATE SET closing_date = excluded.closing_date, due_date = excluded.due_date`,
+		paymentMethodID, monthKey, utils.FormatDate(closing), dueNull, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to save billing cycle: %w", err)
+	}
+
+	return NewExpenseService(s.db).RecomputeBillingPeriods(paymentMethodID)
+}
+
+// DeleteBillingCycle removes the published closing date of a month, so that statement closes on the fixed
+// closing day again, then moves the payment method's expenses accordingly. It returns how many expenses moved.
+func (s *PaymentMethodService) DeleteBillingCycle(paymentMethodID int64, month time.Time) (int, error) {
+	conn := s.db.GetConn()
+
+	result, err := conn.Exec(`DELETE FROM billing_cycles WHERE payment_method_id = ? AND month = ?`,
+		paymentMethodID, utils.FormatMonth(month))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete billing cycle: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, fmt.Errorf("no closing date set for %s", utils.FormatMonth(month))
+	}
+
+	return NewExpenseService(s.db).RecomputeBillingPeriods(paymentMethodID)
+}
+
+// BillingCycles returns the statement engine of a payment method with a closing day: its closing day and
+// shift, the closing dates published for it and the lobby's holidays
+func (s *PaymentMethodService) BillingCycles(method *database.PaymentMethod) (utils.BillingCycles, error) {
+	cycles := utils.NewBillingCycles(int(method.ClosingDay.Int64))
+	if method.ClosingShift != "" {
+		cycles.Shift = utils.ClosingShift(method.ClosingShift)
+	}
+
+	calendar, err := s.GetClosingCalendar(method.ID)
+	if err != nil {
+		return cycles, err
+	}
+	cycles.Calendar = calendar
+
+	holidays, err := s.GetHolidays(method.LobbyID)
+	if err != nil {
+		return cycles, err
+	}
+	cycles.Holidays = make(map[string]bool, len(holidays))
+	for _, holiday := range holidays {
+		cycles.Holidays[utils.FormatDate(holiday.Date)] = true
+	}
+	return cycles, nil
+}
+
+// BillingPeriodForMonth returns the statement of a payment method that closes in the given month,
+// using the closing date published for it when there is one
+func (s *PaymentMethodService) BillingPeriodForMonth(method *database.PaymentMethod, month time.Time) (start, end time.Time, err error) {
+	cycles, err := s.BillingCycles(method)
+	if err != nil {
+		return start, end, err
+	}
+	period := cycles.PeriodForMonth(month.Year(), month.Month())
+	return period.Start, period.End, nil
+}
+
+// SetClosingShift sets where a payment method's closing day moves to when it falls on a weekend or holiday,
+// then moves its expenses to the statements they now fall in. It returns how many expenses moved.
+func (s *PaymentMethodService) SetClosingShift(id int64, shift utils.ClosingShift) (int, error) {
+	conn := s.db.GetConn()
+
+	if !utils.ValidClosingShift(shift) {
+		return 0, fmt.Errorf("invalid closing shift: %s", shift)
+	}
+	if _, err := conn.Exec(`UPDATE payment_methods SET closing_shift = ? WHERE id = ?`, string(shift), id); err != nil {
+		return 0, fmt.Errorf("failed to update closing shift: %w", err)
+	}
+
+	return NewExpenseService(s.db).RecomputeBillingPeriods(id)
+}
+
+// GetHolidays gets a lobby's holidays, ordered by date
+func (s *PaymentMethodService) GetHolidays(lobbyID int64) ([]*database.Holiday, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT lobby_id, date, name FROM holidays WHERE lobby_id = ? ORDER BY date`, lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holidays: %w", err)
+	}
+	defer rows.Close()
+
+	var holidays []*database.Holiday
+	for rows.Next() {
+		var holiday database.Holiday
+		if err := rows.Scan(&holiday.LobbyID, &holiday.Date, &holiday.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan holiday: %w", err)
+		}
+		holidays = append(holidays, &holiday)
+	}
+	return holidays, nil
+}
+
+// AddHoliday adds (or renames) a holiday of a lobby, then moves the expenses of every payment method whose
+// closing it shifts. It returns how many expenses moved.
+func (s *PaymentMethodService) AddHoliday(lobbyID int64, date time.Time, name string) (int, error) {
+	conn := s.db.GetConn()
+
+	_, err := conn.Exec(`INSERT INTO holidays (lobby_id, date, name) VALUES (?, ?, ?)
+	          ON CONFLICT (lobby_id, date) DO UPDATE SET name = excluded.name`,
+		lobbyID, utils.FormatDate(date), sql.NullString{String: name, Valid: name != ""})
+	if err != nil {
+		return 0, fmt.Errorf("failed to add holiday: %w", err)
+	}
+
+	return s.recomputeLobbyBillingPeriods(lobbyID)
+}
+
+// DeleteHoliday removes a holiday of a lobby, then moves the expenses of every payment method whose
+// closing it shifted. It returns how many expenses moved.
+func (s *PaymentMethodService) DeleteHoliday(lobbyID int64, date time.Time) (int, error) {
+	conn := s.db.GetConn()
+
+	result, err := conn.Exec(`DELETE FROM holidays WHERE lobby_id = ? AND date = ?`, lobbyID, utils.FormatDate(date))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete holiday: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, fmt.Errorf("%s is not a holiday", utils.FormatDate(date))
+	}
+
+	return s.recomputeLobbyBillingPeriods(lobbyID)
+}
+
+// recomputeLobbyBillingPeriods recomputes the billing periods of every payment method of a lobby
+// whose closing day shifts on holidays
+func (s *PaymentMethodService) recomputeLobbyBillingPeriods(lobbyID int64) (int, error) {
+	methods, err := s.GetPaymentMethodsByLobby(lobbyID, false)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	expenseService := NewExpenseService(s.db)
+	for _, method := range methods {
+		if !method.ClosingDay.Valid || method.ClosingShift == string(utils.ShiftNone) {
+			continue
+		}
+		n, err := expenseService.RecomputeBillingPeriods(method.ID)
+		if err != nil {
+			return moved, err
+		}
+		moved += n
+	}
+	return moved, nil
+}
+
+// StatementDueDate returns when the statement of a payment method closing on periodEnd must be paid:
+// the due date published for that statement, or the one the payment method's due day gives.
+// It reports false when the payment method has no closing day or no due date configured.
+func (s *PaymentMethodService) StatementDueDate(method *database.PaymentMethod, periodEnd time.Time) (time.Time, bool, error) {
+	if !method.ClosingDay.Valid {
+		return time.Time{}, false, nil
+	}
+
+	cycles, err := s.GetBillingCycles(method.ID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for _, cycle := range cycles {
+		if cycle.DueDate.Valid && utils.FormatDate(cycle.ClosingDate) == utils.FormatDate(periodEnd) {
+			return cycle.DueDate.Time, true, nil
+		}
+	}
+
+	dueDay, ok := statementDueDate(method, periodEnd)
+	return dueDay, ok, nil
+}
+
+// statementDueDate returns the due date the payment method's due day or offset gives a statement closing on periodEnd
+func statementDueDate(method *database.PaymentMethod, periodEnd time.Time) (time.Time, bool) {
+	switch {
+	case method.DueOffsetDays.Valid:
+		return utils.CalculateDueDate(periodEnd, 0, int(method.DueOffsetDays.Int64)), true
+	case method.DueDay.Valid:
+		return utils.CalculateDueDate(periodEnd, int(method.DueDay.Int64), 0), true
+	}
+	return time.Time{}, false
+}
+
+// DueStatement is a closed statement of a payment method that is about to be due
+type DueStatement struct {
+	Method      *database.PaymentMethod
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	DueDate     time.Time
+	Expenses    []*database.Expense
+}
+
+// GetDueReminders finds, across all lobbies, the last closed statement of every active payment method
+// that is due within the given number of days, and has expenses. Each statement is returned only once:
+// later calls skip the ones already reminded.
+func (s *PaymentMethodService) GetDueReminders(now time.Time, daysAhead int) ([]DueStatement, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT ` + paymentMethodColumns + `
+	          FROM payment_methods WHERE is_active = 1 AND closing_day IS NOT NULL
+	          AND (due_day IS NOT NULL OR due_offset_days IS NOT NULL)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment methods: %w", err)
+	}
+	var methods []*database.PaymentMethod
+	for rows.Next() {
+		method, err := scanPaymentMethod(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan payment method: %w", err)
+		}
+		methods = append(methods, method)
+	}
+	rows.Close()
+
+	today := utils.CalendarDate(now)
+	expenseService := NewExpenseService(s.db)
+
+	var statements []DueStatement
+	for _, method := range methods {
+		cycles, err := s.BillingCycles(method)
+		if err != nil {
+			return nil, err
+		}
+		period := cycles.LastClosed(now)
+		start, end := period.Start, period.End
+		dueDate, ok, err := s.StatementDueDate(method, end)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || dueDate.Before(today) || dueDate.After(today.AddDate(0, 0, daysAhead)) {
+			continue
+		}
+
+		expenses, err := expenseService.GetExpensesByBillingPeriod(method.LobbyID, method.ID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if len(expenses) == 0 {
+			continue
+		}
+
+		result, err := conn.Exec(`INSERT OR IGNORE INTO payment_reminders (payment_method_id, period_end, created_at)
+		          VALUES (?, ?, ?)`, method.ID, utils.FormatDate(end), time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to record payment reminder: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			continue
+		}
+
+		statements = append(statements, DueStatement{
+			Method:      method,
+			PeriodStart: start,
+			PeriodEnd:   end,
+			DueDate:     dueDate,
+			Expenses:    expenses,
+		})
+	}
+	return statements, nil
+}
+
 // DeletePaymentMethod deletes a payment method (soft delete by setting is_active = false)
 func (s *PaymentMethodService) DeletePaymentMethod(id int64) error {
 	return s.UpdatePaymentMethod(id, nil, nil, nil, nil, boolPtr(false))
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// reconcileBoundaryDays is how many days around a statement's boundaries are searched for
+// expenses the bank may have billed in it
+const reconcileBoundaryDays = 5
+
+var (
+	// ErrReconciliationNotFound is returned when a statement has not been reconciled
+	ErrReconciliationNotFound = errors.New("reconciliation not found")
+	// ErrNothingToAdjust is returned when the bank's total is not above the logged total
+	ErrNothingToAdjust = errors.New("the statement total is not above the logged total")
+	// ErrAlreadyAdjusted is returned when a reconciled statement already has its adjustment expense
+	ErrAlreadyAdjusted = errors.New("the statement already has an adjustment")
+	// ErrStatementReconciled is returned when adding, changing or deleting an expense would change a reconciled
+	// statement and the change was not forced
+	ErrStatementReconciled = errors.New("the change affects a reconciled statement")
+)
+
+// ReconciliationService checks card statements against the totals on the bank's statements
+type ReconciliationService struct {
+	db             *database.DB
+	expenseService *ExpenseService
+}
+
+// NewReconciliationService creates a new reconciliation service
+func NewReconciliationService(db *database.DB, expenseService *ExpenseService) *ReconciliationService {
+	return &ReconciliationService{
+		db:             db,
+		expenseService: expenseService,
+	}
+}
+
+// ReconciliationReport compares a statement of a card with what was logged for it
+type ReconciliationReport struct {
+	Reconciliation *database.StatementReconciliation
+	Difference     utils.Money // The bank's total minus the logged total; positive when expenses are missing
+	Method         *database.PaymentMethod
+	Expenses       []*database.Expense    // Logged expenses in the statement
+	Others         map[string]utils.Money // Logged totals in other currencies, not compared
+	OutOfCycle     []*database.Expense    // Logged expenses dated just outside the statement
+	MissingItems   []utils.Money          // Statement items with no logged expense of that amount
+	Unmatched      []*database.Expense    // Logged expenses with no statement item of that amount
+}
+
+// reconciliationColumns lists the columns selected for a reconciliation, in scanReconciliation order
+const reconciliationColumns = `id, payment_method_id, month, period_start, period_end, statement_total_minor,
+	          logged_total_minor, currency, adjustment_expense_id, reconciled_by, reconciled_at`
+
+// scanReconciliation scans a row selected with reconciliationColumns
+func scanReconciliation(row rowScanner) (*database.StatementReconciliation, error) {
+	var rec database.StatementReconciliation
+	err := row.Scan(
+		&rec.ID,
+		&rec.PaymentMethodID,
+		&rec.Month,
+		&rec.PeriodStart,
+		&rec.PeriodEnd,
+		&rec.StatementTotal.Amount,
+		&rec.LoggedTotal.Amount,
+		&rec.StatementTotal.Currency,
+		&rec.AdjustmentExpenseID,
+		&rec.ReconciledBy,
+		&rec.ReconciledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rec.LoggedTotal.Currency = rec.StatementTotal.Currency
+	return &rec, nil
+}
+
+// Reconcile compares the statement of a card for a month with the bank's total and, optionally, the amounts
+// of its line items, and marks the statement as reconciled by the user. Reconciling again replaces the totals.
+func (s *ReconciliationService) Reconcile(method *database.PaymentMethod, month time.Time, total utils.Money, items []utils.Money, userID int64) (*ReconciliationReport, error) {
+	conn := s.db.GetConn()
+
+	if !method.ClosingDay.Valid {
+		return nil, fmt.Errorf("payment method %s has no billing cycle", method.Name)
+	}
+	currency, err := s.expenseService.resolveCurrency(method.LobbyID, total.Currency)
+	if err != nil {
+		return nil, err
+	}
+	total = total.WithCurrency(currency)
+
+	start, end, err := NewPaymentMethodService(s.db).BillingPeriodForMonth(method, month)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.compare(method, start, end, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	logged, err := report.loggedTotal(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Exec(`INSERT INTO statement_reconciliations
+	          (payment_method_id, month, period_start, period_end, statement_total_minor, logged_total_minor,
+	           currency, reconciled_by, reconciled_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	          ON CONFLICT (payment_method_id, month) DO UPDATE SET
+	           period_start = excluded.period_start, period_end = excluded.period_end,
+	           statement_total_minor = excluded.statement_total_minor, logged_total_minor = excluded.logged_total_minor,
+	           currency = excluded.currency, reconciled_by = excluded.reconciled_by, reconciled_at = excluded.reconciled_at`,
+		method.ID, utils.FormatMonth(month), utils.FormatDate(start), utils.FormatDate(end),
+		total.Amount, logged.Amount, currency, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to save reconciliation: %w", err)
+	}
+
+	report.Reconciliation, err = s.GetReconciliation(method.ID, month)
+	if err != nil {
+		return nil, err
+	}
+	if report.Difference, err = report.Reconciliation.StatementTotal.Sub(report.Reconciliation.LoggedTotal); err != nil {
+		return nil, err
+	}
+	if len(items) > 0 {
+		report.matchItems(items, currency)
+	}
+	return report, nil
+}
+
+// compare gathers the logged expenses of a statement and the ones dated just outside it
+func (s *ReconciliationService) compare(method *database.PaymentMethod, start, end time.Time, currency string) (*ReconciliationReport, error) {
+	expenses, err := s.expenseService.GetExpensesByBillingPeriod(method.LobbyID, method.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconciliationReport{
+		Method:   method,
+		Expenses: expenses,
+		Others:   make(map[string]utils.Money),
+	}
+	for _, expense := range expenses {
+		if expense.Amount.Currency != currency {
+			if report.Others[expense.Amount.Currency], err = report.Others[expense.Amount.Currency].Add(expense.Amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	from := start.AddDate(0, 0, -reconcileBoundaryDays)
+	to := end.AddDate(0, 0, reconcileBoundaryDays)
+	nearby, err := s.expenseService.GetExpensesByLobby(method.LobbyID, &from, &to, &method.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, expense := range nearby {
+		// Later installments are dated on their own statement's first day
+		if expense.ParentExpenseID.Valid {
+			continue
+		}
+		if expense.ExpenseDate.Before(start) || expense.ExpenseDate.After(end) {
+			report.OutOfCycle = append(report.OutOfCycle, expense)
+		}
+	}
+	return report, nil
+}
+
+// loggedTotal returns the total of the report's expenses in a currency
+func (r *ReconciliationReport) loggedTotal(currency string) (utils.Money, error) {
+	var amounts []utils.Money
+	for _, expense := range r.Expenses {
+		if expense.Amount.Currency == currency {
+			amounts = append(amounts, expense.Amount)
+		}
+	}
+	return utils.Sum(currency, amounts...)
+}
+
+// matchItems pairs each statement item with a logged expense of the same amount, each used once
+func (r *ReconciliationReport) matchItems(items []utils.Money, currency string) {
+	used := make(map[int64]bool)
+	for _, item := range items {
+		item = item.WithCurrency(currency)
+		found := false
+		for _, expense := range r.Expenses {
+			if !used[expense.ID] && expense.Amount == item {
+				used[expense.ID] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.MissingItems = append(r.MissingItems, item)
+		}
+	}
+	for _, expense := range r.Expenses {
+		if !used[expense.ID] && expense.Amount.Currency == currency {
+			r.Unmatched = append(r.Unmatched, expense)
+		}
+	}
+}
+
+// GetReconciliation gets the reconciliation of a card's statement for a month
+func (s *ReconciliationService) GetReconciliation(paymentMethodID int64, month time.Time) (*database.StatementReconciliation, error) {
+	conn := s.db.GetConn()
+
+	rec, err := scanReconciliation(conn.QueryRow(`SELECT `+reconciliationColumns+`
+	          FROM statement_reconciliations WHERE payment_method_id = ? AND month = ?`,
+		paymentMethodID, utils.FormatMonth(month)))
+	if err == sql.ErrNoRows {
+		return nil, ErrReconciliationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconciliation: %w", err)
+	}
+	return rec, nil
+}
+
+// GetReconciliationByID gets a reconciliation by ID
+func (s *ReconciliationService) GetReconciliationByID(id int64) (*database.StatementReconciliation, error) {
+	conn := s.db.GetConn()
+
+	rec, err := scanReconciliation(conn.QueryRow(`SELECT `+reconciliationColumns+`
+	          FROM statement_reconciliations WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrReconciliationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconciliation: %w", err)
+	}
+	return rec, nil
+}
+
+// ReconciledStatement returns the reconciliation of the statement an expense is on,
+// or nil if the expense is not on a reconciled statement
+func (s *ReconciliationService) ReconciledStatement(expense *database.Expense) (*database.StatementReconciliation, error) {
+	return reconciledStatement(s.db, expense.PaymentMethodID, expense.BillingPeriodEnd)
+}
+
+// reconciledStatement returns the reconciliation of a payment method's statement closing on periodEnd,
+// or nil if there is none
+func reconciledStatement(db *database.DB, paymentMethodID sql.NullInt64, periodEnd sql.NullTime) (*database.StatementReconciliation, error) {
+	if !paymentMethodID.Valid || !periodEnd.Valid {
+		return nil, nil
+	}
+
+	rec, err := scanReconciliation(db.GetConn().QueryRow(`SELECT `+reconciliationColumns+`
+	          FROM statement_reconciliations WHERE payment_method_id = ? AND period_end = ?`,
+		paymentMethodID.Int64, utils.FormatDate(periodEnd.Time)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconciliation: %w", err)
+	}
+	return rec, nil
+}
+
+// checkReconciled returns ErrStatementReconciled when a payment method's statement closing on periodEnd
+// is reconciled
+func (s *ExpenseService) checkReconciled(paymentMethodID sql.NullInt64, periodEnd sql.NullTime) error {
+	rec, err := reconciledStatement(s.db, paymentMethodID, periodEnd)
+	if err != nil {
+		return err
+	}
+	if rec != nil {
+		return fmt.Errorf("%w (%s)", ErrStatementReconciled, rec.Month)
+	}
+	return nil
+}
+
+// AddAdjustment adds an expense for what the bank charged above the logged total (fees, taxes) on the
+// reconciled statement's closing day, so the statement matches the bank's total
+func (s *ReconciliationService) AddAdjustment(reconciliationID int64, userID int64, description string) (*database.Expense, error) {
+	conn := s.db.GetConn()
+
+	rec, err := s.GetReconciliationByID(reconciliationID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.AdjustmentExpenseID.Valid {
+		return nil, ErrAlreadyAdjusted
+	}
+
+	method, err := NewPaymentMethodService(s.db).GetPaymentMethodByID(rec.PaymentMethodID)
+	if err != nil {
+		return nil, err
+	}
+	if method == nil {
+		return nil, fmt.Errorf("payment method not found")
+	}
+
+	// Expenses may have changed since the statement was reconciled
+	expenses, err := s.expenseService.GetExpensesByBillingPeriod(method.LobbyID, method.ID, rec.PeriodStart,
+		time.Date(rec.PeriodEnd.Year(), rec.PeriodEnd.Month(), rec.PeriodEnd.Day(), 23, 59, 59, 999999999, rec.PeriodEnd.Location()))
+	if err != nil {
+		return nil, err
+	}
+	report := &ReconciliationReport{Expenses: expenses}
+	logged, err := report.loggedTotal(rec.StatementTotal.Currency)
+	if err != nil {
+		return nil, err
+	}
+	difference, err := rec.StatementTotal.Sub(logged)
+	if err != nil {
+		return nil, err
+	}
+	if !difference.IsPositive() {
+		return nil, ErrNothingToAdjust
+	}
+
+	// The adjustment is part of reconciling, so it goes on the statement without asking
+	expense, err := s.expenseService.CreateExpense(method.LobbyID, userID, difference, description, "",
+		rec.PeriodEnd, &method.ID, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Exec(`UPDATE statement_reconciliations SET adjustment_expense_id = ?, logged_total_minor = ? WHERE id = ?`,
+		expense.ID, rec.StatementTotal.Amount, rec.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save adjustment: %w", err)
+	}
+	return expense, nil
+}
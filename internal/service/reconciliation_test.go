@@ -0,0 +1,123 @@
+package service
+
+import (
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"testing"
+)
+
+func TestReconciledStatementGuard(t *testing.T) {
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 2)
+	card := newTestCard(t, db, lobby.ID, 20)
+	expenses := NewExpenseService(db)
+	reconciliations := NewReconciliationService(db, expenses)
+
+	// The September statement (August 21st to September 20th) is reconciled; October's is open
+	add := func(t *testing.T, date string, methodID *int64, force bool) (int64, error) {
+		t.Helper()
+		expense, err := expenses.CreateExpense(lobby.ID, 1, utils.NewMoney(1000, "ARS"), "", "", day(date), methodID, nil, force)
+		if err != nil {
+			return 0, err
+		}
+		return expense.ID, nil
+	}
+	mustAdd := func(t *testing.T, date string) int64 {
+		t.Helper()
+		id, err := add(t, date, &card.ID, true)
+		if err != nil {
+			t.Fatalf("CreateExpense: %v", err)
+		}
+		return id
+	}
+	reconciled := mustAdd(t, "2026-09-10")
+	if _, err := reconciliations.Reconcile(card, day("2026-09-01"), utils.NewMoney(1000, "ARS"), nil, 1); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	other := int64(0)
+
+	tests := []struct {
+		name string
+		op   func(t *testing.T) error
+		want bool // Whether ErrStatementReconciled is returned
+	}{
+		{"add on reconciled statement", func(t *testing.T) error {
+			_, err := add(t, "2026-09-15", &card.ID, false)
+			return err
+		}, true},
+		{"add on reconciled statement forced", func(t *testing.T) error {
+			_, err := add(t, "2026-09-15", &card.ID, true)
+			return err
+		}, false},
+		{"add on open statement", func(t *testing.T) error {
+			_, err := add(t, "2026-09-21", &card.ID, false)
+			return err
+		}, false},
+		{"add without card", func(t *testing.T) error {
+			_, err := add(t, "2026-09-15", nil, false)
+			return err
+		}, false},
+		{"add installments reaching back", func(t *testing.T) error {
+			_, err := expenses.CreateInstallmentExpense(lobby.ID, 1, utils.NewMoney(3000, "ARS"), 3, 0, "", "",
+				day("2026-09-05"), card.ID, nil, false)
+			return err
+		}, true},
+		{"change amount", func(t *testing.T) error {
+			amount := utils.NewMoney(2000, "ARS")
+			return expenses.UpdateExpense(reconciled, &amount, nil, nil, nil, nil, nil, false)
+		}, true},
+		{"change description", func(t *testing.T) error {
+			description := "renamed"
+			return expenses.UpdateExpense(reconciled, nil, &description, nil, nil, nil, nil, false)
+		}, true},
+		{"move out of reconciled statement", func(t *testing.T) error {
+			date := day("2026-10-01")
+			return expenses.UpdateExpense(reconciled, nil, nil, nil, &date, nil, nil, false)
+		}, true},
+		{"move onto reconciled statement", func(t *testing.T) error {
+			id := mustAdd(t, "2026-10-01")
+			date := day("2026-09-01")
+			return expenses.UpdateExpense(id, nil, nil, nil, &date, nil, nil, false)
+		}, true},
+		{"move onto card with reconciled statement", func(t *testing.T) error {
+			id, err := add(t, "2026-09-12", nil, false)
+			if err != nil {
+				t.Fatalf("CreateExpense: %v", err)
+			}
+			return expenses.UpdateExpense(id, nil, nil, nil, nil, &card.ID, nil, false)
+		}, true},
+		{"change open expense", func(t *testing.T) error {
+			other = mustAdd(t, "2026-10-02")
+			amount := utils.NewMoney(1500, "ARS")
+			return expenses.UpdateExpense(other, &amount, nil, nil, nil, nil, nil, false)
+		}, false},
+		{"change forced", func(t *testing.T) error {
+			description := "renamed"
+			return expenses.UpdateExpense(reconciled, nil, &description, nil, nil, nil, nil, true)
+		}, false},
+		{"delete from reconciled statement", func(t *testing.T) error {
+			_, err := expenses.DeleteExpense(reconciled, false)
+			return err
+		}, true},
+		{"delete open expense", func(t *testing.T) error {
+			_, err := expenses.DeleteExpense(other, false)
+			return err
+		}, false},
+		{"delete forced", func(t *testing.T) error {
+			_, err := expenses.DeleteExpense(reconciled, true)
+			return err
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.op(t)
+			if got := errors.Is(err, ErrStatementReconciled); got != tt.want {
+				t.Errorf("err = %v, want ErrStatementReconciled: %v", err, tt.want)
+			}
+			if !tt.want && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// RecurringRun is an expense created from a recurring rule
+type RecurringRun struct {
+	Rule    *database.RecurringExpense
+	Expense *database.Expense // nil when the run fell on a reconciled statement and was not created
+	Date    time.Time
+}
+
+// RecurringService handles recurring expense rules and their scheduled materialization
+type RecurringService struct {
+	db             *database.DB
+	expenseService *ExpenseService
+}
+
+// NewRecurringService creates a new recurring expense service
+func NewRecurringService(db *database.DB, expenseService *ExpenseService) *RecurringService {
+	return &RecurringService{
+		db:             db,
+		expenseService: expenseService,
+	}
+}
+
+// NormalizeCadence normalizes a cadence name (handles Spanish aliases), returning "" if invalid
+func NormalizeCadence(cadence string) string {
+	aliases := map[string]string{
+		"weekly":  "weekly",
+		"semanal": "weekly",
+		"monthly": "monthly",
+		"mensual": "monthly",
+		"yearly":  "yearly",
+		"annual":  "yearly",
+		"anual":   "yearly",
+	}
+	return aliases[strings.ToLower(cadence)]
+}
+
+// firstRecurringDate returns the first run date on or after start
+func firstRecurringDate(cadence string, dayOfMonth int, start time.Time) time.Time {
+	start = utils.StartOfDay(start)
+	switch cadence {
+	case "weekly":
+		return start
+	case "yearly":
+		date := utils.ClampedDate(start.Year(), start.Month(), dayOfMonth, start.Location())
+		if date.Before(start) {
+			date = utils.ClampedDate(start.Year()+1, start.Month(), dayOfMonth, start.Location())
+		}
+		return date
+	default:
+		date := utils.ClampedDate(start.Year(), start.Month(), dayOfMonth, start.Location())
+		if date.Before(start) {
+			date = utils.ClampedDate(start.Year(), start.Month()+1, dayOfMonth, start.Location())
+		}
+		return date
+	}
+}
+
+// nextRecurringDate returns the run date that follows the given one
+func nextRecurringDate(cadence string, dayOfMonth int, after time.Time) time.Time {
+	switch cadence {
+	case "weekly":
+		return after.AddDate(0, 0, 7)
+	case "yearly":
+		return utils.ClampedDate(after.Year()+1, after.Month(), dayOfMonth, after.Location())
+	default:
+		return utils.ClampedDate(after.Year(), after.Month()+1, dayOfMonth, after.Location())
+	}
+}
+
+// CreateRecurringExpense creates a new recurring expense rule
+func (s *RecurringService) CreateRecurringExpense(lobbyID int64, spenderTelegramID int64, amount utils.Money, description string, category string, paymentMethodID *int64, cadence string, dayOfMonth int, startDate time.Time, endDate *time.Time) (*database.RecurringExpense, error) {
+	conn := s.db.GetConn()
+
+	normalized := NormalizeCadence(cadence)
+	if normalized == "" {
+		return nil, fmt.Errorf("invalid cadence: %s", cadence)
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	currency, err := s.expenseService.resolveCurrency(lobbyID, amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
+
+	var dayNull sql.NullInt64
+	if normalized != "weekly" {
+		if dayOfMonth < 1 || dayOfMonth > 31 {
+			return nil, fmt.Errorf("day of month must be between 1 and 31")
+		}
+		dayNull = sql.NullInt64{Int64: int64(dayOfMonth), Valid: true}
+	}
+
+	nextRun := firstRecurringDate(normalized, dayOfMonth, startDate)
+
+	var endNull sql.NullTime
+	if endDate != nil {
+		if endDate.Before(nextRun) {
+			return nil, fmt.Errorf("end date is before the first run (%s)", utils.FormatDate(nextRun))
+		}
+		endNull = sql.NullTime{Time: *endDate, Valid: true}
+	}
+
+	var descNull sql.NullString
+	if description != "" {
+		descNull = sql.NullString{String: description, Valid: true}
+	}
+
+	var catNull sql.NullString
+	if category != "" {
+		catNull = sql.NullString{String: category, Valid: true}
+	}
+
+	var pmIDNull sql.NullInt64
+	if paymentMethodID != nil {
+		pmIDNull = sql.NullInt64{Int64: *paymentMethodID, Valid: true}
+	}
+
+	query := `INSERT INTO recurring_expenses
+	          (lobby_id, spender_telegram_id, payment_method_id, amount_minor, currency, description, category,
+	           cadence, day_of_month, start_date, end_date, next_run_date, is_active, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	result, err := conn.Exec(query,
+		lobbyID,
+		spenderTelegramID,
+		pmIDNull,
+		amount.Amount,
+		amount.Currency,
+		descNull,
+		catNull,
+		normalized,
+		dayNull,
+		utils.StartOfDay(startDate),
+		endNull,
+		nextRun,
+		true,
+		now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recurring expense: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recurring expense ID: %w", err)
+	}
+
+	return &database.RecurringExpense{
+		ID:                id,
+		LobbyID:           lobbyID,
+		SpenderTelegramID: spenderTelegramID,
+		PaymentMethodID:   pmIDNull,
+		Amount:            amount,
+		Description:       descNull,
+		Category:          catNull,
+		Cadence:           normalized,
+		DayOfMonth:        dayNull,
+		StartDate:         utils.StartOfDay(startDate),
+		EndDate:           endNull,
+		NextRunDate:       nextRun,
+		IsActive:          true,
+		CreatedAt:         now,
+	}, nil
+}
+
+// recurringColumns lists the columns selected for a recurring expense, in scanRecurringExpense order
+const recurringColumns = `id, lobby_id, spender_telegram_id, payment_method_id, amount_minor,
+	          currency, description, category, cadence, day_of_month, start_date, end_date,
+	          next_run_date, last_run_date, is_active, created_at`
+
+// scanRecurringExpense scans a row selected with recurringColumns
+func scanRecurringExpense(row rowScanner) (*database.RecurringExpense, error) {
+	var rule database.RecurringExpense
+	err := row.Scan(
+		&rule.ID,
+		&rule.LobbyID,
+		&rule.SpenderTelegramID,
+		&rule.PaymentMethodID,
+		&rule.Amount.Amount,
+		&rule.Amount.Currency,
+		&rule.Description,
+		&rule.Category,
+		&rule.Cadence,
+		&rule.DayOfMonth,
+		&rule.StartDate,
+		&rule.EndDate,
+		&rule.NextRunDate,
+		&rule.LastRunDate,
+		&rule.IsActive,
+		&rule.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// queryRecurringExpenses runs a query selecting recurringColumns and scans all rows
+func (s *RecurringService) queryRecurringExpenses(query string, args ...interface{}) ([]*database.RecurringExpense, error) {
+	rows, err := s.db.GetConn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring expenses: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*database.RecurringExpense
+	for rows.Next() {
+		rule, err := scanRecurringExpense(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recurring expense: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// GetRecurringExpensesByLobby gets the active recurring expenses of a lobby
+func (s *RecurringService) GetRecurringExpensesByLobby(lobbyID int64) ([]*database.RecurringExpense, error) {
+	query := `SELECT ` + recurringColumns + `
+	          FROM recurring_expenses
+	          WHERE lobby_id = ? AND is_active = 1
+	          ORDER BY next_run_date`
+	return s.queryRecurringExpenses(query, lobbyID)
+}
+
+// GetRecurringExpenseByID gets a recurring expense by ID
+func (s *RecurringService) GetRecurringExpenseByID(id int64) (*database.RecurringExpense, error) {
+	query := `SELECT ` + recurringColumns + `
+	          FROM recurring_expenses WHERE id = ?`
+
+	rule, err := scanRecurringExpense(s.db.GetConn().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring expense: %w", err)
+	}
+	return rule, nil
+}
+
+// DeactivateRecurringExpense stops a recurring expense (already created expenses are kept)
+func (s *RecurringService) DeactivateRecurringExpense(id int64) error {
+	_, err := s.db.GetConn().Exec(`UPDATE recurring_expenses SET is_active = 0 WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate recurring expense: %w", err)
+	}
+	return nil
+}
+
+// MaterializeDue creates the expenses of every rule whose next run is due by now.
+// Runs missed while the bot was down are caught up, each one exactly once.
+func (s *RecurringService) MaterializeDue(now time.Time) ([]RecurringRun, error) {
+	today := utils.StartOfDay(now)
+
+	query := `SELECT ` + recurringColumns + `
+	          FROM recurring_expenses
+	          WHERE is_active = 1 AND next_run_date <= ?
+	          ORDER BY next_run_date`
+	rules, err := s.queryRecurringExpenses(query, today)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []RecurringRun
+	for _, rule := range rules {
+		ruleRuns, err := s.materializeRule(rule, today)
+		runs = append(runs, ruleRuns...)
+		if err != nil {
+			// Keep going with the other rules; this one is retried on the next tick
+			log.Printf("Error materializing recurring expense %d: %v", rule.ID, err)
+		}
+	}
+
+	return runs, nil
+}
+
+// errRecurringRunExists is returned while creating a run's expense when the run was already created
+var errRecurringRunExists = errors.New("recurring run already created")
+
+// materializeRule creates every pending run of a rule up to today
+func (s *RecurringService) materializeRule(rule *database.RecurringExpense, today time.Time) ([]RecurringRun, error) {
+	conn := s.db.GetConn()
+	var runs []RecurringRun
+
+	for !rule.NextRunDate.After(today) {
+		runDate := rule.NextRunDate
+		if rule.EndDate.Valid && runDate.After(rule.EndDate.Time) {
+			break
+		}
+
+		// The run is recorded in the same transaction as its expense, and before advancing next_run_date,
+		// so a crash or error anywhere in between never creates the same expense twice
+		var paymentMethodID *int64
+		if rule.PaymentMethodID.Valid {
+			paymentMethodID = &rule.PaymentMethodID.Int64
+		}
+		expense, err := s.expenseService.createExpense(
+			rule.LobbyID,
+			rule.SpenderTelegramID,
+			rule.Amount,
+			rule.Description.String,
+			rule.Category.String,
+			runDate,
+			paymentMethodID,
+			nil,
+			false,
+			func(q rowQuerier, expense *database.Expense) error {
+				result, err := q.Exec(`INSERT OR IGNORE INTO recurring_expense_runs (recurring_expense_id, run_date, expense_id, created_at) VALUES (?, ?, ?, ?)`,
+					rule.ID, runDate, expense.ID, time.Now())
+				if err != nil {
+					return fmt.Errorf("failed to record recurring run: %w", err)
+				}
+				if affected, _ := result.RowsAffected(); affected == 0 {
+					return errRecurringRunExists
+				}
+				return nil
+			},
+		)
+		switch {
+		case err == nil:
+			runs = append(runs, RecurringRun{Rule: rule, Expense: expense, Date: runDate})
+		case errors.Is(err, ErrStatementReconciled):
+			// Nobody is there to confirm changing a reconciled statement: the run is recorded without an
+			// expense, so it is not retried, and reported to be added by hand
+			result, err := conn.Exec(`INSERT OR IGNORE INTO recurring_expense_runs (recurring_expense_id, run_date, created_at) VALUES (?, ?, ?)`,
+				rule.ID, runDate, time.Now())
+			if err != nil {
+				return runs, fmt.Errorf("failed to record recurring run: %w", err)
+			}
+			if affected, _ := result.RowsAffected(); affected > 0 {
+				runs = append(runs, RecurringRun{Rule: rule, Date: runDate})
+			}
+		case !errors.Is(err, errRecurringRunExists):
+			return runs, err
+		}
+
+		next := nextRecurringDate(rule.Cadence, int(rule.DayOfMonth.Int64), runDate)
+		_, err = conn.Exec(`UPDATE recurring_expenses SET next_run_date = ?, last_run_date = ? WHERE id = ?`,
+			next, runDate, rule.ID)
+		if err != nil {
+			return runs, fmt.Errorf("failed to advance recurring expense: %w", err)
+		}
+		rule.NextRunDate = next
+		rule.LastRunDate = sql.NullTime{Time: runDate, Valid: true}
+	}
+
+	// Rules past their end date are finished
+	if rule.EndDate.Valid && rule.NextRunDate.After(rule.EndDate.Time) {
+		if err := s.DeactivateRecurringExpense(rule.ID); err != nil {
+			return runs, err
+		}
+	}
+
+	return runs, nil
+}
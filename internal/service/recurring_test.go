@@ -0,0 +1,93 @@
+package service
+
+import (
+	"botGastosPareja/pkg/utils"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMaterializeDueCatchesUpOnce(t *testing.T) {
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 2)
+	expenses := NewExpenseService(db)
+	recurring := NewRecurringService(db, expenses)
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name       string
+		cadence    string
+		dayOfMonth int
+		start      string
+		end        string
+		want       []string
+	}{
+		{"monthly", "monthly", 5, "2026-07-01", "", []string{"2026-07-05", "2026-08-05", "2026-09-05", "2026-10-05"}},
+		{"monthly on the 31st", "monthly", 31, "2026-07-01", "", []string{"2026-07-31", "2026-08-31", "2026-09-30"}},
+		{"weekly", "weekly", 0, "2026-09-20", "", []string{"2026-09-20", "2026-09-27", "2026-10-04", "2026-10-11", "2026-10-18"}},
+		{"yearly", "yearly", 15, "2025-03-01", "", []string{"2025-03-15", "2026-03-15"}},
+		{"ended", "monthly", 1, "2026-08-01", "2026-09-15", []string{"2026-08-01", "2026-09-01"}},
+		{"not due yet", "monthly", 25, "2026-10-19", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var end *time.Time
+			if tt.end != "" {
+				date := day(tt.end)
+				end = &date
+			}
+			rule, err := recurring.CreateRecurringExpense(lobby.ID, 1, utils.NewMoney(1000, "ARS"), tt.name, "", nil,
+				tt.cadence, tt.dayOfMonth, day(tt.start), end)
+			if err != nil {
+				t.Fatalf("CreateRecurringExpense: %v", err)
+			}
+
+			runDates := func() []string {
+				t.Helper()
+				runs, err := recurring.MaterializeDue(now)
+				if err != nil {
+					t.Fatalf("MaterializeDue: %v", err)
+				}
+				var dates []string
+				for _, run := range runs {
+					if run.Rule.ID == rule.ID {
+						dates = append(dates, utils.FormatDate(run.Date))
+					}
+				}
+				return dates
+			}
+			if got := runDates(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("runs = %v, want %v", got, tt.want)
+			}
+			// Running again, or after failing to advance the rule, creates nothing more
+			if got := runDates(); len(got) != 0 {
+				t.Errorf("second runs = %v, want none", got)
+			}
+			if _, err := db.GetConn().Exec(`UPDATE recurring_expenses SET next_run_date = ?, is_active = 1 WHERE id = ?`,
+				rule.NextRunDate, rule.ID); err != nil {
+				t.Fatalf("rewind rule: %v", err)
+			}
+			if got := runDates(); len(got) != 0 {
+				t.Errorf("runs after rewinding = %v, want none", got)
+			}
+
+			var created int
+			if err := db.GetConn().QueryRow(`SELECT COUNT(*) FROM expenses e
+				JOIN recurring_expense_runs r ON r.expense_id = e.id WHERE r.recurring_expense_id = ?`, rule.ID).Scan(&created); err != nil {
+				t.Fatalf("count expenses: %v", err)
+			}
+			if created != len(tt.want) {
+				t.Errorf("created %d expenses, want %d", created, len(tt.want))
+			}
+
+			rule, err = recurring.GetRecurringExpenseByID(rule.ID)
+			if err != nil {
+				t.Fatalf("GetRecurringExpenseByID: %v", err)
+			}
+			if ended := tt.end != ""; rule.IsActive == ended {
+				t.Errorf("active = %v, want %v", rule.IsActive, !ended)
+			}
+		})
+	}
+}
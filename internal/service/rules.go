@@ -0,0 +1,378 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrRuleNotFound is returned when a categorization rule does not exist in the lobby
+var ErrRuleNotFound = errors.New("rule not found")
+
+const (
+	// learnMinExpenses is how many categorized expenses must share a word before a rule is proposed
+	learnMinExpenses = 2
+	// learnMinConfidence is the share of those expenses that must agree on the category (and payment method)
+	learnMinConfidence = 0.8
+	// learnMaxSuggestions is how many rules "learn from history" proposes at most
+	learnMaxSuggestions = 10
+	// learnMinWordLength skips short words ("de", "la", "to") when learning
+	learnMinWordLength = 3
+)
+
+// RuleService manages the categorization rules of each lobby
+type RuleService struct {
+	db *database.DB
+}
+
+// NewRuleService creates a new rule service
+func NewRuleService(db *database.DB) *RuleService {
+	return &RuleService{db: db}
+}
+
+// RuleSuggestion is a rule proposed from the lobby's past expenses
+type RuleSuggestion struct {
+	Keyword           string
+	Category          string
+	PaymentMethodID   *int64
+	PaymentMethodName string
+	Matches           int // Categorized expenses whose description has the keyword
+}
+
+// ruleColumns lists the columns selected for a rule, in scanRule order
+const ruleColumns = `r.id, r.lobby_id, r.pattern, r.is_regex, r.category_id, c.name, r.payment_method_id, r.created_at`
+
+// scanRule scans a row selected with ruleColumns
+func scanRule(row rowScanner) (*database.CategoryRule, error) {
+	var rule database.CategoryRule
+	err := row.Scan(
+		&rule.ID,
+		&rule.LobbyID,
+		&rule.Pattern,
+		&rule.IsRegex,
+		&rule.CategoryID,
+		&rule.CategoryName,
+		&rule.PaymentMethodID,
+		&rule.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// keywordText folds a text and turns punctuation into spaces, padded so whole words can be found
+// with strings.Contains(" uber ", ...)
+func keywordText(s string) string {
+	folded := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, utils.FoldText(s))
+	return " " + strings.Join(strings.Fields(folded), " ") + " "
+}
+
+// ruleMatches reports whether a rule applies to a description
+func ruleMatches(rule *database.CategoryRule, description string) bool {
+	if rule.IsRegex {
+		re, err := regexp.Compile("(?i)" + rule.Pattern)
+		return err == nil && re.MatchString(description)
+	}
+	keyword := strings.TrimSpace(keywordText(rule.Pattern))
+	return keyword != "" && strings.Contains(keywordText(description), " "+keyword+" ")
+}
+
+// CreateRule adds a rule to a lobby. The category is created if the lobby does not have it yet.
+// Keywords match whole words ignoring case and accents; regular expressions are case-insensitive.
+func (s *RuleService) CreateRule(lobbyID int64, pattern string, isRegex bool, category string, paymentMethodID *int64) (*database.CategoryRule, error) {
+	conn := s.db.GetConn()
+
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, fmt.Errorf("rule pattern cannot be empty")
+	}
+	if isRegex {
+		if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+	} else if strings.TrimSpace(keywordText(pattern)) == "" {
+		return nil, fmt.Errorf("keyword must contain letters or digits")
+	}
+
+	cat, err := NewCategoryService(s.db).ResolveCategory(lobbyID, category)
+	if err != nil {
+		return nil, err
+	}
+	if cat == nil {
+		return nil, fmt.Errorf("rule needs a category")
+	}
+
+	var pmIDNull sql.NullInt64
+	if paymentMethodID != nil {
+		pmIDNull = sql.NullInt64{Int64: *paymentMethodID, Valid: true}
+	}
+
+	now := time.Now()
+	result, err := conn.Exec(`INSERT INTO category_rules (lobby_id, pattern, is_regex, category_id, payment_method_id, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?)`, lobbyID, pattern, isRegex, cat.ID, pmIDNull, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rule: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule ID: %w", err)
+	}
+
+	return &database.CategoryRule{
+		ID:              id,
+		LobbyID:         lobbyID,
+		Pattern:         pattern,
+		IsRegex:         isRegex,
+		CategoryID:      cat.ID,
+		CategoryName:    cat.Name,
+		PaymentMethodID: pmIDNull,
+		CreatedAt:       now,
+	}, nil
+}
+
+// GetRules gets the rules of a lobby in the order they are tried (oldest first)
+func (s *RuleService) GetRules(lobbyID int64) ([]*database.CategoryRule, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT `+ruleColumns+`
+	          FROM category_rules r JOIN categories c ON c.id = r.category_id
+	          WHERE r.lobby_id = ? ORDER BY r.id`, lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*database.CategoryRule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// DeleteRule deletes a rule of the lobby
+func (s *RuleService) DeleteRule(lobbyID int64, id int64) error {
+	conn := s.db.GetConn()
+
+	result, err := conn.Exec(`DELETE FROM category_rules WHERE id = ? AND lobby_id = ?`, id, lobbyID)
+	if err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+// MatchRule returns the first rule of the lobby matching a description, or nil if none does.
+// A rule's payment method is dropped when the method has been deactivated.
+func (s *RuleService) MatchRule(lobbyID int64, description string) (*database.CategoryRule, error) {
+	if strings.TrimSpace(description) == "" {
+		return nil, nil
+	}
+
+	rules, err := s.GetRules(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if !ruleMatches(rule, description) {
+			continue
+		}
+		if rule.PaymentMethodID.Valid {
+			pm, err := NewPaymentMethodService(s.db).GetPaymentMethodByID(rule.PaymentMethodID.Int64)
+			if err != nil {
+				return nil, err
+			}
+			if pm == nil || !pm.IsActive {
+				rule.PaymentMethodID = sql.NullInt64{}
+			}
+		}
+		return rule, nil
+	}
+	return nil, nil
+}
+
+// SuggestRules proposes keyword rules learned from the lobby's categorized expenses:
+// a word of the description that (nearly) always comes with the same category,
+// and with the same payment method when that is consistent too. Words already covered by a rule are skipped.
+func (s *RuleService) SuggestRules(lobbyID int64) ([]RuleSuggestion, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT e.description, e.category, e.payment_method_id, pm.name
+	          FROM expenses e LEFT JOIN payment_methods pm ON pm.id = e.payment_method_id AND pm.is_active = 1
+	          WHERE e.lobby_id = ? AND e.description IS NOT NULL AND e.category IS NOT NULL AND e.category != ''
+	          AND e.parent_expense_id IS NULL`, lobbyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expenses: %w", err)
+	}
+	defer rows.Close()
+
+	type wordStats struct {
+		total      int
+		categories map[string]int
+		methods    map[int64]int
+		names      map[int64]string
+	}
+	stats := make(map[string]*wordStats)
+
+	for rows.Next() {
+		var description, category string
+		var pmID sql.NullInt64
+		var pmName sql.NullString
+		if err := rows.Scan(&description, &category, &pmID, &pmName); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+
+		seen := make(map[string]bool)
+		for _, word := range strings.Fields(keywordText(description)) {
+			if seen[word] || len([]rune(word)) < learnMinWordLength || strings.IndexFunc(word, unicode.IsLetter) < 0 {
+				continue
+			}
+			seen[word] = true
+
+			ws, ok := stats[word]
+			if !ok {
+				ws = &wordStats{categories: map[string]int{}, methods: map[int64]int{}, names: map[int64]string{}}
+				stats[word] = ws
+			}
+			ws.total++
+			ws.categories[category]++
+			if pmID.Valid && pmName.Valid {
+				ws.methods[pmID.Int64]++
+				ws.names[pmID.Int64] = pmName.String
+			}
+		}
+	}
+
+	rules, err := s.GetRules(lobbyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestions []RuleSuggestion
+	for word, ws := range stats {
+		if ws.total < learnMinExpenses {
+			continue
+		}
+		covered := false
+		for _, rule := range rules {
+			if ruleMatches(rule, word) {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+
+		bestCategory, bestCount := "", 0
+		for category, count := range ws.categories {
+			if count > bestCount || (count == bestCount && category < bestCategory) {
+				bestCategory, bestCount = category, count
+			}
+		}
+		if float64(bestCount)/float64(ws.total) < learnMinConfidence {
+			continue
+		}
+
+		suggestion := RuleSuggestion{Keyword: word, Category: bestCategory, Matches: ws.total}
+		for id, count := range ws.methods {
+			if float64(count)/float64(ws.total) >= learnMinConfidence {
+				pmID := id
+				suggestion.PaymentMethodID = &pmID
+				suggestion.PaymentMethodName = ws.names[id]
+			}
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Matches != suggestions[j].Matches {
+			return suggestions[i].Matches > suggestions[j].Matches
+		}
+		return suggestions[i].Keyword < suggestions[j].Keyword
+	})
+	if len(suggestions) > learnMaxSuggestions {
+		suggestions = suggestions[:learnMaxSuggestions]
+	}
+	return suggestions, nil
+}
+
+// ApplyToUncategorized runs the lobby's rules over its expenses without category and categorizes the
+// ones that match. A rule's payment method is only set on expenses that have none. Unless forced, expenses
+// on reconciled statements are left alone.
+// It returns how many expenses were categorized and how many were left alone; installments count once per purchase.
+func (s *RuleService) ApplyToUncategorized(lobbyID int64, force bool) (int, int, error) {
+	conn := s.db.GetConn()
+
+	rows, err := conn.Query(`SELECT id, description, payment_method_id FROM expenses
+	          WHERE lobby_id = ? AND category_id IS NULL AND (category IS NULL OR category = '')
+	          AND description IS NOT NULL AND parent_expense_id IS NULL`, lobbyID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query uncategorized expenses: %w", err)
+	}
+
+	type uncategorized struct {
+		id          int64
+		description string
+		hasMethod   bool
+	}
+	var pending []uncategorized
+	for rows.Next() {
+		var item uncategorized
+		var pmID sql.NullInt64
+		if err := rows.Scan(&item.id, &item.description, &pmID); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		item.hasMethod = pmID.Valid
+		pending = append(pending, item)
+	}
+	rows.Close()
+
+	expenseService := NewExpenseService(s.db)
+	applied, held := 0, 0
+	for _, item := range pending {
+		rule, err := s.MatchRule(lobbyID, item.description)
+		if err != nil {
+			return applied, held, err
+		}
+		if rule == nil {
+			continue
+		}
+
+		var paymentMethodID *int64
+		if !item.hasMethod && rule.PaymentMethodID.Valid {
+			paymentMethodID = &rule.PaymentMethodID.Int64
+		}
+		category := rule.CategoryName
+		err = expenseService.UpdateExpense(item.id, nil, nil, &category, nil, paymentMethodID, nil, force)
+		if errors.Is(err, ErrStatementReconciled) {
+			held++
+			continue
+		}
+		if err != nil {
+			return applied, held, err
+		}
+		applied++
+	}
+	return applied, held, nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Security event types
+const (
+	SecurityEventJoin             = "join"
+	SecurityEventJoinFailed       = "join_failed"
+	SecurityEventJoinLocked       = "join_locked"
+	SecurityEventTokenRegenerated = "token_regenerated"
+	SecurityEventMemberLeft       = "member_left"
+	SecurityEventMemberRemoved    = "member_removed"
+	SecurityEventOwnerTransferred = "owner_transferred"
+)
+
+const (
+	// userFreeJoinFailures is how many failed joins a user can make before being locked out; every failure
+	// after that doubles the lockout, from userLockoutBase up to userLockoutMax
+	userFreeJoinFailures = 5
+	userLockoutBase      = time.Minute
+	userLockoutMax       = 24 * time.Hour
+	// userFailureMemory is how long a user's failed joins are remembered after the last one
+	userFailureMemory = 24 * time.Hour
+
+	// OwnerAlertJoinFailures is how many failed joins by one user into one lobby, within OwnerAlertWindow,
+	// alert the lobby owner
+	OwnerAlertJoinFailures = 3
+	OwnerAlertWindow       = 24 * time.Hour
+)
+
+// ErrJoinLocked is returned when a user tries to join a lobby while locked out after too many failed attempts
+var ErrJoinLocked = errors.New("too many failed attempts to join a lobby, try again later")
+
+// userJoinScope is the join_lockouts scope counting the failed joins of a user
+func userJoinScope(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+// joinLockout returns how long to lock out after a number of remembered failures: nothing within the free
+// failures, then base doubling with each further failure, at most max
+func joinLockout(failures, free int, base, max time.Duration) time.Duration {
+	if failures <= free {
+		return 0
+	}
+	lockout := base
+	for i := free + 1; i < failures && lockout < max; i++ {
+		lockout *= 2
+	}
+	if lockout > max {
+		lockout = max
+	}
+	return lockout
+}
+
+// nullID stores a Telegram or lobby ID, or NULL when it is zero
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// SecurityService logs security events and locks users out of joining lobbies after too many failed attempts
+type SecurityService struct {
+	db *database.DB
+}
+
+// NewSecurityService creates a new security service
+func NewSecurityService(db *database.DB) *SecurityService {
+	return &SecurityService{db: db}
+}
+
+// logSecurityEvent records a security event; a zero lobbyID, userID or targetID is stored as NULL
+func logSecurityEvent(q rowQuerier, eventType string, lobbyID, userID, targetID int64, detail string) error {
+	_, err := q.Exec(`INSERT INTO security_events (event_type, lobby_id, user_telegram_id, target_telegram_id, detail, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?)`, eventType, nullID(lobbyID), nullID(userID), nullID(targetID),
+		sql.NullString{String: detail, Valid: detail != ""}, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to log security event: %w", err)
+	}
+	return nil
+}
+
+// LogEvent records a security event; a zero lobbyID, userID or targetID is stored as NULL
+func (s *SecurityService) LogEvent(eventType string, lobbyID, userID, targetID int64, detail string) error {
+	return logSecurityEvent(s.db.GetConn(), eventType, lobbyID, userID, targetID, detail)
+}
+
+// JoinLockedUntil returns until when a user cannot try to join lobbies because of their failed attempts;
+// the zero time when they can. Other users' failures never lock them out.
+func (s *SecurityService) JoinLockedUntil(userID int64) (time.Time, error) {
+	var lockedUntil sql.NullTime
+	err := s.db.GetConn().QueryRow(`SELECT locked_until FROM join_lockouts WHERE scope = ?`, userJoinScope(userID)).Scan(&lockedUntil)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("failed to get join lockout: %w", err)
+	}
+	if !lockedUntil.Valid || !lockedUntil.Time.After(time.Now()) {
+		return time.Time{}, nil
+	}
+	return lockedUntil.Time, nil
+}
+
+// countFailure counts a failed join in a scope, forgetting earlier failures older than memory, and locks the
+// scope out once it has more than free failures. It returns the lockout, zero when none. The count is
+// incremented in the database, so concurrent failures are all counted.
+func (s *SecurityService) countFailure(scope string, free int, base, max, memory time.Duration, now time.Time) (time.Duration, error) {
+	tx, err := s.db.GetConn().Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var failures int
+	err = tx.QueryRow(`INSERT INTO join_lockouts (scope, failures, last_failure_at) VALUES (?, 1, ?)
+	          ON CONFLICT (scope) DO UPDATE SET
+	          failures = CASE WHEN join_lockouts.last_failure_at >= ? THEN join_lockouts.failures + 1 ELSE 1 END,
+	          last_failure_at = excluded.last_failure_at
+	          RETURNING failures`, scope, now, now.Add(-memory)).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count join failure: %w", err)
+	}
+
+	lockout := joinLockout(failures, free, base, max)
+	var lockedUntil sql.NullTime
+	if lockout > 0 {
+		lockedUntil = sql.NullTime{Time: now.Add(lockout), Valid: true}
+	}
+	if _, err := tx.Exec(`UPDATE join_lockouts SET locked_until = ? WHERE scope = ?`, lockedUntil, scope); err != nil {
+		return 0, fmt.Errorf("failed to lock out joins: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit join failure: %w", err)
+	}
+	return lockout, nil
+}
+
+// RecordFailedJoin logs a user's failed attempt to join a lobby (zero when the token matched none) and counts it
+// towards the user's lockout. It returns how many times the user failed to join that lobby
+// within OwnerAlertWindow, to alert its owner.
+func (s *SecurityService) RecordFailedJoin(userID, lobbyID int64, reason string) (int, error) {
+	conn := s.db.GetConn()
+	now := time.Now()
+
+	if err := logSecurityEvent(conn, SecurityEventJoinFailed, lobbyID, userID, 0, reason); err != nil {
+		return 0, err
+	}
+
+	lockout, err := s.countFailure(userJoinScope(userID), userFreeJoinFailures, userLockoutBase, userLockoutMax, userFailureMemory, now)
+	if err != nil {
+		return 0, err
+	}
+	if lockout > 0 {
+		if err := logSecurityEvent(conn, SecurityEventJoinLocked, lobbyID, userID, 0, "user "+lockout.String()); err != nil {
+			return 0, err
+		}
+	}
+
+	if lobbyID == 0 {
+		return 0, nil
+	}
+	var failures int
+	err = conn.QueryRow(`SELECT COUNT(*) FROM security_events
+	          WHERE event_type = ? AND user_telegram_id = ? AND lobby_id = ? AND created_at >= ?`,
+		SecurityEventJoinFailed, userID, lobbyID, now.Add(-OwnerAlertWindow)).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count failed joins: %w", err)
+	}
+	return failures, nil
+}
+
+// RecordJoin logs that a user joined a lobby. Their failed attempts are still counted until forgotten, so
+// joining a lobby of their own does not let anyone keep guessing tokens.
+func (s *SecurityService) RecordJoin(userID, lobbyID int64, detail string) error {
+	return logSecurityEvent(s.db.GetConn(), SecurityEventJoin, lobbyID, userID, 0, detail)
+}
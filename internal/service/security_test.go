@@ -0,0 +1,147 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJoinLockout(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{5, 0},
+		{6, time.Minute},
+		{7, 2 * time.Minute},
+		{8, 4 * time.Minute},
+		{10, 16 * time.Minute},
+		{15, 512 * time.Minute},
+		{16, 1024 * time.Minute},
+		{17, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := joinLockout(tt.failures, userFreeJoinFailures, userLockoutBase, userLockoutMax); got != tt.want {
+			t.Errorf("joinLockout(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	// The cap applies even when the base is not a power-of-two fraction of it
+	if got := joinLockout(4, 1, 40*time.Minute, time.Hour); got != time.Hour {
+		t.Errorf("joinLockout past the cap = %v, want 1h", got)
+	}
+}
+
+func TestFailedJoinsLockOutOnlyTheUser(t *testing.T) {
+	db := newTestDB(t)
+	security := NewSecurityService(db)
+	users := NewUserService(db)
+	for userID := int64(100); userID < 200; userID++ {
+		if _, err := users.GetOrCreateUser(userID, "", ""); err != nil {
+			t.Fatalf("failed to create user %d: %v", userID, err)
+		}
+	}
+
+	// Many users failing a few times each lock nobody out
+	for userID := int64(100); userID < 200; userID++ {
+		for i := 0; i < userFreeJoinFailures; i++ {
+			if _, err := security.RecordFailedJoin(userID, 0, "not found"); err != nil {
+				t.Fatalf("RecordFailedJoin: %v", err)
+			}
+		}
+	}
+	for _, userID := range []int64{1, 100, 199} {
+		until, err := security.JoinLockedUntil(userID)
+		if err != nil {
+			t.Fatalf("JoinLockedUntil: %v", err)
+		}
+		if !until.IsZero() {
+			t.Errorf("user %d locked out until %v, want not locked out", userID, until)
+		}
+	}
+
+	// One more failure locks out only that user
+	before := time.Now()
+	if _, err := security.RecordFailedJoin(150, 0, "not found"); err != nil {
+		t.Fatalf("RecordFailedJoin: %v", err)
+	}
+	until, err := security.JoinLockedUntil(150)
+	if err != nil {
+		t.Fatalf("JoinLockedUntil: %v", err)
+	}
+	if until.Before(before.Add(userLockoutBase)) || until.After(time.Now().Add(userLockoutBase)) {
+		t.Errorf("user 150 locked out until %v, want %v from now", until, userLockoutBase)
+	}
+	if until, err := security.JoinLockedUntil(151); err != nil || !until.IsZero() {
+		t.Errorf("user 151 locked out until %v (%v), want not locked out", until, err)
+	}
+}
+
+func TestFailedJoinsForgottenAfterMemory(t *testing.T) {
+	db := newTestDB(t)
+	newTestLobby(t, db, 1)
+	security := NewSecurityService(db)
+
+	for i := 0; i < userFreeJoinFailures; i++ {
+		if _, err := security.RecordFailedJoin(1, 0, "not found"); err != nil {
+			t.Fatalf("RecordFailedJoin: %v", err)
+		}
+	}
+	// The last failure was long ago, so the next one starts counting again
+	if _, err := db.GetConn().Exec(`UPDATE join_lockouts SET last_failure_at = ?`,
+		time.Now().Add(-userFailureMemory-time.Minute)); err != nil {
+		t.Fatalf("age failures: %v", err)
+	}
+	lockout, err := security.countFailure(userJoinScope(1), userFreeJoinFailures, userLockoutBase, userLockoutMax, userFailureMemory, time.Now())
+	if err != nil {
+		t.Fatalf("countFailure: %v", err)
+	}
+	if lockout != 0 {
+		t.Errorf("lockout = %v after the failures were forgotten, want none", lockout)
+	}
+	var failures int
+	if err := db.GetConn().QueryRow(`SELECT failures FROM join_lockouts WHERE scope = ?`, userJoinScope(1)).Scan(&failures); err != nil {
+		t.Fatalf("query failures: %v", err)
+	}
+	if failures != 1 {
+		t.Errorf("failures = %d, want 1", failures)
+	}
+}
+
+func TestConcurrentFailedJoinsAreAllCounted(t *testing.T) {
+	// A file database, so concurrent writers wait for each other instead of failing
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "bot.db") + "?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	newTestLobby(t, db, 1)
+	security := NewSecurityService(db)
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := security.RecordFailedJoin(1, 0, "not found"); err != nil {
+				t.Errorf("RecordFailedJoin: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failures int
+	if err := db.GetConn().QueryRow(`SELECT failures FROM join_lockouts WHERE scope = ?`, userJoinScope(1)).Scan(&failures); err != nil {
+		t.Fatalf("query failures: %v", err)
+	}
+	if failures != attempts {
+		t.Errorf("failures = %d, want %d", failures, attempts)
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDSN names the in-memory database of a test; opening it again while open runs the migrations again
+func testDSN(t *testing.T) string {
+	return "file:" + strings.NewReplacer("/", "_", " ", "_").Replace(t.Name()) + "?mode=memory&cache=shared"
+}
+
+// newTestDB opens an empty in-memory database with the schema migrated, private to the test
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.NewDB(testDSN(t))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestLobby creates users 1..members and a separate-account lobby of theirs, owned by user 1
+func newTestLobby(t *testing.T, db *database.DB, members int) *database.Lobby {
+	t.Helper()
+	users := NewUserService(db)
+	for id := int64(1); id <= int64(members); id++ {
+		if _, err := users.GetOrCreateUser(id, "", ""); err != nil {
+			t.Fatalf("failed to create user %d: %v", id, err)
+		}
+	}
+
+	lobbies := NewLobbyService(db)
+	lobby, err := lobbies.CreateLobby(1, "separate", nil)
+	if err != nil {
+		t.Fatalf("failed to create lobby: %v", err)
+	}
+	if members > lobby.MaxMembers {
+		if err := lobbies.SetMaxMembers(lobby.ID, members); err != nil {
+			t.Fatalf("failed to allow %d members: %v", members, err)
+		}
+	}
+	for id := int64(2); id <= int64(members); id++ {
+		if err := lobbies.JoinLobbyDirectly(lobby.ID, id); err != nil {
+			t.Fatalf("failed to add user %d: %v", id, err)
+		}
+	}
+	lobby, err = lobbies.GetLobbyByID(lobby.ID)
+	if err != nil {
+		t.Fatalf("failed to reload lobby: %v", err)
+	}
+	return lobby
+}
+
+// newTestCard creates a credit card of a lobby closing on a day of the month
+func newTestCard(t *testing.T, db *database.DB, lobbyID int64, closingDay int64) *database.PaymentMethod {
+	t.Helper()
+	card, err := NewPaymentMethodService(db).CreatePaymentMethod(lobbyID, "Visa", "credit_card", nil, &closingDay)
+	if err != nil {
+		t.Fatalf("failed to create card: %v", err)
+	}
+	return card
+}
+
+// inLocation runs the rest of a test with the server's time zone set to name
+func inLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	previous := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = previous })
+	return loc
+}
+
+// day parses a "2006-01-02" date at midnight UTC
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// newTestSettlementService returns a settlement service with its dependencies on db
+func newTestSettlementService(db *database.DB) *SettlementService {
+	lobbies := NewLobbyService(db)
+	return NewSettlementService(db, NewExpenseService(db), lobbies, NewExchangeRateService(db, lobbies))
+}
@@ -2,43 +2,100 @@ package service
 
 import (
 	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
 	"fmt"
+	"math/bits"
+	"sort"
 	"time"
 )
 
 // SettlementResult represents the result of a settlement calculation
 type SettlementResult struct {
-	LobbyID               int64
-	AccountType           string
-	PeriodStart           time.Time
-	PeriodEnd             time.Time
-	User1ID               int64
-	User2ID               int64
-	User1TotalSpent       float64
-	User2TotalSpent       float64
-	TotalExpenses         float64
-	User1Expected         float64
-	User2Expected         float64
-	User1SalaryPercentage float64 // Actual salary percentage from lobby
-	User2SalaryPercentage float64 // Actual salary percentage from lobby
-	User1Debt             float64 // Positive = user1 owes, Negative = user2 owes user1
-	User2Debt             float64 // Positive = user2 owes, Negative = user1 owes user2
-	Expenses              []*database.Expense
+	LobbyID           int64
+	AccountType       string // In force at the end of the period
+	PeriodStart       time.Time
+	PeriodEnd         time.Time
+	Currency          string              // Base currency every amount is converted to
+	Members           []*MemberSettlement // In joining order
+	TotalExpenses     utils.Money
+	IncomeRatio       *IncomeRatio // Set when the percentages come from the members' incomes
+	SharedAccountPaid utils.Money  // Paid from the shared account: everything but members' cards in shared lobbies
+	SharedTotal       utils.Money  // Part of TotalExpenses split by the lobby ratio
+	Transfers         []Transfer   // Fewest payments between members that settle their balances
+	SplitItems        []SplitItem  // Expenses with their own split mode (personal, partner, custom)
+	OwnerPaidItems    []OwnerPaidItem
+	SettingsPeriods   []SettingsPeriod // Shared expenses by the settings and members on their date, oldest first
+	Expenses          []*database.Expense
+}
+
+// MemberSettlement is what one member consumed and paid during a settlement period
+type MemberSettlement struct {
+	UserID       int64
+	TotalSpent   utils.Money // Expenses they spent, whoever pays the bill
+	Percentage   float64     // Their part of the shared expenses at the end of the period, 0..1
+	Expected     utils.Money // What they consumed: their part of the shared expenses plus the items charged to them
+	Paid         utils.Money // Bills they pay: expenses on their cards, or spent without a card owner
+	AccountShare utils.Money // Their part of what the shared account paid
+	Balance      utils.Money // Paid + AccountShare - Expected: positive when the others owe them
+}
+
+// Transfer is a payment from one member to another that settles part of their balances
+type Transfer struct {
+	FromID int64
+	ToID   int64
+	Amount utils.Money
+}
+
+// Member returns the settlement of a member, or nil if they are not a member of the lobby
+func (r *SettlementResult) Member(userID int64) *MemberSettlement {
+	for _, member := range r.Members {
+		if member.UserID == userID {
+			return member
+		}
+	}
+	return nil
+}
+
+// SettingsPeriod is the part of a settlement split by one version of the lobby settings among the same members
+type SettingsPeriod struct {
+	Settings          *database.LobbySettings
+	From              time.Time   // Date of its first expense
+	SharedTotal       utils.Money // Shared expenses dated while the settings were in force
+	SharedAccountPaid utils.Money // Shared expenses paid from the shared account while the settings were in force
+	Sharing           []bool      // Whether each of the result's members was in the lobby, in their order
+	Percentages       []float64   // Each member's part of SharedTotal, 0..1, in the order of the result's members
+	Shares            []utils.Money
+}
+
+// OwnerPaidItem is an expense spent by one member whose bill another member pays, on a card they own
+type OwnerPaidItem struct {
+	Expense *database.Expense
+	Amount  utils.Money // Amount in the settlement currency
+	PayerID int64       // Telegram ID of the card owner
+}
+
+// SplitItem is an expense that is not split by the lobby ratio, with each member's share
+type SplitItem struct {
+	Expense *database.Expense
+	Amount  utils.Money   // Amount in the settlement currency
+	Shares  []utils.Money // In the order of the result's members
 }
 
 // SettlementService handles settlement calculations
 type SettlementService struct {
-	db             *database.DB
-	expenseService *ExpenseService
-	lobbyService   *LobbyService
+	db                  *database.DB
+	expenseService      *ExpenseService
+	lobbyService        *LobbyService
+	exchangeRateService *ExchangeRateService
 }
 
 // NewSettlementService creates a new settlement service
-func NewSettlementService(db *database.DB, expenseService *ExpenseService, lobbyService *LobbyService) *SettlementService {
+func NewSettlementService(db *database.DB, expenseService *ExpenseService, lobbyService *LobbyService, exchangeRateService *ExchangeRateService) *SettlementService {
 	return &SettlementService{
-		db:             db,
-		expenseService: expenseService,
-		lobbyService:   lobbyService,
+		db:                  db,
+		expenseService:      expenseService,
+		lobbyService:        lobbyService,
+		exchangeRateService: exchangeRateService,
 	}
 }
 
@@ -60,13 +117,8 @@ func (s *SettlementService) CalculateSettlement(lobbyID int64, startDate *time.T
 	}
 
 	result := &SettlementResult{
-		LobbyID:               lobbyID,
-		AccountType:           lobby.AccountType,
-		User1ID:               lobby.User1TelegramID,
-		User2ID:               lobby.User2TelegramID,
-		User1SalaryPercentage: lobby.User1SalaryPercentage,
-		User2SalaryPercentage: lobby.User2SalaryPercentage,
-		Expenses:              expenses,
+		LobbyID:  lobbyID,
+		Expenses: expenses,
 	}
 
 	if startDate != nil {
@@ -76,34 +128,13 @@ func (s *SettlementService) CalculateSettlement(lobbyID int64, startDate *time.T
 		result.PeriodEnd = *endDate
 	}
 
-	// Calculate totals per user
-	for _, expense := range expenses {
-		result.TotalExpenses += expense.Amount
-		if expense.SpenderTelegramID == lobby.User1TelegramID {
-			result.User1TotalSpent += expense.Amount
-		} else if expense.SpenderTelegramID == lobby.User2TelegramID {
-			result.User2TotalSpent += expense.Amount
-		}
-	}
-
-	// Calculate settlement based on salary percentages
-	// Use salary percentages if configured (not default 0.5/0.5), otherwise equal split
-	useSalaryPercentages := (lobby.User1SalaryPercentage != 0.5 || lobby.User2SalaryPercentage != 0.5) ||
-		lobby.AccountType == "shared"
-
-	if useSalaryPercentages {
-		// Based on salary percentage
-		result.User1Expected = result.TotalExpenses * lobby.User1SalaryPercentage
-		result.User2Expected = result.TotalExpenses * lobby.User2SalaryPercentage
-		result.User1Debt = result.User1Expected - result.User1TotalSpent
-		result.User2Debt = result.User2Expected - result.User2TotalSpent
-	} else {
-		// Equal split (default for separate accounts without custom percentages)
-		expectedPerPerson := result.TotalExpenses / 2.0
-		result.User1Debt = expectedPerPerson - result.User1TotalSpent
-		result.User2Debt = expectedPerPerson - result.User2TotalSpent
-		result.User1Expected = expectedPerPerson
-		result.User2Expected = expectedPerPerson
+	// The ratio comes from the incomes of the months in the range; an open range uses the lobby settings
+	var incomeFrom, incomeTo time.Time
+	if startDate != nil && endDate != nil {
+		incomeFrom, incomeTo = *startDate, *endDate
+	}
+	if err := s.calculate(lobby, result, incomeFrom, incomeTo); err != nil {
+		return nil, err
 	}
 
 	return result, nil
@@ -127,46 +158,510 @@ func (s *SettlementService) CalculateBillingSettlement(lobbyID int64, paymentMet
 	}
 
 	result := &SettlementResult{
-		LobbyID:               lobbyID,
-		AccountType:           lobby.AccountType,
-		User1ID:               lobby.User1TelegramID,
-		User2ID:               lobby.User2TelegramID,
-		PeriodStart:           periodStart,
-		PeriodEnd:             periodEnd,
-		User1SalaryPercentage: lobby.User1SalaryPercentage,
-		User2SalaryPercentage: lobby.User2SalaryPercentage,
-		Expenses:              expenses,
-	}
-
-	// Calculate totals per user
-	for _, expense := range expenses {
-		result.TotalExpenses += expense.Amount
-		if expense.SpenderTelegramID == lobby.User1TelegramID {
-			result.User1TotalSpent += expense.Amount
-		} else if expense.SpenderTelegramID == lobby.User2TelegramID {
-			result.User2TotalSpent += expense.Amount
-		}
-	}
-
-	// Calculate settlement based on salary percentages
-	// Use salary percentages if configured (not default 0.5/0.5), otherwise equal split
-	useSalaryPercentages := (lobby.User1SalaryPercentage != 0.5 || lobby.User2SalaryPercentage != 0.5) ||
-		lobby.AccountType == "shared"
-
-	if useSalaryPercentages {
-		// Based on salary percentage
-		result.User1Expected = result.TotalExpenses * lobby.User1SalaryPercentage
-		result.User2Expected = result.TotalExpenses * lobby.User2SalaryPercentage
-		result.User1Debt = result.User1Expected - result.User1TotalSpent
-		result.User2Debt = result.User2Expected - result.User2TotalSpent
-	} else {
-		// Equal split (default for separate accounts without custom percentages)
-		expectedPerPerson := result.TotalExpenses / 2.0
-		result.User1Debt = expectedPerPerson - result.User1TotalSpent
-		result.User2Debt = expectedPerPerson - result.User2TotalSpent
-		result.User1Expected = expectedPerPerson
-		result.User2Expected = expectedPerPerson
+		LobbyID:     lobbyID,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Expenses:    expenses,
+	}
+
+	// A statement is split by the incomes of its month, the month it closes in
+	if err := s.calculate(lobby, result, periodEnd, periodEnd); err != nil {
+		return nil, err
 	}
 
 	return result, nil
 }
+
+// calculate fills in the totals and balances of a result from its expenses, in the lobby's base currency.
+// Each expense follows the lobby settings in force on its date and is shared by the members in the lobby then,
+// so a period with a settings or membership change is split in parts. The shared expenses are split by the members' incomes of the months from incomeFrom to incomeTo
+// when they logged them, or else by those settings; zero dates skip the incomes.
+func (s *SettlementService) calculate(lobby *database.Lobby, result *SettlementResult, incomeFrom, incomeTo time.Time) error {
+	converter, err := s.exchangeRateService.NewConverter(lobby.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+
+	history, err := s.lobbyService.GetSettingsHistory(lobby.ID)
+	if err != nil {
+		return err
+	}
+	periodEnd := result.PeriodEnd
+	if periodEnd.IsZero() {
+		periodEnd = time.Now()
+	}
+	current := SettingsAt(history, periodEnd)
+
+	// Everyone in the lobby during the period, or on the date of one of its expenses, is settled, so the
+	// expenses of those who left stay theirs
+	allMemberships, err := s.lobbyService.GetMemberships(lobby.ID, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	var memberships []*database.LobbyMember
+	for _, membership := range allMemberships {
+		if membership.InLobbyBetween(result.PeriodStart, periodEnd) || coversExpense(membership, result.Expenses) {
+			memberships = append(memberships, membership)
+		}
+	}
+	periodLobby := *lobby
+	periodLobby.Members = uniqueMembers(memberships)
+	lobby = &periodLobby
+	result.AccountType = current.AccountType
+
+	// A couple sharing expenses splits them by their incomes when they logged them
+	ratios := make(map[string]*IncomeRatio)
+	incomeRatio := func(sharing []bool) (*IncomeRatio, error) {
+		if incomeFrom.IsZero() {
+			return nil, nil
+		}
+		key := fmt.Sprint(sharing)
+		if ratio, ok := ratios[key]; ok {
+			return ratio, nil
+		}
+		couple := database.Lobby{ID: lobby.ID}
+		for i, member := range lobby.Members {
+			if sharing[i] {
+				couple.Members = append(couple.Members, member)
+			}
+		}
+		ratio, err := NewIncomeService(s.db).IncomeRatio(&couple, incomeFrom, incomeTo, converter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive ratio from incomes: %w", err)
+		}
+		ratios[key] = ratio
+		return ratio, nil
+	}
+
+	endSharing := sharingOn(lobby.Members, memberships, periodEnd)
+	endRatio, err := incomeRatio(endSharing)
+	if err != nil {
+		return err
+	}
+	if len(lobby.Members) == 2 {
+		result.IncomeRatio = endRatio
+	}
+	result.Currency = converter.BaseCurrency
+	zero := utils.NewMoney(0, result.Currency)
+	result.TotalExpenses = zero
+	result.SharedTotal = zero
+	result.SharedAccountPaid = zero
+
+	// Members are indexed in joining order; own holds the items charged to each of them
+	index := make(map[int64]int, len(lobby.Members))
+	own := make([]utils.Money, len(lobby.Members))
+	currentPercentages := sharePercentages(lobby.Members, endSharing, current, endRatio)
+	result.Members = make([]*MemberSettlement, len(lobby.Members))
+	for i, member := range lobby.Members {
+		index[member.UserTelegramID] = i
+		own[i] = zero
+		result.Members[i] = &MemberSettlement{
+			UserID:       member.UserTelegramID,
+			TotalSpent:   zero,
+			Percentage:   currentPercentages[i],
+			Expected:     zero,
+			Paid:         zero,
+			AccountShare: zero,
+		}
+	}
+
+	// Expenses are grouped by the settings in force and the members in the lobby on their date
+	type periodKey struct {
+		settings *database.LobbySettings
+		sharing  string
+	}
+	periods := make(map[periodKey]*SettingsPeriod)
+	var ordered []*SettingsPeriod
+
+	methods, err := NewPaymentMethodService(s.db).GetPaymentMethodsByLobby(lobby.ID, false)
+	if err != nil {
+		return err
+	}
+	methodsByID := make(map[int64]*database.PaymentMethod, len(methods))
+	for _, method := range methods {
+		methodsByID[method.ID] = method
+	}
+
+	// Calculate totals per member: who consumed (spender) and who pays the bill (payer)
+	for _, expense := range result.Expenses {
+		amount, err := converter.ExpenseAmount(expense)
+		if err != nil {
+			return fmt.Errorf("failed to convert expense %d: %w", expense.ID, err)
+		}
+
+		if result.TotalExpenses, err = result.TotalExpenses.Add(amount); err != nil {
+			return err
+		}
+		spender, isMember := index[expense.SpenderTelegramID]
+		if isMember {
+			if result.Members[spender].TotalSpent, err = result.Members[spender].TotalSpent.Add(amount); err != nil {
+				return err
+			}
+		}
+
+		settings := SettingsAt(history, expense.ExpenseDate)
+		sharing := sharingOn(lobby.Members, memberships, expense.ExpenseDate)
+		key := periodKey{settings: settings, sharing: fmt.Sprint(sharing)}
+		period := periods[key]
+		if period == nil {
+			ratio, err := incomeRatio(sharing)
+			if err != nil {
+				return err
+			}
+			period = &SettingsPeriod{
+				Settings:          settings,
+				Sharing:           sharing,
+				SharedTotal:       zero,
+				SharedAccountPaid: zero,
+				Percentages:       sharePercentages(lobby.Members, sharing, settings, ratio),
+			}
+			periods[key] = period
+			ordered = append(ordered, period)
+		}
+		if period.From.IsZero() || expense.ExpenseDate.Before(period.From) {
+			period.From = expense.ExpenseDate
+		}
+
+		payerID := payerOf(expense, methodsByID, lobby, settings.AccountType)
+		if payer, ok := index[payerID]; ok {
+			if result.Members[payer].Paid, err = result.Members[payer].Paid.Add(amount); err != nil {
+				return err
+			}
+		} else if payerID == 0 {
+			if result.SharedAccountPaid, err = result.SharedAccountPaid.Add(amount); err != nil {
+				return err
+			}
+		}
+		// Payments from the shared account are settled with deposits, not between the members
+		if payerID != 0 && payerID != expense.SpenderTelegramID {
+			result.OwnerPaidItems = append(result.OwnerPaidItems, OwnerPaidItem{Expense: expense, Amount: amount, PayerID: payerID})
+		}
+
+		// Expenses with their own split are charged directly to each member
+		if expense.IsShared() || !isMember {
+			if result.SharedTotal, err = result.SharedTotal.Add(amount); err != nil {
+				return err
+			}
+			if period.SharedTotal, err = period.SharedTotal.Add(amount); err != nil {
+				return err
+			}
+			if payerID == 0 {
+				if period.SharedAccountPaid, err = period.SharedAccountPaid.Add(amount); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		shares, err := splitItemShares(expense, amount, spender, period.Percentages, sharing)
+		if err != nil {
+			return err
+		}
+		item := SplitItem{Expense: expense, Amount: amount, Shares: shares}
+		for i, share := range item.Shares {
+			if own[i], err = own[i].Add(share); err != nil {
+				return err
+			}
+			if payerID == 0 {
+				// The shared account paid for it on behalf of whoever it is charged to
+				if result.Members[i].AccountShare, err = result.Members[i].AccountShare.Add(share); err != nil {
+					return err
+				}
+			}
+		}
+		result.SplitItems = append(result.SplitItems, item)
+	}
+
+	// Each part of the period is split by the settings in force in it, oldest first
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].From.Before(ordered[j].From) })
+	for _, settings := range history {
+		for _, period := range ordered {
+			if period.Settings != settings {
+				continue
+			}
+			// Shares are allocated in cents so they always add up to the total
+			// The shared account is funded by the same ratio
+			period.Shares = period.SharedTotal.Allocate(period.Percentages...)
+			accountShares := period.SharedAccountPaid.Allocate(period.Percentages...)
+			for i, member := range result.Members {
+				if member.Expected, err = member.Expected.Add(period.Shares[i]); err != nil {
+					return err
+				}
+				if member.AccountShare, err = member.AccountShare.Add(accountShares[i]); err != nil {
+					return err
+				}
+			}
+			result.SettingsPeriods = append(result.SettingsPeriods, *period)
+		}
+	}
+
+	// Balances compare what each member consumed with what they paid; what the shared account paid is
+	// settled with the members' deposits into it
+	balances := make([]utils.Money, len(result.Members))
+	for i, member := range result.Members {
+		if member.Expected, err = member.Expected.Add(own[i]); err != nil {
+			return err
+		}
+		if member.Balance, err = utils.Sum(result.Currency, member.Paid, member.AccountShare, member.Expected.Neg()); err != nil {
+			return err
+		}
+		balances[i] = member.Balance
+	}
+	result.Transfers, err = settleBalances(lobby.MemberIDs(), balances)
+	return err
+}
+
+// sharingOn reports which of some members, in order, share the expenses of a day: those with a membership
+// covering it, or all of them when nobody was in the lobby yet (expenses dated before it was created)
+func sharingOn(members, memberships []*database.LobbyMember, date time.Time) []bool {
+	sharing := make([]bool, len(members))
+	anyone := false
+	for i, member := range members {
+		for _, membership := range memberships {
+			if membership.UserTelegramID == member.UserTelegramID && membership.InLobbyOn(date) {
+				sharing[i], anyone = true, true
+				break
+			}
+		}
+	}
+	if !anyone {
+		for i := range sharing {
+			sharing[i] = true
+		}
+	}
+	return sharing
+}
+
+// coversExpense reports whether a membership covers the date of one of some expenses
+func coversExpense(membership *database.LobbyMember, expenses []*database.Expense) bool {
+	for _, expense := range expenses {
+		if membership.InLobbyOn(expense.ExpenseDate) {
+			return true
+		}
+	}
+	return false
+}
+
+// sharePercentages returns each member's part (0..1) of the shared expenses under some settings, in joining
+// order. Only the members sharing them take a part: two of them split by the incomes ratio when given or else
+// the settings' salary percentages, and more by their share weights. Weights that add up to zero split evenly.
+func sharePercentages(members []*database.LobbyMember, sharing []bool, settings *database.LobbySettings, incomeRatio *IncomeRatio) []float64 {
+	var couple []int
+	for i := range members {
+		if sharing[i] {
+			couple = append(couple, i)
+		}
+	}
+
+	weights := make([]float64, len(members))
+	switch {
+	case len(couple) == 2 && incomeRatio != nil:
+		weights[couple[0]], weights[couple[1]] = incomeRatio.User1Percentage, incomeRatio.User2Percentage
+	case len(couple) == 2:
+		weights[couple[0]], weights[couple[1]] = settings.User1SalaryPercentage, settings.User2SalaryPercentage
+	default:
+		for i, member := range members {
+			if sharing[i] {
+				weights[i] = member.ShareWeight
+			}
+		}
+	}
+
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+	for i := range weights {
+		switch {
+		case total > 0:
+			weights[i] /= total
+		case sharing[i]:
+			weights[i] = 1 / float64(len(couple))
+		}
+	}
+	return weights
+}
+
+// splitItemShares charges an expense with its own split mode to the members: the spender (an index into
+// percentages) takes their part, and the rest goes to the other members sharing it by their share percentages
+func splitItemShares(expense *database.Expense, amount utils.Money, spender int, percentages []float64, sharing []bool) ([]utils.Money, error) {
+	spenderShare, otherShare := SplitShares(expense, amount)
+
+	others := make([]float64, len(percentages))
+	var total float64
+	for i, percentage := range percentages {
+		if i != spender {
+			others[i] = percentage
+			total += percentage
+		}
+	}
+	if total == 0 {
+		// The other members have no share of shared expenses: those sharing it take this one evenly
+		for i := range others {
+			if i != spender && sharing[i] {
+				others[i] = 1
+				total++
+			}
+		}
+	}
+	if total == 0 {
+		// Alone in the lobby, the spender takes it all
+		others[spender] = 1
+	}
+
+	shares := otherShare.Allocate(others...)
+	var err error
+	if shares[spender], err = shares[spender].Add(spenderShare); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// maxExactSettlement is how many members with a balance settleBalances finds the fewest transfers for;
+// the search doubles with each member, so larger groups are settled greedily instead
+const maxExactSettlement = 16
+
+// settleBalances returns the fewest transfers that bring the members' balances (positive when the others owe
+// them) to zero. Members are split into as many groups whose balances add up to zero as possible, since a group
+// of n members settles with n-1 transfers, and each group is settled greedily. Ties go to the member who
+// joined first.
+func settleBalances(memberIDs []int64, balances []utils.Money) ([]Transfer, error) {
+	if _, err := utils.Sum("", balances...); err != nil {
+		return nil, err
+	}
+
+	var open []int
+	for i, balance := range balances {
+		if !balance.IsZero() {
+			open = append(open, i)
+		}
+	}
+	if len(open) > maxExactSettlement {
+		return settleGreedily(memberIDs, balances, open)
+	}
+
+	var transfers []Transfer
+	for _, group := range zeroSumGroups(balances, open) {
+		settled, err := settleGreedily(memberIDs, balances, group)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, settled...)
+	}
+	return transfers, nil
+}
+
+// zeroSumGroups splits members (indexes of balances) into the most groups whose balances add up to zero,
+// ordered by their first member. Balances not adding up to zero, such as a rounding remainder, are left in
+// one more group.
+func zeroSumGroups(balances []utils.Money, members []int) [][]int {
+	// most[set] is the most zero-sum groups the members in set can be split into, counting set itself when
+	// it adds up to zero but not when it is only a remainder
+	n := len(members)
+	sums := make([]int64, 1<<n)
+	most := make([]int, 1<<n)
+	zero := func(set int) int {
+		if sums[set] == 0 {
+			return 1
+		}
+		return 0
+	}
+	for set := 1; set < 1<<n; set++ {
+		sums[set] = sums[set&(set-1)] + balances[members[bits.TrailingZeros(uint(set))]].Amount
+		for i := 0; i < n; i++ {
+			if set&(1<<i) != 0 && most[set^(1<<i)] > most[set] {
+				most[set] = most[set^(1<<i)]
+			}
+		}
+		most[set] += zero(set)
+	}
+
+	// Take out members one by one along the best split, closing a group whenever what was taken out adds
+	// up to zero
+	order := make([]int, 0, n)
+	for set := 1<<n - 1; set != 0; {
+		for i := 0; i < n; i++ {
+			if set&(1<<i) != 0 && most[set^(1<<i)] == most[set]-zero(set) {
+				order = append(order, members[i])
+				set ^= 1 << i
+				break
+			}
+		}
+	}
+	var groups [][]int
+	var group []int
+	var sum int64
+	for i := len(order) - 1; i >= 0; i-- {
+		group = append(group, order[i])
+		sum += balances[order[i]].Amount
+		if sum == 0 {
+			sort.Ints(group)
+			groups = append(groups, group)
+			group = nil
+		}
+	}
+	if len(group) > 0 {
+		sort.Ints(group)
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
+
+// settleGreedily returns transfers between some members (indexes of balances) that bring their balances to zero:
+// the member who owes the most pays the one owed the most until one of them is settled, so there are at most one
+// fewer transfers than members. Ties go to the member who joined first.
+func settleGreedily(memberIDs []int64, balances []utils.Money, members []int) ([]Transfer, error) {
+	remaining := make(map[int]utils.Money, len(members))
+	for _, i := range members {
+		remaining[i] = balances[i]
+	}
+
+	var transfers []Transfer
+	for {
+		debtor, creditor := -1, -1
+		for _, i := range members {
+			balance := remaining[i]
+			if balance.IsNegative() && (debtor < 0 || balance.Amount < remaining[debtor].Amount) {
+				debtor = i
+			}
+			if balance.IsPositive() && (creditor < 0 || balance.Amount > remaining[creditor].Amount) {
+				creditor = i
+			}
+		}
+		if debtor < 0 || creditor < 0 {
+			return transfers, nil
+		}
+
+		amount := remaining[debtor].Neg()
+		if remaining[creditor].Amount < amount.Amount {
+			amount = remaining[creditor]
+		}
+		transfers = append(transfers, Transfer{FromID: memberIDs[debtor], ToID: memberIDs[creditor], Amount: amount})
+		var err error
+		if remaining[debtor], err = remaining[debtor].Add(amount); err != nil {
+			return nil, err
+		}
+		if remaining[creditor], err = remaining[creditor].Sub(amount); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// payerOf returns who pays an expense's bill: the member who owns its payment method, the shared account (0)
+// when the lobby has a shared account and no member owns the payment method, or else the spender
+func payerOf(expense *database.Expense, methods map[int64]*database.PaymentMethod, lobby *database.Lobby, accountType string) int64 {
+	var method *database.PaymentMethod
+	if expense.PaymentMethodID.Valid {
+		method = methods[expense.PaymentMethodID.Int64]
+	}
+	switch {
+	case method != nil && method.OwnerTelegramID.Valid:
+		owner := method.OwnerTelegramID.Int64
+		if lobby.IsMember(owner) {
+			return owner
+		}
+	case accountType == "shared":
+		return 0
+	}
+	return expense.SpenderTelegramID
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"botGastosPareja/pkg/utils"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSettleBalances(t *testing.T) {
+	tests := []struct {
+		name     string
+		balances []int64 // Of members 1, 2, ...; positive when the others owe them
+		want     []string
+	}{
+		{"two members", []int64{500, -500}, []string{"2->1 5.00"}},
+		{"all settled", []int64{0, 0, 0}, nil},
+		{"nobody", nil, nil},
+		{"one settled member", []int64{0, 300, -300}, []string{"3->2 3.00"}},
+		{"one creditor", []int64{600, -200, -400}, []string{"3->1 4.00", "2->1 2.00"}},
+		{"one debtor", []int64{-900, 400, 500}, []string{"1->3 5.00", "1->2 4.00"}},
+		{"no zero-sum subgroup", []int64{500, 300, -400, -400}, []string{"3->1 4.00", "4->2 3.00", "4->1 1.00"}},
+		// Greedily, the 6.00 debt pays the 5.00 credit first and takes 4 transfers
+		{"pairs before the largest", []int64{500, 400, 200, -600, -500}, []string{"5->1 5.00", "4->2 4.00", "4->3 2.00"}},
+		{"two pairs", []int64{100, -250, 250, -100}, []string{"4->1 1.00", "2->3 2.50"}},
+		{"ties go to who joined first", []int64{100, 100, -100, -100}, []string{"3->1 1.00", "4->2 1.00"}},
+		{"rounding remainder", []int64{667, -334, -333}, []string{"2->1 3.34", "3->1 3.33"}},
+		// Balances not adding up to zero leave the remainder unsettled instead of inventing a transfer
+		{"cent left over", []int64{334, -167, -166}, []string{"2->1 1.67", "3->1 1.66"}},
+		{"cent owed over", []int64{-1, 0, 0}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memberIDs := make([]int64, len(tt.balances))
+			balances := make([]utils.Money, len(tt.balances))
+			for i, amount := range tt.balances {
+				memberIDs[i] = int64(i + 1)
+				balances[i] = utils.NewMoney(amount, "ARS")
+			}
+
+			transfers, err := settleBalances(memberIDs, balances)
+			if err != nil {
+				t.Fatalf("settleBalances: %v", err)
+			}
+			var got []string
+			for _, transfer := range transfers {
+				got = append(got, fmt.Sprintf("%d->%d %s", transfer.FromID, transfer.ToID, transfer.Amount.Format()))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("transfers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettleBalancesFewestTransfers(t *testing.T) {
+	// Every split of 8 members into pairs owing each other different amounts settles with one transfer per pair
+	balances := []utils.Money{}
+	memberIDs := []int64{}
+	for i, amount := range []int64{700, -300, 500, -100, 300, -500, 100, -700} {
+		memberIDs = append(memberIDs, int64(i+1))
+		balances = append(balances, utils.NewMoney(amount, "USD"))
+	}
+	transfers, err := settleBalances(memberIDs, balances)
+	if err != nil {
+		t.Fatalf("settleBalances: %v", err)
+	}
+	if len(transfers) != 4 {
+		t.Errorf("got %d transfers, want 4: %+v", len(transfers), transfers)
+	}
+	settled := make(map[int64]int64)
+	for _, transfer := range transfers {
+		settled[transfer.FromID] += transfer.Amount.Amount
+		settled[transfer.ToID] -= transfer.Amount.Amount
+	}
+	for i, balance := range balances {
+		if settled[memberIDs[i]]+balance.Amount != 0 {
+			t.Errorf("member %d is left with %d", memberIDs[i], settled[memberIDs[i]]+balance.Amount)
+		}
+	}
+}
+
+func TestSettleBalancesMixedCurrencies(t *testing.T) {
+	_, err := settleBalances([]int64{1, 2}, []utils.Money{utils.NewMoney(100, "ARS"), utils.NewMoney(-100, "USD")})
+	if !errors.Is(err, utils.ErrCurrencyMismatch) {
+		t.Errorf("err = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestCalculateSettlementThreeMembers(t *testing.T) {
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 3)
+	expenses := NewExpenseService(db)
+	settlements := newTestSettlementService(db)
+
+	// 10.00 split three ways leaves a cent over, which the settlement keeps exact
+	date := utils.CalendarDate(time.Now())
+	if _, err := expenses.CreateExpense(lobby.ID, 1, utils.NewMoney(1000, "ARS"), "", "", date, nil, nil, false); err != nil {
+		t.Fatalf("CreateExpense: %v", err)
+	}
+	start, end := date.AddDate(0, 0, -1), date.AddDate(0, 0, 1)
+	result, err := settlements.CalculateSettlement(lobby.ID, &start, &end)
+	if err != nil {
+		t.Fatalf("CalculateSettlement: %v", err)
+	}
+
+	var total int64
+	for _, member := range result.Members {
+		total += member.Balance.Amount
+	}
+	if total != 0 {
+		t.Errorf("balances add up to %d, want 0", total)
+	}
+	var got []string
+	for _, transfer := range result.Transfers {
+		got = append(got, fmt.Sprintf("%d->%d %s", transfer.FromID, transfer.ToID, transfer.Amount.Format()))
+	}
+	if want := []string{"2->1 3.33", "3->1 3.33"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("transfers = %v, want %v", got, want)
+	}
+}
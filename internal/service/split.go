@@ -0,0 +1,95 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"fmt"
+)
+
+// ExpenseSplit is how an expense's cost is divided between the lobby members
+type ExpenseSplit struct {
+	Mode    string       // database.SplitShared, SplitPersonal, SplitPartner or SplitCustom
+	Percent float64      // Spender's share in percent, for SplitCustom without Amount
+	Amount  *utils.Money // Spender's share as an amount, for SplitCustom
+}
+
+// validate checks a split against the amount of the expense it applies to
+func (split *ExpenseSplit) validate(amount utils.Money) error {
+	switch split.Mode {
+	case database.SplitShared, database.SplitPersonal, database.SplitPartner:
+		return nil
+	case database.SplitCustom:
+	default:
+		return fmt.Errorf("invalid split mode: %s", split.Mode)
+	}
+
+	if split.Amount == nil {
+		if split.Percent < 0 || split.Percent > 100 {
+			return fmt.Errorf("split percentage must be between 0 and 100")
+		}
+		return nil
+	}
+
+	if split.Amount.Currency != "" && split.Amount.Currency != amount.Currency {
+		return fmt.Errorf("split amount must be in %s", amount.Currency)
+	}
+	cmp, err := split.Amount.Cmp(amount)
+	if err != nil {
+		return fmt.Errorf("failed to compare split amount: %w", err)
+	}
+	if split.Amount.IsNegative() || cmp > 0 {
+		return fmt.Errorf("split amount must be between 0 and %s", amount.String())
+	}
+	return nil
+}
+
+// columns returns the split_mode, split_percent and split_amount_minor values to store
+func (split *ExpenseSplit) columns() (string, sql.NullFloat64, sql.NullInt64) {
+	if split == nil {
+		return database.SplitShared, sql.NullFloat64{}, sql.NullInt64{}
+	}
+	if split.Mode != database.SplitCustom {
+		return split.Mode, sql.NullFloat64{}, sql.NullInt64{}
+	}
+	if split.Amount != nil {
+		return split.Mode, sql.NullFloat64{}, sql.NullInt64{Int64: split.Amount.Amount, Valid: true}
+	}
+	return split.Mode, sql.NullFloat64{Float64: split.Percent, Valid: true}, sql.NullInt64{}
+}
+
+// asPercentOf turns an amount split into the equivalent percentage of a purchase total,
+// so it can apply to each installment of the purchase
+func (split *ExpenseSplit) asPercentOf(total utils.Money) (*ExpenseSplit, error) {
+	if split == nil || split.Mode != database.SplitCustom || split.Amount == nil {
+		return split, nil
+	}
+	ratio, err := split.Amount.Ratio(total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert split amount to a percentage: %w", err)
+	}
+	return &ExpenseSplit{Mode: database.SplitCustom, Percent: ratio * 100}, nil
+}
+
+// SplitShares divides an expense's amount, already converted to the settlement currency,
+// into the spender's share and the other member's share according to the expense's split mode.
+// Shared expenses are split evenly here; settlements split them by the lobby ratio instead.
+func SplitShares(expense *database.Expense, amount utils.Money) (spender utils.Money, other utils.Money) {
+	var parts []utils.Money
+	switch expense.SplitMode {
+	case database.SplitPersonal:
+		parts = amount.Allocate(1, 0)
+	case database.SplitPartner:
+		parts = amount.Allocate(0, 1)
+	case database.SplitCustom:
+		ratio := expense.SplitPercent.Float64 / 100
+		if expense.SplitAmount.Valid && expense.Amount.Amount != 0 {
+			// The split amount is stored in the expense's own currency
+			ratio = float64(expense.SplitAmount.Int64) / float64(expense.Amount.Amount)
+		}
+		parts = amount.Allocate(ratio, 1-ratio)
+	default:
+		parts = amount.Split(2)
+	}
+	return parts[0], parts[1]
+}
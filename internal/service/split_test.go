@@ -0,0 +1,135 @@
+package service
+
+import (
+	"botGastosPareja/internal/database"
+	"botGastosPareja/pkg/utils"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSplitShares(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		percent float64 // SplitPercent, when not zero
+		amount  int64   // SplitAmount in ARS, when not zero
+		total   int64   // Converted amount, in USD
+		spender int64
+		other   int64
+	}{
+		{"shared", database.SplitShared, 0, 0, 1000, 500, 500},
+		{"shared odd cent", database.SplitShared, 0, 0, 1001, 501, 500},
+		{"legacy without mode", "", 0, 0, 1000, 500, 500},
+		{"personal", database.SplitPersonal, 0, 0, 1000, 1000, 0},
+		{"partner", database.SplitPartner, 0, 0, 1000, 0, 1000},
+		{"percent", database.SplitCustom, 70, 0, 1000, 700, 300},
+		{"percent with remainder", database.SplitCustom, 33.3, 0, 1001, 333, 668},
+		{"amount", database.SplitCustom, 0, 4000, 1000, 333, 667},
+		{"whole amount", database.SplitCustom, 0, 12000, 1000, 1000, 0},
+		{"zero amount", database.SplitCustom, 0, 0, 1000, 0, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Expenses of 120.00 ARS, converted to USD
+			expense := &database.Expense{Amount: utils.NewMoney(12000, "ARS"), SplitMode: tt.mode}
+			if tt.percent != 0 {
+				expense.SplitPercent = sql.NullFloat64{Float64: tt.percent, Valid: true}
+			}
+			if tt.mode == database.SplitCustom && tt.percent == 0 {
+				expense.SplitAmount = sql.NullInt64{Int64: tt.amount, Valid: true}
+			}
+
+			spender, other := SplitShares(expense, utils.NewMoney(tt.total, "USD"))
+			if spender != utils.NewMoney(tt.spender, "USD") || other != utils.NewMoney(tt.other, "USD") {
+				t.Errorf("shares = %s / %s, want %d / %d USD", spender, other, tt.spender, tt.other)
+			}
+		})
+	}
+}
+
+func TestSplitItemShares(t *testing.T) {
+	third := 1.0 / 3
+	tests := []struct {
+		name        string
+		mode        string
+		percent     float64
+		amount      int64
+		spender     int
+		percentages []float64
+		sharing     []bool
+		want        []int64
+	}{
+		{"personal", database.SplitPersonal, 0, 1000, 0, []float64{third, third, third}, []bool{true, true, true}, []int64{1000, 0, 0}},
+		{"partner by ratio", database.SplitPartner, 0, 1000, 0, []float64{0.5, 0.3, 0.2}, []bool{true, true, true}, []int64{0, 600, 400}},
+		{"partner with remainder", database.SplitPartner, 0, 1001, 0, []float64{third, third, third}, []bool{true, true, true}, []int64{0, 501, 500}},
+		{"custom by middle member", database.SplitCustom, 50, 1000, 1, []float64{third, third, third}, []bool{true, true, true}, []int64{250, 500, 250}},
+		{"shared split evenly here", database.SplitShared, 0, 1000, 2, []float64{0.5, 0.5, 0}, []bool{true, true, true}, []int64{250, 250, 500}},
+		{"others without share", database.SplitPartner, 0, 900, 0, []float64{1, 0, 0}, []bool{true, true, false}, []int64{0, 900, 0}},
+		{"nobody else sharing", database.SplitPartner, 0, 900, 0, []float64{1, 0}, []bool{true, false}, []int64{900, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expense := &database.Expense{Amount: utils.NewMoney(tt.amount, "ARS"), SplitMode: tt.mode}
+			if tt.percent != 0 {
+				expense.SplitPercent = sql.NullFloat64{Float64: tt.percent, Valid: true}
+			}
+
+			shares, err := splitItemShares(expense, expense.Amount, tt.spender, tt.percentages, tt.sharing)
+			if err != nil {
+				t.Fatalf("splitItemShares: %v", err)
+			}
+			var got []int64
+			var sum int64
+			for _, share := range shares {
+				got = append(got, share.Amount)
+				sum += share.Amount
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("shares = %v, want %v", got, tt.want)
+			}
+			if sum != tt.amount {
+				t.Errorf("shares add up to %d, want %d", sum, tt.amount)
+			}
+		})
+	}
+}
+
+func TestCustomSplitSettlement(t *testing.T) {
+	db := newTestDB(t)
+	lobby := newTestLobby(t, db, 2)
+	expenses := NewExpenseService(db)
+	settlements := newTestSettlementService(db)
+	date := utils.CalendarDate(time.Now())
+
+	// Member 1 pays 120.00 of which 40.00 is theirs, and member 2 pays 10.00 that is all member 1's
+	amount := utils.NewMoney(4000, "")
+	if _, err := expenses.CreateExpense(lobby.ID, 1, utils.NewMoney(12000, "ARS"), "dinner", "", date, nil,
+		&ExpenseSplit{Mode: database.SplitCustom, Amount: &amount}, false); err != nil {
+		t.Fatalf("CreateExpense: %v", err)
+	}
+	if _, err := expenses.CreateExpense(lobby.ID, 2, utils.NewMoney(1000, "ARS"), "gift", "", date, nil,
+		&ExpenseSplit{Mode: database.SplitPartner}, false); err != nil {
+		t.Fatalf("CreateExpense: %v", err)
+	}
+	tooMuch := utils.NewMoney(20000, "ARS")
+	if _, err := expenses.CreateExpense(lobby.ID, 1, utils.NewMoney(12000, "ARS"), "", "", date, nil,
+		&ExpenseSplit{Mode: database.SplitCustom, Amount: &tooMuch}, false); err == nil {
+		t.Errorf("a split amount over the expense was accepted")
+	}
+
+	start, end := date.AddDate(0, 0, -1), date.AddDate(0, 0, 1)
+	result, err := settlements.CalculateSettlement(lobby.ID, &start, &end)
+	if err != nil {
+		t.Fatalf("CalculateSettlement: %v", err)
+	}
+	if len(result.Transfers) != 1 {
+		t.Fatalf("got %d transfers, want 1", len(result.Transfers))
+	}
+	if transfer := result.Transfers[0]; transfer.FromID != 2 || transfer.ToID != 1 || transfer.Amount.Amount != 7000 {
+		t.Errorf("transfer = %d->%d %s, want 2->1 70.00", transfer.FromID, transfer.ToID, transfer.Amount)
+	}
+}
@@ -3,28 +3,52 @@ CREATE TABLE IF NOT EXISTS users (
     telegram_id INTEGER PRIMARY KEY,
     username TEXT,
     display_name TEXT,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    active_lobby_id INTEGER,  -- Lobby private chat commands act on, chosen with /use
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (active_lobby_id) REFERENCES lobbies(id)
 );
 
--- Lobbies (couples)
+-- Lobbies (couples, roommates, families); user1 is the owner, members are in lobby_members
 CREATE TABLE IF NOT EXISTS lobbies (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,  -- Optional, to tell a member's lobbies apart
     user1_telegram_id INTEGER,
     user2_telegram_id INTEGER,
     account_type TEXT CHECK(account_type IN ('separate', 'shared')),
     user1_salary_percentage REAL DEFAULT 0.5,
     user2_salary_percentage REAL DEFAULT 0.5,
+    base_currency TEXT NOT NULL DEFAULT 'ARS',  -- Currency reports and settlements are converted to
+    rate_type TEXT NOT NULL DEFAULT 'oficial',  -- Default exchange rate type (oficial, mep, tarjeta, ...)
+    quick_capture BOOLEAN NOT NULL DEFAULT 0,   -- Create expenses from plain messages like "1500 super visa"; on for private chats
+    categories_seeded BOOLEAN NOT NULL DEFAULT 0, -- Default categories already added
+    budget_thresholds TEXT NOT NULL DEFAULT '80,100', -- Budget usage percentages that post a warning
+    max_members INTEGER NOT NULL DEFAULT 2,     -- How many members can join
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user1_telegram_id) REFERENCES users(telegram_id),
     FOREIGN KEY (user2_telegram_id) REFERENCES users(telegram_id)
 );
 
+-- Memberships of each lobby, in joining order; a member who left and came back has a row for each time
+CREATE TABLE IF NOT EXISTS lobby_members (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    user_telegram_id INTEGER NOT NULL,
+    share_weight REAL NOT NULL DEFAULT 1,  -- Relative share of shared expenses with more than two members
+    joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    left_at TIMESTAMP,  -- NULL while a member; ended memberships are kept for the settlements they were in
+    removed_by INTEGER,  -- Owner who removed the member; they cannot rejoin a group lobby without an invitation
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id),
+    FOREIGN KEY (removed_by) REFERENCES users(telegram_id)
+);
+
 -- Categories (predefined + custom)
 CREATE TABLE IF NOT EXISTS categories (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     lobby_id INTEGER,
     name TEXT NOT NULL,
     is_default BOOLEAN DEFAULT 0,
+    is_archived BOOLEAN NOT NULL DEFAULT 0,  -- Hidden from lists and suggestions, kept on old expenses
     FOREIGN KEY (lobby_id) REFERENCES lobbies(id)
 );
 
@@ -36,6 +60,12 @@ CREATE TABLE IF NOT EXISTS payment_methods (
     type TEXT CHECK(type IN ('credit_card', 'debit_card', 'cash', 'bank_transfer', 'other')),
     owner_telegram_id INTEGER,  -- NULL if shared
     closing_day INTEGER,  -- Day of month when statement closes (1-31, NULL for non-credit cards)
+    due_day INTEGER,  -- Day of month when the statement is due (1-31)
+    due_offset_days INTEGER,  -- Or days after the closing when the statement is due
+    closing_shift TEXT NOT NULL DEFAULT 'none',  -- Closing on a weekend/holiday moves: 'none', 'before' or 'after'
+    credit_limit_minor INTEGER,  -- Credit limit in minor units (NULL if not set)
+    credit_limit_currency TEXT,  -- Currency of the credit limit
+    credit_warn_percent INTEGER NOT NULL DEFAULT 80,  -- Usage of the limit that warns when adding expenses
     billing_cycle_days INTEGER DEFAULT 30,  -- Billing cycle length in days
     is_active BOOLEAN DEFAULT 1,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -49,21 +79,306 @@ CREATE TABLE IF NOT EXISTS expenses (
     lobby_id INTEGER,
     spender_telegram_id INTEGER,
     payment_method_id INTEGER,
-    amount REAL NOT NULL,
+    amount_minor INTEGER NOT NULL,  -- Amount in minor units (cents)
+    currency TEXT NOT NULL DEFAULT 'ARS',
     description TEXT,
-    category TEXT,
+    category TEXT,              -- Name of category_id, kept in sync on rename/merge
+    category_id INTEGER,
     expense_date DATE NOT NULL,
     billing_period_start DATE,  -- When this expense's billing period starts
     billing_period_end DATE,    -- When this expense's billing period ends
+    parent_expense_id INTEGER,  -- First installment of the purchase (NULL for the parent itself)
+    installment_number INTEGER, -- 1..installment_count for installment purchases (cuotas)
+    installment_count INTEGER,  -- Total number of installments, NULL for single payments
+    installment_interest REAL,  -- Interest in percent added to an installment purchase, NULL if unknown (older purchases)
+    split_mode TEXT NOT NULL DEFAULT 'shared' CHECK(split_mode IN ('shared', 'personal', 'partner', 'custom')),
+    split_percent REAL,         -- Spender's share in percent (custom split)
+    split_amount_minor INTEGER, -- Spender's share in minor units of the expense currency (custom split)
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (spender_telegram_id) REFERENCES users(telegram_id),
+    FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id),
+    FOREIGN KEY (parent_expense_id) REFERENCES expenses(id),
+    FOREIGN KEY (category_id) REFERENCES categories(id)
+);
+
+-- Recurring expenses (rent, subscriptions, etc.)
+CREATE TABLE IF NOT EXISTS recurring_expenses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    spender_telegram_id INTEGER NOT NULL,
+    payment_method_id INTEGER,
+    amount_minor INTEGER NOT NULL,  -- Amount in minor units (cents)
+    currency TEXT NOT NULL DEFAULT 'ARS',
+    description TEXT,
+    category TEXT,
+    cadence TEXT CHECK(cadence IN ('weekly', 'monthly', 'yearly')) NOT NULL,
+    day_of_month INTEGER,       -- Day of month for monthly/yearly cadences (clamped to month length)
+    start_date DATE NOT NULL,
+    end_date DATE,              -- NULL = no end
+    next_run_date DATE NOT NULL,
+    last_run_date DATE,
+    is_active BOOLEAN DEFAULT 1,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
     FOREIGN KEY (spender_telegram_id) REFERENCES users(telegram_id),
     FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id)
 );
 
+-- One row per materialized run, so missed runs are caught up exactly once
+CREATE TABLE IF NOT EXISTS recurring_expense_runs (
+    recurring_expense_id INTEGER NOT NULL,
+    run_date DATE NOT NULL,
+    expense_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (recurring_expense_id, run_date),
+    FOREIGN KEY (recurring_expense_id) REFERENCES recurring_expenses(id)
+);
+
+-- Exchange rates: 1 unit of currency = rate units of base_currency on rate_date
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    base_currency TEXT NOT NULL,
+    rate_type TEXT NOT NULL DEFAULT 'oficial',  -- oficial, mep, blue, tarjeta, ...
+    rate_date DATE NOT NULL,    -- YYYY-MM-DD; the rate applies from this day until a newer one
+    rate REAL NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (lobby_id, currency, base_currency, rate_type, rate_date),
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id)
+);
+
+-- Settlement payments: money actually transferred between members to settle their balance
+CREATE TABLE IF NOT EXISTS settlement_payments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    payer_telegram_id INTEGER NOT NULL,
+    payee_telegram_id INTEGER NOT NULL,
+    amount_minor INTEGER NOT NULL,  -- Minor units (cents)
+    currency TEXT NOT NULL,
+    payment_date DATE NOT NULL,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (payer_telegram_id) REFERENCES users(telegram_id),
+    FOREIGN KEY (payee_telegram_id) REFERENCES users(telegram_id)
+);
+
+-- Money members put into the shared account of a lobby
+CREATE TABLE IF NOT EXISTS contributions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    user_telegram_id INTEGER NOT NULL,
+    amount_minor INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    contribution_date DATE NOT NULL,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id)
+);
+
+-- Categorization rules: a keyword or regex on the description picks the category of new expenses
+CREATE TABLE IF NOT EXISTS category_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    pattern TEXT NOT NULL,           -- Keyword (whole words, any case/accents) or regular expression
+    is_regex BOOLEAN NOT NULL DEFAULT 0,
+    category_id INTEGER NOT NULL,
+    payment_method_id INTEGER,       -- Also set this payment method when none was given
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (category_id) REFERENCES categories(id),
+    FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id)
+);
+
+-- Monthly budgets: a limit per category, or for the whole lobby when category_id is NULL
+CREATE TABLE IF NOT EXISTS budgets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    category_id INTEGER,             -- NULL = overall budget of the lobby
+    amount_minor INTEGER NOT NULL,   -- Monthly limit in minor units
+    currency TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (category_id) REFERENCES categories(id)
+);
+
+-- Budget thresholds already announced, so each one warns once per month
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    budget_id INTEGER NOT NULL,
+    month TEXT NOT NULL,             -- YYYY-MM
+    threshold INTEGER NOT NULL,      -- Percent of the limit
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (budget_id, month, threshold),
+    FOREIGN KEY (budget_id) REFERENCES budgets(id)
+);
+
+-- Statement due reminders already posted, one per payment method and billing cycle
+CREATE TABLE IF NOT EXISTS payment_reminders (
+    payment_method_id INTEGER NOT NULL,
+    period_end DATE NOT NULL,        -- Closing date of the reminded statement
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (payment_method_id, period_end),
+    FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id)
+);
+
+-- Closing dates published by the bank for specific statements, overriding the fixed closing day
+CREATE TABLE IF NOT EXISTS billing_cycles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    payment_method_id INTEGER NOT NULL,
+    month TEXT NOT NULL,             -- Statement month (YYYY-MM)
+    closing_date DATE NOT NULL,
+    due_date DATE,                   -- NULL to use the payment method's due day
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (payment_method_id, month),
+    FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id)
+);
+
+-- Non-business days of a lobby, besides weekends
+CREATE TABLE IF NOT EXISTS holidays (
+    lobby_id INTEGER NOT NULL,
+    date DATE NOT NULL,
+    name TEXT,
+    PRIMARY KEY (lobby_id, date),
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id)
+);
+
+-- Card statements checked against the bank's total; editing their expenses asks for confirmation
+CREATE TABLE IF NOT EXISTS statement_reconciliations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    payment_method_id INTEGER NOT NULL,
+    month TEXT NOT NULL,                    -- Statement month (YYYY-MM)
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    statement_total_minor INTEGER NOT NULL, -- Total on the bank's statement
+    logged_total_minor INTEGER NOT NULL,    -- Total of the logged expenses when reconciled
+    currency TEXT NOT NULL,
+    adjustment_expense_id INTEGER,          -- Expense added for the difference (fees, taxes)
+    reconciled_by INTEGER NOT NULL,
+    reconciled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (payment_method_id, month),
+    FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id),
+    FOREIGN KEY (adjustment_expense_id) REFERENCES expenses(id) ON DELETE SET NULL,
+    FOREIGN KEY (reconciled_by) REFERENCES users(telegram_id)
+);
+
+-- Payment method used when an expense doesn't name one: a member's default, or their default for a category
+CREATE TABLE IF NOT EXISTS payment_method_defaults (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    user_telegram_id INTEGER NOT NULL,
+    category_id INTEGER,  -- NULL for the member's default for any category
+    payment_method_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id),
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+    FOREIGN KEY (payment_method_id) REFERENCES payment_methods(id) ON DELETE CASCADE
+);
+
+-- Account type and ratio of a lobby in force from a date until the next change, so past periods keep theirs
+CREATE TABLE IF NOT EXISTS lobby_settings_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    effective_from DATE NOT NULL,
+    account_type TEXT NOT NULL,  -- "separate" or "shared"
+    user1_salary_percentage REAL NOT NULL,
+    user2_salary_percentage REAL NOT NULL,
+    changed_by INTEGER,  -- NULL for the settings the lobby was created or migrated with
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (lobby_id, effective_from),
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (changed_by) REFERENCES users(telegram_id)
+);
+
+-- Net income of each member per month; when both members logged one, it sets that month's ratio
+CREATE TABLE IF NOT EXISTS incomes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    user_telegram_id INTEGER NOT NULL,
+    month TEXT NOT NULL,  -- "2006-01"
+    amount_minor INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (lobby_id, user_telegram_id, month),
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id)
+);
+
+-- Invite tokens: each expires, allows a number of joins and can be revoked
+CREATE TABLE IF NOT EXISTS invite_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    lobby_id INTEGER NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    created_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    max_uses INTEGER NOT NULL DEFAULT 1,  -- Joins allowed with the token
+    revoked_at TIMESTAMP,
+    revoked_reason TEXT,  -- 'revoked', 'replaced' by a new token, or 'lobby_full'
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (created_by) REFERENCES users(telegram_id)
+);
+
+-- Who joined with each invite token
+CREATE TABLE IF NOT EXISTS invite_token_uses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    invite_token_id INTEGER NOT NULL,
+    user_telegram_id INTEGER NOT NULL,
+    used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (invite_token_id) REFERENCES invite_tokens(id),
+    FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id)
+);
+
+-- Security events: joins, failed joins, lockouts, token regenerations, members leaving or removed, and ownership transfers
+CREATE TABLE IF NOT EXISTS security_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type TEXT NOT NULL,  -- 'join', 'join_failed', 'join_locked', 'token_regenerated', 'member_left', 'member_removed' or 'owner_transferred'
+    lobby_id INTEGER,  -- NULL when the attempt matched no lobby
+    user_telegram_id INTEGER,  -- Who acted
+    target_telegram_id INTEGER,  -- Who was acted on (the removed member, or the new owner)
+    detail TEXT,  -- Why a join failed, or how a member joined
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (lobby_id) REFERENCES lobbies(id),
+    FOREIGN KEY (user_telegram_id) REFERENCES users(telegram_id),
+    FOREIGN KEY (target_telegram_id) REFERENCES users(telegram_id)
+);
+
+-- Failed join attempts per user and across all users, and the lockout they caused
+CREATE TABLE IF NOT EXISTS join_lockouts (
+    scope TEXT PRIMARY KEY,  -- 'user:<telegram id>'
+    failures INTEGER NOT NULL DEFAULT 0,  -- Consecutive failed attempts
+    last_failure_at TIMESTAMP,
+    locked_until TIMESTAMP  -- No joins are attempted before this time
+);
+
+-- Data migrations already applied
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    name TEXT PRIMARY KEY,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Create indexes for better query performance
 CREATE INDEX IF NOT EXISTS idx_expenses_lobby_date ON expenses(lobby_id, expense_date);
 CREATE INDEX IF NOT EXISTS idx_expenses_billing_period ON expenses(billing_period_start, billing_period_end);
 CREATE INDEX IF NOT EXISTS idx_expenses_payment_method ON expenses(payment_method_id);
 CREATE INDEX IF NOT EXISTS idx_payment_methods_lobby ON payment_methods(lobby_id, is_active);
-
+CREATE INDEX IF NOT EXISTS idx_expenses_parent ON expenses(parent_expense_id);
+CREATE INDEX IF NOT EXISTS idx_recurring_expenses_next_run ON recurring_expenses(is_active, next_run_date);
+CREATE INDEX IF NOT EXISTS idx_exchange_rates_lookup ON exchange_rates(lobby_id, currency, base_currency, rate_type, rate_date);
+CREATE INDEX IF NOT EXISTS idx_settlement_payments_lobby_date ON settlement_payments(lobby_id, payment_date);
+CREATE INDEX IF NOT EXISTS idx_contributions_lobby_date ON contributions(lobby_id, contribution_date);
+CREATE INDEX IF NOT EXISTS idx_categories_lobby ON categories(lobby_id);
+CREATE INDEX IF NOT EXISTS idx_expenses_category ON expenses(category_id);
+CREATE INDEX IF NOT EXISTS idx_category_rules_lobby ON category_rules(lobby_id);
+CREATE INDEX IF NOT EXISTS idx_budgets_lobby ON budgets(lobby_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_method_defaults_unique ON payment_method_defaults(lobby_id, user_telegram_id, IFNULL(category_id, 0));
+CREATE INDEX IF NOT EXISTS idx_incomes_lobby_month ON incomes(lobby_id, month);
+CREATE INDEX IF NOT EXISTS idx_lobby_members_user ON lobby_members(user_telegram_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lobby_members_current ON lobby_members(lobby_id, user_telegram_id) WHERE left_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_invite_tokens_lobby ON invite_tokens(lobby_id);
+CREATE INDEX IF NOT EXISTS idx_invite_token_uses_token ON invite_token_uses(invite_token_id);
+CREATE INDEX IF NOT EXISTS idx_security_events_lobby ON security_events(lobby_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_security_events_user ON security_events(user_telegram_id, event_type, created_at);
@@ -11,7 +11,7 @@ var englishTranslations = map[string]string{
 	"lobby_joined_token":  "✅ Successfully joined the lobby!",
 	"waiting_partner":     "Waiting for partner to join...",
 	"partner_id":          "Partner ID: %d",
-	"lobby_security_info": "🔒 *Security Information:*\n\nYour lobby is protected by an invitation token. Share this token ONLY with your partner:\n\n`%s`\n\n*How to join:*\nYour partner should run:\n`/start %s`\n\n⚠️ Keep this token private! Anyone with this token can join your lobby.",
+	"lobby_security_info": "🔒 *Security Information:*\n\nYour lobby is protected by an invitation token. Share this token ONLY with your partner:\n\n`%s`\n\n*How to join:*\nYour partner should run:\n`/start %s`\n\n⏳ The token works once and expires on %s; create another with `/invite`.\n⚠️ Keep this token private! Anyone with this token can join your lobby.",
 
 	// Error messages
 	"error_user_init":        "❌ Error: Failed to initialize user. Please try again.",
@@ -20,9 +20,9 @@ var englishTranslations = map[string]string{
 	"error_lobby_join":       "❌ Failed to join lobby: %v",
 	"error_lobby_create":     "❌ Error: Failed to create lobby. Please try again.",
 	"error_invalid_lobby_id": "❌ Invalid invitation token. Usage: `/start <invite_token>` to join an existing lobby.",
-	"error_invalid_token":    "❌ Invalid or expired invitation token. Please ask your partner for a new invitation.",
+	"error_invalid_token":    "❌ Invalid invitation token. Please ask for a new invitation.",
 	"error_unknown_command":  "Unknown command. Use /help to see available commands.",
-	"error_invalid_user_id":  "❌ Invalid user ID. Use 'user1', 'user2', 'partner', or a valid user ID from your lobby.",
+	"error_invalid_user_id":  "❌ Invalid member. Use 'user1', 'user2' (by joining order), 'partner', '@username', or a valid user ID from your lobby.",
 	"error_generic":          "❌ Error: %v",
 	"error_invalid_period":   "❌ Invalid period format. Use YYYY-MM",
 
@@ -35,17 +35,26 @@ var englishTranslations = map[string]string{
 /examples - Show command usage examples
 
 *Expense Management:*
-/add <amount> <description> [category] [payment_method] - Add an expense
+/add <amount> <description> [category] [payment_method] [Nx] [split:<mode>] - Add an expense (Nx = installments)
 /list [month] - List expenses (current month or specified)
 /list_billing [payment_method] [period] - List expenses by billing cycle
 /delete [expense_id] - Delete an expense (shows recent expenses if no ID provided)
-/edit <expense_id> <field> <value> - Edit an expense (fields: category, payment_method)
+/edit <expense_id> <field> <value> - Edit an expense (fields: category, payment_method, split)
+/recurring [add|delete] - Manage recurring expenses (rent, subscriptions)
+Or just write the expense: ` + "`1500 super visa`" + `, ` + "`ayer 3200 nafta`" + `
 
 *Reports & Analysis:*
 /summary [start_date] [end_date] - Get spending summary
 /summary_billing [payment_method] [period] - Get summary by billing cycle
 /settle - Calculate who owes whom
 /settle_billing [payment_method] [period] - Calculate settlement for billing period
+/paid <amount> [date] [payer] [payee] [note] - Record a payment between members
+/balance [history] - Running balance across months and payments
+/deposit [amount [date] [partner]|history] - Deposits into the shared account
+/income [amount [month] [partner]|delete] - Monthly incomes that set the split ratio
+/budget [amount [category]|status|alerts] - Monthly budgets with warnings
+/reconcile <card> <month> <total> [items] - Check a card statement against the bank's total
+/rate [currency value [type] [date]] - Exchange rates and base currency
 
 *Configuration:*
 /payment_methods - Manage payment methods (add, edit, delete)
@@ -53,16 +62,45 @@ var englishTranslations = map[string]string{
   ` + "`/payment_methods`" + ` - List all payment methods
   ` + "`/payment_methods add Visa credit_card 15`" + ` - Add credit card with closing day 15
   ` + "`/payment_methods edit 1 closing_day 20`" + ` - Edit payment method #1
+  ` + "`/payment_methods edit 1 due_day 5`" + ` - Statement due on the 5th (reminder 3 days before)
+  ` + "`/payment_methods cycle Visa 2026-12 2026-12-22`" + ` - December's statement closes on the 22nd
+  ` + "`/payment_methods edit 1 closing_shift before`" + ` - Close on the previous business day on weekends/holidays
+  ` + "`/payment_methods holidays add 2026-12-25`" + ` - Add a holiday
+  ` + "`/payment_methods limit Visa 500000 80`" + ` - Credit limit, warn at 80% in use
+  ` + "`/payment_methods default Visa`" + ` - Your default payment method (` + "`default YPF Nafta`" + ` for a category)
+  ` + "`/payment_methods edit 1 owner partner`" + ` - Your partner pays this card's bills
   ` + "`/payment_methods delete 1`" + ` - Delete payment method #1
 
-/categories - Manage categories
-  Example: ` + "`/categories`" + ` - List all categories
+/categories - Manage categories (add, rename, merge, archive)
+  Examples:
+  ` + "`/categories`" + ` - List all categories
+  ` + "`/categories merge Super Groceries`" + ` - Move every Super expense to Groceries
+  ` + "`/categories archive Gifts`" + ` - Hide a category you no longer use
+
+/rules - Categorize expenses automatically by description
+  Examples:
+  ` + "`/rules add netflix Entertainment Visa`" + ` - Netflix expenses go to Entertainment, paid with Visa
+  ` + "`/rules learn`" + ` - Propose rules from your past expenses
+  ` + "`/rules apply`" + ` - Categorize past expenses without category
 
 /settings - Configure account type, salary percentages
   Examples:
   ` + "`/settings`" + ` - Show current settings
   ` + "`/settings account_type shared`" + ` - Set account type to shared
   ` + "`/settings salary 0.6 0.4`" + ` - Set salary percentages (60% user1, 40% user2)
+  ` + "`/settings salary 0.6 0.4 2026-09`" + ` - From September 1st on; earlier months keep theirs
+  ` + "`/settings history`" + ` - Settings in force over time
+  ` + "`/settings quick_capture on|off`" + ` - Add expenses from plain messages (on by default in private chats only)
+
+/members [max <n>|share <member> <weight>] - Lobby members (roommates, families) and their share weights
+/leave - Leave the lobby; your expenses stay in its history
+/remove_member <member> - Remove a member (owner, approved by another member)
+/transfer_owner <member> - Give the lobby to another member
+
+/lobbies [name <name>|new [name]] - Your lobbies (a couple, roommates...), naming and creating them
+/use <id|name> - Switch the lobby your private chat acts on
+
+/invite [duration] [joins] - Invite someone (` + "`/invite 7d 2`" + `, ` + "`/invite list`" + `, ` + "`/invite revoke <id>`" + `)
 
 /language - Change language
   Examples:
@@ -79,41 +117,185 @@ var englishTranslations = map[string]string{
 For more details, use each command without arguments to see its usage.`,
 
 	// Settings
-	"settings_current":      "⚙️ *Current Lobby Settings*\n\nLobby ID: `%d`\nAccount Type: `%s`\nUser 1 Salary %%: %.1f%%\nUser 2 Salary %%: %.1f%%\n\n*To change settings:*\n`/settings account_type <separate|shared>`\n`/settings salary <user1_pct> <user2_pct>`\n\nExample:\n`/settings account_type shared`\n`/settings salary 0.6 0.4`",
+	"settings_current":      "⚙️ *Current Lobby Settings*\n\nLobby ID: `%d`\nAccount Type: `%s`\nUser 1 Salary %%: %.1f%%\nUser 2 Salary %%: %.1f%%\nQuick capture: %s\n\n*To change settings:*\n`/settings account_type <separate|shared> [from]`\n`/settings salary <user1_pct> <user2_pct> [from]`\n`/settings quick_capture <on|off>`\n`/settings history` - Earlier settings\n\nExample:\n`/settings account_type shared`\n`/settings salary 0.6 0.4 2026-09` (from September 1st on)",
 	"settings_updated":      "✅ Settings updated successfully!",
-	"settings_usage":        "❌ Usage: `/settings account_type <separate|shared>`",
+	"settings_usage":        "❌ Usage: `/settings account_type <separate|shared> [from]`",
 	"settings_invalid_type": "❌ Account type must be 'separate' or 'shared'",
-	"settings_salary_usage": "❌ Usage: `/settings salary <user1_percentage> <user2_percentage>`\nExample: `/settings salary 0.6 0.4`",
+	"settings_salary_usage": "❌ Usage: `/settings salary <user1_percentage> <user2_percentage> [from]`\nExample: `/settings salary 0.6 0.4`, or `/settings salary 0.6 0.4 2026-09` from September 1st on",
 	"settings_invalid_pct":  "❌ Invalid percentage values. Use numbers between 0 and 1.",
 	"settings_pct_range":    "❌ Percentages must be between 0 and 1.",
-	"settings_unknown":      "❌ Unknown setting. Use `account_type` or `salary`.",
+	"settings_unknown":      "❌ Unknown setting. Use `account_type`, `salary`, `quick_capture` or `history`.",
 	"settings_error":        "❌ Failed to update settings: %v",
 
+	// Categories
+	"category_usage":         "❌ Usage:\n`/categories` - List categories\n`/categories add <name>`\n`/categories rename <name> <new name>`\n`/categories merge <from> <into>`\n`/categories archive <name>`\n`/categories unarchive <name>`",
+	"category_none":          "📂 No categories yet. Add one with `/categories add <name>`.",
+	"category_list":          "📂 *Categories:*\n\n%s\n",
+	"category_item":          "• %s (%d)",
+	"category_archived_list": "\n🗄 Archived: %s\n",
+	"category_list_hint":     "\nManage them with `/categories add|rename|merge|archive`.",
+	"category_added":         "✅ Category *%s* added.",
+	"category_rename_usage":  "❌ Usage: `/categories rename <name> <new name>`\n\nExample: `/categories rename Super Supermarket`",
+	"category_renamed":       "✅ Category *%s* renamed to *%s*. Its expenses were updated.",
+	"category_merge_usage":   "❌ Usage: `/categories merge <from> <into>`\n\nExample: `/categories merge Super Groceries`",
+	"category_merged":        "✅ *%s* merged into *%s* (%d expenses moved).",
+	"category_archived":      "🗄 Category *%s* archived. Its expenses keep it; restore it with `/categories unarchive %s`.",
+	"category_unarchived":    "✅ Category *%s* restored.",
+	"category_not_found":     "⚠️ %v. See them with `/categories`.",
+	"category_exists":        "⚠️ %v.",
+	"category_error":         "❌ Category error: %v",
+	"category_is_archived":   "⚠️ Category *%s* is archived. Restore it with `/categories unarchive %s` or pick another one.",
+	"category_suggest":       "🤔 There is no category *%s*. Did you mean:\n%s\n\nUse one of those, or create it with `/categories add %s`.",
+	"category_created_note":  "🆕 New category *%s* created.\n",
+
+	// Categorization rules
+	"rule_usage":         "❌ Usage:\n`/rules` - List rules\n`/rules add <keyword|re:regex> <category> [payment_method]`\n`/rules delete <id>`\n`/rules learn` - Propose rules from past expenses\n`/rules accept <n...|all>` - Create proposed rules\n`/rules apply` - Categorize past expenses without category",
+	"rule_add_usage":     "❌ Usage: `/rules add <keyword|re:regex> <category> [payment_method]`\n\nExamples:\n`/rules add netflix Entertainment Visa`\n`/rules add uber Transport`\n`/rules add re:^(coto|dia|jumbo) Groceries`",
+	"rule_none":          "🏷 No rules yet. Expenses added without a category stay uncategorized.\n\nAdd one with `/rules add <keyword> <category>` or let the bot propose some with `/rules learn`.",
+	"rule_list":          "🏷 *Categorization rules* (first match wins):\n\n%s\n\nThey apply to new expenses added without a category.",
+	"rule_item":          "#%d `%s` → %s",
+	"rule_item_payment":  " · %s",
+	"rule_added":         "✅ Rule #%d added: `%s` → %s\n",
+	"rule_apply_hint":    "\nRun `/rules apply` to categorize past expenses without category.",
+	"rule_deleted":       "✅ Rule #%d deleted.",
+	"rule_not_found":     "⚠️ Rule not found. See the IDs with `/rules`.",
+	"rule_error":         "❌ Rule error: %v",
+	"rule_learn_none":    "🤷 Nothing to learn yet: no word shows up with the same category in at least two expenses, or your rules already cover them.",
+	"rule_learn_header":  "🧠 *Rules learned from your expenses:*\n\n",
+	"rule_learn_item":    "%d. `%s` → %s (%d expenses)",
+	"rule_learn_footer":  "\nCreate them with `/rules accept 1 3` or `/rules accept all`.",
+	"rule_learn_invalid": "⚠️ There is no proposal number %s. Run `/rules learn` to see them.",
+	"rule_accepted":      "✅ %d rules created:\n%s\n",
+	"rule_applied":       "✅ %d uncategorized expenses were categorized by your rules.",
+	"rule_applied_held":  "\n🔒 %d expenses on reconciled statements were left alone. To categorize them too, send `/rules apply confirm`.",
+
+	// Budgets
+	"budget_usage":                "❌ Usage:\n`/budget` - Status of this month's budgets\n`/budget <amount> [category]` - Set a monthly budget (no category = overall)\n`/budget delete [category]` - Delete a budget\n`/budget status [YYYY-MM]` - Spent vs. limit\n`/budget alerts <percent...>` - Warning thresholds, e.g. `/budget alerts 80 100`",
+	"budget_none":                 "💰 No budgets yet.\n\nSet one with `/budget 300000` (overall) or `/budget 80000 Groceries`.",
+	"budget_overall":              "Overall",
+	"budget_set":                  "✅ Monthly budget for *%s*: %s\n",
+	"budget_set_spent":            "This month so far: %s (%.0f%%)",
+	"budget_deleted":              "✅ Budget for *%s* deleted.",
+	"budget_not_found":            "⚠️ There is no budget for that. See them with `/budget status`.",
+	"budget_error":                "❌ Budget error: %v",
+	"budget_thresholds":           "🔔 Budget warnings are posted at %s of the limit.\n\nChange them with `/budget alerts 80 100`.",
+	"budget_thresholds_set":       "✅ Budget warnings will be posted at %s of the limit.",
+	"budget_thresholds_usage":     "❌ Usage: `/budget alerts <percent...>`, e.g. `/budget alerts 50 80 100`",
+	"budget_status_header":        "💰 *Budgets for %s*\n",
+	"budget_status_days_left":     "%d days left in the month\n",
+	"budget_status_item":          "\n%s *%s*: %s / %s (%.0f%%)\n",
+	"budget_status_left":          "   %s left\n",
+	"budget_status_left_per_day":  "   %s left (%s per day)\n",
+	"budget_status_over":          "   %s over the limit\n",
+	"budget_alert":                "⚠️ *%s* budget at %d%%: %s of %s spent in %s (%d days left).",
+	"budget_alert_total":          "⚠️ Overall budget at %d%%: %s of %s spent in %s (%d days left).",
+	"budget_alert_exceeded":       "🔴 *%s* budget exceeded: %s of %s spent (%s over) in %s, with %d days left.",
+	"budget_alert_exceeded_total": "🔴 Overall budget exceeded: %s of %s spent (%s over) in %s, with %d days left.",
+
 	// Payment methods
 	"payment_methods_none":            "📋 No payment methods configured.\n\nAdd one with:\n`/payment_methods add <name> <type> [closing_day]`\n\nTypes: credit_card, debit_card, cash, bank_transfer, other",
 	"payment_methods_list":            "📋 *Payment Methods:*\n\n%s",
 	"payment_method_item":             "%s *%s* (%s)",
 	"payment_method_closing":          " - Closes on %d",
-	"payment_method_owner":            " - Owner: %d",
+	"payment_method_owner":            " - Owner: %s",
 	"payment_method_added":            "✅ Payment method *%s* created successfully!",
 	"payment_method_closing_day":      "\nClosing day: %d",
-	"payment_method_add_usage":        "❌ Usage: `/payment_methods add <name> <type> [closing_day]`\n\nTypes: credit_card, debit_card, cash, bank_transfer, other\nExample: `/payment_methods add Visa credit_card 15`",
+	"payment_method_add_usage":        "❌ Usage: `/payment_methods add <name> <type> [closing_day] [due_day|+days]`\n\nTypes: credit_card, debit_card, cash, bank_transfer, other\nExample: `/payment_methods add Visa credit_card 20 5` (closes on the 20th, due on the 5th)\nOr: `/payment_methods add Visa credit_card 20 +10` (due 10 days after closing)",
 	"payment_method_closing_required": "❌ Credit cards require a closing day. Usage: `/payment_methods add <name> credit_card <closing_day>`",
 	"payment_method_closing_invalid":  "❌ Closing day must be a number between 1 and 31",
 	"payment_method_not_found":        "⚠️ Payment method '%s' not found.",
-	"payment_method_not_found_list":   "⚠️ Payment method '%s' not found.\n\nAvailable methods:\n%s\n\nExpense added without payment method.",
+	"payment_method_not_found_list":   "⚠️ Payment method '%s' not found.\n\nAvailable methods:\n%s\n\nExpense added without it (your default payment method applies, if you set one).",
 	"payment_method_add_error":        "❌ Failed to create payment method: %v",
-	"payment_method_edit_usage":       "❌ Usage: `/payment_methods edit <id> <field> <value>`\n\nFields: name, type, closing_day, active\nExample: `/payment_methods edit 1 closing_day 20`",
+	"payment_method_edit_usage":       "❌ Usage: `/payment_methods edit <id> <field> <value>`\n\nFields: name, type, closing_day, closing_shift (`none`, `before` or `after` on weekends/holidays), due_day (day, `+days` or `none`), due_offset, owner (`me`, `partner` or `shared`), active\nExample: `/payment_methods edit 1 closing_day 20`\n`/payment_methods edit 1 closing_shift before`\n`/payment_methods edit 1 due_day 5`",
 	"payment_method_delete_usage":     "❌ Usage: `/payment_methods delete <id>`",
 	"payment_method_invalid_id":       "❌ Invalid payment method ID",
 	"payment_method_update_error":     "❌ Failed to update payment method: %v",
 	"payment_method_delete_error":     "❌ Failed to delete payment method: %v",
 	"payment_method_updated":          "✅ Payment method updated successfully!",
 	"payment_method_deleted":          "✅ Payment method deleted successfully!",
-	"payment_method_unknown_action":   "❌ Unknown action. Use: `add`, `edit`, or `delete`",
+	"payment_method_unknown_action":   "❌ Unknown action. Use: `add`, `edit`, `delete`, `cycle`, `holidays`, `limit` or `default`",
+
+	// Statement due dates
+	"payment_method_due_day":         " - Due on the %d",
+	"payment_method_due_offset":      " - Due %d days after closing",
+	"payment_method_due_day_line":    "\nDue day: %d",
+	"payment_method_due_offset_line": "\nDue: %d days after closing",
+	"payment_method_due_invalid":     "❌ Due date must be a day of the month (1-31) or days after closing like `+10` (1-60)",
+	"billing_due_date":               "\n📅 Due date: %s",
+	"payment_due_reminder":           "💳 *%s* statement of %s is due in %d days (%s).\nPeriod: %s to %s",
+	"payment_due_today":              "💳 *%s* statement of %s is due *today*.\nPeriod: %s to %s",
+
+	// Billing cycle calendars
+	"payment_method_cycle_usage":    "❌ Usage: `/payment_methods cycle <name> [<YYYY-MM> <closing_date> [due_date]]`\n\nExample: `/payment_methods cycle Visa 2026-12 2026-12-22 2027-01-05` (December's statement closes on the 22nd, due on January 5th)\n`/payment_methods cycle Visa 2026-12 clear` goes back to the fixed closing day",
+	"payment_method_cycles_none":    "📅 *%s* closes on the %d every month. No specific closing dates set.",
+	"payment_method_cycles_header":  "📅 *%s* closing dates (otherwise the %d):\n\n",
+	"payment_method_cycle_item":     "• %s: closes %s",
+	"payment_method_cycle_item_due": ", due %s",
+	"payment_method_cycle_set":      "✅ *%s* statement of %s closes on %s",
+	"payment_method_cycle_set_due":  ", due %s",
+	"payment_method_cycle_moved":    "\n%d expenses moved to another statement.",
+	"payment_method_cycle_cleared":  "✅ *%s* statement of %s closes on the %d again.\n%d expenses moved to another statement.",
+
+	// Closing shifts and holidays
+	"payment_method_shift_before":  " (earlier on weekends/holidays)",
+	"payment_method_shift_after":   " (later on weekends/holidays)",
+	"payment_method_shift_invalid": "❌ Closing shift must be `none`, `before` (previous business day) or `after` (next business day)",
+	"holidays_usage":               "❌ Usage: `/payment_methods holidays [add <date> [name] | delete <date>]`\n\nExample: `/payment_methods holidays add 2026-12-25 Christmas`",
+	"holidays_none":                "📅 No holidays set. Add one with `/payment_methods holidays add <date> [name]`",
+	"holidays_header":              "📅 *Holidays:*\n\n",
+	"holidays_item":                "• %s %s\n",
+	"holiday_added":                "✅ %s is a holiday now.\n%d expenses moved to another statement.",
+	"holiday_deleted":              "✅ %s is not a holiday anymore.\n%d expenses moved to another statement.",
+
+	// Credit limits
+	"payment_method_credit":             " - Available %s of %s",
+	"payment_method_credit_limit":       " - Limit %s",
+	"payment_method_limit_usage":        "❌ Usage: `/payment_methods limit <name> [<amount>|none] [warn%]`\n\nExample: `/payment_methods limit Visa 500000 80` (warns when 80% of the limit is in use)\n`/payment_methods limit Visa` shows the available credit",
+	"payment_method_limit_warn_invalid": "❌ The warning must be a percentage of the limit between 1 and 100, like `80%`",
+	"payment_method_limit_none":         "💳 *%[1]s* has no credit limit. Set one with `/payment_methods limit %[1]s <amount>`",
+	"payment_method_limit_set":          "✅ *%s* credit limit: %s\nYou'll be warned when %d%% of it is in use.",
+	"payment_method_limit_cleared":      "✅ *%s* has no credit limit now.",
+	"payment_method_limit_status":       "💳 *%s*\n\nLimit: %s\nOwed: %s (%.0f%%)\nAvailable: %s\n\nOwed counts unpaid statements, the open one and future installments. Warning at %d%% of the limit.",
+	"payment_method_limit_exceeded":     "\n🚨 Over the limit by %s",
+	"expense_credit_warning":            "\n⚠️ *%s* is at %.0f%% of its limit: %s available of %s.",
+	"expense_credit_exceeded":           "\n🚨 *%s* is over its limit by %s (limit %s).",
+
+	// Default payment methods
+	"payment_method_defaults_none":            "💳 You have no default payment method.\n\nSet one with `/payment_methods default <name>`, or for a category with `/payment_methods default <name> <category>`.",
+	"payment_method_defaults_header":          "💳 *Your default payment methods:*\n\n",
+	"payment_method_defaults_item":            "• Any category: %s\n",
+	"payment_method_defaults_category_item":   "• %s: %s\n",
+	"payment_method_default_set":              "✅ Your expenses without a payment method will use *%s*.",
+	"payment_method_default_category_set":     "✅ Your *%[2]s* expenses without a payment method will use *%[1]s*.",
+	"payment_method_default_cleared":          "✅ Your default payment method was removed.",
+	"payment_method_default_category_cleared": "✅ Your default payment method for *%s* was removed.",
+	"payment_method_default_not_set":          "⚠️ There was no default payment method to remove.",
+	"expense_default_applied":                 "💳 Your default payment method was used: %s\n",
+	"expense_default_category_applied":        "💳 Your default payment method for %[2]s was used: %[1]s\n",
+
+	// Statement reconciliation
+	"reconcile_usage":                  "❌ Usage: `/reconcile <card> <YYYY-MM> <total> [item amounts...]`\n\nExample: `/reconcile Visa 2026-12 254300`\nWith the statement's items: `/reconcile Visa 2026-12 254300 120000,84300,50000`",
+	"reconcile_error":                  "❌ Failed to reconcile the statement: %v",
+	"reconcile_header":                 "🧾 *%s* statement of %s\nPeriod: %s to %s\n\n",
+	"reconcile_totals":                 "Bank: %s\nLogged: %s (%d expenses)\n",
+	"reconcile_match":                  "✅ The totals match.\n",
+	"reconcile_missing":                "⚠️ The bank charged %s more than logged (fees, taxes or missing expenses).\n",
+	"reconcile_extra":                  "⚠️ Logged %s more than the bank charged.\n",
+	"reconcile_other_currencies":       "Also logged in other currencies: %s\n",
+	"reconcile_out_of_cycle_header":    "\n📅 Logged just outside this statement (the bank may have billed them here):\n",
+	"reconcile_items_missing":          "\n❓ On the statement but not logged: %s\n",
+	"reconcile_items_unmatched_header": "\n❓ Logged but not on the statement:\n",
+	"reconcile_marked":                 "\n🔒 Statement marked as reconciled; adding, editing or deleting its expenses will ask for confirmation.",
+	"reconcile_adjust_button":          "➕ Add %s adjustment",
+	"reconcile_adjust_description":     "Adjustment %s %s",
+	"reconcile_adjusted":               "✅ Added an adjustment of %s to the *%s* statement of %s (ID: %d).",
+	"reconcile_already_adjusted":       "This statement already has an adjustment",
+	"reconcile_nothing_to_adjust":      "Nothing to adjust: the logged total already reaches the bank's",
+	"reconcile_confirm_edit":           "🔒 This expense is on the reconciled *%s* statement of %s.\nTo change it anyway, send:\n`%s confirm`",
+	"reconcile_confirm_change":         "🔒 This falls on a card statement that is already reconciled.\nTo go ahead anyway, send:\n`%s confirm`",
 
 	// Expenses
-	"expense_add_usage":           "❌ Usage: `/add <amount> <description> [category] [payment_method]`\n\nExamples:\n`/add 50.00 Groceries`\n`/add 25.50 Dinner credit_card_1`",
+	"expense_add_usage":           "❌ Usage: `/add <amount> <description> [category] [payment_method] [currency] [Nx] [+interest%] [split:<mode>]`\n\nExamples:\n`/add 50.00 Groceries`\n`/add 25.50 Dinner credit_card_1`\n`/add 120000 TV Electronics Visa 6x`\n`/add 30usd Hotel Travel`\n`/add 8000 Haircut split:personal`\n`/add 12000 Dinner split:70%`",
 	"expense_invalid_amount":      "❌ Invalid amount. Please provide a positive number.",
 	"expense_added":               "✅ Expense added!\n\nAmount: %s\nDescription: %s\n",
 	"expense_category":            "Category: %s\n",
@@ -138,15 +320,106 @@ For more details, use each command without arguments to see its usage.`,
 	"expense_delete_not_found":    "❌ Expense not found or doesn't belong to your lobby.",
 	"expense_delete_error":        "❌ Failed to delete expense: %v",
 	"expense_deleted":             "✅ Expense deleted successfully!",
-	"expense_edit_usage":          "❌ Usage: `/edit <expense_id> <field> <value>`\n\nFields: `category`, `payment_method`\n\nExamples:\n`/edit 123 category Groceries`\n`/edit 123 payment_method Visa`",
+	"expense_edit_usage":          "❌ Usage: `/edit <expense_id> <field> <value>`\n\nFields: `category`, `payment_method`, `split`\n\nExamples:\n`/edit 123 category Groceries`\n`/edit 123 payment_method Visa`\n`/edit 123 split personal`",
 	"expense_edit_invalid_id":     "❌ Invalid expense ID. Usage: `/edit <expense_id> <field> <value>`",
 	"expense_edit_not_found":      "❌ Expense not found or doesn't belong to your lobby.",
 	"expense_edit_category_usage": "❌ Usage: `/edit <expense_id> category <category_name>`",
 	"expense_edit_payment_usage":  "❌ Usage: `/edit <expense_id> payment_method <payment_method_name>`",
-	"expense_edit_invalid_field":  "❌ Invalid field. Use `category` or `payment_method`.",
+	"expense_edit_invalid_field":  "❌ Invalid field. Use `category`, `payment_method` or `split`.",
 	"expense_edit_error":          "❌ Failed to edit expense: %v",
 	"expense_edited":              "✅ Expense updated successfully!",
 
+	// Installments (cuotas)
+	"expense_installment_label":      " (installment %d/%d)",
+	"expense_installments_invalid":   "❌ Invalid installments. Use e.g. `6x` (2-48 installments) and optionally `+10%` interest.\n\nExample: `/add 120000 TV Electronics Visa 6x +10%`",
+	"expense_installments_need_card": "❌ Installment purchases need a credit card with a closing day.\n\nExample: `/add 120000 TV Electronics Visa 6x`",
+	"expense_added_installments":     "✅ Installment purchase added!\n\nTotal: %s (%d installments)\nDescription: %s\n",
+	"expense_installment_interest":   "Interest: %.1f%%\n",
+	"expense_installment_line":       "• Installment %d/%d: %s (%s to %s)\n",
+	"expense_installments_header":    "\n*Installments:*\n",
+	"expense_installment_item":       "• %s%s: %s\n",
+	"expense_deleted_installments":   "✅ Expense deleted together with its %d remaining installments!",
+	"expense_installments_kept":      "\nℹ️ %d installments already billed are kept.",
+
+	// Recurring expenses
+	"recurring_usage":           "❌ Usage:\n`/recurring` - List recurring expenses\n`/recurring add <amount> <description> <weekly|monthly|yearly> [day] [category] [payment_method] [end_date] [partner]`\n`/recurring delete <id>`",
+	"recurring_add_usage":       "❌ Usage: `/recurring add <amount> <description> <weekly|monthly|yearly> [day] [category] [payment_method] [end_date] [partner]`\n\nExamples:\n`/recurring add 15000 Rent monthly 5 Housing`\n`/recurring add 9.99 Netflix monthly 12 Entertainment Visa`\n`/recurring add 3000 Cleaning weekly`",
+	"recurring_delete_usage":    "❌ Usage: `/recurring delete <id>`",
+	"recurring_none":            "📭 No recurring expenses. Add one with `/recurring add`.",
+	"recurring_list_header":     "🔁 *Recurring Expenses:*\n\n",
+	"recurring_item":            "#%d - %s - %s\n  %s · next: %s\n",
+	"recurring_until":           "  Until: %s\n",
+	"recurring_cadence_weekly":  "weekly",
+	"recurring_cadence_monthly": "monthly on day %d",
+	"recurring_cadence_yearly":  "yearly on %s",
+	"recurring_added":           "✅ Recurring expense #%d added: %s - %s\n%s · next: %s\n",
+	"recurring_invalid_cadence": "❌ Invalid cadence. Use `weekly`, `monthly` or `yearly`.",
+	"recurring_invalid_day":     "❌ Invalid day. Use a day of the month between 1 and 31.",
+	"recurring_add_error":       "❌ Error adding recurring expense: %v",
+	"recurring_not_found":       "❌ Recurring expense not found.",
+	"recurring_deleted":         "✅ Recurring expense #%d stopped. Expenses already added are kept.",
+	"recurring_materialized":    "🔁 Recurring expense added: %s - %s (%s) [rule #%d]",
+	"recurring_held":            "⚠️ Recurring expense not added: %s - %s (%s) [rule #%d]. It falls on a card statement that is already reconciled; add it by hand if it belongs there.",
+
+	// Quick capture (expenses from plain messages)
+	"quick_capture_date":           "Date: %s\n",
+	"quick_capture_undo":           "↩️ Undo",
+	"quick_capture_edit":           "✏️ Edit",
+	"quick_capture_undone":         "↩️ Undone: %s %s",
+	"quick_capture_edit_hint":      "✏️ *Edit expense #%d*\n\n`/edit %d category <name>`\n`/edit %d payment_method <name>`\n`/edit %d split <shared|personal|partner|70%%>`\n\nTo add it again from scratch, delete it with `/delete %d`.",
+	"quick_capture_confirm":        "💸 Add %s %s as an expense?",
+	"quick_capture_add":            "✅ Add",
+	"quick_capture_ignore":         "✖️ Ignore",
+	"quick_capture_ignored":        "✖️ Not added.",
+	"quick_capture_not_yours":      "Only who wrote the message can answer.",
+	"quick_capture_gone":           "That message is no longer available. Write the expense again.",
+	"settings_on":                  "on",
+	"settings_off":                 "off",
+	"settings_quick_capture_usage": "❌ Usage: `/settings quick_capture <on|off>`",
+	"settings_quick_capture_on":    "✅ Quick capture enabled. Messages like `1500 super visa` or `ayer 3200 nafta` are added as expenses.",
+	"settings_quick_capture_off":   "✅ Quick capture disabled. Use /add to add expenses.",
+
+	// Settings history
+	"settings_updated_from":       "✅ Settings updated from %s on, until the next change. Earlier periods keep their settings.",
+	"settings_invalid_date":       "❌ Invalid date. Use YYYY-MM-DD, a month (YYYY-MM) or a word like `ayer`.",
+	"settings_future_date":        "❌ Settings can't take effect in the future. Change them on the day, or give a past date.",
+	"settings_history_header":     "📜 *Settings history*\n\n",
+	"settings_history_now":        "today",
+	"settings_history_item":       "• %s → %s: `%s`, %.1f%% | %.1f%%\n",
+	"settings_history_changed_by": "   changed by %s\n",
+	"settings_history_hint":       "\nSettlements use the settings in force on each expense's date. Correct a past change with `/settings salary 0.6 0.4 2026-09-01`.",
+	"settle_settings_header":      "⚙️ *Settings or members changed during the period:*\n",
+	"settle_settings_item":        "• From %s on %s: %s\n",
+
+	// Exchange rates and currencies
+	"rate_usage":            "❌ Usage:\n`/rate` - Show base currency and latest rates\n`/rate <currency> <value> [type] [date]` - Set a rate (1 currency = value base currency)\n`/rate <currency>` - Rate history\n`/rate base <currency>` - Set the lobby's base currency\n`/rate type <type>` - Set the default rate type\n`/rate delete <id>` - Delete a rate\n\nExamples:\n`/rate USD 1050`\n`/rate USD 1180 mep 2024-05-01`\n`/rate USD 1650 tarjeta`",
+	"rate_header":           "💱 *Exchange Rates*\n\nBase currency: %s\nDefault rate type: %s\n\n",
+	"rate_history_header":   "💱 *%s rate history:*\n\n",
+	"rate_none":             "No exchange rates yet. Add one with `/rate <currency> <value> [type] [date]`.\n",
+	"rate_item":             "#%d • 1 %s = %s %s (%s, from %s)\n",
+	"rate_set":              "✅ Rate saved: 1 %s = %s %s (%s) from %s",
+	"rate_invalid_currency": "❌ Unknown currency: %s\n\nSupported: ARS, USD, EUR, BRL, UYU, CLP, GBP",
+	"rate_invalid_value":    "❌ Invalid rate. Please provide a positive number.",
+	"rate_invalid_type":     "❌ Invalid rate type: %s\n\nUse one of: %s",
+	"rate_error":            "❌ Error saving rate: %v",
+	"rate_base_set":         "✅ Base currency set to %s. Summaries and settlements are now converted to %s.",
+	"rate_type_set":         "✅ Default rate type set to %s. Credit card expenses still use the `tarjeta` rate when there is one.",
+	"rate_deleted":          "✅ Rate #%d deleted.",
+	"rate_not_found":        "❌ Rate not found.",
+	"rate_missing":          "⚠️ Missing exchange rate: %v\n\nAdd one with `/rate <currency> <value> [type] [date]`.",
+	"summary_converted":     "Includes %s, converted to %s at the rate of each expense date.\n\n",
+
+	// Split modes
+	"expense_split":         "Split: %s\n",
+	"expense_split_invalid": "❌ Invalid split. Use `split:shared`, `split:personal`, `split:partner`, `split:70%` (your share) or `split:3000` (your share as an amount).",
+	"split_shared":          "shared (lobby ratio)",
+	"split_personal":        "personal (100% spender)",
+	"split_partner":         "for the partner (100% the other member)",
+	"split_custom_percent":  "custom: %.0f%% spender / %.0f%% other",
+	"split_custom_amount":   "custom: spender pays %s",
+	"settle_split_header":   "\n👤 *Not split by the lobby ratio* (shared part: %s)\n",
+	"settle_split_item":     "• %s %s, %s\n   %s\n",
+
 	// Settlement
 	"settle_usage":          "❌ Usage: `/settle_billing <payment_method> [period]`\n\nExample: `/settle_billing Visa 2024-01`",
 	"settle_error":          "❌ Error calculating settlement: %v",
@@ -162,6 +435,145 @@ For more details, use each command without arguments to see its usage.`,
 	"settle_user2_owes":     "➡️ User 2 owes User 1: %s\n",
 	"settle_all_settled":    "✅ All settled! No debts.\n",
 
+	// Card owners in settlements
+	"settle_paid_header":           "💳 *Who pays the bills* (card owners):\n",
+	"settle_paid_line":             "%s pays: %s\n",
+	"settle_paid_shared_account":   "Shared account pays: %s\n",
+	"settle_owner_paid_header":     "\n💳 *Spent on someone else's card:*\n",
+	"settle_owner_paid_item":       "• %s %s, spent by %s, paid by %s\n",
+	"payment_method_owner_invalid": "❌ Owner must be `me`, `partner`, a member's Telegram ID, or `shared`",
+
+	// Monthly incomes
+	"income_usage":          "❌ Usage:\n`/income` - This month's incomes and the ratio they set\n`/income <amount> [YYYY-MM] [partner]` - Log a monthly income (yours by default)\n`/income delete [YYYY-MM] [partner]` - Delete an income\n`/income list [YYYY-MM]` - Incomes of a month\n\nExample: `/income 850000` and `/income 600000 partner`",
+	"income_set":            "✅ Income of %s for %s: %s\n\n",
+	"income_deleted":        "🗑️ Income of %s for %s deleted.",
+	"income_not_found":      "⚠️ %s has no income logged for %s.",
+	"income_none":           "💼 No incomes logged for %s.\n\nLog yours with `/income 850000` and your partner's with `/income 600000 partner`: the month's shared expenses are then split by them instead of `/settings salary`.",
+	"income_header":         "💼 *Incomes for %s*\n\n",
+	"income_line":           "%s: %s\n",
+	"income_missing":        "—",
+	"income_ratio":          "\n📊 This month is split %s %.1f%% | %s %.1f%%\n",
+	"income_ratio_fallback": "\n📊 Until both incomes are logged, this month is split by the lobby settings (%.1f%% | %.1f%%).\n",
+	"income_error":          "❌ Failed to save the income: %v",
+	"settle_income_ratio":   "📊 Ratio from the incomes of %s: %s %s | %s %s\n",
+
+	// Settlement payments and running balance
+	"paid_usage":             "❌ Usage: `/paid <amount> [date] [payer] [payee] [note]`\n\nExamples:\n`/paid 15000` - You paid your partner 15000\n`/paid 50usd ayer transfer` - Paid yesterday\n`/paid 8000 partner` - Your partner paid you\n`/paid 12000 user3` - You paid user3 (lobbies with more members)\n`/paid 12000 user2 user3` - user2 paid user3\n`/paid delete <id>` - Delete a payment",
+	"paid_recorded":          "✅ Payment #%d recorded: %s paid %s %s on %s\n\n",
+	"paid_error":             "❌ Failed to record payment: %v",
+	"paid_deleted":           "✅ Payment #%d deleted.",
+	"paid_not_found":         "⚠️ Payment not found. See the IDs with `/balance history`.",
+	"balance_header":         "📒 *Running Balance*\n\n",
+	"balance_owes":           "➡️ %s owes %s: %s\n",
+	"balance_settled":        "✅ All settled! No debts.\n",
+	"balance_error":          "❌ Error calculating balance: %v",
+	"balance_periods_header": "\n*By month* (carried + expenses − payments = balance):\n",
+	"balance_period_item":    "• %s\n",
+	"balance_history_header": "\n💸 *Payments:*\n",
+	"balance_history_item":   "#%d %s: %s → %s %s\n",
+	"balance_history_note":   "   %s\n",
+	"balance_history_none":   "\nNo payments recorded yet. Record one with `/paid <amount>`.\n",
+	"balance_history_hint":   "\nFull history: `/balance history`\n",
+	"settle_running_balance": "\n📒 *Balance up to %s* (including earlier months and payments):\n",
+
+	// Leaving and removing members
+	"leave_done":                    "👋 You left %s. Your expenses stay in its history.",
+	"leave_owner_must_transfer":     "❌ You own this lobby. Give it to another member with `/transfer_owner <member>` first, then `/leave`.",
+	"leave_notice":                  "👋 %s left the lobby %s. Their expenses stay in its history, and there is room for someone new (`/invite`).",
+	"remove_member_usage":           "❌ Usage: `/remove_member <member>`\n\nExamples:\n`/remove_member partner`\n`/remove_member user3`\n`/remove_member @ana`",
+	"remove_member_self":            "❌ You cannot remove yourself. Give the lobby to another member with `/transfer_owner`, then `/leave`.",
+	"remove_member_confirm":         "⚠️ %s wants to remove %s from the lobby %s.\n\nTheir expenses stay in its history and their recurring expenses stop. They will need a new invitation to come back. Another member has to approve it.",
+	"remove_member_button":          "✅ Remove %s",
+	"remove_member_cancel_button":   "❌ Keep them",
+	"remove_member_done":            "✅ %s was removed from the lobby. There is room for someone new (`/invite`).",
+	"remove_member_cancelled":       "❌ %s kept %s in the lobby.",
+	"remove_member_gone":            "%s is no longer a member.",
+	"remove_member_notice":          "🚪 You were removed from the lobby %s. Its owner can invite you again.",
+	"remove_member_requested":       "⏳ The other members were asked to approve removing %s.",
+	"remove_member_not_approver":    "Only another member of the lobby can approve it.",
+	"remove_member_outdated":        "This request is outdated: the lobby has a new owner.",
+	"remove_member_approved_notice": "✅ %s approved it: %s was removed from the lobby %s.",
+	"remove_member_rejected_notice": "❌ %s did not approve removing %s from the lobby %s.",
+	"error_member_removed":          "🚫 You were removed from this group's lobby. Ask its owner for an invitation and join with `/start <token>`.",
+	"transfer_owner_usage":          "❌ Usage: `/transfer_owner <member>`\n\nThe new owner manages the members and invitations of the lobby.\n\nExamples:\n`/transfer_owner partner`\n`/transfer_owner @ana`",
+	"transfer_owner_done":           "👑 %s now owns the lobby %s.",
+	"transfer_owner_notice":         "👑 %s gave you the lobby %s: you now manage its members and invitations.",
+
+	// Invitations
+	"error_not_lobby_owner":    "❌ Only the lobby owner can do this.",
+	"invite_token_display":     "📨 *Invitation*\n\nToken: `%s`\nTo join, run: `/start %s`\n\n⏳ Works for %d join(s), until %s.\n⚠️ Share it privately: anyone with this token can join your lobby.",
+	"invite_token_regenerated": "🔄 Every earlier invitation was revoked.\n\n",
+	"invite_usage":             "❌ Usage: `/invite [duration] [joins]`\n\nExamples:\n`/invite` - One join, valid for 48 hours\n`/invite 12h` - Valid for 12 hours\n`/invite 7d 2` - Two joins within 7 days\n`/invite list` - Invitations still valid\n`/invite revoke <id>` - Revoke one (`/invite revoke` for all)",
+	"invite_error":             "❌ Failed to create the invitation: %v",
+	"invite_lobby_full":        "⚠️ The lobby is full. The owner can allow more members with `/members max <n>`.",
+	"invite_expired":           "⌛ This invitation has expired. Ask for a new one (`/invite`).",
+	"invite_used_up":           "⚠️ This invitation was already used. Ask for a new one (`/invite`).",
+	"invite_revoked":           "🚫 This invitation was revoked. Ask for a new one (`/invite`).",
+	"invite_already_member":    "ℹ️ You are already in this lobby.",
+	"invite_not_found":         "⚠️ Invitation not found or no longer valid. See them with `/invite list`.",
+	"invite_revoked_all":       "✅ %d invitation(s) revoked.",
+	"invite_revoked_one":       "✅ Invitation #%d revoked.",
+	"invite_none":              "No invitations are valid right now. Create one with `/invite`.",
+	"invite_list_header":       "📨 *Valid invitations*\n\n",
+	"invite_list_item":         "#%d by %s - %d/%d joins, until %s\n",
+	"invite_list_used_by":      "   Joined: %s\n",
+	"invite_list_hint":         "\n`/invite revoke <id>` - Revoke one",
+
+	// Join lockouts
+	"join_locked":         "🔒 Too many failed attempts to join a lobby. You can try again after %s.",
+	"join_locked_later":   "🔒 Too many failed attempts to join a lobby. Try again later.",
+	"join_failures_alert": "🚨 %s (ID %d) failed %d times to join your lobby %s with invitations that did not work.\n\nIf you don't know them, don't share new invitations with them. See the valid ones with `/invite list` and revoke them with `/invite revoke`.",
+
+	// Lobby members
+	"lobby_members_info":      "Members (%d/%d): %s",
+	"members_header":          "👥 *Lobby Members* (%d/%d)\n\n",
+	"members_item":            "%d. %s - weight %g (%.1f%%)",
+	"members_owner":           " 👑",
+	"members_couple_hint":     "\nWith two members, expenses are split by `/settings salary` or `/income`; weights apply once a third member joins.\n",
+	"members_usage":           "❌ Usage: `/members [max <n>|share <member> <weight>]`\n\nExamples:\n`/members` - List members\n`/members max 4` - Allow up to 4 members\n`/members share user3 2` - user3 takes twice the share of a weight-1 member",
+	"members_max_updated":     "✅ The lobby now allows up to %d members.\n\n",
+	"members_share_updated":   "✅ %s's share weight is now %g.\n\n",
+	"members_error":           "❌ Failed to update members: %v",
+	"error_partner_ambiguous": "❌ This lobby has more than two members. Name the member instead: `user2`, `@username`...",
+	"paid_payee_required":     "❌ This lobby has more than two members. Say who received the payment: `/paid 15000 user3` (you paid user3) or `/paid 15000 user2 user3` (user2 paid user3).",
+	"balance_period_member":   "   %s: %s + %s − %s = %s\n",
+	"income_ratio_weights":    "\n📊 With more than two members, shared expenses are split by each member's weight (`/members`).\n",
+	"settle_settings_share":   "%s %.1f%% %s",
+
+	// Lobby list and switching
+	"lobby_unnamed":        "Lobby #%d",
+	"lobbies_header":       "🏠 *Your Lobbies*\n\n",
+	"lobbies_item":         "%s %s (#%d) - %s, %d/%d members\n",
+	"lobbies_kind_private": "private",
+	"lobbies_kind_group":   "group",
+	"lobbies_hint":         "\n✅ = the lobby this chat uses.\n`/use <id|name>` - Switch the lobby of your private chat\n`/lobbies name <name>` - Name this chat's lobby\n`/lobbies new [name]` - Create another private lobby",
+	"lobbies_usage":        "❌ Usage: `/lobbies [name <name>|new [name]]`\n\nExamples:\n`/lobbies` - List your lobbies\n`/lobbies name Home` - Name this chat's lobby\n`/lobbies new Roommates` - Create another private lobby and switch to it",
+	"lobbies_named":        "✅ Lobby #%d is now called *%s*.",
+	"lobbies_created":      "✅ Created %s. Commands in this private chat now act on it.\n\n",
+	"lobbies_private_only": "⚠️ This only works in a private chat with the bot; in a group, commands always act on the group's lobby.",
+	"lobbies_error":        "❌ Error: %v",
+	"use_not_found":        "⚠️ You are not in a lobby called \"%s\". See yours with `/lobbies`.",
+	"use_switched":         "✅ This private chat now uses *%s* (#%d).",
+
+	// Shared account deposits
+	"deposit_usage":          "❌ Usage: `/deposit <amount> [date] [partner] [note]`\n\nExamples:\n`/deposit 200000` - You put 200000 into the shared account\n`/deposit 150000 ayer partner` - Your partner deposited yesterday\n`/deposit` - Deposits against each share this month\n`/deposit 2026-09` - For another month\n`/deposit history` - Every deposit\n`/deposit delete <id>` - Delete a deposit",
+	"deposit_not_shared":     "⚠️ Deposits are for lobbies with a shared account. Switch with `/settings account_type shared`.",
+	"deposit_recorded":       "✅ Deposit #%d recorded: %s put %s into the shared account on %s\n",
+	"deposit_error":          "❌ Failed to record deposit: %v",
+	"deposit_deleted":        "✅ Deposit #%d deleted.",
+	"deposit_not_found":      "⚠️ Deposit not found. See the IDs with `/deposit history`.",
+	"deposit_history_header": "🏦 *Deposits into the shared account:*\n",
+	"deposit_history_item":   "#%d %s: %s %s\n",
+	"deposit_history_note":   "   %s\n",
+	"deposit_history_none":   "No deposits recorded yet. Record one with `/deposit <amount>`.",
+	"shared_account_header":  "\n🏦 *Shared account* (%s to %s)\n",
+	"shared_account_spent":   "Paid from the account: %s\n",
+	"shared_account_member":  "• %s: deposited %s, share %s\n",
+	"shared_account_short":   "➡️ %s deposited %s less than their share\n",
+	"shared_account_even":    "✅ Deposits cover each member's share.\n",
+	"shared_account_balance": "💰 Account balance: %s at the start, %s at the end\n",
+	"shared_account_error":   "❌ Error calculating the shared account: %v",
+
 	// Summary
 	"summary_none":          "📊 *Summary*\n\nNo expenses found for %s.",
 	"summary_header":        "📊 *Spending Summary*\n\nPeriod: %s\nTotal Expenses: %s\nNumber of Expenses: %d\n\n",
@@ -217,24 +629,47 @@ With category:
 
 With payment method:
 • ` + "`/add 50.00 Groceries Food Visa`" + `
-• ` + "`/add 25.50 Dinner Cash`" + `
+• ` + "`/add 25.50 Dinner Cash`" + ` (no category)
+
+Without payment method, your default one is used (` + "`/payment_methods default Visa`" + `, or per category: ` + "`/payment_methods default YPF Fuel`" + `)
+
+In installments (credit cards only):
+• ` + "`/add 120000 TV Electronics Visa 6x`" + `
+• ` + "`/add 90000 Phone Tech Visa 12x +15%`" + ` (15% interest)
+
+In another currency (converted with ` + "`/rate`" + `):
+• ` + "`/add 30usd Hotel Travel`" + `
+• ` + "`/add 120 Dinner Food Visa USD`" + `
+
+Not split by the lobby ratio:
+• ` + "`/add 8000 Haircut split:personal`" + ` (all yours)
+• ` + "`/add 15000 Gift split:partner`" + ` (all your partner's)
+• ` + "`/add 12000 Dinner split:70%`" + ` (you pay 70%)
+• ` + "`/add 12000 Dinner split:4000`" + ` (you pay 4000)
 
 For your partner:
 • ` + "`/add 50.00 Groceries Food Visa partner`" + `
 • ` + "`/add 25.50 Dinner partner`" + `
 
+Without a command (on in private chats; in a group turn it on with ` + "`/settings quick_capture on`" + `):
+• ` + "`1500 super visa`" + `
+• ` + "`ayer 3200 nafta`" + `
+• ` + "`15/03 20usd taxi`" + `
+
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 
 ✏️ *EDIT EXPENSES* (` + "`/edit`" + `)
 
 Format: ` + "`/edit <expense_id> <field> <value>`" + `
 
-Fields: ` + "`category`" + `, ` + "`payment_method`" + ` (or ` + "`payment`" + `)
+Fields: ` + "`category`" + `, ` + "`payment_method`" + ` (or ` + "`payment`" + `), ` + "`split`" + `
 
 Examples:
 • ` + "`/edit 123 category Groceries`" + `
 • ` + "`/edit 456 payment_method Visa`" + `
 • ` + "`/edit 789 payment Cash`" + `
+• ` + "`/edit 321 split personal`" + ` (only the spender's)
+• ` + "`/edit 321 split 70%`" + ` (spender pays 70%)
 
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 
@@ -251,9 +686,74 @@ Without ID shows last 10 expenses:
 
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 
+💸 *SETTLEMENT PAYMENTS* (` + "`/paid`" + `, ` + "`/balance`" + `)
+
+• ` + "`/paid 15000`" + ` (you paid your partner)
+• ` + "`/paid 8000 ayer partner`" + ` (your partner paid you yesterday)
+• ` + "`/balance`" + ` (what is still owed, carried across months)
+• ` + "`/balance history`" + ` (every month and payment)
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+🏦 *SHARED ACCOUNT* (` + "`/deposit`" + `)
+
+• ` + "`/deposit 200000`" + ` (you put money into the joint account)
+• ` + "`/deposit 150000 ayer partner`" + ` (your partner did, yesterday)
+• ` + "`/deposit`" + ` (deposits against each share and the account balance)
+📊 With ` + "`/settings account_type shared`" + `, ` + "`/settle`" + ` also compares deposits with each share
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+👥 *ROOMMATES AND FAMILIES* (` + "`/members`" + `)
+
+• ` + "`/members max 4`" + ` (the owner lets up to 4 members join with the invite)
+• ` + "`/members share user3 2`" + ` (user3 takes twice the share of the others)
+• ` + "`/add 9000 super user3`" + ` (user3 spent it)
+• ` + "`/paid 12000 user3`" + ` (you paid user3)
+📊 ` + "`/settle`" + ` lists the fewest transfers that settle everyone
+• ` + "`/remove_member user3`" + ` (the owner asks to take user3 out and another member approves; their expenses stay in past settlements)
+• ` + "`/transfer_owner partner`" + ` then ` + "`/leave`" + ` (hand the lobby over and move out)
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+🏠 *SEVERAL LOBBIES* (` + "`/lobbies`" + `, ` + "`/use`" + `)
+
+• ` + "`/lobbies`" + ` (your lobbies; ✅ marks the one this chat uses)
+• ` + "`/lobbies name Home`" + ` (name this chat's lobby)
+• ` + "`/lobbies new Roommates`" + ` (another private lobby, e.g. with a roommate)
+• ` + "`/use Home`" + ` (back to the lobby with your partner in private chat)
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+💼 *INCOMES* (` + "`/income`" + `)
+
+• ` + "`/income 850000`" + ` (your income this month)
+• ` + "`/income 600000 partner`" + ` (your partner's)
+• ` + "`/income 820000 2026-09`" + ` (for another month)
+📊 With both incomes of a month, its shared expenses are split by them; without them, by ` + "`/settings salary`" + `
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+💰 *BUDGETS* (` + "`/budget`" + `)
+
+• ` + "`/budget 300000`" + ` (overall monthly limit)
+• ` + "`/budget 80000 Groceries`" + ` (limit for a category)
+• ` + "`/budget`" + ` (spent vs. limit and days left)
+• ` + "`/budget alerts 80 100`" + ` (warn at 80% and 100%)
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+🧾 *RECONCILE A STATEMENT* (` + "`/reconcile`" + `)
+
+• ` + "`/reconcile Visa 2026-12 254300`" + ` (bank's total vs. logged, with a button to add the difference as an adjustment)
+• ` + "`/reconcile Visa 2026-12 254300 120000,84300,50000`" + ` (also matches the statement's items)
+🔒 Expenses of a reconciled statement ask for ` + "`confirm`" + ` before being added, edited or deleted
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
 💳 *ADD PAYMENT METHODS* (` + "`/payment_methods add`" + `)
 
-Format: ` + "`/payment_methods add <name> <type> [closing_day]`" + `
+Format: ` + "`/payment_methods add <name> <type> [closing_day] [due_day|+days]`" + `
 
 Accepted types:
 • ` + "`credit_card`" + `
@@ -264,12 +764,19 @@ Accepted types:
 
 Examples:
 • ` + "`/payment_methods add Visa credit_card 15`" + `
+• ` + "`/payment_methods add Master credit_card 20 5`" + ` (due on the 5th)
+• ` + "`/payment_methods add Amex credit_card 22 +10`" + ` (due 10 days after closing)
 • ` + "`/payment_methods add Debit debit_card`" + `
 • ` + "`/payment_methods add Cash cash`" + `
 • ` + "`/payment_methods add Transfer bank_transfer`" + `
 • ` + "`/payment_methods add PayPal other`" + `
 
 ⚠️ Credit cards require a closing day (1-31)
+📅 With a due date, the group gets a reminder 3 days before the statement is due
+🗓️ When the bank moves a closing date: ` + "`/payment_methods cycle Visa 2026-12 2026-12-22 2027-01-05`" + ` (December's statement closes on the 22nd, due on January 5th); its expenses move to the right statement
+🏖️ ` + "`/payment_methods edit 1 closing_shift before`" + ` closes on the previous business day when the closing falls on a weekend or a holiday (` + "`/payment_methods holidays add 2026-12-25`" + `)
+👤 ` + "`/payment_methods edit 1 owner partner`" + ` makes your partner the card's owner: what you spend on it counts as paid by them in ` + "`/settle`" + ` (` + "`shared`" + ` for the shared account)
+💰 ` + "`/payment_methods limit Visa 500000 80`" + ` sets the card's credit limit; the list shows the available credit and ` + "`/add`" + ` warns when 80% of it is in use
 
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 
@@ -11,7 +11,7 @@ var spanishARTranslations = map[string]string{
 	"lobby_joined_token":  "✅ ¡Te uniste exitosamente al lobby!",
 	"waiting_partner":     "Esperando que se una tu pareja...",
 	"partner_id":          "ID de Pareja: %d",
-	"lobby_security_info": "🔒 *Información de Seguridad:*\n\nTu lobby está protegido por un token de invitación. Compartí este token SOLO con tu pareja:\n\n`%s`\n\n*Cómo unirse:*\nTu pareja debería ejecutar:\n`/start %s`\n\n⚠️ ¡Mantené este token privado! Cualquiera con este token puede unirse a tu lobby.",
+	"lobby_security_info": "🔒 *Información de Seguridad:*\n\nTu lobby está protegido por un token de invitación. Compartí este token SOLO con tu pareja:\n\n`%s`\n\n*Cómo unirse:*\nTu pareja debería ejecutar:\n`/start %s`\n\n⏳ El token sirve una sola vez y vence el %s; creá otro con `/invite`.\n⚠️ ¡Mantené este token privado! Cualquiera con este token puede unirse a tu lobby.",
 
 	// Error messages
 	"error_user_init":        "❌ Error: No se pudo inicializar el usuario. Por favor intentá de nuevo.",
@@ -20,9 +20,9 @@ var spanishARTranslations = map[string]string{
 	"error_lobby_join":       "❌ No se pudo unir al lobby: %v",
 	"error_lobby_create":     "❌ Error: No se pudo crear el lobby. Por favor intentá de nuevo.",
 	"error_invalid_lobby_id": "❌ Token de invitación inválido. Uso: `/start <invite_token>` para unirte a un lobby existente.",
-	"error_invalid_token":    "❌ Token de invitación inválido o expirado. Por favor pedile a tu pareja un nuevo token.",
+	"error_invalid_token":    "❌ Token de invitación inválido. Por favor pedí una nueva invitación.",
 	"error_unknown_command":  "Comando desconocido. Usá /help para ver los comandos disponibles.",
-	"error_invalid_user_id":  "❌ ID de usuario inválido. Usá 'user1', 'user2', 'partner', o un ID de usuario válido de tu lobby.",
+	"error_invalid_user_id":  "❌ Miembro inválido. Usá 'user1', 'user2' (por orden de ingreso), 'partner', '@usuario', o un ID de usuario válido de tu lobby.",
 	"error_generic":          "❌ Error: %v",
 	"error_invalid_period":   "❌ Formato de período inválido. Usá YYYY-MM",
 
@@ -35,17 +35,26 @@ var spanishARTranslations = map[string]string{
 /examples - Mostrar ejemplos de uso de comandos
 
 *Gestión de Gastos:*
-/add <monto> <descripción> [categoría] [método_pago] - Agregar un gasto
+/add <monto> <descripción> [categoría] [método_pago] [Nx] [split:<modo>] - Agregar un gasto (Nx = cuotas)
 /list [mes] - Listar gastos (mes actual o especificado)
 /list_billing [método_pago] [período] - Listar gastos por ciclo de facturación
 /delete [id_gasto] - Eliminar un gasto (muestra gastos recientes si no se proporciona ID)
-/edit <id_gasto> <campo> <valor> - Editar un gasto (campos: category, payment_method)
+/edit <id_gasto> <campo> <valor> - Editar un gasto (campos: category, payment_method, split)
+/recurring [add|delete] - Gestionar gastos recurrentes (alquiler, suscripciones)
+O simplemente escribí el gasto: ` + "`1500 super visa`" + `, ` + "`ayer 3200 nafta`" + `
 
 *Reportes y Análisis:*
 /summary [fecha_inicio] [fecha_fin] - Obtener resumen de gastos
 /summary_billing [método_pago] [período] - Obtener resumen por ciclo de facturación
 /settle - Calcular quién le debe a quién
 /settle_billing [método_pago] [período] - Calcular liquidación para período de facturación
+/paid <monto> [fecha] [pagador] [receptor] [nota] - Registrar un pago entre miembros
+/balance [history] - Saldo acumulado entre meses y pagos
+/deposit [monto [fecha] [pareja]|history] - Depósitos en la cuenta común
+/income [monto [mes] [pareja]|delete] - Ingresos mensuales que fijan la proporción
+/budget [monto [categoría]|status|alerts] - Presupuestos mensuales con avisos
+/reconcile <tarjeta> <mes> <total> [ítems] - Conciliar un resumen de tarjeta con el total del banco
+/rate [moneda valor [tipo] [fecha]] - Cotizaciones y moneda base
 
 *Configuración:*
 /payment_methods - Gestionar métodos de pago (agregar, editar, eliminar)
@@ -53,16 +62,45 @@ var spanishARTranslations = map[string]string{
   ` + "`/payment_methods`" + ` - Listar todos los métodos de pago
   ` + "`/payment_methods add Visa credit_card 15`" + ` - Agregar tarjeta de crédito con día de cierre 15
   ` + "`/payment_methods edit 1 closing_day 20`" + ` - Editar método de pago #1
+  ` + "`/payment_methods edit 1 due_day 5`" + ` - El resumen vence el 5 (aviso 3 días antes)
+  ` + "`/payment_methods cycle Visa 2026-12 2026-12-22`" + ` - El resumen de diciembre cierra el 22
+  ` + "`/payment_methods edit 1 closing_shift before`" + ` - Cerrar el día hábil anterior si cae en fin de semana/feriado
+  ` + "`/payment_methods holidays add 2026-12-25`" + ` - Agregar un feriado
+  ` + "`/payment_methods limit Visa 500000 80`" + ` - Límite de crédito, aviso al 80% de uso
+  ` + "`/payment_methods default Visa`" + ` - Tu método de pago predeterminado (` + "`default YPF Nafta`" + ` para una categoría)
+  ` + "`/payment_methods edit 1 owner partner`" + ` - Tu pareja paga los resúmenes de esta tarjeta
   ` + "`/payment_methods delete 1`" + ` - Eliminar método de pago #1
 
-/categories - Gestionar categorías
-  Ejemplo: ` + "`/categories`" + ` - Listar todas las categorías
+/categories - Gestionar categorías (agregar, renombrar, unir, archivar)
+  Ejemplos:
+  ` + "`/categories`" + ` - Listar todas las categorías
+  ` + "`/categories merge Super Supermercado`" + ` - Pasar todos los gastos de Super a Supermercado
+  ` + "`/categories archive Regalos`" + ` - Ocultar una categoría que ya no usás
+
+/rules - Categorizar gastos automáticamente por descripción
+  Ejemplos:
+  ` + "`/rules add netflix Entretenimiento Visa`" + ` - Los gastos de Netflix van a Entretenimiento, pagados con Visa
+  ` + "`/rules learn`" + ` - Proponer reglas a partir de tus gastos
+  ` + "`/rules apply`" + ` - Categorizar gastos anteriores sin categoría
 
 /settings - Configurar tipo de cuenta, porcentajes de sueldo
   Ejemplos:
   ` + "`/settings`" + ` - Mostrar configuración actual
   ` + "`/settings account_type shared`" + ` - Establecer tipo de cuenta compartida
   ` + "`/settings salary 0.6 0.4`" + ` - Establecer porcentajes de sueldo (60% usuario1, 40% usuario2)
+  ` + "`/settings salary 0.6 0.4 2026-09`" + ` - Desde el 1 de septiembre; los meses anteriores mantienen los suyos
+  ` + "`/settings history`" + ` - Configuración vigente a lo largo del tiempo
+  ` + "`/settings quick_capture on|off`" + ` - Agregar gastos desde mensajes comunes (activado de entrada solo en chats privados)
+
+/members [max <n>|share <miembro> <peso>] - Miembros del lobby (convivientes, familias) y sus pesos
+/leave - Salir del lobby; tus gastos quedan en el historial
+/remove_member <miembro> - Sacar a un miembro (quien administra, con aprobación de otro miembro)
+/transfer_owner <miembro> - Pasarle el lobby a otro miembro
+
+/lobbies [name <nombre>|new [nombre]] - Tus lobbies (pareja, convivientes...), nombrarlos y crearlos
+/use <id|nombre> - Cambiar el lobby de tu chat privado
+
+/invite [duración] [ingresos] - Invitar a alguien (` + "`/invite 7d 2`" + `, ` + "`/invite list`" + `, ` + "`/invite revoke <id>`" + `)
 
 /language - Cambiar idioma
   Ejemplos:
@@ -79,41 +117,185 @@ var spanishARTranslations = map[string]string{
 Para más detalles, usá cada comando sin argumentos para ver su uso.`,
 
 	// Settings
-	"settings_current":      "⚙️ *Configuración Actual del Lobby*\n\nID del Lobby: `%d`\nTipo de Cuenta: `%s`\nSueldo Usuario 1 %%: %.1f%%\nSueldo Usuario 2 %%: %.1f%%\n\n*Para cambiar la configuración:*\n`/settings account_type <separate|shared>`\n`/settings salary <user1_pct> <user2_pct>`\n\nEjemplo:\n`/settings account_type shared`\n`/settings salary 0.6 0.4`",
+	"settings_current":      "⚙️ *Configuración Actual del Lobby*\n\nID del Lobby: `%d`\nTipo de Cuenta: `%s`\nSueldo Usuario 1 %%: %.1f%%\nSueldo Usuario 2 %%: %.1f%%\nCarga rápida: %s\n\n*Para cambiar la configuración:*\n`/settings account_type <separate|shared> [desde]`\n`/settings salary <user1_pct> <user2_pct> [desde]`\n`/settings quick_capture <on|off>`\n`/settings history` - Configuraciones anteriores\n\nEjemplo:\n`/settings account_type shared`\n`/settings salary 0.6 0.4 2026-09` (desde el 1 de septiembre)",
 	"settings_updated":      "✅ ¡Configuración actualizada exitosamente!",
-	"settings_usage":        "❌ Uso: `/settings account_type <separate|shared>`",
+	"settings_usage":        "❌ Uso: `/settings account_type <separate|shared> [desde]`",
 	"settings_invalid_type": "❌ El tipo de cuenta debe ser 'separate' o 'shared'",
-	"settings_salary_usage": "❌ Uso: `/settings salary <porcentaje_user1> <porcentaje_user2>`\nEjemplo: `/settings salary 0.6 0.4`",
+	"settings_salary_usage": "❌ Uso: `/settings salary <porcentaje_user1> <porcentaje_user2> [desde]`\nEjemplo: `/settings salary 0.6 0.4`, o `/settings salary 0.6 0.4 2026-09` desde el 1 de septiembre",
 	"settings_invalid_pct":  "❌ Valores de porcentaje inválidos. Usá números entre 0 y 1.",
 	"settings_pct_range":    "❌ Los porcentajes deben estar entre 0 y 1.",
-	"settings_unknown":      "❌ Configuración desconocida. Usá `account_type` o `salary`.",
+	"settings_unknown":      "❌ Configuración desconocida. Usá `account_type`, `salary`, `quick_capture` o `history`.",
 	"settings_error":        "❌ No se pudo actualizar la configuración: %v",
 
+	// Categories
+	"category_usage":         "❌ Uso:\n`/categories` - Listar categorías\n`/categories add <nombre>`\n`/categories rename <nombre> <nuevo nombre>`\n`/categories merge <origen> <destino>`\n`/categories archive <nombre>`\n`/categories unarchive <nombre>`",
+	"category_none":          "📂 Todavía no hay categorías. Agregá una con `/categories add <nombre>`.",
+	"category_list":          "📂 *Categorías:*\n\n%s\n",
+	"category_item":          "• %s (%d)",
+	"category_archived_list": "\n🗄 Archivadas: %s\n",
+	"category_list_hint":     "\nAdministralas con `/categories add|rename|merge|archive`.",
+	"category_added":         "✅ Categoría *%s* agregada.",
+	"category_rename_usage":  "❌ Uso: `/categories rename <nombre> <nuevo nombre>`\n\nEjemplo: `/categories rename Super Supermercado`",
+	"category_renamed":       "✅ Categoría *%s* renombrada a *%s*. Sus gastos se actualizaron.",
+	"category_merge_usage":   "❌ Uso: `/categories merge <origen> <destino>`\n\nEjemplo: `/categories merge Super Supermercado`",
+	"category_merged":        "✅ *%s* unida a *%s* (%d gastos movidos).",
+	"category_archived":      "🗄 Categoría *%s* archivada. Sus gastos la conservan; restaurala con `/categories unarchive %s`.",
+	"category_unarchived":    "✅ Categoría *%s* restaurada.",
+	"category_not_found":     "⚠️ %v. Mirá la lista con `/categories`.",
+	"category_exists":        "⚠️ %v.",
+	"category_error":         "❌ Error de categoría: %v",
+	"category_is_archived":   "⚠️ La categoría *%s* está archivada. Restaurala con `/categories unarchive %s` o elegí otra.",
+	"category_suggest":       "🤔 No existe la categoría *%s*. ¿Quisiste decir:\n%s\n\nUsá una de esas, o creala con `/categories add %s`.",
+	"category_created_note":  "🆕 Se creó la categoría *%s*.\n",
+
+	// Categorization rules
+	"rule_usage":         "❌ Uso:\n`/rules` - Listar reglas\n`/rules add <palabra|re:regex> <categoría> [método_pago]`\n`/rules delete <id>`\n`/rules learn` - Proponer reglas a partir de gastos anteriores\n`/rules accept <n...|all>` - Crear las reglas propuestas\n`/rules apply` - Categorizar gastos anteriores sin categoría",
+	"rule_add_usage":     "❌ Uso: `/rules add <palabra|re:regex> <categoría> [método_pago]`\n\nEjemplos:\n`/rules add netflix Entretenimiento Visa`\n`/rules add uber Transporte`\n`/rules add re:^(coto|dia|jumbo) Supermercado`",
+	"rule_none":          "🏷 Todavía no hay reglas. Los gastos cargados sin categoría quedan sin categorizar.\n\nAgregá una con `/rules add <palabra> <categoría>` o dejá que el bot te proponga con `/rules learn`.",
+	"rule_list":          "🏷 *Reglas de categorización* (gana la primera que coincide):\n\n%s\n\nSe aplican a los gastos nuevos cargados sin categoría.",
+	"rule_item":          "#%d `%s` → %s",
+	"rule_item_payment":  " · %s",
+	"rule_added":         "✅ Regla #%d agregada: `%s` → %s\n",
+	"rule_apply_hint":    "\nUsá `/rules apply` para categorizar los gastos anteriores sin categoría.",
+	"rule_deleted":       "✅ Regla #%d eliminada.",
+	"rule_not_found":     "⚠️ Regla no encontrada. Mirá los IDs con `/rules`.",
+	"rule_error":         "❌ Error de regla: %v",
+	"rule_learn_none":    "🤷 Todavía no hay nada para aprender: ninguna palabra aparece con la misma categoría en al menos dos gastos, o tus reglas ya las cubren.",
+	"rule_learn_header":  "🧠 *Reglas aprendidas de tus gastos:*\n\n",
+	"rule_learn_item":    "%d. `%s` → %s (%d gastos)",
+	"rule_learn_footer":  "\nCrealas con `/rules accept 1 3` o `/rules accept all`.",
+	"rule_learn_invalid": "⚠️ No hay una propuesta número %s. Usá `/rules learn` para verlas.",
+	"rule_accepted":      "✅ %d reglas creadas:\n%s\n",
+	"rule_applied":       "✅ Tus reglas categorizaron %d gastos sin categoría.",
+	"rule_applied_held":  "\n🔒 Quedaron sin tocar %d gastos de resúmenes conciliados. Para categorizarlos también, enviá `/rules apply confirm`.",
+
+	// Budgets
+	"budget_usage":                "❌ Uso:\n`/budget` - Estado de los presupuestos del mes\n`/budget <monto> [categoría]` - Fijar un presupuesto mensual (sin categoría = total)\n`/budget delete [categoría]` - Borrar un presupuesto\n`/budget status [AAAA-MM]` - Gastado vs. límite\n`/budget alerts <porcentaje...>` - Umbrales de aviso, ej. `/budget alerts 80 100`",
+	"budget_none":                 "💰 Todavía no hay presupuestos.\n\nFijá uno con `/budget 300000` (total) o `/budget 80000 Supermercado`.",
+	"budget_overall":              "Total",
+	"budget_set":                  "✅ Presupuesto mensual de *%s*: %s\n",
+	"budget_set_spent":            "En lo que va del mes: %s (%.0f%%)",
+	"budget_deleted":              "✅ Presupuesto de *%s* borrado.",
+	"budget_not_found":            "⚠️ No hay un presupuesto para eso. Mirá la lista con `/budget status`.",
+	"budget_error":                "❌ Error de presupuesto: %v",
+	"budget_thresholds":           "🔔 Los avisos de presupuesto se envían al %s del límite.\n\nCambialos con `/budget alerts 80 100`.",
+	"budget_thresholds_set":       "✅ Los avisos de presupuesto se van a enviar al %s del límite.",
+	"budget_thresholds_usage":     "❌ Uso: `/budget alerts <porcentaje...>`, ej. `/budget alerts 50 80 100`",
+	"budget_status_header":        "💰 *Presupuestos de %s*\n",
+	"budget_status_days_left":     "Quedan %d días del mes\n",
+	"budget_status_item":          "\n%s *%s*: %s / %s (%.0f%%)\n",
+	"budget_status_left":          "   Quedan %s\n",
+	"budget_status_left_per_day":  "   Quedan %s (%s por día)\n",
+	"budget_status_over":          "   %s por encima del límite\n",
+	"budget_alert":                "⚠️ Presupuesto de *%s* al %d%%: %s gastado de %s en %s (quedan %d días).",
+	"budget_alert_total":          "⚠️ Presupuesto total al %d%%: %s gastado de %s en %s (quedan %d días).",
+	"budget_alert_exceeded":       "🔴 Presupuesto de *%s* superado: %s gastado de %s (%s de más) en %s, y quedan %d días.",
+	"budget_alert_exceeded_total": "🔴 Presupuesto total superado: %s gastado de %s (%s de más) en %s, y quedan %d días.",
+
 	// Payment methods
 	"payment_methods_none":            "📋 No hay métodos de pago configurados.\n\nAgregá uno con:\n`/payment_methods add <nombre> <tipo> [día_cierre]`\n\nTipos: credit_card (o TarjetaCredito), debit_card (o TarjetaDebito), cash (o Efectivo), bank_transfer (o Transferencia), other (o Otro)",
 	"payment_methods_list":            "📋 *Métodos de Pago:*\n\n%s",
 	"payment_method_item":             "%s *%s* (%s)",
 	"payment_method_closing":          " - Cierra el día %d",
-	"payment_method_owner":            " - Dueño: %d",
+	"payment_method_owner":            " - Titular: %s",
 	"payment_method_added":            "✅ ¡Método de pago *%s* creado exitosamente!",
 	"payment_method_closing_day":      "\nDía de cierre: %d",
-	"payment_method_add_usage":        "❌ Uso: `/payment_methods add <nombre> <tipo> [día_cierre]`\n\nTipos: credit_card (o TarjetaCredito), debit_card (o TarjetaDebito), cash (o Efectivo), bank_transfer (o Transferencia), other (o Otro)\nEjemplo: `/payment_methods add Visa credit_card 15` o `/payment_methods add Visa TarjetaCredito 15`",
+	"payment_method_add_usage":        "❌ Uso: `/payment_methods add <nombre> <tipo> [día_cierre] [día_vencimiento|+días]`\n\nTipos: credit_card (o TarjetaCredito), debit_card (o TarjetaDebito), cash (o Efectivo), bank_transfer (o Transferencia), other (o Otro)\nEjemplo: `/payment_methods add Visa credit_card 20 5` (cierra el 20, vence el 5)\nO: `/payment_methods add Visa TarjetaCredito 20 +10` (vence 10 días después del cierre)",
 	"payment_method_closing_required": "❌ Las tarjetas de crédito requieren un día de cierre. Uso: `/payment_methods add <nombre> credit_card <día_cierre>`",
 	"payment_method_closing_invalid":  "❌ El día de cierre debe ser un número entre 1 y 31",
 	"payment_method_not_found":        "⚠️ Método de pago '%s' no encontrado.",
-	"payment_method_not_found_list":   "⚠️ Método de pago '%s' no encontrado.\n\nMétodos disponibles:\n%s\n\nGasto agregado sin método de pago.",
+	"payment_method_not_found_list":   "⚠️ Método de pago '%s' no encontrado.\n\nMétodos disponibles:\n%s\n\nGasto agregado sin ese método (se usa tu predeterminado, si configuraste uno).",
 	"payment_method_add_error":        "❌ No se pudo crear el método de pago: %v",
-	"payment_method_edit_usage":       "❌ Uso: `/payment_methods edit <id> <campo> <valor>`\n\nCampos: name, type, closing_day, active\nEjemplo: `/payment_methods edit 1 closing_day 20`",
+	"payment_method_edit_usage":       "❌ Uso: `/payment_methods edit <id> <campo> <valor>`\n\nCampos: name, type, closing_day, closing_shift (`none`, `before` o `after` si cae en fin de semana/feriado), due_day (día, `+días` o `none`), due_offset, owner (`me`, `partner` o `shared`), active\nEjemplo: `/payment_methods edit 1 closing_day 20`\n`/payment_methods edit 1 closing_shift before`\n`/payment_methods edit 1 due_day 5`",
 	"payment_method_delete_usage":     "❌ Uso: `/payment_methods delete <id>`",
 	"payment_method_invalid_id":       "❌ ID de método de pago inválido",
 	"payment_method_update_error":     "❌ No se pudo actualizar el método de pago: %v",
 	"payment_method_delete_error":     "❌ No se pudo eliminar el método de pago: %v",
 	"payment_method_updated":          "✅ ¡Método de pago actualizado exitosamente!",
 	"payment_method_deleted":          "✅ ¡Método de pago eliminado exitosamente!",
-	"payment_method_unknown_action":   "❌ Acción desconocida. Usá: `add`, `edit`, o `delete`",
+	"payment_method_unknown_action":   "❌ Acción desconocida. Usá: `add`, `edit`, `delete`, `cycle`, `holidays`, `limit` o `default`",
+
+	// Statement due dates
+	"payment_method_due_day":         " - Vence el %d",
+	"payment_method_due_offset":      " - Vence %d días después del cierre",
+	"payment_method_due_day_line":    "\nDía de vencimiento: %d",
+	"payment_method_due_offset_line": "\nVencimiento: %d días después del cierre",
+	"payment_method_due_invalid":     "❌ El vencimiento debe ser un día del mes (1-31) o días después del cierre como `+10` (1-60)",
+	"billing_due_date":               "\n📅 Vencimiento: %s",
+	"payment_due_reminder":           "💳 El resumen de *%s* por %s vence en %d días (%s).\nPeríodo: %s a %s",
+	"payment_due_today":              "💳 El resumen de *%s* por %s vence *hoy*.\nPeríodo: %s a %s",
+
+	// Billing cycle calendars
+	"payment_method_cycle_usage":    "❌ Uso: `/payment_methods cycle <nombre> [<AAAA-MM> <fecha_cierre> [fecha_vencimiento]]`\n\nEjemplo: `/payment_methods cycle Visa 2026-12 2026-12-22 2027-01-05` (el resumen de diciembre cierra el 22 y vence el 5 de enero)\n`/payment_methods cycle Visa 2026-12 clear` vuelve al día de cierre fijo",
+	"payment_method_cycles_none":    "📅 *%s* cierra el %d todos los meses. No hay fechas de cierre específicas.",
+	"payment_method_cycles_header":  "📅 Fechas de cierre de *%s* (si no, el %d):\n\n",
+	"payment_method_cycle_item":     "• %s: cierra %s",
+	"payment_method_cycle_item_due": ", vence %s",
+	"payment_method_cycle_set":      "✅ El resumen de %[2]s de *%[1]s* cierra el %[3]s",
+	"payment_method_cycle_set_due":  ", vence %s",
+	"payment_method_cycle_moved":    "\n%d gastos pasaron a otro resumen.",
+	"payment_method_cycle_cleared":  "✅ El resumen de %[2]s de *%[1]s* vuelve a cerrar el %[3]d.\n%[4]d gastos pasaron a otro resumen.",
+
+	// Closing shifts and holidays
+	"payment_method_shift_before":  " (antes si es fin de semana/feriado)",
+	"payment_method_shift_after":   " (después si es fin de semana/feriado)",
+	"payment_method_shift_invalid": "❌ El corrimiento del cierre debe ser `none`, `before` (día hábil anterior) o `after` (día hábil siguiente)",
+	"holidays_usage":               "❌ Uso: `/payment_methods holidays [add <fecha> [nombre] | delete <fecha>]`\n\nEjemplo: `/payment_methods holidays add 2026-12-25 Navidad`",
+	"holidays_none":                "📅 No hay feriados. Agregá uno con `/payment_methods holidays add <fecha> [nombre]`",
+	"holidays_header":              "📅 *Feriados:*\n\n",
+	"holidays_item":                "• %s %s\n",
+	"holiday_added":                "✅ %s ahora es feriado.\n%d gastos pasaron a otro resumen.",
+	"holiday_deleted":              "✅ %s ya no es feriado.\n%d gastos pasaron a otro resumen.",
+
+	// Credit limits
+	"payment_method_credit":             " - Disponible %s de %s",
+	"payment_method_credit_limit":       " - Límite %s",
+	"payment_method_limit_usage":        "❌ Uso: `/payment_methods limit <nombre> [<monto>|none] [aviso%]`\n\nEjemplo: `/payment_methods limit Visa 500000 80` (avisa cuando se usa el 80% del límite)\n`/payment_methods limit Visa` muestra el crédito disponible",
+	"payment_method_limit_warn_invalid": "❌ El aviso tiene que ser un porcentaje del límite entre 1 y 100, como `80%`",
+	"payment_method_limit_none":         "💳 *%[1]s* no tiene límite de crédito. Configuralo con `/payment_methods limit %[1]s <monto>`",
+	"payment_method_limit_set":          "✅ Límite de crédito de *%s*: %s\nTe vamos a avisar cuando se use el %d%% del límite.",
+	"payment_method_limit_cleared":      "✅ *%s* ya no tiene límite de crédito.",
+	"payment_method_limit_status":       "💳 *%s*\n\nLímite: %s\nAdeudado: %s (%.0f%%)\nDisponible: %s\n\nLo adeudado incluye resúmenes impagos, el abierto y las cuotas futuras. Aviso al %d%% del límite.",
+	"payment_method_limit_exceeded":     "\n🚨 Excedido por %s",
+	"expense_credit_warning":            "\n⚠️ *%s* está al %.0f%% de su límite: quedan %s disponibles de %s.",
+	"expense_credit_exceeded":           "\n🚨 *%s* superó su límite por %s (límite %s).",
+
+	// Default payment methods
+	"payment_method_defaults_none":            "💳 No tenés método de pago predeterminado.\n\nConfiguralo con `/payment_methods default <nombre>`, o para una categoría con `/payment_methods default <nombre> <categoría>`.",
+	"payment_method_defaults_header":          "💳 *Tus métodos de pago predeterminados:*\n\n",
+	"payment_method_defaults_item":            "• Cualquier categoría: %s\n",
+	"payment_method_defaults_category_item":   "• %s: %s\n",
+	"payment_method_default_set":              "✅ Tus gastos sin método de pago van a usar *%s*.",
+	"payment_method_default_category_set":     "✅ Tus gastos de *%[2]s* sin método de pago van a usar *%[1]s*.",
+	"payment_method_default_cleared":          "✅ Se quitó tu método de pago predeterminado.",
+	"payment_method_default_category_cleared": "✅ Se quitó tu método de pago predeterminado para *%s*.",
+	"payment_method_default_not_set":          "⚠️ No había método de pago predeterminado para quitar.",
+	"expense_default_applied":                 "💳 Se usó tu método de pago predeterminado: %s\n",
+	"expense_default_category_applied":        "💳 Se usó tu método de pago predeterminado para %[2]s: %[1]s\n",
+
+	// Statement reconciliation
+	"reconcile_usage":                  "❌ Uso: `/reconcile <tarjeta> <AAAA-MM> <total> [montos de los ítems...]`\n\nEjemplo: `/reconcile Visa 2026-12 254300`\nCon los ítems del resumen: `/reconcile Visa 2026-12 254300 120000,84300,50000`",
+	"reconcile_error":                  "❌ Error al conciliar el resumen: %v",
+	"reconcile_header":                 "🧾 Resumen de *%s* de %s\nPeríodo: %s a %s\n\n",
+	"reconcile_totals":                 "Banco: %s\nRegistrado: %s (%d gastos)\n",
+	"reconcile_match":                  "✅ Los totales coinciden.\n",
+	"reconcile_missing":                "⚠️ El banco cobró %s más de lo registrado (comisiones, impuestos o gastos faltantes).\n",
+	"reconcile_extra":                  "⚠️ Se registraron %s más de lo que cobró el banco.\n",
+	"reconcile_other_currencies":       "También registrado en otras monedas: %s\n",
+	"reconcile_out_of_cycle_header":    "\n📅 Registrados justo fuera de este resumen (el banco puede haberlos incluido):\n",
+	"reconcile_items_missing":          "\n❓ En el resumen pero no registrados: %s\n",
+	"reconcile_items_unmatched_header": "\n❓ Registrados pero no en el resumen:\n",
+	"reconcile_marked":                 "\n🔒 Resumen marcado como conciliado; agregar, editar o borrar sus gastos pedirá confirmación.",
+	"reconcile_adjust_button":          "➕ Agregar ajuste de %s",
+	"reconcile_adjust_description":     "Ajuste %s %s",
+	"reconcile_adjusted":               "✅ Se agregó un ajuste de %s al resumen de *%s* de %s (ID: %d).",
+	"reconcile_already_adjusted":       "Este resumen ya tiene un ajuste",
+	"reconcile_nothing_to_adjust":      "Nada que ajustar: lo registrado ya alcanza el total del banco",
+	"reconcile_confirm_edit":           "🔒 Este gasto está en el resumen conciliado de *%s* de %s.\nPara cambiarlo igual, enviá:\n`%s confirm`",
+	"reconcile_confirm_change":         "🔒 Esto cae en un resumen de tarjeta que ya está conciliado.\nPara hacerlo igual, enviá:\n`%s confirm`",
 
 	// Expenses
-	"expense_add_usage":           "❌ Uso: `/add <monto> <descripción> [categoría] [método_pago]`\n\nEjemplos:\n`/add 50.00 Supermercado`\n`/add 25.50 Cena tarjeta_1`",
+	"expense_add_usage":           "❌ Uso: `/add <monto> <descripción> [categoría] [método_pago] [moneda] [Nx] [+interés%] [split:<modo>]`\n\nEjemplos:\n`/add 50.00 Supermercado`\n`/add 25.50 Cena tarjeta_1`\n`/add 120000 TV Electro Visa 6x`\n`/add 30usd Hotel Viajes`\n`/add 8000 Peluquería split:personal`\n`/add 12000 Cena split:70%`",
 	"expense_invalid_amount":      "❌ Monto inválido. Por favor proporcioná un número positivo.",
 	"expense_added":               "✅ ¡Gasto agregado!\n\nMonto: %s\nDescripción: %s\n",
 	"expense_category":            "Categoría: %s\n",
@@ -138,15 +320,106 @@ Para más detalles, usá cada comando sin argumentos para ver su uso.`,
 	"expense_delete_not_found":    "❌ Gasto no encontrado o no pertenece a tu lobby.",
 	"expense_delete_error":        "❌ No se pudo eliminar el gasto: %v",
 	"expense_deleted":             "✅ ¡Gasto eliminado exitosamente!",
-	"expense_edit_usage":          "❌ Uso: `/edit <id_gasto> <campo> <valor>`\n\nCampos: `category` (categoría), `payment_method` (método_pago)\n\nEjemplos:\n`/edit 123 category Supermercado`\n`/edit 123 payment_method Visa`",
+	"expense_edit_usage":          "❌ Uso: `/edit <id_gasto> <campo> <valor>`\n\nCampos: `category` (categoría), `payment_method` (método_pago), `split` (reparto)\n\nEjemplos:\n`/edit 123 category Supermercado`\n`/edit 123 payment_method Visa`\n`/edit 123 split personal`",
 	"expense_edit_invalid_id":     "❌ ID de gasto inválido. Uso: `/edit <id_gasto> <campo> <valor>`",
 	"expense_edit_not_found":      "❌ Gasto no encontrado o no pertenece a tu lobby.",
 	"expense_edit_category_usage": "❌ Uso: `/edit <id_gasto> category <nombre_categoría>`",
 	"expense_edit_payment_usage":  "❌ Uso: `/edit <id_gasto> payment_method <nombre_método_pago>`",
-	"expense_edit_invalid_field":  "❌ Campo inválido. Usá `category` o `payment_method`.",
+	"expense_edit_invalid_field":  "❌ Campo inválido. Usá `category`, `payment_method` o `split`.",
 	"expense_edit_error":          "❌ No se pudo editar el gasto: %v",
 	"expense_edited":              "✅ ¡Gasto actualizado exitosamente!",
 
+	// Installments (cuotas)
+	"expense_installment_label":      " (cuota %d/%d)",
+	"expense_installments_invalid":   "❌ Cuotas inválidas. Usá por ejemplo `6x` (entre 2 y 48 cuotas) y opcionalmente `+10%` de interés.\n\nEjemplo: `/add 120000 TV Electro Visa 6x +10%`",
+	"expense_installments_need_card": "❌ Las compras en cuotas necesitan una tarjeta de crédito con día de cierre.\n\nEjemplo: `/add 120000 TV Electro Visa 6x`",
+	"expense_added_installments":     "✅ ¡Compra en cuotas agregada!\n\nTotal: %s (%d cuotas)\nDescripción: %s\n",
+	"expense_installment_interest":   "Interés: %.1f%%\n",
+	"expense_installment_line":       "• Cuota %d/%d: %s (%s a %s)\n",
+	"expense_installments_header":    "\n*Cuotas:*\n",
+	"expense_installment_item":       "• %s%s: %s\n",
+	"expense_deleted_installments":   "✅ ¡Gasto eliminado junto con sus %d cuotas restantes!",
+	"expense_installments_kept":      "\nℹ️ Se mantienen %d cuotas ya facturadas.",
+
+	// Recurring expenses
+	"recurring_usage":           "❌ Uso:\n`/recurring` - Listar gastos recurrentes\n`/recurring add <monto> <descripción> <semanal|mensual|anual> [día] [categoría] [método_pago] [fecha_fin] [pareja]`\n`/recurring delete <id>`",
+	"recurring_add_usage":       "❌ Uso: `/recurring add <monto> <descripción> <semanal|mensual|anual> [día] [categoría] [método_pago] [fecha_fin] [pareja]`\n\nEjemplos:\n`/recurring add 15000 Alquiler mensual 5 Vivienda`\n`/recurring add 9.99 Netflix mensual 12 Entretenimiento Visa`\n`/recurring add 3000 Limpieza semanal`",
+	"recurring_delete_usage":    "❌ Uso: `/recurring delete <id>`",
+	"recurring_none":            "📭 No hay gastos recurrentes. Agregá uno con `/recurring add`.",
+	"recurring_list_header":     "🔁 *Gastos Recurrentes:*\n\n",
+	"recurring_item":            "#%d - %s - %s\n  %s · próximo: %s\n",
+	"recurring_until":           "  Hasta: %s\n",
+	"recurring_cadence_weekly":  "semanal",
+	"recurring_cadence_monthly": "mensual el día %d",
+	"recurring_cadence_yearly":  "anual el %s",
+	"recurring_added":           "✅ Gasto recurrente #%d agregado: %s - %s\n%s · próximo: %s\n",
+	"recurring_invalid_cadence": "❌ Frecuencia inválida. Usá `semanal`, `mensual` o `anual`.",
+	"recurring_invalid_day":     "❌ Día inválido. Usá un día del mes entre 1 y 31.",
+	"recurring_add_error":       "❌ Error al agregar gasto recurrente: %v",
+	"recurring_not_found":       "❌ Gasto recurrente no encontrado.",
+	"recurring_deleted":         "✅ Gasto recurrente #%d detenido. Los gastos ya agregados se mantienen.",
+	"recurring_materialized":    "🔁 Gasto recurrente agregado: %s - %s (%s) [regla #%d]",
+	"recurring_held":            "⚠️ Gasto recurrente no agregado: %s - %s (%s) [regla #%d]. Cae en un resumen de tarjeta que ya está conciliado; si corresponde, cargalo a mano.",
+
+	// Quick capture (expenses from plain messages)
+	"quick_capture_date":           "Fecha: %s\n",
+	"quick_capture_undo":           "↩️ Deshacer",
+	"quick_capture_edit":           "✏️ Editar",
+	"quick_capture_undone":         "↩️ Deshecho: %s %s",
+	"quick_capture_edit_hint":      "✏️ *Editar gasto #%d*\n\n`/edit %d category <nombre>`\n`/edit %d payment_method <nombre>`\n`/edit %d split <shared|personal|partner|70%%>`\n\nPara cargarlo de nuevo desde cero, eliminalo con `/delete %d`.",
+	"quick_capture_confirm":        "💸 ¿Agrego %s %s como gasto?",
+	"quick_capture_add":            "✅ Agregar",
+	"quick_capture_ignore":         "✖️ Ignorar",
+	"quick_capture_ignored":        "✖️ No se agregó.",
+	"quick_capture_not_yours":      "Solo quien escribió el mensaje puede responder.",
+	"quick_capture_gone":           "Ese mensaje ya no está disponible. Escribí el gasto de nuevo.",
+	"settings_on":                  "activada",
+	"settings_off":                 "desactivada",
+	"settings_quick_capture_usage": "❌ Uso: `/settings quick_capture <on|off>`",
+	"settings_quick_capture_on":    "✅ Carga rápida activada. Mensajes como `1500 super visa` o `ayer 3200 nafta` se agregan como gastos.",
+	"settings_quick_capture_off":   "✅ Carga rápida desactivada. Usá /add para agregar gastos.",
+
+	// Settings history
+	"settings_updated_from":       "✅ Configuración actualizada desde el %s, hasta el próximo cambio. Los períodos anteriores mantienen su configuración.",
+	"settings_invalid_date":       "❌ Fecha inválida. Usá AAAA-MM-DD, un mes (AAAA-MM) o una palabra como `ayer`.",
+	"settings_future_date":        "❌ La configuración no puede regir desde el futuro. Cambiala ese día, o indicá una fecha pasada.",
+	"settings_history_header":     "📜 *Historial de configuración*\n\n",
+	"settings_history_now":        "hoy",
+	"settings_history_item":       "• %s → %s: `%s`, %.1f%% | %.1f%%\n",
+	"settings_history_changed_by": "   cambiada por %s\n",
+	"settings_history_hint":       "\nLas liquidaciones usan la configuración vigente en la fecha de cada gasto. Corregí un cambio pasado con `/settings salary 0.6 0.4 2026-09-01`.",
+	"settle_settings_header":      "⚙️ *La configuración o los miembros cambiaron durante el período:*\n",
+	"settle_settings_item":        "• Desde %s sobre %s: %s\n",
+
+	// Exchange rates and currencies
+	"rate_usage":            "❌ Uso:\n`/rate` - Ver moneda base y últimas cotizaciones\n`/rate <moneda> <valor> [tipo] [fecha]` - Cargar una cotización (1 moneda = valor en moneda base)\n`/rate <moneda>` - Historial de cotizaciones\n`/rate base <moneda>` - Definir la moneda base del lobby\n`/rate type <tipo>` - Definir el tipo de cotización por defecto\n`/rate delete <id>` - Eliminar una cotización\n\nEjemplos:\n`/rate USD 1050`\n`/rate USD 1180 mep 2024-05-01`\n`/rate USD 1650 tarjeta`",
+	"rate_header":           "💱 *Cotizaciones*\n\nMoneda base: %s\nTipo de cotización por defecto: %s\n\n",
+	"rate_history_header":   "💱 *Historial de cotizaciones de %s:*\n\n",
+	"rate_none":             "Todavía no hay cotizaciones. Agregá una con `/rate <moneda> <valor> [tipo] [fecha]`.\n",
+	"rate_item":             "#%d • 1 %s = %s %s (%s, desde %s)\n",
+	"rate_set":              "✅ Cotización guardada: 1 %s = %s %s (%s) desde %s",
+	"rate_invalid_currency": "❌ Moneda desconocida: %s\n\nSoportadas: ARS, USD, EUR, BRL, UYU, CLP, GBP",
+	"rate_invalid_value":    "❌ Cotización inválida. Ingresá un número positivo.",
+	"rate_invalid_type":     "❌ Tipo de cotización inválido: %s\n\nUsá uno de: %s",
+	"rate_error":            "❌ Error al guardar la cotización: %v",
+	"rate_base_set":         "✅ Moneda base configurada en %s. Los resúmenes y liquidaciones ahora se convierten a %s.",
+	"rate_type_set":         "✅ Tipo de cotización por defecto: %s. Los gastos con tarjeta de crédito siguen usando la cotización `tarjeta` si existe.",
+	"rate_deleted":          "✅ Cotización #%d eliminada.",
+	"rate_not_found":        "❌ Cotización no encontrada.",
+	"rate_missing":          "⚠️ Falta una cotización: %v\n\nAgregá una con `/rate <moneda> <valor> [tipo] [fecha]`.",
+	"summary_converted":     "Incluye %s, convertido a %s con la cotización de la fecha de cada gasto.\n\n",
+
+	// Split modes
+	"expense_split":         "Reparto: %s\n",
+	"expense_split_invalid": "❌ Reparto inválido. Usá `split:shared`, `split:personal`, `split:partner`, `split:70%` (tu parte) o `split:3000` (tu parte como monto).",
+	"split_shared":          "compartido (proporción del lobby)",
+	"split_personal":        "personal (100% de quien pagó)",
+	"split_partner":         "para la pareja (100% del otro miembro)",
+	"split_custom_percent":  "personalizado: %.0f%% quien pagó / %.0f%% el otro",
+	"split_custom_amount":   "personalizado: quien pagó pone %s",
+	"settle_split_header":   "\n👤 *Fuera de la proporción del lobby* (parte compartida: %s)\n",
+	"settle_split_item":     "• %s %s, %s\n   %s\n",
+
 	// Settlement
 	"settle_usage":          "❌ Uso: `/settle_billing <método_pago> [período]`\n\nEjemplo: `/settle_billing Visa 2024-01`",
 	"settle_error":          "❌ Error al calcular la liquidación: %v",
@@ -162,6 +435,145 @@ Para más detalles, usá cada comando sin argumentos para ver su uso.`,
 	"settle_user2_owes":     "➡️ Usuario 2 le debe a Usuario 1: %s\n",
 	"settle_all_settled":    "✅ ¡Todo saldado! Sin deudas.\n",
 
+	// Card owners in settlements
+	"settle_paid_header":           "💳 *Quién paga los resúmenes* (titulares de tarjetas):\n",
+	"settle_paid_line":             "%s paga: %s\n",
+	"settle_paid_shared_account":   "La cuenta común paga: %s\n",
+	"settle_owner_paid_header":     "\n💳 *Gastado con la tarjeta de otro:*\n",
+	"settle_owner_paid_item":       "• %s %s, gastó %s, paga %s\n",
+	"payment_method_owner_invalid": "❌ El titular tiene que ser `me`, `partner`, el ID de Telegram de un miembro, o `shared`",
+
+	// Monthly incomes
+	"income_usage":          "❌ Uso:\n`/income` - Ingresos de este mes y la proporción que fijan\n`/income <monto> [AAAA-MM] [pareja]` - Registrar un ingreso mensual (el tuyo por defecto)\n`/income delete [AAAA-MM] [pareja]` - Borrar un ingreso\n`/income list [AAAA-MM]` - Ingresos de un mes\n\nEjemplo: `/income 850000` y `/income 600000 pareja`",
+	"income_set":            "✅ Ingreso de %s para %s: %s\n\n",
+	"income_deleted":        "🗑️ Ingreso de %s para %s borrado.",
+	"income_not_found":      "⚠️ %s no tiene un ingreso registrado para %s.",
+	"income_none":           "💼 No hay ingresos registrados para %s.\n\nRegistrá el tuyo con `/income 850000` y el de tu pareja con `/income 600000 pareja`: los gastos compartidos del mes se dividen según ellos en lugar de `/settings salary`.",
+	"income_header":         "💼 *Ingresos de %s*\n\n",
+	"income_line":           "%s: %s\n",
+	"income_missing":        "—",
+	"income_ratio":          "\n📊 Este mes se divide %s %.1f%% | %s %.1f%%\n",
+	"income_ratio_fallback": "\n📊 Hasta que estén los dos ingresos, este mes se divide según la configuración del lobby (%.1f%% | %.1f%%).\n",
+	"income_error":          "❌ Error al guardar el ingreso: %v",
+	"settle_income_ratio":   "📊 Proporción según los ingresos de %s: %s %s | %s %s\n",
+
+	// Settlement payments and running balance
+	"paid_usage":             "❌ Uso: `/paid <monto> [fecha] [pagador] [receptor] [nota]`\n\nEjemplos:\n`/paid 15000` - Le pagaste 15000 a tu pareja\n`/paid 50usd ayer transferencia` - Pago de ayer\n`/paid 8000 pareja` - Tu pareja te pagó\n`/paid 12000 user3` - Le pagaste a user3 (lobbies con más miembros)\n`/paid 12000 user2 user3` - user2 le pagó a user3\n`/paid delete <id>` - Eliminar un pago",
+	"paid_recorded":          "✅ Pago #%d registrado: %s le pagó a %s %s el %s\n\n",
+	"paid_error":             "❌ Error al registrar el pago: %v",
+	"paid_deleted":           "✅ Pago #%d eliminado.",
+	"paid_not_found":         "⚠️ Pago no encontrado. Mirá los IDs con `/balance history`.",
+	"balance_header":         "📒 *Saldo Acumulado*\n\n",
+	"balance_owes":           "➡️ %s le debe a %s: %s\n",
+	"balance_settled":        "✅ ¡Todo saldado! No hay deudas.\n",
+	"balance_error":          "❌ Error al calcular el saldo: %v",
+	"balance_periods_header": "\n*Por mes* (arrastre + gastos − pagos = saldo):\n",
+	"balance_period_item":    "• %s\n",
+	"balance_history_header": "\n💸 *Pagos:*\n",
+	"balance_history_item":   "#%d %s: %s → %s %s\n",
+	"balance_history_note":   "   %s\n",
+	"balance_history_none":   "\nTodavía no hay pagos registrados. Registrá uno con `/paid <monto>`.\n",
+	"balance_history_hint":   "\nHistorial completo: `/balance history`\n",
+	"settle_running_balance": "\n📒 *Saldo al %s* (incluye meses anteriores y pagos):\n",
+
+	// Leaving and removing members
+	"leave_done":                    "👋 Saliste de %s. Tus gastos quedan en su historial.",
+	"leave_owner_must_transfer":     "❌ Este lobby es tuyo. Primero pasáselo a otro miembro con `/transfer_owner <miembro>` y después usá `/leave`.",
+	"leave_notice":                  "👋 %s salió del lobby %s. Sus gastos quedan en el historial y hay lugar para alguien más (`/invite`).",
+	"remove_member_usage":           "❌ Uso: `/remove_member <miembro>`\n\nEjemplos:\n`/remove_member pareja`\n`/remove_member user3`\n`/remove_member @ana`",
+	"remove_member_self":            "❌ No podés sacarte del lobby. Pasale el lobby a otro miembro con `/transfer_owner` y después usá `/leave`.",
+	"remove_member_confirm":         "⚠️ %s quiere sacar a %s del lobby %s.\n\nSus gastos quedan en el historial y sus gastos recurrentes se detienen. Va a necesitar una nueva invitación para volver. Otro miembro tiene que aprobarlo.",
+	"remove_member_button":          "✅ Sacar a %s",
+	"remove_member_cancel_button":   "❌ Que se quede",
+	"remove_member_done":            "✅ %s ya no está en el lobby. Hay lugar para alguien más (`/invite`).",
+	"remove_member_cancelled":       "❌ %s decidió que %s se quede en el lobby.",
+	"remove_member_gone":            "%s ya no es miembro.",
+	"remove_member_notice":          "🚪 Te sacaron del lobby %s. Quien lo administra puede volver a invitarte.",
+	"remove_member_requested":       "⏳ Les pedimos a los demás miembros que aprueben sacar a %s.",
+	"remove_member_not_approver":    "Solo otro miembro del lobby puede aprobarlo.",
+	"remove_member_outdated":        "Este pedido ya no vale: el lobby tiene otra persona que lo administra.",
+	"remove_member_approved_notice": "✅ %s lo aprobó: %s ya no está en el lobby %s.",
+	"remove_member_rejected_notice": "❌ %s no aprobó sacar a %s del lobby %s.",
+	"error_member_removed":          "🚫 Te sacaron del lobby de este grupo. Pedile una invitación a quien lo administra y unite con `/start <token>`.",
+	"transfer_owner_usage":          "❌ Uso: `/transfer_owner <miembro>`\n\nQuien lo recibe administra los miembros y las invitaciones del lobby.\n\nEjemplos:\n`/transfer_owner pareja`\n`/transfer_owner @ana`",
+	"transfer_owner_done":           "👑 Ahora %s administra el lobby %s.",
+	"transfer_owner_notice":         "👑 %s te pasó el lobby %s: ahora administrás sus miembros e invitaciones.",
+
+	// Invitations
+	"error_not_lobby_owner":    "❌ Solo quien administra el lobby puede hacer esto.",
+	"invite_token_display":     "📨 *Invitación*\n\nToken: `%s`\nPara unirse, ejecutar: `/start %s`\n\n⏳ Sirve para %d ingreso(s), hasta el %s.\n⚠️ Compartilo en privado: cualquiera con este token puede unirse a tu lobby.",
+	"invite_token_regenerated": "🔄 Se revocaron todas las invitaciones anteriores.\n\n",
+	"invite_usage":             "❌ Uso: `/invite [duración] [ingresos]`\n\nEjemplos:\n`/invite` - Un ingreso, válida por 48 horas\n`/invite 12h` - Válida por 12 horas\n`/invite 7d 2` - Dos ingresos dentro de 7 días\n`/invite list` - Invitaciones vigentes\n`/invite revoke <id>` - Revocar una (`/invite revoke` para todas)",
+	"invite_error":             "❌ No se pudo crear la invitación: %v",
+	"invite_lobby_full":        "⚠️ El lobby está completo. Quien lo administra puede permitir más miembros con `/members max <n>`.",
+	"invite_expired":           "⌛ Esta invitación venció. Pedí una nueva (`/invite`).",
+	"invite_used_up":           "⚠️ Esta invitación ya se usó. Pedí una nueva (`/invite`).",
+	"invite_revoked":           "🚫 Esta invitación fue revocada. Pedí una nueva (`/invite`).",
+	"invite_already_member":    "ℹ️ Ya estás en este lobby.",
+	"invite_not_found":         "⚠️ No se encontró la invitación o ya no es válida. Mirá las vigentes con `/invite list`.",
+	"invite_revoked_all":       "✅ %d invitación(es) revocada(s).",
+	"invite_revoked_one":       "✅ Invitación #%d revocada.",
+	"invite_none":              "No hay invitaciones vigentes. Creá una con `/invite`.",
+	"invite_list_header":       "📨 *Invitaciones vigentes*\n\n",
+	"invite_list_item":         "#%d de %s - %d/%d ingresos, hasta el %s\n",
+	"invite_list_used_by":      "   Ingresaron: %s\n",
+	"invite_list_hint":         "\n`/invite revoke <id>` - Revocar una",
+
+	// Join lockouts
+	"join_locked":         "🔒 Demasiados intentos fallidos de unirse a un lobby. Podés volver a intentar después del %s.",
+	"join_locked_later":   "🔒 Demasiados intentos fallidos de unirse a un lobby. Probá de nuevo más tarde.",
+	"join_failures_alert": "🚨 %s (ID %d) intentó %d veces unirse a tu lobby %s con invitaciones que no sirvieron.\n\nSi no sabés quién es, no le compartas nuevas invitaciones. Mirá las vigentes con `/invite list` y revocalas con `/invite revoke`.",
+
+	// Lobby members
+	"lobby_members_info":      "Miembros (%d/%d): %s",
+	"members_header":          "👥 *Miembros del Lobby* (%d/%d)\n\n",
+	"members_item":            "%d. %s - peso %g (%.1f%%)",
+	"members_owner":           " 👑",
+	"members_couple_hint":     "\nCon dos miembros, los gastos se dividen según `/settings salary` o `/income`; los pesos se aplican cuando se suma un tercer miembro.\n",
+	"members_usage":           "❌ Uso: `/members [max <n>|share <miembro> <peso>]`\n\nEjemplos:\n`/members` - Ver los miembros\n`/members max 4` - Permitir hasta 4 miembros\n`/members share user3 2` - user3 paga el doble que un miembro de peso 1",
+	"members_max_updated":     "✅ El lobby ahora permite hasta %d miembros.\n\n",
+	"members_share_updated":   "✅ El peso de %s ahora es %g.\n\n",
+	"members_error":           "❌ Error al actualizar los miembros: %v",
+	"error_partner_ambiguous": "❌ Este lobby tiene más de dos miembros. Nombrá al miembro: `user2`, `@usuario`...",
+	"paid_payee_required":     "❌ Este lobby tiene más de dos miembros. Indicá quién recibió el pago: `/paid 15000 user3` (le pagaste a user3) o `/paid 15000 user2 user3` (user2 le pagó a user3).",
+	"balance_period_member":   "   %s: %s + %s − %s = %s\n",
+	"income_ratio_weights":    "\n📊 Con más de dos miembros, los gastos compartidos se dividen según el peso de cada miembro (`/members`).\n",
+	"settle_settings_share":   "%s %.1f%% %s",
+
+	// Lobby list and switching
+	"lobby_unnamed":        "Lobby #%d",
+	"lobbies_header":       "🏠 *Tus Lobbies*\n\n",
+	"lobbies_item":         "%s %s (#%d) - %s, %d/%d miembros\n",
+	"lobbies_kind_private": "privado",
+	"lobbies_kind_group":   "grupo",
+	"lobbies_hint":         "\n✅ = el lobby que usa este chat.\n`/use <id|nombre>` - Cambiar el lobby de tu chat privado\n`/lobbies name <nombre>` - Ponerle nombre al lobby de este chat\n`/lobbies new [nombre]` - Crear otro lobby privado",
+	"lobbies_usage":        "❌ Uso: `/lobbies [name <nombre>|new [nombre]]`\n\nEjemplos:\n`/lobbies` - Ver tus lobbies\n`/lobbies name Casa` - Ponerle nombre al lobby de este chat\n`/lobbies new Depto` - Crear otro lobby privado y pasar a usarlo",
+	"lobbies_named":        "✅ El lobby #%d ahora se llama *%s*.",
+	"lobbies_created":      "✅ Se creó %s. Los comandos de este chat privado ahora lo usan.\n\n",
+	"lobbies_private_only": "⚠️ Esto solo funciona en un chat privado con el bot; en un grupo, los comandos siempre usan el lobby del grupo.",
+	"lobbies_error":        "❌ Error: %v",
+	"use_not_found":        "⚠️ No estás en un lobby llamado \"%s\". Mirá los tuyos con `/lobbies`.",
+	"use_switched":         "✅ Este chat privado ahora usa *%s* (#%d).",
+
+	// Shared account deposits
+	"deposit_usage":          "❌ Uso: `/deposit <monto> [fecha] [pareja] [nota]`\n\nEjemplos:\n`/deposit 200000` - Pusiste 200000 en la cuenta común\n`/deposit 150000 ayer pareja` - Tu pareja depositó ayer\n`/deposit` - Depósitos frente a la parte de cada uno este mes\n`/deposit 2026-09` - De otro mes\n`/deposit history` - Todos los depósitos\n`/deposit delete <id>` - Eliminar un depósito",
+	"deposit_not_shared":     "⚠️ Los depósitos son para lobbies con cuenta común. Cambiala con `/settings account_type shared`.",
+	"deposit_recorded":       "✅ Depósito #%d registrado: %s puso %s en la cuenta común el %s\n",
+	"deposit_error":          "❌ Error al registrar el depósito: %v",
+	"deposit_deleted":        "✅ Depósito #%d eliminado.",
+	"deposit_not_found":      "⚠️ Depósito no encontrado. Mirá los IDs con `/deposit history`.",
+	"deposit_history_header": "🏦 *Depósitos en la cuenta común:*\n",
+	"deposit_history_item":   "#%d %s: %s %s\n",
+	"deposit_history_note":   "   %s\n",
+	"deposit_history_none":   "Todavía no hay depósitos registrados. Registrá uno con `/deposit <monto>`.",
+	"shared_account_header":  "\n🏦 *Cuenta común* (%s a %s)\n",
+	"shared_account_spent":   "Pagado desde la cuenta: %s\n",
+	"shared_account_member":  "• %s: depositó %s, su parte %s\n",
+	"shared_account_short":   "➡️ %s depositó %s menos que su parte\n",
+	"shared_account_even":    "✅ Los depósitos cubren la parte de cada uno.\n",
+	"shared_account_balance": "💰 Saldo de la cuenta: %s al inicio, %s al final\n",
+	"shared_account_error":   "❌ Error al calcular la cuenta común: %v",
+
 	// Summary
 	"summary_none":          "📊 *Resumen*\n\nNo se encontraron gastos para %s.",
 	"summary_header":        "📊 *Resumen de Gastos*\n\nPeríodo: %s\nTotal de Gastos: %s\nCantidad de Gastos: %d\n\n",
@@ -217,24 +629,47 @@ Con categoría:
 
 Con método de pago:
 • ` + "`/add 50.00 Supermercado Comida Visa`" + `
-• ` + "`/add 25.50 Cena Efectivo`" + `
+• ` + "`/add 25.50 Cena Efectivo`" + ` (sin categoría)
+
+Sin método de pago se usa tu predeterminado (` + "`/payment_methods default Visa`" + `, o por categoría: ` + "`/payment_methods default YPF Nafta`" + `)
+
+En cuotas (solo tarjetas de crédito):
+• ` + "`/add 120000 TV Electro Visa 6x`" + `
+• ` + "`/add 90000 Celular Tecnología Visa 12x +15%`" + ` (15% de interés)
+
+En otra moneda (se convierte con ` + "`/rate`" + `):
+• ` + "`/add 30usd Hotel Viajes`" + `
+• ` + "`/add 120 Cena Comida Visa USD`" + `
+
+Fuera de la proporción del lobby:
+• ` + "`/add 8000 Peluquería split:personal`" + ` (todo tuyo)
+• ` + "`/add 15000 Regalo split:partner`" + ` (todo de tu pareja)
+• ` + "`/add 12000 Cena split:70%`" + ` (vos ponés el 70%)
+• ` + "`/add 12000 Cena split:4000`" + ` (vos ponés 4000)
 
 Para tu pareja:
 • ` + "`/add 50.00 Supermercado Comida Visa pareja`" + `
 • ` + "`/add 25.50 Cena partner`" + `
 
+Sin comando (activado en chats privados; en un grupo se activa con ` + "`/settings quick_capture on`" + `):
+• ` + "`1500 super visa`" + `
+• ` + "`ayer 3200 nafta`" + `
+• ` + "`15/03 20usd taxi`" + `
+
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 
 ✏️ *EDITAR GASTOS* (` + "`/edit`" + `)
 
 Formato: ` + "`/edit <id_gasto> <campo> <valor>`" + `
 
-Campos: ` + "`category`" + `, ` + "`payment_method`" + ` (o ` + "`payment`" + `)
+Campos: ` + "`category`" + `, ` + "`payment_method`" + ` (o ` + "`payment`" + `), ` + "`split`" + `
 
 Ejemplos:
 • ` + "`/edit 123 category Supermercado`" + `
 • ` + "`/edit 456 payment_method Visa`" + `
 • ` + "`/edit 789 payment Efectivo`" + `
+• ` + "`/edit 321 split personal`" + ` (solo de quien pagó)
+• ` + "`/edit 321 split 70%`" + ` (quien pagó pone el 70%)
 
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 
@@ -251,9 +686,74 @@ Sin ID muestra últimos 10 gastos:
 
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 
+💸 *PAGOS ENTRE USTEDES* (` + "`/paid`" + `, ` + "`/balance`" + `)
+
+• ` + "`/paid 15000`" + ` (le pagaste a tu pareja)
+• ` + "`/paid 8000 ayer pareja`" + ` (tu pareja te pagó ayer)
+• ` + "`/balance`" + ` (lo que se sigue debiendo, arrastrado entre meses)
+• ` + "`/balance history`" + ` (cada mes y cada pago)
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+🏦 *CUENTA COMÚN* (` + "`/deposit`" + `)
+
+• ` + "`/deposit 200000`" + ` (pusiste plata en la cuenta común)
+• ` + "`/deposit 150000 ayer pareja`" + ` (tu pareja depositó ayer)
+• ` + "`/deposit`" + ` (depósitos frente a la parte de cada uno y saldo de la cuenta)
+📊 Con ` + "`/settings account_type shared`" + `, ` + "`/settle`" + ` también compara los depósitos con la parte de cada uno
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+👥 *CONVIVIENTES Y FAMILIAS* (` + "`/members`" + `)
+
+• ` + "`/members max 4`" + ` (quien administra el lobby permite hasta 4 miembros con la invitación)
+• ` + "`/members share user3 2`" + ` (user3 paga el doble que el resto)
+• ` + "`/add 9000 super user3`" + ` (lo gastó user3)
+• ` + "`/paid 12000 user3`" + ` (le pagaste a user3)
+📊 ` + "`/settle`" + ` muestra la menor cantidad de transferencias para saldar todo
+• ` + "`/remove_member user3`" + ` (quien administra pide sacar a user3 y otro miembro lo aprueba; sus gastos quedan en las liquidaciones pasadas)
+• ` + "`/transfer_owner pareja`" + ` y después ` + "`/leave`" + ` (pasar el lobby e irte)
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+🏠 *VARIOS LOBBIES* (` + "`/lobbies`" + `, ` + "`/use`" + `)
+
+• ` + "`/lobbies`" + ` (tus lobbies; ✅ marca el que usa este chat)
+• ` + "`/lobbies name Casa`" + ` (ponerle nombre al lobby de este chat)
+• ` + "`/lobbies new Depto`" + ` (otro lobby privado, por ejemplo con quien convivís)
+• ` + "`/use Casa`" + ` (volver al lobby con tu pareja en el chat privado)
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+💼 *INGRESOS* (` + "`/income`" + `)
+
+• ` + "`/income 850000`" + ` (tu ingreso de este mes)
+• ` + "`/income 600000 pareja`" + ` (el de tu pareja)
+• ` + "`/income 820000 2026-09`" + ` (para otro mes)
+📊 Con los dos ingresos de un mes, sus gastos compartidos se dividen según ellos; sin ellos, según ` + "`/settings salary`" + `
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+💰 *PRESUPUESTOS* (` + "`/budget`" + `)
+
+• ` + "`/budget 300000`" + ` (límite mensual total)
+• ` + "`/budget 80000 Supermercado`" + ` (límite de una categoría)
+• ` + "`/budget`" + ` (gastado vs. límite y días que quedan)
+• ` + "`/budget alerts 80 100`" + ` (avisar al 80% y al 100%)
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+🧾 *CONCILIAR UN RESUMEN* (` + "`/reconcile`" + `)
+
+• ` + "`/reconcile Visa 2026-12 254300`" + ` (total del banco vs. lo registrado, con un botón para agregar la diferencia como ajuste)
+• ` + "`/reconcile Visa 2026-12 254300 120000,84300,50000`" + ` (también compara los ítems del resumen)
+🔒 Los gastos de un resumen conciliado piden ` + "`confirm`" + ` antes de agregarlos, editarlos o borrarlos
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
 💳 *AGREGAR MÉTODOS DE PAGO* (` + "`/payment_methods add`" + `)
 
-Formato: ` + "`/payment_methods add <nombre> <tipo> [día_cierre]`" + `
+Formato: ` + "`/payment_methods add <nombre> <tipo> [día_cierre] [día_vencimiento|+días]`" + `
 
 Tipos aceptados (inglés o español):
 • ` + "`credit_card`" + ` / ` + "`TarjetaCredito`" + ` / ` + "`tarjeta_credito`" + `
@@ -265,12 +765,19 @@ Tipos aceptados (inglés o español):
 Ejemplos:
 • ` + "`/payment_methods add Visa credit_card 15`" + `
 • ` + "`/payment_methods add Visa TarjetaCredito 15`" + `
+• ` + "`/payment_methods add Master credit_card 20 5`" + ` (vence el 5)
+• ` + "`/payment_methods add Amex credit_card 22 +10`" + ` (vence 10 días después del cierre)
 • ` + "`/payment_methods add Debito TarjetaDebito`" + `
 • ` + "`/payment_methods add Efectivo cash`" + `
 • ` + "`/payment_methods add Transferencia bank_transfer`" + `
 • ` + "`/payment_methods add PayPal other`" + `
 
 ⚠️ Las tarjetas de crédito requieren día de cierre (1-31)
+📅 Con vencimiento, el grupo recibe un aviso 3 días antes de que venza el resumen
+🗓️ Cuando el banco mueve un cierre: ` + "`/payment_methods cycle Visa 2026-12 2026-12-22 2027-01-05`" + ` (el resumen de diciembre cierra el 22 y vence el 5 de enero); sus gastos pasan al resumen correcto
+🏖️ ` + "`/payment_methods edit 1 closing_shift before`" + ` cierra el día hábil anterior cuando el cierre cae en fin de semana o feriado (` + "`/payment_methods holidays add 2026-12-25`" + `)
+👤 ` + "`/payment_methods edit 1 owner partner`" + ` hace titular a tu pareja: lo que gastes con esa tarjeta cuenta como pagado por tu pareja en ` + "`/settle`" + ` (` + "`shared`" + ` para la cuenta común)
+💰 ` + "`/payment_methods limit Visa 500000 80`" + ` fija el límite de crédito de la tarjeta; la lista muestra el disponible y ` + "`/add`" + ` avisa cuando se usa el 80%
 
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 
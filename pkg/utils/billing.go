@@ -4,79 +4,144 @@ import (
 	"time"
 )
 
-// CalculateBillingPeriod calculates the billing period for an expense based on payment method closing day
-func CalculateBillingPeriod(expenseDate time.Time, closingDay int64) (start, end time.Time) {
-	expenseDay := expenseDate.Day()
-	expenseYear := expenseDate.Year()
-	expenseMonth := expenseDate.Month()
-
-	closingDayInt := int(closingDay)
-
-	if expenseDay < closingDayInt {
-		// Expense is before closing day, so it's in the current billing period
-		// Billing period starts on the 1st of current month (or day after previous closing)
-		start = time.Date(expenseYear, expenseMonth, 1, 0, 0, 0, 0, expenseDate.Location())
-		
-		// Billing period ends on closing day of current month
-		lastDayOfMonth := time.Date(expenseYear, expenseMonth+1, 0, 0, 0, 0, 0, expenseDate.Location()).Day()
-		endDay := closingDayInt
-		if endDay > lastDayOfMonth {
-			endDay = lastDayOfMonth
-		}
-		end = time.Date(expenseYear, expenseMonth, endDay, 23, 59, 59, 999999999, expenseDate.Location())
-	} else {
-		// Expense is on or after closing day, so it's in the next billing period
-		// Billing period starts on the day after closing day of current month
-		start = time.Date(expenseYear, expenseMonth, closingDayInt+1, 0, 0, 0, 0, expenseDate.Location())
-		
-		// Billing period ends on closing day of next month
-		nextMonth := expenseMonth + 1
-		nextYear := expenseYear
-		if nextMonth > 12 {
-			nextMonth = 1
-			nextYear++
-		}
-		lastDayOfNextMonth := time.Date(nextYear, nextMonth+1, 0, 0, 0, 0, 0, expenseDate.Location()).Day()
-		endDay := closingDayInt
-		if endDay > lastDayOfNextMonth {
-			endDay = lastDayOfNextMonth
-		}
-		end = time.Date(nextYear, nextMonth, endDay, 23, 59, 59, 999999999, expenseDate.Location())
+// ClosingCalendar maps a statement month ("2006-01") to the closing date the bank published for it.
+// Months without an entry close on the payment method's fixed closing day.
+type ClosingCalendar map[string]time.Time
+
+// ClosingShift says where a fixed closing day that falls on a weekend or holiday moves to
+type ClosingShift string
+
+// Closing shifts
+const (
+	ShiftNone   ClosingShift = "none"   // Close on the day itself
+	ShiftBefore ClosingShift = "before" // Close on the previous business day
+	ShiftAfter  ClosingShift = "after"  // Close on the next business day
+)
+
+// ValidClosingShift reports whether shift is a known closing shift
+func ValidClosingShift(shift ClosingShift) bool {
+	switch shift {
+	case ShiftNone, ShiftBefore, ShiftAfter:
+		return true
+	}
+	return false
+}
+
+// BillingPeriod is one statement of a payment method: from Start (midnight) to the end of its closing day.
+// Periods are always in UTC (see CalendarDate), whatever the location of the dates they were computed from,
+// so the periods stored with expenses match the ones queried later.
+type BillingPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// BillingCycles computes the statements of a payment method. Statements are contiguous and never overlap:
+// each one starts the day after the previous one closes, and a date belongs to the first statement
+// closing on or after it. The statement of a month is the one whose closing day falls in that month,
+// unless the bank published another closing date for it.
+type BillingCycles struct {
+	ClosingDay int             // Fixed closing day (1-31), clamped to the last day of short months
+	Calendar   ClosingCalendar // Published closing dates, used as they are
+	Shift      ClosingShift    // Where a fixed closing on a weekend or holiday moves to
+	Holidays   map[string]bool // Non-business days other than weekends, by "2006-01-02"
+}
+
+// NewBillingCycles returns the statements of a payment method closing on a fixed day every month
+func NewBillingCycles(closingDay int) BillingCycles {
+	return BillingCycles{ClosingDay: closingDay, Shift: ShiftNone}
+}
+
+// IsBusinessDay reports whether a day is neither a weekend nor a holiday
+func (c BillingCycles) IsBusinessDay(day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.Holidays[FormatDate(day)]
+}
+
+// Closing returns midnight of the closing day of a month's statement, in loc
+func (c BillingCycles) Closing(year int, month time.Month, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	if date, ok := c.Calendar[FormatMonth(first)]; ok {
+		return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
 	}
 
-	return start, end
+	closing := ClampedDate(first.Year(), first.Month(), c.ClosingDay, loc)
+	step := 0
+	switch c.Shift {
+	case ShiftBefore:
+		step = -1
+	case ShiftAfter:
+		step = 1
+	}
+	for step != 0 && !c.IsBusinessDay(closing) {
+		closing = closing.AddDate(0, 0, step)
+	}
+	return closing
 }
 
-// GetBillingPeriodForMonth returns the billing period dates for a given month and closing day
-func GetBillingPeriodForMonth(year int, month time.Month, closingDay int) (start, end time.Time) {
-	// Previous period ends on closing day of previous month
-	prevMonth := month - 1
-	prevYear := year
-	if prevMonth < 1 {
-		prevMonth = 12
-		prevYear--
+// period returns the statement of a month
+func (c BillingCycles) period(year int, month time.Month) BillingPeriod {
+	closing := c.Closing(year, month, time.UTC)
+	prevClosing := c.Closing(year, month-1, time.UTC)
+	return BillingPeriod{
+		Start: prevClosing.AddDate(0, 0, 1),
+		End:   CalendarDayEnd(closing),
 	}
-	
-	lastDayOfPrevMonth := time.Date(prevYear, prevMonth+1, 0, 0, 0, 0, 0, time.UTC).Day()
-	endDayPrev := closingDay
-	if endDayPrev > lastDayOfPrevMonth {
-		endDayPrev = lastDayOfPrevMonth
+}
+
+// statementMonth returns the first day of the month whose statement a date's calendar day belongs to
+func (c BillingCycles) statementMonth(date time.Time) time.Time {
+	day := CalendarDate(date)
+	month := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for c.Closing(month.Year(), month.Month(), time.UTC).Before(day) {
+		month = month.AddDate(0, 1, 0)
 	}
-	
-	// Current period starts day after previous closing
-	start = time.Date(year, month, closingDay+1, 0, 0, 0, 0, time.UTC)
-	if closingDay == lastDayOfPrevMonth {
-		start = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	for !c.Closing(month.Year(), month.Month()-1, time.UTC).Before(day) {
+		month = month.AddDate(0, -1, 0)
 	}
-	
-	// Current period ends on closing day of current month
-	lastDayOfMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
-	endDay := closingDay
-	if endDay > lastDayOfMonth {
-		endDay = lastDayOfMonth
+	return month
+}
+
+// PeriodOf returns the statement the calendar day of a date belongs to
+func (c BillingCycles) PeriodOf(date time.Time) BillingPeriod {
+	month := c.statementMonth(date)
+	return c.period(month.Year(), month.Month())
+}
+
+// PeriodForMonth returns the statement of a month
+func (c BillingCycles) PeriodForMonth(year int, month time.Month) BillingPeriod {
+	return c.period(year, month)
+}
+
+// LastClosed returns the most recent statement that closed before the given day
+func (c BillingCycles) LastClosed(date time.Time) BillingPeriod {
+	month := c.statementMonth(date)
+	return c.period(month.Year(), month.Month()-1)
+}
+
+// Periods returns count consecutive statements, starting with the one the date belongs to
+func (c BillingCycles) Periods(date time.Time, count int) []BillingPeriod {
+	month := c.statementMonth(date)
+	periods := make([]BillingPeriod, 0, count)
+	for i := 0; i < count; i++ {
+		periods = append(periods, c.period(month.Year(), month.Month()+time.Month(i)))
 	}
-	end = time.Date(year, month, endDay, 23, 59, 59, 999999999, time.UTC)
-	
-	return start, end
+	return periods
 }
 
+// CalculateDueDate returns when a statement closing on closingDate must be paid: offsetDays after
+// the closing when offsetDays is positive, otherwise the first dueDay after the closing
+// (clamped to the month's last day), e.g. closing on the 20th with due day 5 is due on the 5th of next month
+func CalculateDueDate(closingDate time.Time, dueDay int, offsetDays int) time.Time {
+	closing := StartOfDay(closingDate)
+	if offsetDays > 0 {
+		return closing.AddDate(0, 0, offsetDays)
+	}
+
+	due := ClampedDate(closing.Year(), closing.Month(), dueDay, closing.Location())
+	if !due.After(closing) {
+		due = ClampedDate(closing.Year(), closing.Month()+1, dueDay, closing.Location())
+	}
+	return due
+}
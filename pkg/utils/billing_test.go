@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestBillingCyclesPeriodOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		cycles BillingCycles
+		date   string
+		start  string
+		end    string
+	}{
+		{"before closing", NewBillingCycles(15), "2026-10-10", "2026-09-16", "2026-10-15"},
+		{"on closing day", NewBillingCycles(15), "2026-10-15", "2026-09-16", "2026-10-15"},
+		{"after closing", NewBillingCycles(15), "2026-10-16", "2026-10-16", "2026-11-15"},
+		{"first of month", NewBillingCycles(15), "2026-10-01", "2026-09-16", "2026-10-15"},
+		{"into next year", NewBillingCycles(20), "2026-12-21", "2026-12-21", "2027-01-20"},
+		{"from previous year", NewBillingCycles(20), "2027-01-05", "2026-12-21", "2027-01-20"},
+		{"closing 31 in February", NewBillingCycles(31), "2027-02-10", "2027-02-01", "2027-02-28"},
+		{"closing 31 after February", NewBillingCycles(31), "2027-03-01", "2027-03-01", "2027-03-31"},
+		{"closing 30 leap year", NewBillingCycles(30), "2028-02-29", "2028-01-31", "2028-02-29"},
+		{"closing 29 after short February", NewBillingCycles(29), "2027-03-01", "2027-03-01", "2027-03-29"},
+		{"closing 30 in April", NewBillingCycles(30), "2027-05-01", "2027-05-01", "2027-05-30"},
+		{"closing 1", NewBillingCycles(1), "2026-12-15", "2026-12-02", "2027-01-01"},
+		{"closing 31 in December", NewBillingCycles(31), "2026-12-31", "2026-12-01", "2026-12-31"},
+		{"closing 31 new year", NewBillingCycles(31), "2027-01-01", "2027-01-01", "2027-01-31"},
+		// 2026-11-15 is a Sunday
+		{"weekend closing moves before", BillingCycles{ClosingDay: 15, Shift: ShiftBefore}, "2026-11-13", "2026-10-16", "2026-11-13"},
+		{"weekend closing moves after", BillingCycles{ClosingDay: 15, Shift: ShiftAfter}, "2026-11-16", "2026-10-16", "2026-11-16"},
+		{"after shifted closing", BillingCycles{ClosingDay: 15, Shift: ShiftBefore}, "2026-11-15", "2026-11-14", "2026-12-15"},
+		// 2027-01-01 is a Friday holiday, 2-3 are the weekend
+		{"holiday closing moves after", BillingCycles{ClosingDay: 1, Shift: ShiftAfter, Holidays: map[string]bool{"2027-01-01": true}},
+			"2027-01-02", "2026-12-02", "2027-01-04"},
+		{"holiday closing moves before", BillingCycles{ClosingDay: 1, Shift: ShiftBefore, Holidays: map[string]bool{"2027-01-01": true}},
+			"2027-01-01", "2027-01-01", "2027-02-01"},
+		{"published closing", BillingCycles{ClosingDay: 20, Calendar: ClosingCalendar{"2026-12": date("2026-12-23")}},
+			"2026-12-22", "2026-11-21", "2026-12-23"},
+		{"after published closing", BillingCycles{ClosingDay: 20, Calendar: ClosingCalendar{"2026-12": date("2026-12-23")}},
+			"2026-12-24", "2026-12-24", "2027-01-20"},
+		{"published closing next month", BillingCycles{ClosingDay: 28, Calendar: ClosingCalendar{"2026-12": date("2027-01-02")}},
+			"2027-01-01", "2026-11-29", "2027-01-02"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period := tt.cycles.PeriodOf(date(tt.date))
+			if got := FormatDate(period.Start); got != tt.start {
+				t.Errorf("start = %s, want %s", got, tt.start)
+			}
+			if got := FormatDate(period.End); got != tt.end {
+				t.Errorf("end = %s, want %s", got, tt.end)
+			}
+			if period.End.Hour() != 23 || period.End.Minute() != 59 {
+				t.Errorf("end = %v, want the end of the closing day", period.End)
+			}
+		})
+	}
+}
+
+func TestBillingCyclesPeriodForMonth(t *testing.T) {
+	tests := []struct {
+		name   string
+		cycles BillingCycles
+		month  string
+		start  string
+		end    string
+	}{
+		{"mid month", NewBillingCycles(15), "2026-10", "2026-09-16", "2026-10-15"},
+		{"january", NewBillingCycles(15), "2027-01", "2026-12-16", "2027-01-15"},
+		{"december", NewBillingCycles(15), "2026-12", "2026-11-16", "2026-12-15"},
+		{"closing 31 march", NewBillingCycles(31), "2027-03", "2027-03-01", "2027-03-31"},
+		{"closing 31 february", NewBillingCycles(31), "2027-02", "2027-02-01", "2027-02-28"},
+		{"closing 30 leap february", NewBillingCycles(30), "2028-02", "2028-01-31", "2028-02-29"},
+		{"closing 30 march after leap february", NewBillingCycles(30), "2028-03", "2028-03-01", "2028-03-30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			month, _ := ParseMonth(tt.month)
+			period := tt.cycles.PeriodForMonth(month.Year(), month.Month())
+			if got := FormatDate(period.Start); got != tt.start {
+				t.Errorf("start = %s, want %s", got, tt.start)
+			}
+			if got := FormatDate(period.End); got != tt.end {
+				t.Errorf("end = %s, want %s", got, tt.end)
+			}
+		})
+	}
+}
+
+func TestBillingCyclesLastClosed(t *testing.T) {
+	tests := []struct {
+		name   string
+		cycles BillingCycles
+		date   string
+		start  string
+		end    string
+	}{
+		{"after closing", NewBillingCycles(20), "2026-10-25", "2026-09-21", "2026-10-20"},
+		{"on closing day", NewBillingCycles(20), "2026-10-20", "2026-08-21", "2026-09-20"},
+		{"across new year", NewBillingCycles(20), "2027-01-10", "2026-11-21", "2026-12-20"},
+		{"closing 31", NewBillingCycles(31), "2027-03-05", "2027-02-01", "2027-02-28"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period := tt.cycles.LastClosed(date(tt.date))
+			if got := FormatDate(period.Start); got != tt.start {
+				t.Errorf("start = %s, want %s", got, tt.start)
+			}
+			if got := FormatDate(period.End); got != tt.end {
+				t.Errorf("end = %s, want %s", got, tt.end)
+			}
+		})
+	}
+}
+
+// TestBillingCyclesContiguous checks that every day belongs to exactly one statement, that statements
+// follow each other without gaps, and that add-time and query-time agree on them
+func TestBillingCyclesContiguous(t *testing.T) {
+	holidays := map[string]bool{"2026-12-25": true, "2027-01-01": true, "2027-05-25": true}
+	var tests []BillingCycles
+	for day := 1; day <= 31; day++ {
+		for _, shift := range []ClosingShift{ShiftNone, ShiftBefore, ShiftAfter} {
+			tests = append(tests, BillingCycles{ClosingDay: day, Shift: shift, Holidays: holidays})
+		}
+	}
+
+	for _, cycles := range tests {
+		prev := cycles.PeriodOf(date("2026-06-01"))
+		for day := date("2026-06-02"); day.Before(date("2028-06-01")); day = day.AddDate(0, 0, 1) {
+			period := cycles.PeriodOf(day)
+			if day.Before(period.Start) || day.After(period.End) {
+				t.Fatalf("closing %d %s: %s outside its statement %s - %s", cycles.ClosingDay, cycles.Shift,
+					FormatDate(day), FormatDate(period.Start), FormatDate(period.End))
+			}
+			if period != prev {
+				if want := StartOfDay(prev.End).AddDate(0, 0, 1); !period.Start.Equal(want) {
+					t.Fatalf("closing %d %s: statement after %s starts %s, want %s", cycles.ClosingDay, cycles.Shift,
+						FormatDate(prev.End), FormatDate(period.Start), FormatDate(want))
+				}
+				month := period.End
+				if byMonth := cycles.PeriodForMonth(month.Year(), month.Month()); byMonth != period && cycles.Shift == ShiftNone {
+					t.Fatalf("closing %d: statement of %s is %s - %s, want %s - %s", cycles.ClosingDay, FormatMonth(month),
+						FormatDate(byMonth.Start), FormatDate(byMonth.End), FormatDate(period.Start), FormatDate(period.End))
+				}
+				prev = period
+			}
+		}
+	}
+}
+
+func TestBillingCyclesPeriods(t *testing.T) {
+	periods := NewBillingCycles(20).Periods(date("2026-11-25"), 3)
+	want := []struct{ start, end string }{
+		{"2026-11-21", "2026-12-20"},
+		{"2026-12-21", "2027-01-20"},
+		{"2027-01-21", "2027-02-20"},
+	}
+	if len(periods) != len(want) {
+		t.Fatalf("got %d periods, want %d", len(periods), len(want))
+	}
+	for i, w := range want {
+		if FormatDate(periods[i].Start) != w.start || FormatDate(periods[i].End) != w.end {
+			t.Errorf("period %d = %s - %s, want %s - %s", i,
+				FormatDate(periods[i].Start), FormatDate(periods[i].End), w.start, w.end)
+		}
+	}
+}
+
+func TestCalculateDueDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		closing string
+		dueDay  int
+		offset  int
+		want    string
+	}{
+		{"due next month", "2026-10-20", 5, 0, "2026-11-05"},
+		{"due same month", "2026-10-05", 20, 0, "2026-10-20"},
+		{"due across new year", "2026-12-20", 5, 0, "2027-01-05"},
+		{"due day clamped", "2027-01-31", 30, 0, "2027-02-28"},
+		{"offset", "2026-12-20", 0, 15, "2027-01-04"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatDate(CalculateDueDate(date(tt.closing), tt.dueDay, tt.offset)); got != tt.want {
+				t.Errorf("due = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBillingCyclesPeriodOfInUTC(t *testing.T) {
+	buenosAires := time.FixedZone("ART", -3*60*60)
+	tokyo := time.FixedZone("JST", 9*60*60)
+	cycles := NewBillingCycles(20)
+
+	tests := []struct {
+		name  string
+		date  time.Time
+		month time.Month
+	}{
+		{"late on closing day west of UTC", time.Date(2026, 10, 20, 23, 30, 0, 0, buenosAires), time.October},
+		{"early after closing east of UTC", time.Date(2026, 10, 21, 0, 30, 0, 0, tokyo), time.November},
+		{"midnight of first day west of UTC", time.Date(2026, 9, 21, 0, 0, 0, 0, buenosAires), time.October},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cycles.PeriodOf(tt.date)
+			want := cycles.PeriodForMonth(2026, tt.month)
+			if got != want {
+				t.Errorf("PeriodOf = %v - %v, want %v - %v", got.Start, got.End, want.Start, want.End)
+			}
+			if got.Start.Location() != time.UTC || got.End.Location() != time.UTC {
+				t.Errorf("period in %v, want UTC", got.Start.Location())
+			}
+		})
+	}
+}
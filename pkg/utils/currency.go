@@ -2,8 +2,47 @@ package utils
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 )
 
+// DefaultCurrency is the base currency of new lobbies and the currency of amounts entered without one
+const DefaultCurrency = "ARS"
+
+// currencyAliases maps the spellings accepted from users to ISO currency codes
+var currencyAliases = map[string]string{
+	"ars":     "ARS",
+	"pesos":   "ARS",
+	"peso":    "ARS",
+	"usd":     "USD",
+	"us$":     "USD",
+	"u$s":     "USD",
+	"u$d":     "USD",
+	"dolar":   "USD",
+	"dólar":   "USD",
+	"dolares": "USD",
+	"dólares": "USD",
+	"dollar":  "USD",
+	"dollars": "USD",
+	"eur":     "EUR",
+	"€":       "EUR",
+	"euro":    "EUR",
+	"euros":   "EUR",
+	"brl":     "BRL",
+	"reales":  "BRL",
+	"uyu":     "UYU",
+	"clp":     "CLP",
+	"gbp":     "GBP",
+}
+
+// amountWithCurrencyRegex matches amounts with a currency prefix or suffix, e.g. "USD50" or "50usd"
+var amountWithCurrencyRegex = regexp.MustCompile(`^(?:([^\d.,\s]+)\s*)?(\d+(?:[.,]\d+)?)(?:\s*([^\d.,\s]+))?$`)
+
+// NormalizeCurrency returns the ISO code for a currency code or alias, or "" if it is not recognized
+func NormalizeCurrency(code string) string {
+	return currencyAliases[strings.ToLower(strings.TrimSpace(code))]
+}
+
 // FormatCurrency formats a float as currency
 func FormatCurrency(amount float64) string {
 	return fmt.Sprintf("%.2f", amount)
@@ -13,4 +52,3 @@ func FormatCurrency(amount float64) string {
 func FormatCurrencyWithSymbol(amount float64, symbol string) string {
 	return fmt.Sprintf("%s%s", symbol, FormatCurrency(amount))
 }
-
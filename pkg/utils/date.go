@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -61,3 +62,90 @@ func FormatMonth(t time.Time) string {
 	return t.Format("2006-01")
 }
 
+
+// ClampedDate returns the given day of a month at midnight, clamped to the month's last day
+// (e.g. day 31 in February returns February 28/29)
+func ClampedDate(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, loc)
+}
+
+// CalendarDate returns midnight UTC of the day a time falls on where it happened. Stored dates take this form,
+// so SQLite, which compares them as text, orders them by day whatever the server's time zone.
+func CalendarDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// CalendarDayEnd returns the last instant of the day a time falls on, in UTC like CalendarDate
+func CalendarDayEnd(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 999999999, time.UTC)
+}
+
+// StartOfDay returns midnight of the given day
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// daysAgoWords maps the words for recent days to how many days back they are
+var daysAgoWords = map[string]int{
+	"hoy":       0,
+	"today":     0,
+	"ayer":      1,
+	"yesterday": 1,
+	"anteayer":  2,
+	"antier":    2,
+}
+
+// weekdayWords maps weekday names (Spanish and English) to weekdays
+var weekdayWords = map[string]time.Weekday{
+	"domingo":   time.Sunday,
+	"sunday":    time.Sunday,
+	"lunes":     time.Monday,
+	"monday":    time.Monday,
+	"martes":    time.Tuesday,
+	"tuesday":   time.Tuesday,
+	"miercoles": time.Wednesday,
+	"miércoles": time.Wednesday,
+	"wednesday": time.Wednesday,
+	"jueves":    time.Thursday,
+	"thursday":  time.Thursday,
+	"viernes":   time.Friday,
+	"friday":    time.Friday,
+	"sabado":    time.Saturday,
+	"sábado":    time.Saturday,
+	"saturday":  time.Saturday,
+}
+
+// ParseDateWord parses a date written the way people do in chat, relative to now:
+// "hoy"/"today", "ayer"/"yesterday", "anteayer", a weekday name (its latest occurrence, today included),
+// a day/month such as "15/03" (the latest one not in the future), or any format accepted by ParseDate
+func ParseDateWord(word string, now time.Time) (time.Time, bool) {
+	word = strings.ToLower(strings.TrimSpace(word))
+
+	if days, ok := daysAgoWords[word]; ok {
+		return now.AddDate(0, 0, -days), true
+	}
+
+	if weekday, ok := weekdayWords[word]; ok {
+		days := (int(now.Weekday()) - int(weekday) + 7) % 7
+		return now.AddDate(0, 0, -days), true
+	}
+
+	if dayMonth, err := time.Parse("2/1", word); err == nil {
+		date := time.Date(now.Year(), dayMonth.Month(), dayMonth.Day(), now.Hour(), now.Minute(), now.Second(), 0, now.Location())
+		if date.After(now) {
+			date = date.AddDate(-1, 0, 0)
+		}
+		return date, true
+	}
+
+	if date, err := ParseDate(word); err == nil {
+		return date, true
+	}
+
+	return time.Time{}, false
+}
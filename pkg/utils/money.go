@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// minorUnitsPerMajor is the number of minor units (cents) in one unit of every supported currency
+const minorUnitsPerMajor = 100
+
+// ErrCurrencyMismatch is returned when amounts of different currencies are combined; convert them first
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Money is an exact amount of a currency, stored in minor units (cents) to avoid float rounding drift
+type Money struct {
+	Amount   int64  // Minor units, e.g. 1050 = 10.50
+	Currency string // ISO currency code, "" when unknown
+}
+
+// NewMoney creates a Money from minor units
+func NewMoney(minorUnits int64, currency string) Money {
+	return Money{Amount: minorUnits, Currency: currency}
+}
+
+// MoneyFromFloat creates a Money from a decimal amount, rounding to the nearest minor unit
+func MoneyFromFloat(amount float64, currency string) Money {
+	return Money{Amount: int64(math.Round(amount * minorUnitsPerMajor)), Currency: currency}
+}
+
+// ParseMoney parses an amount optionally prefixed or suffixed by a currency
+// (e.g. "50", "50.5", "50,50", "50usd", "USD50", "u$s50"). The currency is "" when none was given.
+// The amount is parsed exactly, without going through a float.
+func ParseMoney(s string) (Money, error) {
+	m := amountWithCurrencyRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || (m[1] != "" && m[3] != "") {
+		return Money{}, fmt.Errorf("invalid amount: %s", s)
+	}
+
+	currency := ""
+	if code := m[1] + m[3]; code != "" {
+		currency = NormalizeCurrency(code)
+		if currency == "" {
+			return Money{}, fmt.Errorf("unknown currency: %s", code)
+		}
+	}
+
+	whole, fraction, _ := strings.Cut(strings.Replace(m[2], ",", ".", 1), ".")
+	if len(fraction) > 2 {
+		return Money{}, fmt.Errorf("invalid amount: %s (at most 2 decimals)", s)
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount: %s", s)
+	}
+	cents, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount: %s", s)
+	}
+
+	return Money{Amount: units*minorUnitsPerMajor + cents, Currency: currency}, nil
+}
+
+// Float returns the amount as a decimal number, for ratios and display only
+func (m Money) Float() float64 {
+	return float64(m.Amount) / minorUnitsPerMajor
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsNegative reports whether the amount is less than zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// WithCurrency returns the same amount in the given currency
+func (m Money) WithCurrency(currency string) Money {
+	return Money{Amount: m.Amount, Currency: currency}
+}
+
+// currencyOf returns the currency of the result of combining two amounts. An amount without currency takes
+// the other's; two different currencies return ErrCurrencyMismatch.
+func currencyOf(a, b Money) (string, error) {
+	switch {
+	case a.Currency == "":
+		return b.Currency, nil
+	case b.Currency == "" || a.Currency == b.Currency:
+		return a.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, a.Currency, b.Currency)
+}
+
+// Add returns m + other, or ErrCurrencyMismatch when they are in different currencies
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := currencyOf(m, other)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: currency}, nil
+}
+
+// Sub returns m - other, or ErrCurrencyMismatch when they are in different currencies
+func (m Money) Sub(other Money) (Money, error) {
+	currency, err := currencyOf(m, other)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount - other.Amount, Currency: currency}, nil
+}
+
+// Cmp compares m with other: -1 if m is less, 0 if they are equal and +1 if m is greater.
+// Amounts in different currencies return ErrCurrencyMismatch.
+func (m Money) Cmp(other Money) (int, error) {
+	if _, err := currencyOf(m, other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Sum adds up amounts of a currency, or returns ErrCurrencyMismatch when one is in another currency
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := NewMoney(0, currency)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Abs returns the absolute value of m
+func (m Money) Abs() Money {
+	if m.Amount < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// MulRatio returns m multiplied by a ratio (a percentage share, an exchange rate, ...),
+// rounded to the nearest minor unit
+func (m Money) MulRatio(ratio float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * ratio)), Currency: m.Currency}
+}
+
+// Convert converts m to another currency with the given rate (units of "to" per unit of m's currency)
+func (m Money) Convert(rate float64, to string) Money {
+	return m.MulRatio(rate).WithCurrency(to)
+}
+
+// Ratio returns m / total, or 0 when total is zero. Amounts in different currencies return ErrCurrencyMismatch.
+func (m Money) Ratio(total Money) (float64, error) {
+	if _, err := currencyOf(m, total); err != nil {
+		return 0, err
+	}
+	if total.Amount == 0 {
+		return 0, nil
+	}
+	return float64(m.Amount) / float64(total.Amount), nil
+}
+
+// Allocate splits m in parts proportional to the given weights. The parts always add up to m exactly:
+// leftover minor units go to the parts with the largest rounding remainders.
+func (m Money) Allocate(weights ...float64) []Money {
+	parts := make([]Money, len(weights))
+	var totalWeight float64
+	for _, w := range weights {
+		if w > 0 {
+			totalWeight += w
+		}
+	}
+	if len(weights) == 0 {
+		return parts
+	}
+	if totalWeight == 0 {
+		// Nothing to weigh by: split evenly
+		weights = make([]float64, len(parts))
+		for i := range weights {
+			weights[i] = 1
+		}
+		totalWeight = float64(len(weights))
+	}
+
+	remainders := make([]float64, len(weights))
+	var allocated int64
+	for i, w := range weights {
+		if w < 0 {
+			w = 0
+		}
+		exact := float64(m.Amount) * w / totalWeight
+		share := int64(math.Trunc(exact))
+		parts[i] = Money{Amount: share, Currency: m.Currency}
+		remainders[i] = math.Abs(exact - float64(share))
+		allocated += share
+	}
+
+	// Hand out the leftover minor units, one each, by largest remainder (earlier parts win ties)
+	step := int64(1)
+	if m.Amount < 0 {
+		step = -1
+	}
+	for left := m.Amount - allocated; left != 0; left -= step {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		parts[best].Amount += step
+		remainders[best] = -1
+	}
+
+	return parts
+}
+
+// Split splits m in n equal parts that add up to m exactly
+func (m Money) Split(n int) []Money {
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return m.Allocate(weights...)
+}
+
+// Format formats the amount without currency (e.g. "1234.50")
+func (m Money) Format() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/minorUnitsPerMajor, amount%minorUnitsPerMajor)
+}
+
+// String formats the amount followed by its currency code (e.g. "50.00 USD")
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Format()
+	}
+	return m.Format() + " " + m.Currency
+}
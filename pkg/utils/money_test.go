@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{input: "50", want: Money{5000, ""}},
+		{input: "50.5", want: Money{5050, ""}},
+		{input: "50,50", want: Money{5050, ""}},
+		{input: "0.29", want: Money{29, ""}},
+		{input: "19.99", want: Money{1999, ""}},
+		{input: " 1234.05 ", want: Money{123405, ""}},
+		{input: "50usd", want: Money{5000, "USD"}},
+		{input: "50 USD", want: Money{5000, "USD"}},
+		{input: "USD50", want: Money{5000, "USD"}},
+		{input: "u$s50", want: Money{5000, "USD"}},
+		{input: "10.5 euros", want: Money{1050, "EUR"}},
+		{input: "€7", want: Money{700, "EUR"}},
+		{input: "3000 pesos", want: Money{300000, "ARS"}},
+		{input: "", wantErr: true},
+		{input: "abc", wantErr: true},
+		{input: "1.234", wantErr: true},
+		{input: "1.2.3", wantErr: true},
+		{input: "-5", wantErr: true},
+		{input: "50 xyz", wantErr: true},
+		{input: "usd50usd", wantErr: true},
+		{input: "99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMoney(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseMoney(%q) = %v, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q): %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		weights []float64
+		want    []int64
+	}{
+		{"even", 1000, []float64{1, 1}, []int64{500, 500}},
+		{"odd cent goes first", 1001, []float64{1, 1}, []int64{501, 500}},
+		{"thirds", 1000, []float64{1, 1, 1}, []int64{334, 333, 333}},
+		{"two leftover cents", 200, []float64{1, 1, 1}, []int64{67, 67, 66}},
+		{"largest remainder wins", 100, []float64{0.335, 0.335, 0.33}, []int64{34, 33, 33}},
+		{"by ratio", 1000, []float64{0.6, 0.4}, []int64{600, 400}},
+		{"ratio with remainder", 1001, []float64{0.7, 0.3}, []int64{701, 300}},
+		{"all to one", 999, []float64{1, 0}, []int64{999, 0}},
+		{"all to the other", 999, []float64{0, 1}, []int64{0, 999}},
+		{"negative weights count as zero", 500, []float64{-1, 1}, []int64{0, 500}},
+		{"no weight splits evenly", 101, []float64{0, 0}, []int64{51, 50}},
+		{"negative amount", -1001, []float64{1, 1}, []int64{-501, -500}},
+		{"negative thirds", -100, []float64{1, 1, 1}, []int64{-34, -33, -33}},
+		{"zero", 0, []float64{1, 2}, []int64{0, 0}},
+		{"no parts", 100, nil, []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := NewMoney(tt.amount, "ARS").Allocate(tt.weights...)
+			if len(parts) != len(tt.want) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tt.want))
+			}
+			var sum int64
+			for i, part := range parts {
+				if part.Amount != tt.want[i] || part.Currency != "ARS" {
+					t.Errorf("part %d = %+v, want %d ARS", i, part, tt.want[i])
+				}
+				sum += part.Amount
+			}
+			if len(parts) > 0 && sum != tt.amount {
+				t.Errorf("parts add up to %d, want %d", sum, tt.amount)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		amount int64
+		n      int
+		want   []int64
+	}{
+		{1000, 2, []int64{500, 500}},
+		{1001, 2, []int64{501, 500}},
+		{1000, 3, []int64{334, 333, 333}},
+		{5, 4, []int64{2, 1, 1, 1}},
+		{2, 5, []int64{1, 1, 0, 0, 0}},
+		{-7, 2, []int64{-4, -3}},
+	}
+
+	for _, tt := range tests {
+		parts := NewMoney(tt.amount, "USD").Split(tt.n)
+		if len(parts) != tt.n {
+			t.Fatalf("Split(%d) of %d: got %d parts", tt.n, tt.amount, len(parts))
+		}
+		for i, part := range parts {
+			if part.Amount != tt.want[i] {
+				t.Errorf("Split(%d) of %d: part %d = %d, want %d", tt.n, tt.amount, i, part.Amount, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestMixedCurrencies(t *testing.T) {
+	ars := NewMoney(1000, "ARS")
+	usd := NewMoney(500, "USD")
+
+	if _, err := ars.Add(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add: err = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := ars.Sub(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sub: err = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := ars.Cmp(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Cmp: err = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := ars.Ratio(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Ratio: err = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := Sum("ARS", ars, usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sum: err = %v, want ErrCurrencyMismatch", err)
+	}
+
+	// An amount without currency takes the other's
+	sum, err := Money{}.Add(usd)
+	if err != nil || sum != usd {
+		t.Errorf("zero + %v = %v, %v; want %v", usd, sum, err, usd)
+	}
+	diff, err := ars.Sub(NewMoney(250, ""))
+	if err != nil || diff != NewMoney(750, "ARS") {
+		t.Errorf("%v - 2.50 = %v, %v; want 7.50 ARS", ars, diff, err)
+	}
+	if cmp, err := ars.Cmp(NewMoney(1000, "ARS")); err != nil || cmp != 0 {
+		t.Errorf("Cmp of equal amounts = %d, %v; want 0", cmp, err)
+	}
+	if ratio, err := usd.Ratio(NewMoney(2000, "USD")); err != nil || ratio != 0.25 {
+		t.Errorf("Ratio = %v, %v; want 0.25", ratio, err)
+	}
+	if ratio, err := usd.Ratio(NewMoney(0, "USD")); err != nil || ratio != 0 {
+		t.Errorf("Ratio of a zero total = %v, %v; want 0", ratio, err)
+	}
+	if total, err := Sum("ARS", ars, ars.Neg(), NewMoney(1, "ARS")); err != nil || total != NewMoney(1, "ARS") {
+		t.Errorf("Sum = %v, %v; want 0.01 ARS", total, err)
+	}
+}
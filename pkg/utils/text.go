@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"strings"
+)
+
+// accentFolds maps accented letters to their plain form for FoldText
+var accentFolds = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	"à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u", "ç", "c",
+)
+
+// FoldText normalizes a name for comparison: lower case, no accents, single spaces.
+// "Supermercado", " supermercado " and "SÚPERMERCADO" all fold to "supermercado".
+func FoldText(s string) string {
+	return accentFolds.Replace(strings.Join(strings.Fields(strings.ToLower(s)), " "))
+}
+
+// EditDistance returns the Levenshtein distance between two strings, counted in runes
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}